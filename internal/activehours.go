@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseActiveHours parses a window such as "08:00-18:00" into minutes since midnight.
+// Windows where the end is before the start wrap around midnight.
+func parseActiveHours(window string) (start, end int, err error) {
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid active_hours %q (use HH:MM-HH:MM)", window)
+	}
+
+	start, err = parseClock(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid active_hours %q: %v", window, err)
+	}
+	end, err = parseClock(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid active_hours %q: %v", window, err)
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("invalid active_hours %q: start and end are the same", window)
+	}
+
+	return start, end, nil
+}
+
+// parseClock parses a HH:MM time of day into minutes since midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", strings.TrimSpace(value))
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// validateActiveHours checks a feed's active_hours window and timezone
+func validateActiveHours(feed Feed) error {
+	if feed.ActiveHours == "" {
+		return nil
+	}
+	if _, _, err := parseActiveHours(feed.ActiveHours); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(feed.ActiveTimezone); err != nil {
+		return fmt.Errorf("invalid active_timezone %q: %v", feed.ActiveTimezone, err)
+	}
+	return nil
+}
+
+// isActive reports whether the feed should be fetched at the given time. Feeds without
+// an active_hours window are always active.
+func isActive(feed Feed, now time.Time) bool {
+	if feed.ActiveHours == "" {
+		return true
+	}
+
+	start, end, err := parseActiveHours(feed.ActiveHours)
+	if err != nil {
+		return true
+	}
+	location, err := time.LoadLocation(feed.ActiveTimezone)
+	if err != nil {
+		return true
+	}
+
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIsActive(t *testing.T) {
+	// 10:30 in New York
+	now := time.Date(2024, 5, 1, 14, 30, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		hours    string
+		timezone string
+		want     bool
+	}{
+		{"", "", true},
+		{"08:00-18:00", "America/New_York", true},
+		{"11:00-18:00", "America/New_York", false},
+		{"08:00-10:30", "America/New_York", false},
+		{"08:00-18:00", "", true},
+		{"15:00-18:00", "UTC", false},
+		// Windows wrapping around midnight
+		{"22:00-11:00", "America/New_York", true},
+		{"22:00-06:00", "America/New_York", false},
+	} {
+		feed := Feed{ActiveHours: tc.hours, ActiveTimezone: tc.timezone}
+		if got := isActive(feed, now); got != tc.want {
+			t.Errorf("%s %s: active %v, want %v", tc.hours, tc.timezone, got, tc.want)
+		}
+	}
+}
+
+func TestValidateActiveHours(t *testing.T) {
+	for _, feed := range []Feed{
+		{ActiveHours: "08:00"},
+		{ActiveHours: "8am-6pm"},
+		{ActiveHours: "08:00-08:00"},
+		{ActiveHours: "08:00-18:00", ActiveTimezone: "Mars/Olympus_Mons"},
+	} {
+		if err := validateActiveHours(feed); err == nil {
+			t.Errorf("%q %q: expected an error", feed.ActiveHours, feed.ActiveTimezone)
+		}
+	}
+	if err := validateActiveHours(Feed{ActiveHours: "22:00-06:00", ActiveTimezone: "Europe/Berlin"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// activeWindow returns an active_hours window in UTC that starts the given offset from now
+func activeWindow(from, to time.Duration) string {
+	now := time.Now().UTC()
+	return fmt.Sprintf("%s-%s", now.Add(from).Format("15:04"), now.Add(to).Format("15:04"))
+}
+
+func TestRunFeedOnlyInsideActiveHours(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+
+	inactive := testFeed(server.URL)
+	inactive.ActiveHours = activeWindow(time.Hour, 2*time.Hour)
+	inactive.ActiveTimezone = "UTC"
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{inactive}})
+
+	fs.runFeed(inactive)
+	if n := server.requests.Load(); n != 0 {
+		t.Fatalf("feed fetched %d times outside its active hours", n)
+	}
+
+	active := inactive
+	active.ActiveHours = activeWindow(-time.Hour, time.Hour)
+	fs.runFeed(active)
+	if n := server.requests.Load(); n != 1 {
+		t.Fatalf("feed fetched %d times inside its active hours, want 1", n)
+	}
+	if texts := sentTexts(recorder); len(texts) != 1 {
+		t.Fatalf("got messages %q, want the item", texts)
+	}
+}
@@ -0,0 +1,78 @@
+package internal
+
+import "fmt"
+
+// Modes deciding what happens to the items a feed already has when it is first fetched
+const (
+	feedModeAll      = "all"      // post every item, including the backlog
+	feedModeRealtime = "realtime" // post new items only, the backlog is marked as seen
+	feedModeCatchUp  = "catch_up" // post the newest catch_up_items of the backlog
+)
+
+// defaultBackfillConcurrency is how many feeds may record their backlog at the same time
+// when backfill_concurrency is not set
+const defaultBackfillConcurrency = 2
+
+// backfillConcurrency returns how many feeds may record their backlog at the same time
+func (c *Config) backfillConcurrency() int {
+	if c.BackfillConcurrency > 0 {
+		return c.BackfillConcurrency
+	}
+	return defaultBackfillConcurrency
+}
+
+// defaultCatchUpItems is how much of the backlog is posted in catch_up mode when
+// catch_up_items is not set
+const defaultCatchUpItems = 10
+
+// validateFeedMode checks a feed's mode and catch_up_items
+func validateFeedMode(feed Feed) error {
+	if feed.CatchUpItems < 0 {
+		return fmt.Errorf("catch_up_items must not be negative")
+	}
+	switch feed.Mode {
+	case "", feedModeAll, feedModeRealtime, feedModeCatchUp:
+		return nil
+	}
+	return fmt.Errorf("unknown mode %q (use %q, %q or %q)", feed.Mode, feedModeRealtime, feedModeCatchUp, feedModeAll)
+}
+
+// backlogLimit returns how many of the items found on a feed's first fetch are posted;
+// the rest are marked as seen. It is -1 when every item is posted.
+func backlogLimit(feed Feed) int {
+	switch feed.Mode {
+	case feedModeRealtime:
+		return 0
+	case feedModeCatchUp:
+		if feed.CatchUpItems > 0 {
+			return feed.CatchUpItems
+		}
+		return defaultCatchUpItems
+	}
+	return -1
+}
+
+// isFirstFetch reports whether nothing has been recorded for the feed yet
+func (fs *FeedScheduler) isFirstFetch(feed Feed) (bool, error) {
+	_, found, err := fs.dbManager.LastItemTime(feed.Key())
+	if err != nil {
+		return false, err
+	}
+	return !found, nil
+}
+
+// markBacklogSeen records the backlog items of a feed's first fetch that aren't posted.
+// When many new feeds are fetched at startup only a few of them write their backlog at
+// a time, in batches, so the database stays responsive for the other feeds.
+func (fs *FeedScheduler) markBacklogSeen(items []FeedItem) error {
+	select {
+	case fs.backfill <- struct{}{}:
+		defer func() { <-fs.backfill }()
+	case <-fs.ctx.Done():
+		return fs.ctx.Err()
+	}
+
+	return fs.withDBRetry("recording backlog", func() error {
+		return fs.dbManager.SaveFeedItems(items)
+	})
+}
@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFeedModesOnBacklog(t *testing.T) {
+	backlog := []testItem{{GUID: "3", Title: "Third"}, {GUID: "2", Title: "Second"}, {GUID: "1", Title: "First"}}
+
+	for _, tc := range []struct {
+		mode         string
+		catchUpItems int
+		want         []string
+	}{
+		{feedModeRealtime, 0, []string{"Fourth"}},
+		{feedModeCatchUp, 2, []string{"Second", "Third", "Fourth"}},
+		{feedModeAll, 0, []string{"First", "Second", "Third", "Fourth"}},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			server := newFeedServer(t, rssFeed(backlog...))
+			feed := testFeed(server.URL)
+			feed.Mode = tc.mode
+			feed.CatchUpItems = tc.catchUpItems
+			fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+			fs.runFeed(feed)
+			// Only the first fetch is limited, later items are always posted
+			server.setBody(rssFeed(append([]testItem{{GUID: "4", Title: "Fourth"}}, backlog...)...))
+			fs.runFeed(feed)
+
+			if texts := sentTexts(recorder); !reflect.DeepEqual(texts, tc.want) {
+				t.Fatalf("got messages %q, want %q", texts, tc.want)
+			}
+			for _, guid := range []string{"1", "2", "3", "4"} {
+				if posted, _ := fs.dbManager.IsFeedItemPosted(guid, feed.Key()); !posted {
+					t.Errorf("item %s was not recorded", guid)
+				}
+			}
+		})
+	}
+}
+
+func TestBacklogLimit(t *testing.T) {
+	for _, tc := range []struct {
+		feed Feed
+		want int
+	}{
+		{Feed{}, -1},
+		{Feed{Mode: feedModeAll}, -1},
+		{Feed{Mode: feedModeRealtime, CatchUpItems: 5}, 0},
+		{Feed{Mode: feedModeCatchUp}, defaultCatchUpItems},
+		{Feed{Mode: feedModeCatchUp, CatchUpItems: 3}, 3},
+	} {
+		if got := backlogLimit(tc.feed); got != tc.want {
+			t.Errorf("%+v: got %d, want %d", tc.feed, got, tc.want)
+		}
+	}
+}
+
+func TestValidateFeedMode(t *testing.T) {
+	for _, feed := range []Feed{{Mode: "backfill"}, {Mode: feedModeCatchUp, CatchUpItems: -1}} {
+		if err := validateFeedMode(feed); err == nil {
+			t.Errorf("%+v: expected an error", feed)
+		}
+	}
+}
+
+func TestFeedModeFromForm(t *testing.T) {
+	req := formRequest(t, "/config", url.Values{
+		"feed_slots":          {"new", "new"},
+		"feed_urls":           {"https://example.com/a.xml", "https://example.com/b.xml"},
+		"feed_modes":          {feedModeAll, feedModeCatchUp},
+		"feed_catch_up_items": {"", "5"},
+	})
+
+	feeds := processFeedsFromForm(req, nil)
+	if len(feeds) != 2 {
+		t.Fatalf("got %d feeds, want 2", len(feeds))
+	}
+	if feeds[0].Mode != "" || feeds[1].Mode != feedModeCatchUp || feeds[1].CatchUpItems != 5 {
+		t.Fatalf("unexpected modes %+v", feeds)
+	}
+}
+
+// largeBacklog returns n items, newest first
+func largeBacklog(n int) []testItem {
+	items := make([]testItem, n)
+	for i := range items {
+		items[i] = testItem{GUID: fmt.Sprintf("item-%d", n-i), Title: fmt.Sprintf("Item %d", n-i)}
+	}
+	return items
+}
+
+func TestStartupWithManyLargeBacklogs(t *testing.T) {
+	const feeds, items = 20, 200
+	server := newFeedServer(t, rssFeed(largeBacklog(items)...))
+
+	config := &Config{BackfillConcurrency: 2}
+	for i := 0; i < feeds; i++ {
+		feed := testFeed(fmt.Sprintf("%s/?feed=%d", server.URL, i))
+		feed.Mode = feedModeRealtime
+		config.Feeds = append(config.Feeds, feed)
+	}
+	fs, recorder := newTestScheduler(t, config)
+
+	// The initial fetch of an imported batch of feeds, with their tickers firing together
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, feed := range config.Feeds {
+		wg.Add(1)
+		go func(feed Feed) {
+			defer wg.Done()
+			fs.runFeed(feed)
+		}(feed)
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Fatalf("recording the backlogs took %v", elapsed)
+	}
+
+	for _, status := range apiStatus(t, fs) {
+		if status.LastError != "" {
+			t.Errorf("feed %s failed: %s", status.FeedURL, status.LastError)
+		}
+	}
+	var recorded int
+	if err := fs.dbManager.db.QueryRow(`SELECT COUNT(*) FROM feed_items`).Scan(&recorded); err != nil {
+		t.Fatal(err)
+	}
+	if recorded != feeds*items {
+		t.Fatalf("recorded %d items, want %d", recorded, feeds*items)
+	}
+	if calls := recorder.Calls(); len(calls) != 0 {
+		t.Fatalf("got %d Telegram calls for realtime feeds", len(calls))
+	}
+}
+
+func TestBackfillConcurrencyLimit(t *testing.T) {
+	fs, _ := newTestScheduler(t, &Config{BackfillConcurrency: 1})
+	if n := cap(fs.backfill); n != 1 {
+		t.Fatalf("got backfill concurrency %d, want 1", n)
+	}
+
+	// Another feed is recording its backlog
+	fs.backfill <- struct{}{}
+	done := make(chan error, 1)
+	go func() {
+		done <- fs.markBacklogSeen([]FeedItem{{GUID: "1", Title: "First", FeedURL: "https://example.com/feed.xml"}})
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("backlog recorded past the limit: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	<-fs.backfill
+	if err := <-done; err != nil {
+		t.Fatalf("markBacklogSeen: %v", err)
+	}
+	if posted, _ := fs.dbManager.IsFeedItemPosted("1", "https://example.com/feed.xml"); !posted {
+		t.Fatal("backlog item was not recorded")
+	}
+
+	if n := (&Config{}).backfillConcurrency(); n != defaultBackfillConcurrency {
+		t.Fatalf("got default concurrency %d, want %d", n, defaultBackfillConcurrency)
+	}
+}
@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Preferences for the {{.Body}} variable
+const (
+	bodyPreferDescription = "description"
+	bodyPreferContent     = "content"
+	bodyPreferLongest     = "longest"
+)
+
+// validateBodyPreference checks a feed's body_preference option
+func validateBodyPreference(preference string) error {
+	switch preference {
+	case "", bodyPreferDescription, bodyPreferContent, bodyPreferLongest:
+		return nil
+	}
+	return fmt.Errorf("unknown body_preference %q (use %q, %q or %q)", preference, bodyPreferDescription, bodyPreferContent, bodyPreferLongest)
+}
+
+// selectBody picks the text for {{.Body}}: the preferred one of description and content,
+// falling back to the other when it is empty. "longest" picks whichever is longer.
+func selectBody(description, content, preference string) string {
+	hasDescription := strings.TrimSpace(description) != ""
+	hasContent := strings.TrimSpace(content) != ""
+
+	switch preference {
+	case bodyPreferContent:
+		if hasContent {
+			return content
+		}
+		return description
+	case bodyPreferLongest:
+		if len(content) > len(description) {
+			return content
+		}
+		return description
+	default:
+		if hasDescription {
+			return description
+		}
+		if hasContent {
+			return content
+		}
+		return ""
+	}
+}
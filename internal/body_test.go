@@ -0,0 +1,67 @@
+package internal
+
+import "testing"
+
+func TestSelectBody(t *testing.T) {
+	const short, long = "Short summary", "The full text of the article"
+
+	for _, tc := range []struct {
+		preference  string
+		description string
+		content     string
+		want        string
+	}{
+		// Only a description
+		{"", short, "", short},
+		{bodyPreferDescription, short, "", short},
+		{bodyPreferContent, short, "", short},
+		{bodyPreferLongest, short, "", short},
+		// Only content
+		{"", "", long, long},
+		{bodyPreferDescription, "", long, long},
+		{bodyPreferContent, "", long, long},
+		{bodyPreferLongest, "", long, long},
+		// Both
+		{"", short, long, short},
+		{bodyPreferDescription, short, long, short},
+		{bodyPreferContent, short, long, long},
+		{bodyPreferLongest, short, long, long},
+		{bodyPreferLongest, long, short, long},
+		// Neither
+		{"", "", "", ""},
+		{bodyPreferDescription, "", "", ""},
+		{bodyPreferContent, "", "", ""},
+		{bodyPreferLongest, "", "", ""},
+		// Whitespace counts as empty
+		{bodyPreferContent, short, "  ", short},
+		{bodyPreferDescription, "\n", long, long},
+	} {
+		if got := selectBody(tc.description, tc.content, tc.preference); got != tc.want {
+			t.Errorf("%q with description %q and content %q: got %q, want %q", tc.preference, tc.description, tc.content, got, tc.want)
+		}
+	}
+}
+
+func TestValidateBodyPreference(t *testing.T) {
+	for _, preference := range []string{"", bodyPreferDescription, bodyPreferContent, bodyPreferLongest} {
+		if err := validateBodyPreference(preference); err != nil {
+			t.Errorf("%q: unexpected error: %v", preference, err)
+		}
+	}
+	if err := validateBodyPreference("summary"); err == nil {
+		t.Fatal("expected an error for an unknown preference")
+	}
+}
+
+func TestBodyTemplateVariable(t *testing.T) {
+	item := map[string]interface{}{"Description": "Short summary", "Content": "The full text of the article"}
+
+	feed := Feed{BodyPreference: bodyPreferContent}
+	if got := renderFeedItemTemplate(feed, item, "{{.Body}}"); got != "The full text of the article" {
+		t.Fatalf("got %q, want the content", got)
+	}
+	feed.BodyPreference = ""
+	if got := renderFeedItemTemplate(feed, item, "{{.Body}}"); got != "Short summary" {
+		t.Fatalf("got %q, want the description", got)
+	}
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limits for resolving item links to their final destination
+const (
+	maxLinkRedirects   = 5
+	linkResolveTimeout = 10 * time.Second
+	linkCacheTTL       = 24 * time.Hour
+	maxLinkCacheSize   = 10000
+)
+
+// resolvedLink is a cached link resolution
+type resolvedLink struct {
+	url     string
+	expires time.Time
+}
+
+// linkResolver follows the redirects of aggregator links (Google News, Feedburner, ...)
+// to find the article they point to. Results are cached, and only public addresses are
+// contacted.
+type linkResolver struct {
+	client *http.Client
+	mu     sync.Mutex
+	cache  map[string]resolvedLink
+}
+
+// newLinkResolver creates a link resolver
+func newLinkResolver() *linkResolver {
+	client := newSafeHTTPClient(linkResolveTimeout)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxLinkRedirects {
+			return errTooManyRedirects
+		}
+		return nil
+	}
+
+	return &linkResolver{
+		client: client,
+		cache:  make(map[string]resolvedLink),
+	}
+}
+
+// errTooManyRedirects stops a resolution that exceeds maxLinkRedirects
+var errTooManyRedirects = errors.New("too many redirects")
+
+// resolve returns the final destination of a link, or the link itself when it doesn't
+// redirect or can't be resolved
+func (lr *linkResolver) resolve(ctx context.Context, link string) string {
+	if link == "" {
+		return link
+	}
+
+	lr.mu.Lock()
+	cached, ok := lr.cache[link]
+	lr.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.url
+	}
+
+	resolved := link
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, nil)
+	if err == nil {
+		req.Header.Set("User-Agent", "go-telegram-notifications-bot")
+		resp, err := lr.client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 400 {
+				resolved = resp.Request.URL.String()
+			}
+		}
+	}
+
+	lr.mu.Lock()
+	if len(lr.cache) >= maxLinkCacheSize {
+		lr.cache = make(map[string]resolvedLink)
+	}
+	lr.cache[link] = resolvedLink{url: resolved, expires: time.Now().Add(linkCacheTTL)}
+	lr.mu.Unlock()
+
+	return resolved
+}
+
+// resolveItemLink replaces the link of a rendered item with its final destination for
+// feeds with resolve_links enabled
+func (fs *FeedScheduler) resolveItemLink(feed Feed, itemMap map[string]interface{}) {
+	if !feed.ResolveLinks {
+		return
+	}
+	itemMap["Link"] = fs.links.resolve(fs.ctx, getStringValue(itemMap, "Link"))
+}
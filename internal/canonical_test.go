@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// redirectServer redirects /short to /article through /hop, and /loop to itself
+func redirectServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/short":
+			http.Redirect(w, r, "/hop", http.StatusMovedPermanently)
+		case "/hop":
+			http.Redirect(w, r, "/article?id=1", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/missing":
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// allowLoopback lets a link resolver reach test servers, which the safe dialer refuses
+func allowLoopback(lr *linkResolver) {
+	lr.client.Transport = http.DefaultTransport
+}
+
+func TestLinkResolverFollowsRedirects(t *testing.T) {
+	server, requests := redirectServer(t)
+	lr := newLinkResolver()
+	allowLoopback(lr)
+
+	if got, want := lr.resolve(context.Background(), server.URL+"/short"), server.URL+"/article?id=1"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Fatalf("got %d requests, want 3", n)
+	}
+
+	// The resolution is cached
+	lr.resolve(context.Background(), server.URL+"/short")
+	if n := requests.Load(); n != 3 {
+		t.Fatalf("got %d requests after resolving again, want the cached result", n)
+	}
+}
+
+func TestLinkResolverKeepsLinksThatDontRedirect(t *testing.T) {
+	server, _ := redirectServer(t)
+	lr := newLinkResolver()
+	allowLoopback(lr)
+
+	for _, path := range []string{"/article?id=1", "/missing", "/loop"} {
+		if got := lr.resolve(context.Background(), server.URL+path); got != server.URL+path {
+			t.Errorf("%s: got %s, want the link unchanged", path, got)
+		}
+	}
+}
+
+func TestLinkResolverRefusesInternalAddresses(t *testing.T) {
+	server, requests := redirectServer(t)
+
+	if got := newLinkResolver().resolve(context.Background(), server.URL+"/short"); got != server.URL+"/short" {
+		t.Fatalf("got %s, want the link unchanged", got)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("loopback server contacted %d times", n)
+	}
+}
+
+func TestRunFeedResolvesItemLinks(t *testing.T) {
+	redirects, _ := redirectServer(t)
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First", Link: redirects.URL + "/short"}))
+	feed := testFeed(server.URL)
+	feed.TelegramTemplate = "{{.Link}}"
+	feed.ResolveLinks = true
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	allowLoopback(fs.links)
+
+	fs.runFeed(feed)
+
+	if texts := sentTexts(recorder); len(texts) != 1 || texts[0] != redirects.URL+"/article?id=1" {
+		t.Fatalf("got messages %q, want the resolved link", texts)
+	}
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// channelUsernamePattern matches public channel usernames such as @mychannel
+var channelUsernamePattern = regexp.MustCompile(`^@[A-Za-z][A-Za-z0-9_]{3,31}$`)
+
+// ChatID identifies a Telegram chat, either by numeric ID or by the @username of a public channel
+type ChatID string
+
+// ParseChatID parses a numeric chat ID or an @username.
+func ParseChatID(s string) (ChatID, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ChatID(s), nil
+	}
+	if channelUsernamePattern.MatchString(s) {
+		return ChatID(s), nil
+	}
+	return "", fmt.Errorf("invalid chat ID %q: must be a number or an @username", s)
+}
+
+// IsZero reports whether the chat ID is unset.
+func (c ChatID) IsZero() bool {
+	return c == "" || c == "0"
+}
+
+// numeric returns the chat ID as a number when it is not an @username.
+func (c ChatID) numeric() (int64, bool) {
+	id, err := strconv.ParseInt(string(c), 10, 64)
+	return id, err == nil
+}
+
+// MarshalJSON encodes numeric chat IDs as JSON numbers and usernames as strings.
+func (c ChatID) MarshalJSON() ([]byte, error) {
+	if id, ok := c.numeric(); ok {
+		return json.Marshal(id)
+	}
+	return json.Marshal(string(c))
+}
+
+// MarshalYAML keeps numeric chat IDs as YAML integers.
+func (c ChatID) MarshalYAML() (interface{}, error) {
+	if id, ok := c.numeric(); ok {
+		return id, nil
+	}
+	return string(c), nil
+}
+
+// UnmarshalYAML accepts both integer and string chat IDs.
+func (c *ChatID) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("chat ID must be a scalar value")
+	}
+	if value.Tag == "!!null" {
+		*c = ""
+		return nil
+	}
+	id, err := ParseChatID(value.Value)
+	if err != nil {
+		return err
+	}
+	*c = id
+	return nil
+}
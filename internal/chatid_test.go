@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseChatID(t *testing.T) {
+	for input, want := range map[string]ChatID{
+		"-1001234567890": "-1001234567890",
+		" 42 ":           "42",
+		"@my_channel":    "@my_channel",
+		"":               "",
+	} {
+		got, err := ParseChatID(input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", input, err)
+		}
+		if got != want {
+			t.Errorf("%q: got %q, want %q", input, got, want)
+		}
+	}
+
+	for _, input := range []string{"my_channel", "@ab", "@1channel", "12abc"} {
+		if _, err := ParseChatID(input); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestChatIDMarshalJSON(t *testing.T) {
+	for id, want := range map[ChatID]string{
+		"-1001234567890": `-1001234567890`,
+		"@channelname":   `"@channelname"`,
+	} {
+		data, err := json.Marshal(id)
+		if err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+		if string(data) != want {
+			t.Errorf("%s: got %s, want %s", id, data, want)
+		}
+	}
+}
+
+func TestChatIDYAMLRoundTrip(t *testing.T) {
+	var feed Feed
+	if err := yaml.Unmarshal([]byte("telegram_chat_id: -1001234\nfallback_chat_id: \"@backup_channel\"\n"), &feed); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if feed.TelegramChatId != "-1001234" || feed.FallbackChatID != "@backup_channel" {
+		t.Fatalf("got %q and %q", feed.TelegramChatId, feed.FallbackChatID)
+	}
+
+	data, err := yaml.Marshal(feed)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded map[string]interface{}
+	yaml.Unmarshal(data, &decoded)
+	if _, ok := decoded["telegram_chat_id"].(int); !ok {
+		t.Fatalf("numeric chat ID was written as %T", decoded["telegram_chat_id"])
+	}
+
+	if err := yaml.Unmarshal([]byte("telegram_chat_id: not-a-chat\n"), &feed); err == nil {
+		t.Fatal("expected an error for an invalid chat ID")
+	}
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// coalesceWindow is how long a fetched feed is shared with other feeds using its URL.
+// It is well below the minimum fetch interval, so a result is never reused for the next
+// cycle of the same feed.
+const coalesceWindow = time.Minute
+
+// sharedFetch is a fetch of a URL whose result is shared by every feed using the URL
+type sharedFetch struct {
+	done      chan struct{}
+	feed      *gofeed.Feed
+	err       error
+	fetchedAt time.Time
+}
+
+// fetchCoalescer makes feeds that point at the same URL share one fetch per cycle
+type fetchCoalescer struct {
+	mu      sync.Mutex
+	fetches map[string]*sharedFetch
+	fetch   func(ctx context.Context, feedURL string, parseTimeout time.Duration) (*gofeed.Feed, error)
+}
+
+// newFetchCoalescer creates a coalescer around a fetch function
+func newFetchCoalescer(fetch func(ctx context.Context, feedURL string, parseTimeout time.Duration) (*gofeed.Feed, error)) *fetchCoalescer {
+	return &fetchCoalescer{
+		fetches: make(map[string]*sharedFetch),
+		fetch:   fetch,
+	}
+}
+
+// Fetch returns the feed at the URL. A fetch that is in progress, or finished less than
+// coalesceWindow ago, is shared instead of fetching the URL again. The parsed feed is
+// shared as well, so callers must not modify it.
+func (c *fetchCoalescer) Fetch(ctx context.Context, feedURL string, parseTimeout time.Duration) (*gofeed.Feed, error) {
+	c.mu.Lock()
+	shared, exists := c.fetches[feedURL]
+	if exists {
+		select {
+		case <-shared.done:
+			if time.Since(shared.fetchedAt) >= coalesceWindow {
+				exists = false
+			}
+		default:
+			// Still in progress
+		}
+	}
+	if !exists {
+		shared = &sharedFetch{done: make(chan struct{})}
+		c.fetches[feedURL] = shared
+		c.mu.Unlock()
+
+		shared.feed, shared.err = c.fetch(ctx, feedURL, parseTimeout)
+		shared.fetchedAt = time.Now()
+		close(shared.done)
+		return shared.feed, shared.err
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-shared.done:
+		return shared.feed, shared.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// assignSharedFeedIDs gives an id to every feed that shares its URL with an earlier feed
+// without an id, so that each of them keeps its own sent items and status. The first
+// such feed keeps the URL as its key. The id is made of the URL, chat and thread, and
+// stays the same across loads until it is saved with the configuration. With inherit,
+// the feeds take over the items already sent under the URL, which they shared before
+// they had ids. It returns a message for every feed it gave an id.
+func (c *Config) assignSharedFeedIDs(inherit bool) []string {
+	keys := make(map[string]bool)
+	for _, feed := range c.Feeds {
+		if feed.ID != "" {
+			keys[feed.ID] = true
+		}
+	}
+
+	var messages []string
+	for i := range c.Feeds {
+		feed := &c.Feeds[i]
+		if feed.ID != "" {
+			continue
+		}
+		if !keys[feed.FeedUrl] {
+			keys[feed.FeedUrl] = true
+			continue
+		}
+
+		id := fmt.Sprintf("%s#%s", feed.FeedUrl, feed.TelegramChatId)
+		if feed.TelegramMessageThreadId != 0 {
+			id = fmt.Sprintf("%s/%d", id, feed.TelegramMessageThreadId)
+		}
+		for n := 2; keys[id]; n++ {
+			id = fmt.Sprintf("%s#%s-%d", feed.FeedUrl, feed.TelegramChatId, n)
+		}
+		keys[id] = true
+		feed.ID = id
+		if inherit {
+			feed.inheritsFrom = feed.FeedUrl
+		}
+		messages = append(messages, fmt.Sprintf("feed %d (%s) shares its URL with another feed; using id %q", i+1, feed.FeedUrl, id))
+	}
+	return messages
+}
+
+// inheritSharedFeedItems gives feeds that were assigned an id at load the items already
+// sent under their URL, unless they have items of their own
+func (fs *FeedScheduler) inheritSharedFeedItems() {
+	for _, feed := range fs.configManager.Get().Feeds {
+		if feed.inheritsFrom == "" {
+			continue
+		}
+		var copied int64
+		err := fs.withDBRetry("copying shared feed items", func() (err error) {
+			copied, err = fs.dbManager.CopyFeedItems(feed.inheritsFrom, feed.Key())
+			return err
+		})
+		if err != nil {
+			log.Printf("Error copying the items of %s to %s: %v", feed.inheritsFrom, feed.Key(), err)
+			continue
+		}
+		if copied > 0 {
+			log.Printf("Copied %d items from %s to %s", copied, feed.inheritsFrom, feed.Key())
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCoalescedFeedsSharingURL(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "item-1", Title: "First"}))
+
+	english := testFeed(server.URL)
+	english.ID = "news-en"
+	english.TelegramChatId = "100"
+	german := testFeed(server.URL)
+	german.ID = "news-de"
+	german.TelegramChatId = "200"
+	german.TelegramTemplate = "DE: {{.Title}}"
+
+	fs, recorder := newTestScheduler(t, &Config{CoalesceFetches: true, Feeds: []Feed{english, german}})
+	for _, feed := range fs.configManager.Get().Feeds {
+		if err := fs.fetchAndProcessFeed(feed); err != nil {
+			t.Fatalf("fetchAndProcessFeed: %v", err)
+		}
+	}
+
+	if got := server.requests.Load(); got != 1 {
+		t.Fatalf("got %d fetches, want 1", got)
+	}
+	calls := recorder.callsTo("sendMessage")
+	if len(calls) != 2 {
+		t.Fatalf("got %d sends, want 2", len(calls))
+	}
+	if calls[0].chatID() != "100" || calls[0].text() != "First" {
+		t.Errorf("unexpected first send to %s: %q", calls[0].chatID(), calls[0].text())
+	}
+	if calls[1].chatID() != "200" || calls[1].text() != "DE: First" {
+		t.Errorf("unexpected second send to %s: %q", calls[1].chatID(), calls[1].text())
+	}
+
+	// Both feeds recorded the item, so the next cycle sends nothing
+	for _, feed := range fs.configManager.Get().Feeds {
+		posted, err := fs.dbManager.IsFeedItemPosted("item-1", feed.Key())
+		if err != nil || !posted {
+			t.Fatalf("item not recorded for feed %s (err %v)", feed.Key(), err)
+		}
+	}
+}
+
+func TestValidateDuplicateFeedIDs(t *testing.T) {
+	first := testFeed("https://example.com/feed.xml")
+	second := testFeed("https://example.com/feed.xml")
+
+	// Feeds sharing a URL without ids are given ids when loaded, not rejected
+	if err := (&Config{Feeds: []Feed{first, second}}).Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	first.ID, second.ID = "one", "one"
+	if err := (&Config{Feeds: []Feed{first, second}}).Validate(); err == nil {
+		t.Fatal("expected an error for duplicate ids")
+	}
+
+	second.ID = "two"
+	if err := (&Config{Feeds: []Feed{first, second}}).Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAssignSharedFeedIDs(t *testing.T) {
+	const feedURL = "https://example.com/feed.xml"
+	feed := func(chat ChatID, thread int64, id string) Feed {
+		f := testFeed(feedURL)
+		f.TelegramChatId, f.TelegramMessageThreadId, f.ID = chat, thread, id
+		return f
+	}
+	config := &Config{Feeds: []Feed{
+		feed("100", 0, ""),
+		feed("200", 0, ""),
+		feed("200", 0, ""),
+		feed("200", 7, ""),
+		feed("300", 0, "named"),
+		testFeed("https://example.com/other.xml"),
+	}}
+
+	if messages := config.assignSharedFeedIDs(false); len(messages) != 3 {
+		t.Fatalf("got messages %q, want one per assigned id", messages)
+	}
+	var keys []string
+	for _, f := range config.Feeds {
+		keys = append(keys, f.Key())
+	}
+	want := []string{feedURL, feedURL + "#200", feedURL + "#200-2", feedURL + "#200/7", "named", "https://example.com/other.xml"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("got keys %q, want %q", keys, want)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("assigned ids don't validate: %v", err)
+	}
+
+	// Once assigned the ids are kept
+	if messages := config.assignSharedFeedIDs(false); len(messages) != 0 {
+		t.Fatalf("got messages %q the second time", messages)
+	}
+}
+
+func TestLoadConfigAssignsSharedFeedIDs(t *testing.T) {
+	cm := NewConfigManager()
+	cm.Path = writeConfigFile(t, `
+feeds:
+  - feed_url: "https://example.com/feed.xml"
+    telegram_chat_id: "100"
+    feed_fetch_interval_minutes: 30
+  - feed_url: "https://example.com/feed.xml"
+    telegram_chat_id: "200"
+    feed_fetch_interval_minutes: 30
+`)
+	if err := cm.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	feeds := cm.Get().Feeds
+	if feeds[0].Key() != "https://example.com/feed.xml" || feeds[1].Key() != "https://example.com/feed.xml#200" {
+		t.Fatalf("got keys %q and %q", feeds[0].Key(), feeds[1].Key())
+	}
+
+	// The id is written with the next save
+	if err := cm.Update(func(cfg *Config) error { return nil }); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	data, err := os.ReadFile(cm.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "id: https://example.com/feed.xml#200") {
+		t.Fatalf("saved config doesn't have the id:\n%s", data)
+	}
+}
+
+func TestSharedFeedInheritsSentItems(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	first, second := testFeed(server.URL), testFeed(server.URL)
+	second.TelegramChatId = "200"
+	config := &Config{Feeds: []Feed{first, second}, SkipInitialFetch: true}
+	config.assignSharedFeedIDs(true)
+	fs, recorder := newTestScheduler(t, config)
+
+	// Before the upgrade both feeds recorded their items under the URL
+	if err := fs.dbManager.SaveFeedItem(FeedItem{GUID: "1", Title: "First", FeedURL: server.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	fs.Start()
+	for _, feed := range fs.configManager.Get().Feeds {
+		fs.runFeed(feed)
+	}
+	if calls := recorder.Calls(); len(calls) != 0 {
+		t.Fatalf("got calls %v, want the sent item remembered by both feeds", calls)
+	}
+
+	// From then on each feed keeps its own items
+	server.setBody(rssFeed(testItem{GUID: "2", Title: "Second"}, testItem{GUID: "1", Title: "First"}))
+	for _, feed := range fs.configManager.Get().Feeds {
+		fs.runFeed(feed)
+	}
+	if got := len(recorder.callsTo("sendMessage")); got != 2 {
+		t.Fatalf("got %d sends of the new item, want one per feed", got)
+	}
+}
+
+func TestConfigFormAddsFeedForSharedURL(t *testing.T) {
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{testFeed("https://example.com/feed.xml")}, SkipInitialFetch: true})
+
+	// The new row has no slot and no id
+	form := url.Values{
+		"feed_slots":         {"0", ""},
+		"feed_ids":           {"", ""},
+		"feed_urls":          {"https://example.com/feed.xml", "https://example.com/feed.xml"},
+		"feed_intervals":     {"60", "60"},
+		"telegram_tokens":    {"123:test", "123:test"},
+		"telegram_chat_ids":  {"100", "200"},
+		"telegram_templates": {"{{.Title}}", "{{.Title}}"},
+	}
+	if rec := serve(newTestRouter(fs), http.MethodPost, "/config", form.Encode()); rec.Code != http.StatusSeeOther {
+		t.Fatalf("save failed with %d: %s", rec.Code, rec.Body.String())
+	}
+
+	feeds := fs.configManager.Get().Feeds
+	if len(feeds) != 2 || feeds[0].Key() == feeds[1].Key() || feeds[1].ID == "" {
+		t.Fatalf("got feeds %+v, want the second one given an id", feeds)
+	}
+	if feeds[0].Key() != "https://example.com/feed.xml" {
+		t.Fatalf("existing feed's key changed to %q", feeds[0].Key())
+	}
+}
@@ -1,47 +1,372 @@
 package internal
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// defaultConfigPath is where the configuration is read from and saved to by default
+const defaultConfigPath = "config.yaml"
+
 // ConfigManager handles loading and saving configuration.
 type ConfigManager struct {
-	Config *Config
+	// Path is the config file location. An http:// or https:// URL loads the
+	// configuration from a central server and makes it read-only.
+	Path   string
+	config atomic.Pointer[Config]
+	mu     sync.Mutex
 }
 
 // NewConfigManager creates a new ConfigManager.
 func NewConfigManager() *ConfigManager {
-	return &ConfigManager{
-		Config: &Config{},
-	}
+	cm := &ConfigManager{Path: defaultConfigPath}
+	cm.config.Store(&Config{})
+	return cm
+}
+
+// Get returns the active configuration. It is replaced as a whole, never changed in
+// place, so it can be read without locking while updates are made.
+func (cm *ConfigManager) Get() *Config {
+	return cm.config.Load()
+}
+
+// set makes a configuration the active one
+func (cm *ConfigManager) set(config *Config) {
+	cm.config.Store(config)
 }
 
-// LoadConfig loads the configuration from the config.yaml file.
+// IsRemote reports whether the configuration is loaded from a URL.
+func (cm *ConfigManager) IsRemote() bool {
+	return isRemoteConfigPath(cm.Path)
+}
+
+// isRemoteConfigPath reports whether a config path is an HTTP(S) URL.
+func isRemoteConfigPath(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// LoadConfig loads the configuration from the config file or URL.
 func (cm *ConfigManager) LoadConfig() error {
-	data, err := os.ReadFile("config.yaml")
+	var data []byte
+	var err error
+	if cm.IsRemote() {
+		data, err = fetchRemoteConfig(cm.Path)
+	} else {
+		data, err = os.ReadFile(cm.Path)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to read config file: %v", err)
 	}
 
-	err = yaml.Unmarshal(data, cm.Config)
+	config := &Config{}
+	err = yaml.Unmarshal(data, config)
 	if err != nil {
 		return fmt.Errorf("failed to parse config file: %v", err)
 	}
 
+	err = loadFeedsDir(config, cm.Path, false)
+	if err != nil {
+		return err
+	}
+
+	for _, warning := range config.clampFetchIntervals() {
+		log.Printf("Warning: %s", warning)
+	}
+	for _, message := range config.assignSharedFeedIDs(true) {
+		log.Printf("Warning: %s", message)
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	config.linkPartials()
+
+	cm.set(config)
+	return nil
+}
+
+// CheckConfig reads a configuration file or URL and returns every problem found in it,
+// without starting anything. Unknown keys, which are ignored when loading, are reported
+// too, since they are usually typos.
+func CheckConfig(path string) []string {
+	var data []byte
+	var err error
+	if isRemoteConfigPath(path) {
+		data, err = fetchRemoteConfig(path)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("failed to read config file: %v", err)}
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return []string{fmt.Sprintf("failed to parse config file: %v", err)}
+	}
+
+	var problems []string
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var strict Config
+	if err := decoder.Decode(&strict); err != nil && err != io.EOF {
+		problems = append(problems, fmt.Sprintf("config file: %v", err))
+	}
+
+	if err := loadFeedsDir(&config, path, false); err != nil {
+		return append(problems, err.Error())
+	}
+	if err := loadFeedsDir(&Config{FeedsDir: config.FeedsDir}, path, true); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	problems = append(problems, config.clampFetchIntervals()...)
+	if err := config.Validate(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	config.linkPartials()
+	for i, feed := range config.Feeds {
+		if err := templateError(feed); err != nil {
+			problems = append(problems, fmt.Sprintf("feed %d (%s): %v", i+1, feed.FeedUrl, err))
+		}
+	}
+
+	return problems
+}
+
+// defaultMaxFeeds is the feed limit used when max_feeds is not set
+const defaultMaxFeeds = 1000
+
+// Validate checks the configuration for values that cannot be used.
+func (c *Config) Validate() error {
+	maxFeeds := c.MaxFeeds
+	if maxFeeds <= 0 {
+		maxFeeds = defaultMaxFeeds
+	}
+	if len(c.Feeds) > maxFeeds {
+		return fmt.Errorf("too many feeds: %d configured but max_feeds is %d", len(c.Feeds), maxFeeds)
+	}
+
+	ids := make(map[string]int)
+	for i, feed := range c.Feeds {
+		if feed.ID != "" {
+			if other, exists := ids[feed.ID]; exists {
+				return fmt.Errorf("feed %d (%s): id %q is already used by feed %d", i+1, feed.FeedUrl, feed.ID, other)
+			}
+			ids[feed.ID] = i + 1
+		}
+		if err := validateFeedHost(feed.FeedUrl, c.AllowedFeedHosts); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateFetchInterval(feed, c.minFetchInterval()); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateDedupFields(feed.DedupFields); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateRoutes(feed.Routes); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateDigestOrder(feed.DigestOrder); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateParseModes(feed.ParseModes); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateRetentionKey(feed.RetentionKey); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateMissingIdentity(feed.MissingIdentity); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateFeedTemplates(feed); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateActiveHours(feed); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateMessageType(feed.MessageType); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateBodyPreference(feed.BodyPreference); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateUndatedItems(feed.UndatedItems); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateTemplateErrorPolicy(feed.TemplateError); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateEmptyMessage(feed.EmptyMessage); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateCategoryFormat(feed); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateUnknownLanguage(feed.UnknownLanguage); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateSendFailure(feed.SendFailure); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateExtraTelegramParams(feed.ExtraTelegramParams); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateFeedMode(feed); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+		if err := validateFeedPartials(feed, c.Partials); err != nil {
+			return fmt.Errorf("feed %d (%s): %v", i+1, feed.FeedUrl, err)
+		}
+	}
+
+	if err := validateStoredDescription(c.StoredDescription); err != nil {
+		return err
+	}
+	if err := validatePartials(c.Partials); err != nil {
+		return err
+	}
+	if err := validateTemplate("test_telegram_template", c.TestTelegramTemplate); err != nil {
+		return err
+	}
+	if err := validateTemplate("alert_template", c.AlertTemplate); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-// SaveConfig saves the configuration to the config.yaml file.
+// replaceToken replaces a Telegram bot token everywhere it is used and returns how many
+// feeds were changed
+func (c *Config) replaceToken(oldToken, newToken string) int {
+	if c.TestTelegramApiToken == oldToken {
+		c.TestTelegramApiToken = newToken
+	}
+	if c.AlertTelegramApiToken == oldToken {
+		c.AlertTelegramApiToken = newToken
+	}
+
+	updated := 0
+	for i := range c.Feeds {
+		if c.Feeds[i].TelegramApiToken == oldToken {
+			c.Feeds[i].TelegramApiToken = newToken
+			updated++
+		}
+	}
+	return updated
+}
+
+// linkPartials gives every feed access to the configuration's partials, which its
+// templates can include with {{template "name" .}}
+func (c *Config) linkPartials() {
+	for i := range c.Feeds {
+		c.Feeds[i].partials = c.Partials
+	}
+}
+
+// errNoFeedsWithTag is returned when a change by tag matches no feed
+var errNoFeedsWithTag = errors.New("no feeds with tag")
+
+// updateFeedsWithTag applies fn to every feed with the given tag and returns the number
+// of feeds it was applied to
+func (c *Config) updateFeedsWithTag(tag string, fn func(feed *Feed)) int {
+	updated := 0
+	for i := range c.Feeds {
+		if c.Feeds[i].HasTag(tag) {
+			fn(&c.Feeds[i])
+			updated++
+		}
+	}
+	return updated
+}
+
+// fetchRemoteConfig downloads a configuration file from a URL.
+func fetchRemoteConfig(url string) ([]byte, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// SaveConfig saves the configuration to the config file.
 func (cm *ConfigManager) SaveConfig() error {
-	data, err := yaml.Marshal(cm.Config)
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	return cm.writeConfig(cm.Get())
+}
+
+// Update applies fn to a copy of the current configuration, validates and saves the
+// result, and only then makes it the active configuration. Concurrent updates are
+// serialized so that none of them is lost.
+func (cm *ConfigManager) Update(fn func(cfg *Config) error) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	current := cm.Get()
+	newConfig := *current
+	newConfig.Feeds = append([]Feed(nil), current.Feeds...)
+
+	err := fn(&newConfig)
+	if err != nil {
+		return err
+	}
+	for _, message := range newConfig.assignSharedFeedIDs(false) {
+		log.Println(message)
+	}
+
+	err = newConfig.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
+	}
+
+	err = cm.writeConfig(&newConfig)
+	if err != nil {
+		return err
+	}
+
+	newConfig.linkPartials()
+	cm.set(&newConfig)
+	return nil
+}
+
+// writeConfig writes a configuration to the config file. Remote configurations and
+// configurations with a feeds_dir, whose feeds are spread over several files, are read-only.
+func (cm *ConfigManager) writeConfig(config *Config) error {
+	if cm.IsRemote() {
+		return fmt.Errorf("configuration is loaded from %s and is read-only", cm.Path)
+	}
+	if config.FeedsDir != "" {
+		return fmt.Errorf("configuration includes the feeds in %s and is read-only; edit the files instead", config.FeedsDir)
+	}
+
+	data, err := yaml.Marshal(config)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %v", err)
 	}
 
-	err = os.WriteFile("config.yaml", data, 0644)
+	err = os.WriteFile(cm.Path, data, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
@@ -0,0 +1,251 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newTestConfigManager(t *testing.T, config *Config) *ConfigManager {
+	t.Helper()
+	cm := NewConfigManager()
+	cm.Path = filepath.Join(t.TempDir(), "config.yaml")
+	config.linkPartials()
+	cm.set(config)
+	return cm
+}
+
+func TestConfigManagerConcurrentUpdateAndGet(t *testing.T) {
+	cm := newTestConfigManager(t, &Config{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			err := cm.Update(func(cfg *Config) error {
+				cfg.Feeds = append(cfg.Feeds, Feed{FeedUrl: fmt.Sprintf("https://example.com/%d.xml", i), FeedFetchIntervalMinutes: 60})
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Update: %v", err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				config := cm.Get()
+				for _, feed := range config.Feeds {
+					_ = feed.FeedUrl
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(cm.Get().Feeds); got != 8 {
+		t.Fatalf("got %d feeds after 8 updates, want 8", got)
+	}
+}
+
+func TestConfigManagerUpdateKeepsConfigOnError(t *testing.T) {
+	cm := newTestConfigManager(t, &Config{Feeds: []Feed{{FeedUrl: "https://example.com/a.xml", FeedFetchIntervalMinutes: 60}}})
+	before := cm.Get()
+
+	err := cm.Update(func(cfg *Config) error {
+		cfg.Feeds[0].FeedUrl = "https://example.com/b.xml"
+		return fmt.Errorf("rejected")
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if cm.Get() != before || before.Feeds[0].FeedUrl != "https://example.com/a.xml" {
+		t.Fatal("failed update changed the active configuration")
+	}
+}
+
+// remoteConfigServer serves a config file over HTTP
+func remoteConfigServer(t *testing.T, data string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/config.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLoadRemoteConfig(t *testing.T) {
+	server := remoteConfigServer(t, `database: feeds.db
+feeds:
+  - feed_url: https://example.com/feed.xml
+    telegram_chat_id: "-1001234"
+    feed_fetch_interval_minutes: 30
+`)
+
+	cm := NewConfigManager()
+	cm.Path = server.URL + "/config.yaml"
+	if err := cm.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if !cm.IsRemote() {
+		t.Fatal("config loaded from a URL is not remote")
+	}
+
+	config := cm.Get()
+	if config.Database != "feeds.db" || len(config.Feeds) != 1 {
+		t.Fatalf("unexpected config %+v", config)
+	}
+	if feed := config.Feeds[0]; feed.FeedUrl != "https://example.com/feed.xml" || feed.TelegramChatId != "-1001234" {
+		t.Fatalf("unexpected feed %+v", feed)
+	}
+}
+
+func TestRemoteConfigIsReadOnly(t *testing.T) {
+	server := remoteConfigServer(t, `feeds:
+  - feed_url: https://example.com/feed.xml
+    feed_fetch_interval_minutes: 30
+`)
+	cm := NewConfigManager()
+	cm.Path = server.URL + "/config.yaml"
+	if err := cm.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	if err := cm.SaveConfig(); err == nil {
+		t.Fatal("SaveConfig succeeded for a remote config")
+	}
+	err := cm.Update(func(cfg *Config) error {
+		cfg.Feeds[0].FeedFetchIntervalMinutes = 60
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Update succeeded for a remote config")
+	}
+	if got := cm.Get().Feeds[0].FeedFetchIntervalMinutes; got != 30 {
+		t.Fatalf("interval changed to %d", got)
+	}
+}
+
+func TestLoadRemoteConfigHTTPError(t *testing.T) {
+	server := remoteConfigServer(t, "")
+
+	cm := NewConfigManager()
+	cm.Path = server.URL + "/missing.yaml"
+	if err := cm.LoadConfig(); err == nil {
+		t.Fatal("expected an error for a missing remote config")
+	}
+}
+
+func TestUpdateEnforcesMaxFeeds(t *testing.T) {
+	cm := newTestConfigManager(t, &Config{MaxFeeds: 2})
+
+	add := func(n int) error {
+		return cm.Update(func(cfg *Config) error {
+			cfg.Feeds = append(cfg.Feeds, Feed{FeedUrl: fmt.Sprintf("https://example.com/%d.xml", n), FeedFetchIntervalMinutes: 30})
+			return nil
+		})
+	}
+
+	for n := 1; n <= 2; n++ {
+		if err := add(n); err != nil {
+			t.Fatalf("feed %d under the limit rejected: %v", n, err)
+		}
+	}
+	err := add(3)
+	if err == nil || !strings.Contains(err.Error(), "max_feeds is 2") {
+		t.Fatalf("got error %v, want the feed limit", err)
+	}
+	if n := len(cm.Get().Feeds); n != 2 {
+		t.Fatalf("got %d feeds after the rejected save", n)
+	}
+}
+
+func TestValidateDefaultMaxFeeds(t *testing.T) {
+	config := &Config{}
+	for n := 0; n < defaultMaxFeeds; n++ {
+		config.Feeds = append(config.Feeds, Feed{FeedUrl: fmt.Sprintf("https://example.com/%d.xml", n), FeedFetchIntervalMinutes: 30})
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("feeds at the default limit rejected: %v", err)
+	}
+
+	config.Feeds = append(config.Feeds, Feed{FeedUrl: "https://example.com/one-more.xml", FeedFetchIntervalMinutes: 30})
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected an error past the default limit")
+	}
+}
+
+// writeConfigFile writes a configuration file to a temporary directory and returns its path
+func writeConfigFile(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckConfigGood(t *testing.T) {
+	path := writeConfigFile(t, `
+server: ":8080"
+database: "feeds.db"
+feeds:
+  - feed_url: "https://example.com/feed.xml"
+    telegram_api_token: "123:abc"
+    telegram_chat_id: "-1001234"
+    telegram_template: "<b>{{.Title}}</b>\n{{.Link}}"
+    feed_fetch_interval_minutes: 30
+`)
+
+	if problems := CheckConfig(path); len(problems) != 0 {
+		t.Fatalf("got problems %q for a good config", problems)
+	}
+}
+
+func TestCheckConfigBad(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		want string
+	}{
+		{"unparsable", "feeds: [", "failed to parse config file"},
+		{"unknown key", `
+feeds:
+  - feed_url: "https://example.com/feed.xml"
+    telegram_chat_id: "1"
+    telegram_tempalte: "{{.Title}}"
+`, "telegram_tempalte"},
+		{"broken template", `
+feeds:
+  - feed_url: "https://example.com/feed.xml"
+    telegram_chat_id: "1"
+    telegram_template: "{{.Title}} {{.Nonexistent}}"
+`, "feed 1 (https://example.com/feed.xml)"},
+		{"invalid option", `
+feeds:
+  - feed_url: "https://example.com/feed.xml"
+    telegram_chat_id: "1"
+    message_type: "poll"
+`, `unknown message_type "poll"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			problems := CheckConfig(writeConfigFile(t, tc.data))
+			if !strings.Contains(strings.Join(problems, "\n"), tc.want) {
+				t.Fatalf("got problems %q, want one mentioning %q", problems, tc.want)
+			}
+		})
+	}
+
+	if problems := CheckConfig(filepath.Join(t.TempDir(), "missing.yaml")); len(problems) != 1 || !strings.Contains(problems[0], "failed to read config file") {
+		t.Fatalf("got problems %q for a missing file", problems)
+	}
+}
@@ -4,12 +4,12 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
 )
 
-
 // DBManager handles all database operations
 type DBManager struct {
 	db *sql.DB
@@ -17,7 +17,7 @@ type DBManager struct {
 
 // NewDBManager creates a new database manager
 func NewDBManager(databasePath string) (*DBManager, error) {
-	db, err := sql.Open("sqlite", databasePath)
+	db, err := sql.Open("sqlite", withBusyTimeout(databasePath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
@@ -32,35 +32,238 @@ func NewDBManager(databasePath string) (*DBManager, error) {
 	return manager, nil
 }
 
+// dbBusyTimeout is how long a write waits for another connection's write to finish
+// instead of failing with "database is locked"
+const dbBusyTimeout = 5 * time.Second
+
+// withBusyTimeout adds the busy timeout to a database path, keeping any options it has
+func withBusyTimeout(databasePath string) string {
+	separator := "?"
+	if strings.Contains(databasePath, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf("%s%s_pragma=busy_timeout(%d)", databasePath, separator, dbBusyTimeout.Milliseconds())
+}
+
 func (dm *DBManager) createTables() error {
 	query := `
 	CREATE TABLE IF NOT EXISTS feed_items (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		guid TEXT UNIQUE NOT NULL,
+		guid TEXT NOT NULL,
 		title TEXT,
 		description TEXT,
 		link TEXT,
 		published_at DATETIME,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		feed_url TEXT NOT NULL
+		feed_url TEXT NOT NULL,
+		UNIQUE (feed_url, guid)
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_guid ON feed_items(guid);
 	CREATE INDEX IF NOT EXISTS idx_feed_url ON feed_items(feed_url);
 	CREATE INDEX IF NOT EXISTS idx_created_at ON feed_items(created_at);
+	DROP INDEX IF EXISTS idx_published_at;
+	CREATE INDEX IF NOT EXISTS idx_feed_url_created_at ON feed_items(feed_url, created_at);
+	CREATE INDEX IF NOT EXISTS idx_feed_url_published_at ON feed_items(feed_url, published_at);
+
+	CREATE TABLE IF NOT EXISTS digest_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		feed_url TEXT NOT NULL,
+		message TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_digest_feed_url ON digest_items(feed_url);
+
+	CREATE TABLE IF NOT EXISTS pending_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		feed_url TEXT NOT NULL,
+		guid TEXT NOT NULL,
+		item_json TEXT NOT NULL,
+		feed_json TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (feed_url, guid)
+	);
+
+	CREATE TABLE IF NOT EXISTS pinned_messages (
+		feed_url TEXT NOT NULL,
+		chat_id TEXT NOT NULL,
+		message_id INTEGER NOT NULL,
+		PRIMARY KEY (feed_url, chat_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS sent_messages (
+		feed_url TEXT NOT NULL,
+		chat_id TEXT NOT NULL,
+		guid TEXT NOT NULL,
+		link TEXT,
+		message_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (feed_url, chat_id, guid)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_sent_messages_chat_id ON sent_messages(chat_id);
+
+	CREATE TABLE IF NOT EXISTS dedup_schemes (
+		feed_url TEXT PRIMARY KEY,
+		scheme TEXT NOT NULL
+	);
 	`
 
 	_, err := dm.db.Exec(query)
-	return err
+	if err != nil {
+		return err
+	}
+
+	err = dm.addColumnIfMissing("feed_items", "status", "TEXT NOT NULL DEFAULT '"+feedItemStatusSent+"'")
+	if err != nil {
+		return err
+	}
+
+	err = dm.addColumnIfMissing("feed_items", "date_synthesized", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+
+	return dm.migrateFeedItemsUniqueness()
+}
+
+// migrateFeedItemsUniqueness rebuilds a feed_items table created by an older version,
+// where a guid was unique across all feeds, so that a guid is only unique per feed.
+// Otherwise feeds sharing a URL would drop each other's items and post them again.
+func (dm *DBManager) migrateFeedItemsUniqueness() error {
+	var schema string
+	err := dm.db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'feed_items'`).Scan(&schema)
+	if err != nil {
+		return fmt.Errorf("failed to inspect table feed_items: %v", err)
+	}
+	if !strings.Contains(schema, "guid TEXT UNIQUE NOT NULL") {
+		return nil
+	}
+
+	tx, err := dm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start feed_items migration: %v", err)
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`CREATE TABLE feed_items_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			guid TEXT NOT NULL,
+			title TEXT,
+			description TEXT,
+			link TEXT,
+			published_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			feed_url TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT '` + feedItemStatusSent + `',
+			date_synthesized INTEGER NOT NULL DEFAULT 0,
+			UNIQUE (feed_url, guid)
+		)`,
+		`INSERT INTO feed_items_new (id, guid, title, description, link, published_at, created_at, feed_url, status, date_synthesized)
+			SELECT id, guid, title, description, link, published_at, created_at, feed_url, status, date_synthesized FROM feed_items`,
+		`DROP TABLE feed_items`,
+		`ALTER TABLE feed_items_new RENAME TO feed_items`,
+		`CREATE INDEX IF NOT EXISTS idx_guid ON feed_items(guid)`,
+		`CREATE INDEX IF NOT EXISTS idx_feed_url ON feed_items(feed_url)`,
+		`CREATE INDEX IF NOT EXISTS idx_created_at ON feed_items(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_feed_url_created_at ON feed_items(feed_url, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_feed_url_published_at ON feed_items(feed_url, published_at)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to migrate feed_items: %v", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit feed_items migration: %v", err)
+	}
+	log.Printf("Migrated feed_items so that item GUIDs are unique per feed")
+	return nil
+}
+
+// addColumnIfMissing adds a column to a table created by an older version
+func (dm *DBManager) addColumnIfMissing(table, column, definition string) error {
+	rows, err := dm.db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed to inspect table %s: %v", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to inspect table %s: %v", table, err)
+	}
+
+	_, err = dm.db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
+	if err != nil {
+		return fmt.Errorf("failed to add column %s to %s: %v", column, table, err)
+	}
+
+	return nil
+}
+
+// States of a stored feed item
+const (
+	feedItemStatusSent         = "sent"
+	feedItemStatusPossiblySent = "possibly_sent"
+)
+
+// Ways the description of an item can be stored
+const (
+	storedDescriptionFull      = "full"
+	storedDescriptionTruncated = "truncated"
+	storedDescriptionNone      = "none"
+)
+
+// defaultStoredDescriptionLength is how many characters are kept in truncated mode
+const defaultStoredDescriptionLength = 200
+
+// validateStoredDescription checks the stored_description option
+func validateStoredDescription(mode string) error {
+	switch mode {
+	case "", storedDescriptionFull, storedDescriptionTruncated, storedDescriptionNone:
+		return nil
+	}
+	return fmt.Errorf("unknown stored_description %q (use %q, %q or %q)", mode, storedDescriptionFull, storedDescriptionTruncated, storedDescriptionNone)
+}
+
+// trimForStorage shortens or drops the description of an item before it is stored.
+// Items are only kept to detect duplicates, so the full text is rarely needed.
+func trimForStorage(item FeedItem, config *Config) FeedItem {
+	switch config.StoredDescription {
+	case storedDescriptionNone:
+		item.Description = ""
+	case storedDescriptionTruncated:
+		limit := config.StoredDescriptionLength
+		if limit <= 0 {
+			limit = defaultStoredDescriptionLength
+		}
+		if runes := []rune(item.Description); len(runes) > limit {
+			item.Description = string(runes[:limit])
+		}
+	}
+	return item
 }
 
 func (dm *DBManager) SaveFeedItem(item FeedItem) error {
 	query := `
-	INSERT OR IGNORE INTO feed_items (guid, title, description, link, published_at, feed_url)
-	VALUES (?, ?, ?, ?, ?, ?)
+	INSERT OR IGNORE INTO feed_items (guid, title, description, link, published_at, feed_url, date_synthesized)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := dm.db.Exec(query, item.GUID, item.Title, item.Description, item.Link, item.PublishedAt, item.FeedURL)
+	_, err := dm.db.Exec(query, item.GUID, item.Title, item.Description, item.Link, item.PublishedAt, item.FeedURL, item.DateSynthesized)
 	if err != nil {
 		return fmt.Errorf("failed to save feed item: %v", err)
 	}
@@ -68,6 +271,210 @@ func (dm *DBManager) SaveFeedItem(item FeedItem) error {
 	return nil
 }
 
+// feedItemBatchSize is how many items SaveFeedItems inserts per transaction
+const feedItemBatchSize = 500
+
+// SaveFeedItems stores many items at once, e.g. to mark a feed's backlog as seen. Items
+// are inserted in transactions of feedItemBatchSize, so a large backlog neither becomes
+// thousands of separate writes nor holds the database lock for long.
+func (dm *DBManager) SaveFeedItems(items []FeedItem) error {
+	query := `
+	INSERT OR IGNORE INTO feed_items (guid, title, description, link, published_at, feed_url, date_synthesized)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	for start := 0; start < len(items); start += feedItemBatchSize {
+		end := start + feedItemBatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		err := func() error {
+			tx, err := dm.db.Begin()
+			if err != nil {
+				return fmt.Errorf("failed to start saving feed items: %v", err)
+			}
+			defer tx.Rollback()
+
+			stmt, err := tx.Prepare(query)
+			if err != nil {
+				return fmt.Errorf("failed to save feed items: %v", err)
+			}
+			defer stmt.Close()
+
+			for _, item := range items[start:end] {
+				_, err = stmt.Exec(item.GUID, item.Title, item.Description, item.Link, item.PublishedAt, item.FeedURL, item.DateSynthesized)
+				if err != nil {
+					return fmt.Errorf("failed to save feed item: %v", err)
+				}
+			}
+
+			if err := tx.Commit(); err != nil {
+				return fmt.Errorf("failed to commit feed items: %v", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SavePossiblySentItem stores an item whose delivery failed in a way that leaves it unclear
+// whether Telegram received it. The item counts as posted until an operator resolves it.
+func (dm *DBManager) SavePossiblySentItem(item FeedItem) error {
+	query := `
+	INSERT OR IGNORE INTO feed_items (guid, title, description, link, published_at, feed_url, date_synthesized, status)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := dm.db.Exec(query, item.GUID, item.Title, item.Description, item.Link, item.PublishedAt, item.FeedURL, item.DateSynthesized, feedItemStatusPossiblySent)
+	if err != nil {
+		return fmt.Errorf("failed to save possibly sent item: %v", err)
+	}
+
+	return nil
+}
+
+// PossiblySentItems returns the items waiting for an operator to confirm or resend them
+func (dm *DBManager) PossiblySentItems() ([]FeedItem, error) {
+	query := `SELECT id, guid, title, link, published_at, created_at, feed_url, date_synthesized FROM feed_items WHERE status = ? ORDER BY id`
+
+	rows, err := dm.db.Query(query, feedItemStatusPossiblySent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load possibly sent items: %v", err)
+	}
+	defer rows.Close()
+
+	items := []FeedItem{}
+	for rows.Next() {
+		var item FeedItem
+		var title, link sql.NullString
+		err = rows.Scan(&item.ID, &item.GUID, &title, &link, &item.PublishedAt, &item.CreatedAt, &item.FeedURL, &item.DateSynthesized)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read possibly sent item: %v", err)
+		}
+		item.Title = title.String
+		item.Link = link.String
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// MigrateFeedURL moves everything stored for a feed from its old URL to a new one, so
+// that a feed whose URL was edited doesn't send its items again. It returns the number
+// of feed items that were moved.
+func (dm *DBManager) MigrateFeedURL(oldURL, newURL string) (int64, error) {
+	tx, err := dm.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start migration: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE OR IGNORE feed_items SET feed_url = ? WHERE feed_url = ?`, newURL, oldURL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to migrate feed items: %v", err)
+	}
+	migrated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	_, err = tx.Exec(`DELETE FROM feed_items WHERE feed_url = ?`, oldURL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to migrate feed items: %v", err)
+	}
+
+	// Rows the new URL already has win; the old URL's leftovers are dropped
+	for _, table := range []string{"digest_items", "pending_items", "pinned_messages", "sent_messages", "dedup_schemes"} {
+		_, err = tx.Exec(`UPDATE OR IGNORE `+table+` SET feed_url = ? WHERE feed_url = ?`, newURL, oldURL)
+		if err != nil {
+			return 0, fmt.Errorf("failed to migrate %s: %v", table, err)
+		}
+		_, err = tx.Exec(`DELETE FROM `+table+` WHERE feed_url = ?`, oldURL)
+		if err != nil {
+			return 0, fmt.Errorf("failed to migrate %s: %v", table, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("failed to commit migration: %v", err)
+	}
+	return migrated, nil
+}
+
+// CopyFeedItems copies the items stored for one feed key to another that has none yet,
+// so a feed that gets a key of its own doesn't send the items it already sent again.
+// It returns the number of feed items that were copied.
+func (dm *DBManager) CopyFeedItems(fromURL, toURL string) (int64, error) {
+	tx, err := dm.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start copying feed items: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT OR IGNORE INTO feed_items (guid, title, description, link, published_at, created_at, feed_url, status, date_synthesized)
+		SELECT guid, title, description, link, published_at, created_at, ?, status, date_synthesized FROM feed_items
+		WHERE feed_url = ? AND NOT EXISTS (SELECT 1 FROM feed_items WHERE feed_url = ?)`, toURL, fromURL, toURL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy feed items: %v", err)
+	}
+	copied, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	_, err = tx.Exec(`INSERT OR IGNORE INTO dedup_schemes (feed_url, scheme) SELECT ?, scheme FROM dedup_schemes WHERE feed_url = ?`, toURL, fromURL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy dedup scheme: %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("failed to commit copied feed items: %v", err)
+	}
+	return copied, nil
+}
+
+// ConfirmPossiblySentItem marks a possibly sent item as delivered.
+// The boolean is false when there is no such possibly sent item.
+func (dm *DBManager) ConfirmPossiblySentItem(id int64) (bool, error) {
+	query := `UPDATE feed_items SET status = ? WHERE id = ? AND status = ?`
+
+	result, err := dm.db.Exec(query, feedItemStatusSent, id, feedItemStatusPossiblySent)
+	if err != nil {
+		return false, fmt.Errorf("failed to confirm item: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// ForgetPossiblySentItem removes a possibly sent item so it is sent again on the next fetch.
+// The boolean is false when there is no such possibly sent item.
+func (dm *DBManager) ForgetPossiblySentItem(id int64) (bool, error) {
+	query := `DELETE FROM feed_items WHERE id = ? AND status = ?`
+
+	result, err := dm.db.Exec(query, id, feedItemStatusPossiblySent)
+	if err != nil {
+		return false, fmt.Errorf("failed to forget item: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
 func (dm *DBManager) IsFeedItemPosted(guid string, feedURL string) (bool, error) {
 	var count int
 	query := `SELECT COUNT(*) FROM feed_items WHERE guid = ? AND feed_url = ?`
@@ -79,11 +486,239 @@ func (dm *DBManager) IsFeedItemPosted(guid string, feedURL string) (bool, error)
 	return count > 0, nil
 }
 
-func (dm *DBManager) CleanupOldItems(retentionDays int) error {
+// LastItemTime returns when the most recent item of a feed was stored.
+// The boolean is false when the feed has no stored items.
+func (dm *DBManager) LastItemTime(feedURL string) (time.Time, bool, error) {
+	var unix sql.NullInt64
+	query := `SELECT CAST(strftime('%s', MAX(created_at)) AS INTEGER) FROM feed_items WHERE feed_url = ?`
+	err := dm.db.QueryRow(query, feedURL).Scan(&unix)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get last item time: %v", err)
+	}
+
+	if !unix.Valid {
+		return time.Time{}, false, nil
+	}
+
+	return time.Unix(unix.Int64, 0), true, nil
+}
+
+// SaveDigestItem stores a rendered item that is waiting for the next digest of a feed
+func (dm *DBManager) SaveDigestItem(feedURL string, message string) error {
+	query := `INSERT INTO digest_items (feed_url, message) VALUES (?, ?)`
+
+	_, err := dm.db.Exec(query, feedURL, message)
+	if err != nil {
+		return fmt.Errorf("failed to save digest item: %v", err)
+	}
+
+	return nil
+}
+
+// PendingDigestItems returns the items waiting for the next digest of a feed, oldest first
+func (dm *DBManager) PendingDigestItems(feedURL string) ([]DigestItem, error) {
+	query := `SELECT id, feed_url, message, created_at FROM digest_items WHERE feed_url = ? ORDER BY id`
+
+	rows, err := dm.db.Query(query, feedURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load digest items: %v", err)
+	}
+	defer rows.Close()
+
+	var items []DigestItem
+	for rows.Next() {
+		var item DigestItem
+		err = rows.Scan(&item.ID, &item.FeedURL, &item.Message, &item.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read digest item: %v", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// DeleteDigestItems removes digest items once they have been sent
+func (dm *DBManager) DeleteDigestItems(ids []int64) error {
+	for _, id := range ids {
+		_, err := dm.db.Exec(`DELETE FROM digest_items WHERE id = ?`, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete digest item: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// SavePendingItem stores a new item that is waiting for its feed to collect enough items.
+// Items that are already pending are ignored.
+func (dm *DBManager) SavePendingItem(item PendingItem) error {
+	query := `INSERT OR IGNORE INTO pending_items (feed_url, guid, item_json, feed_json) VALUES (?, ?, ?, ?)`
+
+	_, err := dm.db.Exec(query, item.FeedURL, item.GUID, item.ItemJSON, item.FeedJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save pending item: %v", err)
+	}
+
+	return nil
+}
+
+// PendingItems returns the items of a feed that are waiting to be posted, oldest first
+func (dm *DBManager) PendingItems(feedURL string) ([]PendingItem, error) {
+	query := `SELECT id, feed_url, guid, item_json, feed_json, created_at FROM pending_items WHERE feed_url = ? ORDER BY id`
+
+	rows, err := dm.db.Query(query, feedURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pending items: %v", err)
+	}
+	defer rows.Close()
+
+	var items []PendingItem
+	for rows.Next() {
+		var item PendingItem
+		err = rows.Scan(&item.ID, &item.FeedURL, &item.GUID, &item.ItemJSON, &item.FeedJSON, &item.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pending item: %v", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// DeletePendingItem removes a pending item once it has been posted
+func (dm *DBManager) DeletePendingItem(id int64) error {
+	_, err := dm.db.Exec(`DELETE FROM pending_items WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete pending item: %v", err)
+	}
+
+	return nil
+}
+
+// PinnedMessage returns the ID of the message pinned for a feed in a chat.
+// The boolean is false when no message has been pinned yet.
+func (dm *DBManager) PinnedMessage(feedURL string, chatID ChatID) (int64, bool, error) {
+	var messageID int64
+	query := `SELECT message_id FROM pinned_messages WHERE feed_url = ? AND chat_id = ?`
+	err := dm.db.QueryRow(query, feedURL, string(chatID)).Scan(&messageID)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to load pinned message: %v", err)
+	}
+
+	return messageID, true, nil
+}
+
+// SavePinnedMessage records the message pinned for a feed in a chat
+func (dm *DBManager) SavePinnedMessage(feedURL string, chatID ChatID, messageID int64) error {
+	query := `INSERT OR REPLACE INTO pinned_messages (feed_url, chat_id, message_id) VALUES (?, ?, ?)`
+
+	_, err := dm.db.Exec(query, feedURL, string(chatID), messageID)
+	if err != nil {
+		return fmt.Errorf("failed to save pinned message: %v", err)
+	}
+
+	return nil
+}
+
+// DedupScheme returns how the dedup keys of a feed's recorded items were built, as
+// returned by dedupScheme. The boolean is false when none has been recorded.
+func (dm *DBManager) DedupScheme(feedURL string) (string, bool, error) {
+	var scheme string
+	err := dm.db.QueryRow(`SELECT scheme FROM dedup_schemes WHERE feed_url = ?`, feedURL).Scan(&scheme)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to load dedup scheme: %v", err)
+	}
+
+	return scheme, true, nil
+}
+
+// SaveDedupScheme records how the dedup keys of a feed's items are built
+func (dm *DBManager) SaveDedupScheme(feedURL, scheme string) error {
+	query := `INSERT OR REPLACE INTO dedup_schemes (feed_url, scheme) VALUES (?, ?)`
+
+	_, err := dm.db.Exec(query, feedURL, scheme)
+	if err != nil {
+		return fmt.Errorf("failed to save dedup scheme: %v", err)
+	}
+
+	return nil
+}
+
+// SaveSentMessage records the message an item was sent as in a chat, so that later items
+// can reply to it
+func (dm *DBManager) SaveSentMessage(feedURL string, chatID ChatID, guid, link string, messageID int64) error {
+	query := `INSERT OR REPLACE INTO sent_messages (feed_url, chat_id, guid, link, message_id) VALUES (?, ?, ?, ?, ?)`
+
+	_, err := dm.db.Exec(query, feedURL, string(chatID), guid, link, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to save sent message: %v", err)
+	}
+
+	return nil
+}
+
+// SentMessage returns the ID of the latest message sent to a chat for the item with the
+// given GUID or link, from any feed. The boolean is false when no such message is known.
+func (dm *DBManager) SentMessage(chatID ChatID, reference string) (int64, bool, error) {
+	var messageID int64
+	query := `SELECT message_id FROM sent_messages WHERE chat_id = ? AND (guid = ? OR link = ?)
+		ORDER BY created_at DESC LIMIT 1`
+	err := dm.db.QueryRow(query, string(chatID), reference, reference).Scan(&messageID)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to load sent message: %v", err)
+	}
+
+	return messageID, true, nil
+}
+
+// Columns a feed's retention can be based on
+const (
+	retentionKeyCreatedAt   = "created_at"
+	retentionKeyPublishedAt = "published_at"
+)
+
+// validateRetentionKey checks a feed's retention_key option
+func validateRetentionKey(key string) error {
+	switch key {
+	case "", retentionKeyCreatedAt, retentionKeyPublishedAt:
+		return nil
+	}
+	return fmt.Errorf("unknown retention_key %q (use %q or %q)", key, retentionKeyCreatedAt, retentionKeyPublishedAt)
+}
+
+// Queries deleting a feed's items stored or published before a date. Items with a made up
+// publication date are spelled out as their own branch rather than with a CASE, so each
+// branch can use the feed_url/created_at or feed_url/published_at index
+const (
+	cleanupByCreatedAtQuery   = `DELETE FROM feed_items WHERE feed_url = ? AND created_at < ?`
+	cleanupByPublishedAtQuery = `DELETE FROM feed_items WHERE feed_url = ? AND
+		((date_synthesized AND created_at < ?) OR (NOT date_synthesized AND published_at < ?))`
+)
+
+// CleanupOldItems deletes the items of a feed that are older than the retention period.
+// Age is measured from when the item was stored (created_at) or published (published_at).
+// Items whose publication date was made up always age from when they were stored.
+func (dm *DBManager) CleanupOldItems(feedURL string, retentionDays int, retentionKey string) error {
 	thresholdDate := time.Now().AddDate(0, 0, -retentionDays)
-	query := `DELETE FROM feed_items WHERE created_at < ?`
 
-	result, err := dm.db.Exec(query, thresholdDate)
+	query := cleanupByCreatedAtQuery
+	args := []interface{}{feedURL, thresholdDate}
+	if retentionKey == retentionKeyPublishedAt {
+		query = cleanupByPublishedAtQuery
+		args = append(args, thresholdDate)
+	}
+
+	result, err := dm.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to cleanup old items: %v", err)
 	}
@@ -93,10 +728,27 @@ func (dm *DBManager) CleanupOldItems(retentionDays int) error {
 		return fmt.Errorf("failed to get rows affected: %v", err)
 	}
 
-	log.Printf("Cleaned up %d old feed items", rowsAffected)
+	log.Printf("Cleaned up %d old feed items for feed %s", rowsAffected, feedURL)
+
+	_, err = dm.db.Exec(`DELETE FROM sent_messages WHERE feed_url = ? AND created_at < ?`, feedURL, thresholdDate)
+	if err != nil {
+		return fmt.Errorf("failed to cleanup old sent messages: %v", err)
+	}
+
+	return nil
+}
+
+// Ping checks that the database can be queried
+func (dm *DBManager) Ping() error {
+	var one int
+	err := dm.db.QueryRow(`SELECT 1 FROM feed_items LIMIT 1`).Scan(&one)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to query database: %v", err)
+	}
+
 	return nil
 }
 
 func (dm *DBManager) Close() error {
 	return dm.db.Close()
-}
\ No newline at end of file
+}
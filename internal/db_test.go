@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// newTestDB opens a database in a temporary directory, closed when the test ends
+func newTestDB(t *testing.T) *DBManager {
+	t.Helper()
+	db, err := NewDBManager(filepath.Join(t.TempDir(), "feeds.db"))
+	if err != nil {
+		t.Fatalf("NewDBManager: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSaveFeedItemSameGUIDForTwoFeeds(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, feedKey := range []string{"news-en", "news-de"} {
+		if err := db.SaveFeedItem(FeedItem{GUID: "item-1", Title: "Item", FeedURL: feedKey}); err != nil {
+			t.Fatalf("SaveFeedItem: %v", err)
+		}
+	}
+	for _, feedKey := range []string{"news-en", "news-de"} {
+		posted, err := db.IsFeedItemPosted("item-1", feedKey)
+		if err != nil {
+			t.Fatalf("IsFeedItemPosted: %v", err)
+		}
+		if !posted {
+			t.Errorf("item not recorded for feed %s", feedKey)
+		}
+	}
+}
+
+func TestMigrateFeedItemsUniqueness(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feeds.db")
+
+	// The feed_items table as created by older versions
+	old, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = old.Exec(`
+	CREATE TABLE feed_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		guid TEXT UNIQUE NOT NULL,
+		title TEXT,
+		description TEXT,
+		link TEXT,
+		published_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		feed_url TEXT NOT NULL
+	);
+	INSERT INTO feed_items (guid, title, feed_url) VALUES ('item-1', 'Item', 'news-en');
+	`)
+	old.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := NewDBManager(path)
+	if err != nil {
+		t.Fatalf("NewDBManager: %v", err)
+	}
+	defer db.Close()
+
+	posted, err := db.IsFeedItemPosted("item-1", "news-en")
+	if err != nil || !posted {
+		t.Fatalf("existing item lost in migration (posted %v, err %v)", posted, err)
+	}
+	if err := db.SaveFeedItem(FeedItem{GUID: "item-1", Title: "Item", FeedURL: "news-de"}); err != nil {
+		t.Fatalf("SaveFeedItem: %v", err)
+	}
+	posted, err = db.IsFeedItemPosted("item-1", "news-de")
+	if err != nil || !posted {
+		t.Fatalf("item of second feed dropped (posted %v, err %v)", posted, err)
+	}
+}
+
+func TestCleanupOldItemsRetentionKey(t *testing.T) {
+	longAgo := time.Now().AddDate(0, 0, -100)
+
+	for _, tc := range []struct {
+		key  string
+		want []string
+	}{
+		{retentionKeyCreatedAt, []string{"imported", "recent", "undated"}},
+		{retentionKeyPublishedAt, []string{"recent", "undated", "stored-long-ago"}},
+	} {
+		t.Run(tc.key, func(t *testing.T) {
+			db := newTestDB(t)
+			items := []FeedItem{
+				// Published long ago but only stored now, e.g. by an import
+				{GUID: "imported", PublishedAt: longAgo},
+				{GUID: "recent", PublishedAt: time.Now()},
+				// Its publication date was made up, so it ages from when it was stored
+				{GUID: "undated", PublishedAt: longAgo, DateSynthesized: true},
+				{GUID: "stored-long-ago", PublishedAt: time.Now()},
+			}
+			for _, item := range items {
+				item.FeedURL = "feed"
+				if err := db.SaveFeedItem(item); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := db.db.Exec(`UPDATE feed_items SET created_at = ? WHERE guid = 'stored-long-ago'`, longAgo); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := db.CleanupOldItems("feed", 30, tc.key); err != nil {
+				t.Fatalf("CleanupOldItems: %v", err)
+			}
+
+			for _, item := range items {
+				posted, err := db.IsFeedItemPosted(item.GUID, "feed")
+				if err != nil {
+					t.Fatal(err)
+				}
+				kept := false
+				for _, guid := range tc.want {
+					kept = kept || guid == item.GUID
+				}
+				if posted != kept {
+					t.Errorf("item %s: kept %v, want %v", item.GUID, posted, kept)
+				}
+			}
+		})
+	}
+}
+
+func TestCleanupQueriesUseIndexes(t *testing.T) {
+	db := newTestDB(t)
+
+	for query, index := range map[string]string{
+		cleanupByCreatedAtQuery:   "idx_feed_url_created_at",
+		cleanupByPublishedAtQuery: "idx_feed_url_published_at",
+	} {
+		rows, err := db.db.Query("EXPLAIN QUERY PLAN "+query, "feed", time.Now(), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var plan []string
+		for rows.Next() {
+			var id, parent, unused int
+			var detail string
+			if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+				t.Fatal(err)
+			}
+			plan = append(plan, detail)
+		}
+		rows.Close()
+
+		if !strings.Contains(strings.Join(plan, "\n"), index) {
+			t.Errorf("plan %q for %q doesn't use %s", plan, query, index)
+		}
+	}
+}
+
+// storedDescription reads the description stored for an item
+func storedDescription(t *testing.T, db *DBManager, guid string) string {
+	t.Helper()
+	var description string
+	if err := db.db.QueryRow(`SELECT description FROM feed_items WHERE guid = ?`, guid).Scan(&description); err != nil {
+		t.Fatalf("reading item %s: %v", guid, err)
+	}
+	return description
+}
+
+func TestStoredDescriptionTrimmed(t *testing.T) {
+	long := strings.Repeat("é", 500)
+
+	for _, tc := range []struct {
+		config *Config
+		want   string
+	}{
+		{&Config{}, long},
+		{&Config{StoredDescription: storedDescriptionTruncated, StoredDescriptionLength: 50}, strings.Repeat("é", 50)},
+		{&Config{StoredDescription: storedDescriptionTruncated}, strings.Repeat("é", defaultStoredDescriptionLength)},
+		{&Config{StoredDescription: storedDescriptionNone}, ""},
+	} {
+		server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First", Description: long}))
+		feed := testFeed(server.URL)
+		tc.config.Feeds = []Feed{feed}
+		fs, recorder := newTestScheduler(t, tc.config)
+
+		fs.runFeed(feed)
+		fs.runFeed(feed)
+
+		mode := tc.config.StoredDescription
+		if got := storedDescription(t, fs.dbManager, "1"); got != tc.want {
+			t.Errorf("%q: stored %d characters, want %d", mode, utf8.RuneCountInString(got), utf8.RuneCountInString(tc.want))
+		}
+		if texts := sentTexts(recorder); len(texts) != 1 {
+			t.Errorf("%q: sent %d messages, want the item once", mode, len(texts))
+		}
+	}
+}
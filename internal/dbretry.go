@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// defaultDBRetryAttempts is how often a database operation is tried when db_retry_attempts
+// is not set
+const defaultDBRetryAttempts = 3
+
+// dbRetryBackoff is the delay before the first retry of a database operation; it doubles
+// after every attempt
+var dbRetryBackoff = 2 * time.Second
+
+// transientDBErrors are SQLite errors that usually go away on their own
+var transientDBErrors = []string{
+	"database is locked",
+	"database table is locked",
+	"busy",
+	"unable to open database file",
+	"disk i/o error",
+}
+
+// isTransientDBError reports whether a database error is worth retrying
+func isTransientDBError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, transient := range transientDBErrors {
+		if strings.Contains(message, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// withDBRetry runs a database operation, retrying transient errors with backoff. When the
+// operation still fails, the alert chat is notified and the last error is returned.
+func (fs *FeedScheduler) withDBRetry(operation string, fn func() error) error {
+	attempts := fs.configManager.Get().DBRetryAttempts
+	if attempts <= 0 {
+		attempts = defaultDBRetryAttempts
+	}
+
+	backoff := dbRetryBackoff
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = fn()
+		if err == nil || !isTransientDBError(err) {
+			break
+		}
+		if attempt == attempts {
+			break
+		}
+
+		log.Printf("Database error during %s (attempt %d/%d): %v. Retrying in %v...", operation, attempt, attempts, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-fs.ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+
+	if err != nil {
+		alertErr := fs.telegram.SendOperationAlert(operation, err)
+		if alertErr != nil {
+			log.Printf("Error sending alert for %s: %v", operation, alertErr)
+		}
+		return fmt.Errorf("%s failed: %v", operation, err)
+	}
+
+	return nil
+}
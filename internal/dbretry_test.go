@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// shortDBRetryBackoff makes database retries fast for the duration of a test. Call it
+// before creating the scheduler, so the backoff is restored after the scheduler stops.
+func shortDBRetryBackoff(t *testing.T) {
+	backoff := dbRetryBackoff
+	t.Cleanup(func() { dbRetryBackoff = backoff })
+	dbRetryBackoff = time.Millisecond
+}
+
+// flakyOperation returns a database operation that fails with err the first failures
+// times it is called and succeeds afterwards, along with a pointer to its call count
+func flakyOperation(failures int, err error) (func() error, *int) {
+	calls := 0
+	return func() error {
+		calls++
+		if calls <= failures {
+			return err
+		}
+		return nil
+	}, &calls
+}
+
+var errDBLocked = errors.New("failed to query database: database is locked (5) (SQLITE_BUSY)")
+
+func TestWithDBRetryRecoversFromTransientErrors(t *testing.T) {
+	shortDBRetryBackoff(t)
+	fs, recorder := newTestScheduler(t, &Config{AlertTelegramApiToken: "456:alert", AlertTelegramChatId: "999"})
+
+	operation, calls := flakyOperation(2, errDBLocked)
+	if err := fs.withDBRetry("cleanup", operation); err != nil {
+		t.Fatalf("withDBRetry: %v", err)
+	}
+	if *calls != 3 {
+		t.Fatalf("operation ran %d times, want 3", *calls)
+	}
+	if calls := recorder.Calls(); len(calls) != 0 {
+		t.Fatalf("got alerts %v for a recovered operation", calls)
+	}
+}
+
+func TestWithDBRetryAlertsOnPersistentFailure(t *testing.T) {
+	shortDBRetryBackoff(t)
+	fs, recorder := newTestScheduler(t, &Config{AlertTelegramApiToken: "456:alert", AlertTelegramChatId: "999", DBRetryAttempts: 4})
+
+	operation, calls := flakyOperation(10, errDBLocked)
+	err := fs.withDBRetry("database check at startup", operation)
+	if err == nil || !strings.Contains(err.Error(), "database is locked") {
+		t.Fatalf("got error %v, want the database error", err)
+	}
+	if *calls != 4 {
+		t.Fatalf("operation ran %d times, want the 4 configured attempts", *calls)
+	}
+
+	alerts := recorder.callsTo("sendMessage")
+	if len(alerts) != 1 || alerts[0].chatID() != "999" || !strings.Contains(alerts[0].text(), "database check at startup failed") {
+		t.Fatalf("got alerts %v, want one for the failed operation", alerts)
+	}
+}
+
+func TestWithDBRetrySkipsPermanentErrors(t *testing.T) {
+	shortDBRetryBackoff(t)
+	fs, _ := newTestScheduler(t, &Config{})
+
+	operation, calls := flakyOperation(10, errors.New("no such table: feed_items"))
+	if err := fs.withDBRetry("cleanup", operation); err == nil {
+		t.Fatal("expected an error")
+	}
+	if *calls != 1 {
+		t.Fatalf("operation ran %d times, want no retries", *calls)
+	}
+}
+
+func TestWithDBRetryStopsWithScheduler(t *testing.T) {
+	fs, _ := newTestScheduler(t, &Config{})
+
+	done := make(chan error, 1)
+	operation, _ := flakyOperation(10, errDBLocked)
+	go func() { done <- fs.withDBRetry("cleanup", operation) }()
+	fs.Stop()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	case <-time.After(time.Second):
+		// The default backoff is longer than this
+		t.Fatal("retry kept waiting after the scheduler stopped")
+	}
+}
+
+func TestIsTransientDBError(t *testing.T) {
+	for _, message := range []string{"database is locked", "SQLITE_BUSY: busy", "unable to open database file", "disk I/O error"} {
+		if !isTransientDBError(errors.New(message)) {
+			t.Errorf("%q not treated as transient", message)
+		}
+	}
+	for _, message := range []string{"no such table: feed_items", "UNIQUE constraint failed"} {
+		if isTransientDBError(errors.New(message)) {
+			t.Errorf("%q treated as transient", message)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// dedupFieldNames lists the item fields that can be used to build a dedup key.
+var dedupFieldNames = []string{"guid", "link", "title", "published", "description", "content"}
+
+// validateDedupFields checks that every configured dedup field is known.
+func validateDedupFields(fields []string) error {
+	for _, field := range fields {
+		name := strings.ToLower(strings.TrimSpace(field))
+		known := false
+		for _, candidate := range dedupFieldNames {
+			if name == candidate {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown dedup field %q (allowed: %s)", field, strings.Join(dedupFieldNames, ", "))
+		}
+	}
+	return nil
+}
+
+// dedupFieldValue returns the value of a single dedup field for an item.
+func dedupFieldValue(item *gofeed.Item, field string) string {
+	switch field {
+	case "guid":
+		return item.GUID
+	case "link":
+		return item.Link
+	case "title":
+		return item.Title
+	case "published":
+		if item.PublishedParsed != nil {
+			return item.PublishedParsed.UTC().Format(time.RFC3339)
+		}
+		return item.Published
+	case "description":
+		return item.Description
+	case "content":
+		return item.Content
+	}
+	return ""
+}
+
+// Policies for items that have neither a GUID nor a link
+const (
+	missingIdentityHash = "hash"
+	missingIdentitySkip = "skip"
+)
+
+// identityFields are hashed to identify items that have neither a GUID nor a link
+var identityFields = []string{"title", "published", "description", "content"}
+
+// validateMissingIdentity checks a feed's missing_identity policy
+func validateMissingIdentity(policy string) error {
+	switch policy {
+	case "", missingIdentityHash, missingIdentitySkip:
+		return nil
+	}
+	return fmt.Errorf("unknown missing_identity %q (use %q or %q)", policy, missingIdentityHash, missingIdentitySkip)
+}
+
+// dedupKey returns the key used to detect whether an item has already been posted.
+// By default this is the item GUID, falling back to the link. When the feed configures
+// DedupFields the key is a hash of the selected fields instead. Items with neither a
+// GUID nor a link get a hash of their other fields, or an empty key when the feed's
+// missing_identity policy is "skip".
+func dedupKey(feed Feed, item *gofeed.Item) string {
+	if len(feed.DedupFields) > 0 {
+		return hashDedupFields(item, feed.DedupFields)
+	}
+
+	if item.GUID != "" {
+		return item.GUID
+	}
+	if item.Link != "" {
+		return item.Link
+	}
+	if feed.MissingIdentity == missingIdentitySkip {
+		return ""
+	}
+	return hashDedupFields(item, identityFields)
+}
+
+// hashDedupFields hashes the given fields of an item into a dedup key.
+func hashDedupFields(item *gofeed.Item, fields []string) string {
+	var parts []string
+	for _, field := range fields {
+		name := strings.ToLower(strings.TrimSpace(field))
+		parts = append(parts, name+"="+dedupFieldValue(item, name))
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// dedupScheme describes how a feed builds its dedup keys: its normalized dedup fields,
+// or "" for the GUID with the link as fallback
+func dedupScheme(feed Feed) string {
+	var names []string
+	for _, field := range feed.DedupFields {
+		names = append(names, strings.ToLower(strings.TrimSpace(field)))
+	}
+	return strings.Join(names, ",")
+}
+
+// previousDedupFeed returns the feed as it built the keys of the items it has recorded,
+// or nil when that is how it builds them now. Feeds without a recorded scheme have
+// only used the default one.
+func (fs *FeedScheduler) previousDedupFeed(feed Feed) (*Feed, error) {
+	scheme, _, err := fs.dbManager.DedupScheme(feed.Key())
+	if err != nil {
+		return nil, err
+	}
+	if scheme == dedupScheme(feed) {
+		return nil, nil
+	}
+
+	previous := feed
+	previous.DedupFields = nil
+	if scheme != "" {
+		previous.DedupFields = strings.Split(scheme, ",")
+	}
+	return &previous, nil
+}
+
+// postedUnderPreviousKey reports whether an item whose key is unknown was recorded under
+// the key the feed built before its dedup_fields changed
+func (fs *FeedScheduler) postedUnderPreviousKey(feed Feed, previous *Feed, item *gofeed.Item, key string) (bool, error) {
+	previousKey := dedupKey(*previous, item)
+	if previousKey == "" || previousKey == key {
+		return false, nil
+	}
+	return fs.dbManager.IsFeedItemPosted(previousKey, feed.Key())
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestDedupKeyFieldCombinations(t *testing.T) {
+	published := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	item := &gofeed.Item{GUID: "guid-1", Link: "https://example.com/a", Title: "A", PublishedParsed: &published}
+	sameLinkAndTitle := &gofeed.Item{GUID: "guid-2", Link: "https://example.com/a", Title: "A"}
+
+	combinations := [][]string{{"link"}, {"title", "published"}, {"link", "title"}, {"guid"}}
+	keys := make(map[string][]string)
+	for _, fields := range combinations {
+		feed := Feed{DedupFields: fields}
+		key := dedupKey(feed, item)
+		if key != dedupKey(feed, item) {
+			t.Fatalf("%v: key is not stable", fields)
+		}
+		if other, exists := keys[key]; exists {
+			t.Fatalf("%v and %v produce the same key", fields, other)
+		}
+		keys[key] = fields
+	}
+
+	// Fields not in the selection don't affect the key
+	linkOnly := Feed{DedupFields: []string{"link", "title"}}
+	if dedupKey(linkOnly, item) != dedupKey(linkOnly, sameLinkAndTitle) {
+		t.Fatal("items with the same link and title got different keys")
+	}
+	guidOnly := Feed{DedupFields: []string{"guid"}}
+	if dedupKey(guidOnly, item) == dedupKey(guidOnly, sameLinkAndTitle) {
+		t.Fatal("items with different GUIDs got the same key")
+	}
+
+	// Case and spacing of the field names don't matter
+	if dedupKey(Feed{DedupFields: []string{" Link "}}, item) != dedupKey(Feed{DedupFields: []string{"link"}}, item) {
+		t.Fatal("field names are not normalized")
+	}
+}
+
+func TestDedupKeyDefault(t *testing.T) {
+	if key := dedupKey(Feed{}, &gofeed.Item{GUID: "guid-1", Link: "https://example.com/a"}); key != "guid-1" {
+		t.Fatalf("got %q, want the GUID", key)
+	}
+	if key := dedupKey(Feed{}, &gofeed.Item{Link: "https://example.com/a"}); key != "https://example.com/a" {
+		t.Fatalf("got %q, want the link", key)
+	}
+}
+
+func TestValidateDedupFields(t *testing.T) {
+	if err := validateDedupFields([]string{"link", "Title"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := validateDedupFields([]string{"author"}); err == nil {
+		t.Fatal("expected an error for an unknown field")
+	}
+}
+
+func TestChangingDedupFieldsDoesNotRepost(t *testing.T) {
+	server := newFeedServer(t, rssFeed(
+		testItem{GUID: "guid-2", Title: "Second", Link: "https://example.com/2"},
+		testItem{GUID: "guid-1", Title: "First", Link: "https://example.com/1"},
+	))
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{testFeed(server.URL)}})
+
+	feed := fs.configManager.Get().Feeds[0]
+	if err := fs.fetchAndProcessFeed(feed); err != nil {
+		t.Fatalf("fetchAndProcessFeed: %v", err)
+	}
+	if got := len(recorder.callsTo("sendMessage")); got != 2 {
+		t.Fatalf("got %d sends on the first fetch, want 2", got)
+	}
+
+	// Switching to link-based keys recognizes both items; only the new one is sent
+	feed.DedupFields = []string{"link"}
+	server.setBody(rssFeed(
+		testItem{GUID: "guid-3", Title: "Third", Link: "https://example.com/3"},
+		testItem{GUID: "guid-2", Title: "Second", Link: "https://example.com/2"},
+		testItem{GUID: "guid-1", Title: "First", Link: "https://example.com/1"},
+	))
+	if err := fs.fetchAndProcessFeed(feed); err != nil {
+		t.Fatalf("fetchAndProcessFeed: %v", err)
+	}
+	texts := sentTexts(recorder)
+	if len(texts) != 3 || texts[2] != "Third" {
+		t.Fatalf("got sends %q, want only Third after the change", texts)
+	}
+
+	scheme, found, err := fs.dbManager.DedupScheme(feed.Key())
+	if err != nil || !found || scheme != "link" {
+		t.Fatalf("got scheme %q (found %v, err %v), want link", scheme, found, err)
+	}
+}
+
+func TestItemsWithoutIdentityPostedOnce(t *testing.T) {
+	items := []testItem{
+		{Title: "First", Description: "One"},
+		{Title: "Second", Description: "Two"},
+		{Title: "Third", Description: "Three"},
+	}
+	server := newFeedServer(t, rssFeed(items...))
+	feed := testFeed(server.URL)
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	fs.runFeed(feed)
+	fs.runFeed(feed)
+
+	texts := sentTexts(recorder)
+	if len(texts) != len(items) {
+		t.Fatalf("got messages %q, want each item once", texts)
+	}
+	for _, item := range items {
+		found := 0
+		for _, text := range texts {
+			if text == item.Title {
+				found++
+			}
+		}
+		if found != 1 {
+			t.Errorf("%s sent %d times", item.Title, found)
+		}
+	}
+}
+
+func TestItemsWithoutIdentitySkipped(t *testing.T) {
+	server := newFeedServer(t, rssFeed(
+		testItem{Title: "No identity"},
+		testItem{GUID: "1", Title: "With GUID"},
+	))
+	feed := testFeed(server.URL)
+	feed.MissingIdentity = missingIdentitySkip
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	fs.runFeed(feed)
+
+	if texts := sentTexts(recorder); len(texts) != 1 || texts[0] != "With GUID" {
+		t.Fatalf("got messages %q, want only the item with a GUID", texts)
+	}
+}
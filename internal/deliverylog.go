@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// deliveryEvent is the JSON line written to the delivery log for every chat an item
+// was sent to
+type deliveryEvent struct {
+	Time          time.Time `json:"time"`
+	Feed          string    `json:"feed"`
+	FeedName      string    `json:"feed_name,omitempty"`
+	GUID          string    `json:"guid"`
+	Chat          ChatID    `json:"chat"`
+	MessageID     int64     `json:"message_id,omitempty"`
+	MessageLength int       `json:"message_length"`
+	Fallback      bool      `json:"fallback,omitempty"` // sent to the feed's fallback chat
+}
+
+// deliveryLog appends delivery events as JSON lines to the configured delivery_log, a
+// file or "-" for standard output, separately from the general log
+type deliveryLog struct {
+	mu   sync.Mutex
+	path string
+	out  io.WriteCloser
+}
+
+// newDeliveryLog creates a delivery log that opens its file on the first event
+func newDeliveryLog() *deliveryLog {
+	return &deliveryLog{}
+}
+
+// write appends an event to the log at path, reopening the log when the path changed.
+// Failures are logged, since they mustn't affect the delivery itself.
+func (dl *deliveryLog) write(path string, event deliveryEvent) {
+	if path == "" {
+		return
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding delivery event: %v", err)
+		return
+	}
+
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	if dl.out == nil || dl.path != path {
+		dl.closeLocked()
+		if path == "-" {
+			dl.out = nopCloser{os.Stdout}
+		} else {
+			file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				log.Printf("Error opening delivery log %s: %v", path, err)
+				return
+			}
+			dl.out = file
+		}
+		dl.path = path
+	}
+
+	if _, err := dl.out.Write(append(line, '\n')); err != nil {
+		log.Printf("Error writing delivery log %s: %v", path, err)
+	}
+}
+
+// close closes the log file, if one is open
+func (dl *deliveryLog) close() {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.closeLocked()
+}
+
+func (dl *deliveryLog) closeLocked() {
+	if dl.out != nil {
+		dl.out.Close()
+		dl.out = nil
+	}
+}
+
+// nopCloser keeps standard output open when the delivery log is closed
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
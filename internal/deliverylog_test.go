@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readDeliveryLog decodes the JSON lines of a delivery log
+func readDeliveryLog(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var event map[string]interface{}
+		if err := json.Unmarshal(line, &event); err != nil {
+			t.Fatalf("line %q is not JSON: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestDeliveryLogEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deliveries.jsonl")
+	server := newFeedServer(t, rssFeed(testItem{GUID: "item-1", Title: "Grüße"}))
+	feed := testFeed(server.URL)
+	feed.Name = "News"
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{feed}, DeliveryLog: path})
+
+	before := time.Now().Add(-time.Second)
+	fs.runFeed(feed)
+
+	events := readDeliveryLog(t, path)
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	event := events[0]
+	want := map[string]string{
+		"feed":           server.URL,
+		"feed_name":      "News",
+		"guid":           "item-1",
+		"chat":           "100",
+		"message_id":     "1",
+		"message_length": "5",
+	}
+	for field, value := range want {
+		if got := fmt.Sprint(event[field]); got != value {
+			t.Errorf("%s: got %s, want %s", field, got, value)
+		}
+	}
+	if _, ok := event["fallback"]; ok {
+		t.Errorf("fallback set for a regular delivery: %v", event)
+	}
+	sent, err := time.Parse(time.RFC3339Nano, fmt.Sprint(event["time"]))
+	if err != nil || sent.Before(before) || sent.After(time.Now()) {
+		t.Errorf("unexpected time %v", event["time"])
+	}
+}
+
+func TestDeliveryLogOnlyForDeliveries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deliveries.jsonl")
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	feed.Mode = feedModeRealtime
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{feed}, DeliveryLog: path})
+
+	// Backlog items marked as seen aren't deliveries
+	fs.runFeed(feed)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("delivery log written without a delivery: %v", err)
+	}
+}
+
+func TestDeliveryLogFollowsPath(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.jsonl"), filepath.Join(dir, "second.jsonl")
+	dl := newDeliveryLog()
+	defer dl.close()
+
+	dl.write(first, deliveryEvent{GUID: "1", Chat: "100"})
+	dl.write(second, deliveryEvent{GUID: "2", Chat: "100"})
+	dl.write("", deliveryEvent{GUID: "3", Chat: "100"})
+
+	for path, guid := range map[string]string{first: "1", second: "2"} {
+		if events := readDeliveryLog(t, path); len(events) != 1 || events[0]["guid"] != guid {
+			t.Fatalf("%s: got %v, want the event of %s", path, events, guid)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package internal
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Default templates used in digest mode
+const (
+	defaultDigestTemplate     = "<b>{{.FeedName}}</b>\n\n{{.Items}}"
+	defaultDigestItemTemplate = "• <a href=\"{{.Link}}\">{{.Title}}</a>"
+	defaultDigestInterval     = 60
+)
+
+// Supported values for a feed's digest_order
+const (
+	digestOrderOldest = "oldest"
+	digestOrderNewest = "newest"
+)
+
+// digestSeparator separates the items inside a digest message
+const digestSeparator = "\n\n"
+
+// addToDigest renders an item with the digest item template, records it as seen and
+// buffers it until the digest is flushed
+func (fs *FeedScheduler) addToDigest(feed Feed, feedData *gofeed.Feed, item *gofeed.Item, key string) error {
+	template := feed.DigestItemTemplate
+	if template == "" {
+		template = defaultDigestItemTemplate
+	}
+	itemMap := buildItemMap(item, feedData)
+	fs.resolveItemLink(feed, itemMap)
+	rendered := renderFeedItemTemplate(feed, itemMap, template)
+
+	feedItem := newFeedItem(feed, item, key)
+
+	// Record the item right away so it isn't buffered again on the next fetch
+	err := fs.dbManager.SaveFeedItem(trimForStorage(feedItem, fs.configManager.Get()))
+	if err != nil {
+		return err
+	}
+
+	// Pending items live in the database so a restart doesn't lose them
+	err = fs.dbManager.SaveDigestItem(feed.Key(), rendered)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Added feed item to digest: %s", item.Title)
+
+	if feed.DigestFlushCount > 0 {
+		pending, err := fs.dbManager.PendingDigestItems(feed.Key())
+		if err != nil {
+			return err
+		}
+		if len(pending) >= feed.DigestFlushCount {
+			fs.flushDigest(feed)
+		}
+	}
+
+	return nil
+}
+
+// flushDigest sends the buffered items of a feed as one or more digest messages.
+// Items beyond the feed's DigestMaxItems stay buffered for the next digest.
+func (fs *FeedScheduler) flushDigest(feed Feed) {
+	if fs.configManager.Get().Paused {
+		return // Keep the items until posting is resumed
+	}
+
+	fs.digestMu.Lock()
+	defer fs.digestMu.Unlock()
+
+	pending, err := fs.dbManager.PendingDigestItems(feed.Key())
+	if err != nil {
+		log.Printf("Error loading digest for feed %s: %v", feed.FeedUrl, err)
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	batch := selectDigestItems(feed, pending)
+
+	items := make([]string, 0, len(batch))
+	ids := make([]int64, 0, len(batch))
+	for _, item := range batch {
+		items = append(items, item.Message)
+		ids = append(ids, item.ID)
+	}
+
+	for _, message := range buildDigestMessages(feed, items) {
+		_, err := fs.telegram.SendDigest(feed, message)
+		if err != nil {
+			// Keep the items so they are retried with the next digest
+			log.Printf("Error sending digest for feed %s: %v", feed.FeedUrl, err)
+			return
+		}
+	}
+
+	for range batch {
+		fs.recordItemSent(feed.Key())
+	}
+
+	err = fs.dbManager.DeleteDigestItems(ids)
+	if err != nil {
+		log.Printf("Error clearing digest for feed %s: %v", feed.FeedUrl, err)
+	}
+
+	log.Printf("Sent digest with %d items for feed: %s (%d carried over)", len(batch), feed.FeedUrl, len(pending)-len(batch))
+}
+
+// selectDigestItems picks the items for the next digest from the pending items (oldest
+// first). The oldest items are sent first when there are more than DigestMaxItems, and
+// the selection is then put in the configured order.
+func selectDigestItems(feed Feed, pending []DigestItem) []DigestItem {
+	batch := pending
+	if feed.DigestMaxItems > 0 && len(batch) > feed.DigestMaxItems {
+		batch = batch[:feed.DigestMaxItems]
+	}
+
+	batch = append([]DigestItem(nil), batch...)
+	if feed.DigestOrder == digestOrderNewest {
+		for i, j := 0, len(batch)-1; i < j; i, j = i+1, j-1 {
+			batch[i], batch[j] = batch[j], batch[i]
+		}
+	}
+
+	return batch
+}
+
+// validateDigestOrder checks the digest_order option
+func validateDigestOrder(order string) error {
+	switch order {
+	case "", digestOrderOldest, digestOrderNewest:
+		return nil
+	}
+	return fmt.Errorf("unknown digest_order %q (use %q or %q)", order, digestOrderOldest, digestOrderNewest)
+}
+
+// startDigestTicker periodically flushes the digest of a feed
+func (fs *FeedScheduler) startDigestTicker(feed Feed) {
+	key := "digest|" + feed.Key()
+	if existingTicker, exists := fs.tickers[key]; exists {
+		existingTicker.Stop()
+	}
+
+	intervalMinutes := feed.DigestIntervalMinutes
+	if intervalMinutes <= 0 {
+		intervalMinutes = defaultDigestInterval
+	}
+	ticker := time.NewTicker(time.Duration(intervalMinutes) * time.Minute)
+	fs.tickers[key] = ticker
+
+	ctx := fs.tickerCtx
+	fs.tickerWG.Add(1)
+	go func(f Feed) {
+		defer fs.tickerWG.Done()
+		for {
+			select {
+			case <-ticker.C:
+				if ctx.Err() != nil {
+					return
+				}
+				fs.flushDigest(f)
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}(feed)
+
+	log.Printf("Started digest for feed: %s (interval: %d minutes)", feed.FeedUrl, intervalMinutes)
+}
+
+// buildDigestMessages combines rendered items into digest messages, starting a new
+// message whenever the next item would push it past Telegram's message length limit
+func buildDigestMessages(feed Feed, items []string) []string {
+	var messages []string
+	var chunk []string
+
+	for _, item := range items {
+		candidate := append(append([]string(nil), chunk...), item)
+		if len(chunk) > 0 && len(renderDigest(feed, candidate)) > maxMessageLength {
+			messages = append(messages, renderDigest(feed, chunk))
+			chunk = []string{item}
+			continue
+		}
+		chunk = candidate
+	}
+
+	if len(chunk) > 0 {
+		messages = append(messages, renderDigest(feed, chunk))
+	}
+
+	return messages
+}
+
+// renderDigest renders the digest wrapper template around a set of rendered items. A
+// digest template that fails to render is replaced by the default one.
+func renderDigest(feed Feed, items []string) string {
+	template := feed.DigestTemplate
+	if template == "" {
+		template = defaultDigestTemplate
+	}
+
+	data := digestTemplateData{
+		FeedName: html.EscapeString(feed.DisplayName()),
+		FeedURL:  html.EscapeString(feed.FeedUrl),
+		Count:    len(items),
+		Items:    strings.Join(items, digestSeparator),
+	}
+
+	message, err := executeTemplate("digest_template", template, nil, data)
+	if err != nil {
+		log.Printf("Error rendering digest template of feed %s, using the default one: %v", feed.FeedUrl, err)
+		message, _ = executeTemplate("digest_template", defaultDigestTemplate, nil, data)
+	}
+	return withSignature(feed, message)
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// digestFeed is a test feed in digest mode with plain templates
+func digestFeed(feedURL string) Feed {
+	feed := testFeed(feedURL)
+	feed.DigestEnabled = true
+	feed.DigestTemplate = "{{.Count}} items\n\n{{.Items}}"
+	feed.DigestItemTemplate = "{{.Title}}"
+	return feed
+}
+
+func TestDigestCombinesItems(t *testing.T) {
+	server := newFeedServer(t, rssFeed(
+		testItem{GUID: "3", Title: "Third"},
+		testItem{GUID: "2", Title: "Second"},
+		testItem{GUID: "1", Title: "First"},
+	))
+	feed := digestFeed(server.URL)
+	feed.DigestFlushCount = 3
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	fs.runFeed(feed)
+
+	texts := sentTexts(recorder)
+	if len(texts) != 1 {
+		t.Fatalf("got %d messages, want one digest: %q", len(texts), texts)
+	}
+	if want := "3 items\n\nFirst\n\nSecond\n\nThird"; texts[0] != want {
+		t.Fatalf("got digest %q, want %q", texts[0], want)
+	}
+
+	pending, err := fs.dbManager.PendingDigestItems(feed.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("%d items left in the digest", len(pending))
+	}
+}
+
+func TestBuildDigestMessagesSplitsAtLimit(t *testing.T) {
+	feed := digestFeed("https://example.com/feed.xml")
+	items := make([]string, 5)
+	for i := range items {
+		items[i] = strings.Repeat(string(rune('a'+i)), 1500)
+	}
+
+	messages := buildDigestMessages(feed, items)
+	if len(messages) < 2 {
+		t.Fatalf("got %d messages, want the digest to be split", len(messages))
+	}
+
+	var joined string
+	for _, message := range messages {
+		if len(message) > maxMessageLength {
+			t.Fatalf("message of %d bytes exceeds the limit", len(message))
+		}
+		joined += message
+	}
+	for _, item := range items {
+		if !strings.Contains(joined, item) {
+			t.Fatalf("item %c... is missing from the digest", item[0])
+		}
+	}
+}
+
+func TestFlushDigestCarriesOverflow(t *testing.T) {
+	feed := digestFeed("https://example.com/feed.xml")
+	feed.DigestMaxItems = 2
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	for _, title := range []string{"First", "Second", "Third"} {
+		if err := fs.dbManager.SaveDigestItem(feed.Key(), title); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fs.flushDigest(feed)
+	fs.flushDigest(feed)
+
+	texts := sentTexts(recorder)
+	want := []string{"2 items\n\nFirst\n\nSecond", "1 items\n\nThird"}
+	if len(texts) != len(want) || texts[0] != want[0] || texts[1] != want[1] {
+		t.Fatalf("got digests %q, want %q", texts, want)
+	}
+}
+
+func TestSelectDigestItemsOrder(t *testing.T) {
+	pending := []DigestItem{{ID: 1, Message: "First"}, {ID: 2, Message: "Second"}, {ID: 3, Message: "Third"}}
+
+	for _, tc := range []struct {
+		order    string
+		maxItems int
+		want     []int64
+	}{
+		{"", 0, []int64{1, 2, 3}},
+		{digestOrderOldest, 2, []int64{1, 2}},
+		{digestOrderNewest, 0, []int64{3, 2, 1}},
+		// The oldest items are sent first, newest first within the digest
+		{digestOrderNewest, 2, []int64{2, 1}},
+	} {
+		feed := Feed{DigestOrder: tc.order, DigestMaxItems: tc.maxItems}
+		batch := selectDigestItems(feed, pending)
+
+		var got []int64
+		for _, item := range batch {
+			got = append(got, item.ID)
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("order %q max %d: got %v, want %v", tc.order, tc.maxItems, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("order %q max %d: got %v, want %v", tc.order, tc.maxItems, got, tc.want)
+				break
+			}
+		}
+	}
+	if pending[0].ID != 1 {
+		t.Fatal("selectDigestItems reordered the pending items")
+	}
+}
+
+func TestPendingDigestSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feeds.db")
+	db, err := NewDBManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveDigestItem("feed", "First"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = NewDBManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	pending, err := db.PendingDigestItems("feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Message != "First" {
+		t.Fatalf("got pending items %+v after reopening the database", pending)
+	}
+}
@@ -0,0 +1,28 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policies for items that render an empty message
+const (
+	emptyMessageFallback = "fallback"
+	emptyMessageSkip     = "skip"
+)
+
+// validateEmptyMessage checks a feed's empty_message policy
+func validateEmptyMessage(policy string) error {
+	switch policy {
+	case "", emptyMessageFallback, emptyMessageSkip:
+		return nil
+	}
+	return fmt.Errorf("unknown empty_message %q (use %q or %q)", policy, emptyMessageFallback, emptyMessageSkip)
+}
+
+// renderedEmpty reports whether the feed's template renders an item as a message without
+// any visible text. The signature doesn't count, as it says nothing about the item.
+func renderedEmpty(feed Feed, item map[string]interface{}) bool {
+	message := renderFeedItemTemplate(feed, item, feed.TelegramTemplate)
+	return strings.TrimSpace(htmlToPlain(message)) == ""
+}
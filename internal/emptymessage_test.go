@@ -0,0 +1,81 @@
+package internal
+
+import "testing"
+
+func TestRenderedEmpty(t *testing.T) {
+	for _, tc := range []struct {
+		template string
+		item     map[string]interface{}
+		want     bool
+	}{
+		{"{{.Description}}", map[string]interface{}{}, true},
+		{"{{.Description}}", map[string]interface{}{"Description": " \n\t "}, true},
+		{"<b>{{.Description}}</b>\n", map[string]interface{}{}, true},
+		{"{{.Description}}", map[string]interface{}{"Description": "<p></p>"}, true},
+		{"{{.Description}}", map[string]interface{}{"Description": "Text"}, false},
+		{"New post", map[string]interface{}{}, false},
+	} {
+		feed := Feed{TelegramTemplate: tc.template, Signature: "— Example News"}
+		if got := renderedEmpty(feed, tc.item); got != tc.want {
+			t.Errorf("%q with %v: got %v, want %v", tc.template, tc.item, got, tc.want)
+		}
+	}
+}
+
+func TestEmptyMessagePolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy string
+		want   []string
+	}{
+		{"", []string{"<b>First</b>\nhttps://example.com/1"}},
+		{emptyMessageFallback, []string{"<b>First</b>\nhttps://example.com/1"}},
+		{emptyMessageSkip, nil},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First", Link: "https://example.com/1"}))
+			feed := testFeed(server.URL)
+			feed.TelegramTemplate = "{{.Description}}"
+			feed.EmptyMessage = tc.policy
+			fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+			fs.runFeed(feed)
+			fs.runFeed(feed)
+
+			texts := sentTexts(recorder)
+			if len(texts) != len(tc.want) || (len(texts) == 1 && texts[0] != tc.want[0]) {
+				t.Fatalf("got messages %q, want %q", texts, tc.want)
+			}
+			// Either way the item is handled, so it isn't tried again on every fetch
+			if posted, _ := fs.dbManager.IsFeedItemPosted("1", feed.Key()); !posted {
+				t.Fatal("item was not recorded")
+			}
+		})
+	}
+}
+
+func TestEmptyFallbackWithoutTitleOrLinkIsSkipped(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1"}))
+	feed := testFeed(server.URL)
+	feed.TelegramTemplate = "{{.Description}}"
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	fs.runFeed(feed)
+
+	if calls := recorder.Calls(); len(calls) != 0 {
+		t.Fatalf("got Telegram calls %v, want the empty item skipped", calls)
+	}
+	if posted, _ := fs.dbManager.IsFeedItemPosted("1", feed.Key()); !posted {
+		t.Fatal("skipped item was not recorded")
+	}
+}
+
+func TestValidateEmptyMessage(t *testing.T) {
+	for _, policy := range []string{"", emptyMessageFallback, emptyMessageSkip} {
+		if err := validateEmptyMessage(policy); err != nil {
+			t.Errorf("%q: unexpected error: %v", policy, err)
+		}
+	}
+	if err := validateEmptyMessage("send"); err == nil {
+		t.Fatal("expected an error for an unknown policy")
+	}
+}
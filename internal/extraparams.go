@@ -0,0 +1,26 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+)
+
+// reservedTelegramParams are sendMessage parameters extra_telegram_params may not set,
+// since they decide where a message goes and what it says
+var reservedTelegramParams = []string{"chat_id", "text"}
+
+// validateExtraTelegramParams checks that a feed's extra sendMessage parameters don't
+// replace the chat or the text of its messages
+func validateExtraTelegramParams(params map[string]interface{}) error {
+	var reserved []string
+	for _, name := range reservedTelegramParams {
+		if _, ok := params[name]; ok {
+			reserved = append(reserved, name)
+		}
+	}
+	if len(reserved) > 0 {
+		sort.Strings(reserved)
+		return fmt.Errorf("extra_telegram_params may not set %v", reserved)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtraParamsInPayload(t *testing.T) {
+	payload := marshalPayload(t, TelegramMessage{
+		ChatID: "100",
+		Text:   "Hello",
+		ExtraParams: map[string]interface{}{
+			"message_effect_id":      "5104841245755180586",
+			"business_connection_id": "abc",
+		},
+	})
+
+	if payload["message_effect_id"] != "5104841245755180586" || payload["business_connection_id"] != "abc" {
+		t.Fatalf("extra params missing from %v", payload)
+	}
+	if payload["text"] != "Hello" || payload["chat_id"] != float64(100) {
+		t.Fatalf("unexpected payload %v", payload)
+	}
+}
+
+func TestExtraParamsCannotOverrideChatOrText(t *testing.T) {
+	payload := marshalPayload(t, TelegramMessage{
+		ChatID:      "100",
+		Text:        "Hello",
+		ExtraParams: map[string]interface{}{"chat_id": 999, "text": "Spoofed"},
+	})
+	if payload["chat_id"] != float64(100) || payload["text"] != "Hello" {
+		t.Fatalf("extra params replaced the chat or text: %v", payload)
+	}
+
+	err := validateExtraTelegramParams(map[string]interface{}{"text": "Spoofed", "chat_id": 999, "message_effect_id": "1"})
+	if err == nil || !strings.Contains(err.Error(), "[chat_id text]") {
+		t.Fatalf("got error %v, want chat_id and text rejected", err)
+	}
+	if err := validateExtraTelegramParams(map[string]interface{}{"message_effect_id": "1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	feed := testFeed("https://example.com/feed.xml")
+	feed.ExtraTelegramParams = map[string]interface{}{"chat_id": 999}
+	if err := (&Config{Feeds: []Feed{feed}}).Validate(); err == nil || !strings.Contains(err.Error(), "chat_id") {
+		t.Fatalf("got error %v, want the config rejected", err)
+	}
+}
+
+func TestExtraParamsSentWithFeedItems(t *testing.T) {
+	ts, recorder := newTestTelegramService(t, &Config{})
+	feed := testFeed("https://example.com/feed.xml")
+	feed.ExtraTelegramParams = map[string]interface{}{"message_effect_id": "5104841245755180586"}
+
+	if _, err := ts.SendFeedItemToTelegram(feed, map[string]interface{}{"Title": "Hello"}); err != nil {
+		t.Fatalf("SendFeedItemToTelegram: %v", err)
+	}
+
+	calls := recorder.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].Payload["message_effect_id"] != "5104841245755180586" || calls[0].chatID() != "100" {
+		t.Fatalf("got calls %v, want the extra param with the message", recorder.Calls())
+	}
+}
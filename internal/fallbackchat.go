@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"html"
+)
+
+// SendToFallbackChat sends an already rendered message that one of the feed's chats
+// permanently rejected to the feed's fallback chat, after a note naming the failed chat
+func (ts *TelegramService) SendToFallbackChat(feed Feed, message string, sendErr error) (int64, error) {
+	reason := sendErr.Error()
+	var apiErr *TelegramAPIError
+	if errors.As(sendErr, &apiErr) && apiErr.Description != "" {
+		reason = apiErr.Description
+	}
+
+	note := fmt.Sprintf("⚠️ Could not deliver to chat %s: %s",
+		html.EscapeString(string(feed.TelegramChatId)), html.EscapeString(reason))
+
+	fallback := feed
+	fallback.TelegramChatId = feed.FallbackChatID
+	fallback.TelegramMessageThreadId = feed.FallbackMessageThreadID
+	fallback.replyTo = 0 // Message IDs of the failed chat don't exist in the fallback chat
+
+	return ts.SendRenderedMessage(fallback, note+"\n\n"+message)
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPermanentFailureGoesToFallbackChat(t *testing.T) {
+	shortTelegramRetryDelay(t)
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	feed.FallbackChatID = "500"
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		if call.chatID() == "100" {
+			return http.StatusForbidden, telegramError(403, "Forbidden: bot was kicked from the supergroup chat")
+		}
+		return 0, ""
+	})
+
+	fs.runFeed(feed)
+
+	var fallback []telegramCall
+	for _, call := range recorder.callsTo("sendMessage") {
+		if call.chatID() == "500" {
+			fallback = append(fallback, call)
+		}
+	}
+	if len(fallback) != 1 {
+		t.Fatalf("got %d messages to the fallback chat, want 1", len(fallback))
+	}
+	want := "⚠️ Could not deliver to chat 100: Forbidden: bot was kicked from the supergroup chat\n\nFirst"
+	if fallback[0].text() != want {
+		t.Fatalf("got %q, want %q", fallback[0].text(), want)
+	}
+	if posted, _ := fs.dbManager.IsFeedItemPosted("1", feed.Key()); !posted {
+		t.Fatal("item delivered to the fallback chat was not recorded")
+	}
+}
+
+func TestSendToFallbackChat(t *testing.T) {
+	ts, recorder := newTestTelegramService(t, &Config{})
+	feed := testFeed("https://example.com/feed.xml")
+	feed.TelegramChatId = "@news"
+	feed.TelegramMessageThreadId = 7
+	feed.FallbackChatID = "500"
+	feed.FallbackMessageThreadID = 3
+	feed.replyTo = 42
+
+	sendErr := &retriesExhaustedError{attempts: 5, err: &TelegramAPIError{StatusCode: 400, Description: "Bad Request: <chat> not found"}}
+	if _, err := ts.SendToFallbackChat(feed, "<b>First</b>", sendErr); err != nil {
+		t.Fatalf("SendToFallbackChat: %v", err)
+	}
+
+	calls := recorder.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].chatID() != "500" || fmt.Sprint(calls[0].Payload["message_thread_id"]) != "3" {
+		t.Fatalf("got calls %v, want one to the fallback chat and thread", recorder.Calls())
+	}
+	if _, ok := calls[0].Payload["reply_parameters"]; ok {
+		t.Fatalf("fallback message replies to a message of the failed chat: %v", calls[0].Payload)
+	}
+	if text := calls[0].text(); !strings.HasPrefix(text, "⚠️ Could not deliver to chat @news: Bad Request: &lt;chat&gt; not found\n\n") || !strings.HasSuffix(text, "<b>First</b>") {
+		t.Fatalf("unexpected text %q", text)
+	}
+}
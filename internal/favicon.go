@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits for fetching feed favicons
+const (
+	faviconTimeout     = 5 * time.Second
+	faviconCacheTTL    = 24 * time.Hour
+	faviconFailureTTL  = time.Hour
+	maxFaviconBodySize = 100 << 10
+)
+
+// defaultFavicon is shown for feeds whose favicon can't be fetched
+const defaultFavicon = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 16 16"><rect width="16" height="16" rx="3" fill="#f59f00"/><circle cx="4.5" cy="11.5" r="1.5" fill="#fff"/><path d="M3 7a6 6 0 0 1 6 6M3 3a10 10 0 0 1 10 10" stroke="#fff" stroke-width="2" fill="none"/></svg>`
+
+// favicon is a cached favicon. A nil body stands for the default icon.
+type favicon struct {
+	body        []byte
+	contentType string
+	expires     time.Time
+}
+
+// faviconCache fetches and caches the favicons of feed sites for the status page. Only
+// public addresses are contacted.
+type faviconCache struct {
+	client *http.Client
+	mu     sync.Mutex
+	cache  map[string]favicon
+}
+
+// newFaviconCache creates an empty favicon cache
+func newFaviconCache() *faviconCache {
+	return &faviconCache{
+		client: newSafeHTTPClient(faviconTimeout),
+		cache:  make(map[string]favicon),
+	}
+}
+
+// faviconURL returns the favicon location of a feed's site: /favicon.ico on the host of
+// the site link, or of the feed URL when the feed doesn't link to its site
+func faviconURL(siteLink, feedURL string) string {
+	for _, link := range []string{siteLink, feedURL} {
+		parsed, err := url.Parse(link)
+		if err != nil || parsed.Host == "" {
+			continue
+		}
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			continue
+		}
+		return (&url.URL{Scheme: parsed.Scheme, Host: parsed.Host, Path: "/favicon.ico"}).String()
+	}
+	return ""
+}
+
+// get returns the favicon at a URL and its content type, fetching it when it isn't
+// cached. Failures are cached for a shorter time and return the default icon.
+func (fc *faviconCache) get(ctx context.Context, iconURL string) ([]byte, string) {
+	fc.mu.Lock()
+	cached, ok := fc.cache[iconURL]
+	fc.mu.Unlock()
+
+	if !ok || time.Now().After(cached.expires) {
+		cached = fc.fetch(ctx, iconURL)
+
+		fc.mu.Lock()
+		fc.cache[iconURL] = cached
+		fc.mu.Unlock()
+	}
+
+	if cached.body == nil {
+		return []byte(defaultFavicon), "image/svg+xml"
+	}
+	return cached.body, cached.contentType
+}
+
+// fetch downloads a favicon, returning an entry for the default icon when the download
+// fails or doesn't return an image
+func (fc *faviconCache) fetch(ctx context.Context, iconURL string) favicon {
+	failed := favicon{expires: time.Now().Add(faviconFailureTTL)}
+	if iconURL == "" {
+		return failed
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, iconURL, nil)
+	if err != nil {
+		return failed
+	}
+	req.Header.Set("User-Agent", "go-telegram-notifications-bot")
+
+	resp, err := fc.client.Do(req)
+	if err != nil {
+		return failed
+	}
+	defer resp.Body.Close()
+
+	// SVG can carry scripts, and the icon is served from this site's origin
+	contentType := resp.Header.Get("Content-Type")
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "image/svg") {
+		return failed
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFaviconBodySize+1))
+	if err != nil || len(body) == 0 || len(body) > maxFaviconBodySize {
+		return failed
+	}
+
+	return favicon{
+		body:        body,
+		contentType: contentType,
+		expires:     time.Now().Add(faviconCacheTTL),
+	}
+}
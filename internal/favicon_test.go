@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFaviconURL(t *testing.T) {
+	for _, tc := range []struct {
+		siteLink string
+		feedURL  string
+		want     string
+	}{
+		{"https://blog.example.com/posts/", "https://feeds.example.com/blog.xml", "https://blog.example.com/favicon.ico"},
+		{"http://localhost:8080/?page=1", "https://feeds.example.com/blog.xml", "http://localhost:8080/favicon.ico"},
+		{"", "https://feeds.example.com/blog.xml", "https://feeds.example.com/favicon.ico"},
+		{"/relative/path", "https://feeds.example.com/blog.xml", "https://feeds.example.com/favicon.ico"},
+		{"ftp://files.example.com/", "https://feeds.example.com/blog.xml", "https://feeds.example.com/favicon.ico"},
+		{"", "not a url", ""},
+	} {
+		if got := faviconURL(tc.siteLink, tc.feedURL); got != tc.want {
+			t.Errorf("%q, %q: got %q, want %q", tc.siteLink, tc.feedURL, got, tc.want)
+		}
+	}
+}
+
+// iconServer serves a favicon with the given content type and counts the requests for it
+func iconServer(t *testing.T, contentType string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte("icon-bytes"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// testFaviconCache returns a favicon cache that can reach test servers, which the safe
+// dialer refuses
+func testFaviconCache() *faviconCache {
+	fc := newFaviconCache()
+	fc.client.Transport = http.DefaultTransport
+	return fc
+}
+
+func TestFaviconCacheFetchesOnce(t *testing.T) {
+	server, requests := iconServer(t, "image/x-icon")
+	fc := testFaviconCache()
+
+	for i := 0; i < 2; i++ {
+		body, contentType := fc.get(context.Background(), server.URL+"/favicon.ico")
+		if string(body) != "icon-bytes" || contentType != "image/x-icon" {
+			t.Fatalf("got %q (%s), want the icon", body, contentType)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("got %d requests, want the icon cached", n)
+	}
+}
+
+func TestFaviconCacheDefaultIcon(t *testing.T) {
+	svgServer, _ := iconServer(t, "image/svg+xml")
+	htmlServer, _ := iconServer(t, "text/html")
+	missingServer, missingRequests := iconServer(t, "image/x-icon")
+
+	for _, iconURL := range []string{svgServer.URL + "/favicon.ico", htmlServer.URL + "/favicon.ico", missingServer.URL + "/missing.ico", ""} {
+		fc := testFaviconCache()
+		body, contentType := fc.get(context.Background(), iconURL)
+		if !bytes.Equal(body, []byte(defaultFavicon)) || contentType != "image/svg+xml" {
+			t.Errorf("%q: got %q (%s), want the default icon", iconURL, body, contentType)
+		}
+	}
+
+	// Failures are cached too
+	fc := testFaviconCache()
+	fc.get(context.Background(), missingServer.URL+"/missing.ico")
+	fc.get(context.Background(), missingServer.URL+"/missing.ico")
+	if n := missingRequests.Load(); n != 2 {
+		t.Fatalf("got %d requests, want one per cache", n)
+	}
+}
+
+func TestFaviconCacheRefusesInternalAddresses(t *testing.T) {
+	server, requests := iconServer(t, "image/x-icon")
+
+	body, _ := newFaviconCache().get(context.Background(), server.URL+"/favicon.ico")
+	if !bytes.Equal(body, []byte(defaultFavicon)) {
+		t.Fatalf("got %q, want the default icon", body)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("loopback server contacted %d times", n)
+	}
+}
+
+func TestFeedFaviconFromSiteLink(t *testing.T) {
+	icons, requests := iconServer(t, "image/png")
+	server := newFeedServer(t, strings.Replace(rssFeed(testItem{GUID: "1", Title: "First"}), "https://example.com/", icons.URL+"/blog/", 1))
+	feed := testFeed(server.URL)
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	fs.runFeed(feed)
+
+	h := NewHandlers(fs.configManager, fs)
+	h.favicons.client.Transport = http.DefaultTransport
+	router := Router(h)
+
+	for i := 0; i < 2; i++ {
+		rec := serve(router, http.MethodGet, "/feeds/0/favicon", "")
+		if rec.Body.String() != "icon-bytes" || rec.Header().Get("Content-Type") != "image/png" {
+			t.Fatalf("got %q (%s), want the site's icon", rec.Body.String(), rec.Header().Get("Content-Type"))
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("got %d requests, want the icon cached", n)
+	}
+
+	if rec := serve(router, http.MethodGet, "/feeds/5/favicon", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("got status %d for an unknown feed, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// feedsFile is a file in the feeds directory, which only holds feeds
+type feedsFile struct {
+	Feeds []Feed `yaml:"feeds"`
+}
+
+// feedsDirPath resolves the configuration's feeds_dir against the directory of the
+// config file it is set in
+func feedsDirPath(config *Config, configPath string) (string, error) {
+	if isRemoteConfigPath(configPath) {
+		return "", fmt.Errorf("feeds_dir is not supported for configurations loaded from a URL")
+	}
+	if filepath.IsAbs(config.FeedsDir) {
+		return config.FeedsDir, nil
+	}
+	return filepath.Join(filepath.Dir(configPath), config.FeedsDir), nil
+}
+
+// loadFeedsDir appends the feeds of every .yaml and .yml file in the configuration's
+// feeds_dir, in file name order, to the feeds of the base file. With knownFields, keys
+// that don't belong to a feed are reported.
+func loadFeedsDir(config *Config, configPath string, knownFields bool) error {
+	if config.FeedsDir == "" {
+		return nil
+	}
+
+	dir, err := feedsDirPath(config, configPath)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read feeds_dir: %v", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("failed to read feeds file %s: %v", name, err)
+		}
+
+		var file feedsFile
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(knownFields)
+		if err := decoder.Decode(&file); err != nil && err != io.EOF {
+			return fmt.Errorf("failed to parse feeds file %s: %v", name, err)
+		}
+		config.Feeds = append(config.Feeds, file.Feeds...)
+	}
+
+	return nil
+}
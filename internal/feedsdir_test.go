@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFeedsDir writes a base config with feeds_dir "conf.d" and the given files in it,
+// and returns the path of the base config
+func writeFeedsDir(t *testing.T, base string, files map[string]string) string {
+	t.Helper()
+	path := writeConfigFile(t, base)
+	dir := filepath.Join(filepath.Dir(path), "conf.d")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestLoadConfigMergesFeedsDir(t *testing.T) {
+	path := writeFeedsDir(t, `
+server: ":9090"
+database: "feeds.db"
+feeds_dir: "conf.d"
+feeds:
+  - feed_url: "https://example.com/base.xml"
+    telegram_chat_id: "1"
+`, map[string]string{
+		"20-blogs.yml": `
+feeds:
+  - feed_url: "https://blogs.example.com/feed.xml"
+    telegram_chat_id: "3"
+`,
+		"10-news.yaml": `
+feeds:
+  - feed_url: "https://news.example.com/en.xml"
+    telegram_chat_id: "2"
+  - feed_url: "https://news.example.com/de.xml"
+    telegram_chat_id: "2"
+`,
+		"README.md": "Not a feeds file",
+	})
+
+	cm := NewConfigManager()
+	cm.Path = path
+	if err := cm.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	config := cm.Get()
+	if config.Server != ":9090" || config.Database != "feeds.db" {
+		t.Fatalf("top-level settings not taken from the base file: %+v", config)
+	}
+	var urls []string
+	for _, feed := range config.Feeds {
+		urls = append(urls, feed.FeedUrl)
+	}
+	want := []string{
+		"https://example.com/base.xml",
+		"https://news.example.com/en.xml",
+		"https://news.example.com/de.xml",
+		"https://blogs.example.com/feed.xml",
+	}
+	if !reflect.DeepEqual(urls, want) {
+		t.Fatalf("got feeds %q, want %q", urls, want)
+	}
+}
+
+func TestFeedsDirConfigIsReadOnly(t *testing.T) {
+	path := writeFeedsDir(t, "feeds_dir: conf.d\n", map[string]string{
+		"news.yaml": "feeds:\n  - feed_url: https://news.example.com/feed.xml\n    feed_fetch_interval_minutes: 30\n",
+	})
+	cm := NewConfigManager()
+	cm.Path = path
+	if err := cm.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	err := cm.Update(func(cfg *Config) error {
+		cfg.Feeds[0].FeedFetchIntervalMinutes = 60
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Fatalf("got error %v, want the save rejected", err)
+	}
+	if got := cm.Get().Feeds[0].FeedFetchIntervalMinutes; got != 30 {
+		t.Fatalf("interval changed to %d", got)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "feeds_dir: conf.d\n" {
+		t.Fatalf("base file was rewritten: %q", data)
+	}
+}
+
+func TestFeedsDirProblems(t *testing.T) {
+	path := writeFeedsDir(t, "feeds_dir: conf.d\n", map[string]string{
+		"news.yaml": "feeds:\n  - feed_url: https://news.example.com/feed.xml\n    telegram_tempalte: x\n",
+	})
+	if problems := CheckConfig(path); !strings.Contains(strings.Join(problems, "\n"), "news.yaml") {
+		t.Fatalf("got problems %q, want the unknown key in news.yaml", problems)
+	}
+
+	cm := NewConfigManager()
+	cm.Path = writeConfigFile(t, "feeds_dir: missing\n")
+	if err := cm.LoadConfig(); err == nil || !strings.Contains(err.Error(), "feeds_dir") {
+		t.Fatalf("got error %v for a missing feeds_dir", err)
+	}
+
+	if _, err := feedsDirPath(&Config{FeedsDir: "conf.d"}, "https://example.com/config.yaml"); err == nil {
+		t.Fatal("expected an error for a remote config")
+	}
+	if dir, _ := feedsDirPath(&Config{FeedsDir: "/etc/bot/feeds"}, "/srv/config.yaml"); dir != "/etc/bot/feeds" {
+		t.Fatalf("got %s for an absolute feeds_dir", dir)
+	}
+}
@@ -0,0 +1,286 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html/charset"
+)
+
+// maxFeedBodySize limits how much of a feed response is read
+const maxFeedBodySize = 20 << 20
+
+// feedHTTPClient is used for all feed fetches
+var feedHTTPClient = &http.Client{Timeout: 60 * time.Second}
+
+// xmlEncodingPattern matches the encoding attribute of an XML declaration
+var xmlEncodingPattern = regexp.MustCompile(`^(\s*<\?xml[^>]*?encoding\s*=\s*["'])([A-Za-z0-9._:-]+)(["'])`)
+
+// feedParseError is returned when a feed was downloaded but could not be parsed. It
+// keeps the raw body so diagnostics can show what the server actually returned.
+type feedParseError struct {
+	err  error
+	body []byte
+}
+
+func (e *feedParseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *feedParseError) Unwrap() error {
+	return e.err
+}
+
+// snippet returns at most limit bytes of the raw body, noting how much was cut off
+func (e *feedParseError) snippet(limit int) string {
+	body := e.body
+	suffix := ""
+	if len(body) > limit {
+		body = body[:limit]
+		suffix = fmt.Sprintf("... (%d more bytes)", len(e.body)-limit)
+	}
+	return strings.ToValidUTF8(string(body), "\ufffd") + suffix
+}
+
+// defaultDebugBodyBytes is how much of an unparsable feed body is shown when debugging is enabled
+const defaultDebugBodyBytes = 2048
+
+// describeFetchError returns the error text of a failed fetch. When debug_feed_errors is
+// enabled and the feed could not be parsed, the start of the raw body is appended.
+func describeFetchError(err error, config *Config) string {
+	var parseErr *feedParseError
+	if !config.DebugFeedErrors || !errors.As(err, &parseErr) {
+		return err.Error()
+	}
+
+	limit := config.DebugFeedErrorBytes
+	if limit <= 0 {
+		limit = defaultDebugBodyBytes
+	}
+	return fmt.Sprintf("%v (response body: %q)", err, parseErr.snippet(limit))
+}
+
+// feedFetchAttempts is how many times a fetch is tried before waiting for the next interval
+const feedFetchAttempts = 3
+
+// feedFetchBackoff is the delay before the first retry; it doubles with every attempt
+var feedFetchBackoff = 5 * time.Second
+
+// feedStatusError is returned when the feed server answers with a non-2xx status
+type feedStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *feedStatusError) Error() string {
+	return fmt.Sprintf("failed to fetch feed: unexpected HTTP status %s", e.Status)
+}
+
+// feedNetworkError wraps errors from the HTTP client, before any response was received
+type feedNetworkError struct {
+	err error
+}
+
+func (e *feedNetworkError) Error() string {
+	return fmt.Sprintf("failed to fetch feed: %v", e.err)
+}
+
+func (e *feedNetworkError) Unwrap() error {
+	return e.err
+}
+
+// isTransientFetchError reports whether a failed fetch is worth retrying right away:
+// network errors (DNS, refused connections, timeouts) and 5xx responses are, while 4xx
+// responses and unparsable feeds are not.
+func isTransientFetchError(err error) bool {
+	var statusErr *feedStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	var parseErr *feedParseError
+	if errors.As(err, &parseErr) {
+		return false
+	}
+	var netErr *feedNetworkError
+	return errors.As(err, &netErr)
+}
+
+// fetchFeedWithRetry fetches a feed, retrying transient errors with exponential backoff.
+// It gives up early when the context is cancelled.
+func fetchFeedWithRetry(ctx context.Context, feedURL string, parseTimeout time.Duration) (*gofeed.Feed, error) {
+	body, err := fetchFeedBodyWithRetry(ctx, feedURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseFeedBody(feedURL, body, parseTimeout)
+}
+
+// fetchFeedBodyWithRetry downloads a feed body, retrying transient errors with exponential
+// backoff. It gives up early when the context is cancelled.
+func fetchFeedBodyWithRetry(ctx context.Context, feedURL string) ([]byte, error) {
+	backoff := feedFetchBackoff
+	for attempt := 1; ; attempt++ {
+		body, err := fetchFeedBody(ctx, feedURL)
+		if err == nil || attempt >= feedFetchAttempts || !isTransientFetchError(err) {
+			return body, err
+		}
+
+		log.Printf("Fetching feed %s failed (attempt %d/%d): %v. Retrying in %s...", feedURL, attempt, feedFetchAttempts, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// fetchFeed downloads and parses a feed, converting the body to UTF-8 first
+func fetchFeed(feedURL string) (*gofeed.Feed, error) {
+	return fetchFeedContext(context.Background(), feedURL, defaultParseTimeout)
+}
+
+// fetchFeedContext is fetchFeed with a context that cancels the request and a limit on
+// how long parsing the downloaded body may take
+func fetchFeedContext(ctx context.Context, feedURL string, parseTimeout time.Duration) (*gofeed.Feed, error) {
+	body, err := fetchFeedBody(ctx, feedURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseFeedBody(feedURL, body, parseTimeout)
+}
+
+// fetchFeedBody downloads a feed and returns its body converted to UTF-8
+func fetchFeedBody(ctx context.Context, feedURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", "go-telegram-notifications-bot")
+
+	resp, err := feedHTTPClient.Do(req)
+	if err != nil {
+		return nil, &feedNetworkError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &feedStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed body: %v", err)
+	}
+
+	return convertToUTF8(body, resp.Header.Get("Content-Type"))
+}
+
+// parseFeedBody parses a downloaded feed body, giving up after parseTimeout
+func parseFeedBody(feedURL string, body []byte, parseTimeout time.Duration) (*gofeed.Feed, error) {
+	feed, err := parseFeed(body, parseTimeout)
+	if err != nil {
+		return nil, &feedParseError{err: err, body: body}
+	}
+	if feed == nil {
+		// Treat a missing result like a feed without items so callers never see nil
+		log.Printf("Parser returned no feed for %s, treating it as empty", feedURL)
+		feed = &gofeed.Feed{}
+	}
+	annotateItemLanguages(body, feed)
+
+	return feed, nil
+}
+
+// defaultParseTimeout limits parsing a feed when parse_timeout_seconds is not set
+const defaultParseTimeout = 30 * time.Second
+
+// parseTimeoutFor returns how long parsing a feed may take
+func parseTimeoutFor(feed Feed) time.Duration {
+	if feed.ParseTimeoutSeconds > 0 {
+		return time.Duration(feed.ParseTimeoutSeconds) * time.Second
+	}
+	return defaultParseTimeout
+}
+
+// parseFeed parses a downloaded feed body, giving up after the timeout. The parser can't
+// be interrupted, so a parse that times out finishes in the background; the body size
+// limit bounds how long that takes.
+func parseFeed(body []byte, timeout time.Duration) (*gofeed.Feed, error) {
+	type parseResult struct {
+		feed *gofeed.Feed
+		err  error
+	}
+	done := make(chan parseResult, 1)
+
+	go func() {
+		fp := gofeed.NewParser()
+		fp.RSSTranslator = &ttlRSSTranslator{}
+		feed, err := fp.Parse(bytes.NewReader(body))
+		done <- parseResult{feed: feed, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-done:
+		return result.feed, result.err
+	case <-timer.C:
+		return nil, fmt.Errorf("parsing the feed took longer than %s", timeout)
+	}
+}
+
+// convertToUTF8 transcodes a feed body to UTF-8 using the charset from the Content-Type
+// header or the XML declaration. Bodies without a declared charset that are not valid
+// UTF-8 are treated as Windows-1252, the most common mislabelled encoding.
+func convertToUTF8(body []byte, contentType string) ([]byte, error) {
+	label := ""
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		label = params["charset"]
+	}
+	if label == "" {
+		head := body
+		if len(head) > 1024 {
+			head = head[:1024]
+		}
+		if match := xmlEncodingPattern.FindSubmatch(head); match != nil {
+			label = string(match[2])
+		}
+	}
+	if label == "" && !utf8.Valid(body) {
+		label = "windows-1252"
+	}
+
+	label = strings.ToLower(strings.TrimSpace(label))
+	if label == "" || label == "utf-8" || label == "utf8" {
+		return body, nil
+	}
+
+	reader, err := charset.NewReaderLabel(label, bytes.NewReader(body))
+	if err != nil {
+		// Unknown charset, let the parser deal with the original bytes
+		return body, nil
+	}
+
+	converted, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert feed from %s: %v", label, err)
+	}
+
+	// The body is UTF-8 now, so the XML declaration must say so too
+	converted = xmlEncodingPattern.ReplaceAll(converted, []byte("${1}UTF-8${3}"))
+
+	return converted, nil
+}
@@ -0,0 +1,239 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchFeedDecodesWindows1252(t *testing.T) {
+	body, err := os.ReadFile("testdata/windows-1252.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, contentType := range map[string]string{
+		"declared in XML":    "application/rss+xml",
+		"declared in header": "application/rss+xml; charset=windows-1252",
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", contentType)
+				w.Write(body)
+			}))
+			defer server.Close()
+
+			feed, err := fetchFeed(server.URL)
+			if err != nil {
+				t.Fatalf("fetchFeed: %v", err)
+			}
+			if feed.Title != "Café Crème" {
+				t.Errorf("got feed title %q", feed.Title)
+			}
+			if got, want := feed.Items[0].Title, "Naïve “quotes” – and € prices"; got != want {
+				t.Errorf("got item title %q, want %q", got, want)
+			}
+			if got, want := feed.Items[0].Description, "Smörgåsbord für 5 €"; got != want {
+				t.Errorf("got description %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestConvertToUTF8(t *testing.T) {
+	// Undeclared bytes that aren't valid UTF-8 are read as Windows-1252
+	converted, err := convertToUTF8([]byte("<title>caf\xe9</title>"), "text/xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(converted) != "<title>café</title>" {
+		t.Fatalf("got %q", converted)
+	}
+
+	utf8Body := []byte(`<?xml version="1.0" encoding="UTF-8"?><title>café</title>`)
+	converted, err = convertToUTF8(utf8Body, "application/xml; charset=utf-8")
+	if err != nil || string(converted) != string(utf8Body) {
+		t.Fatalf("UTF-8 body changed to %q (err %v)", converted, err)
+	}
+}
+
+// htmlErrorPage is what a misconfigured server returns in place of a feed
+const htmlErrorPage = "<html><body><h1>502 Bad Gateway</h1></body></html>"
+
+func TestDescribeFetchErrorIncludesBodySnippet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(htmlErrorPage))
+	}))
+	defer server.Close()
+
+	_, err := fetchFeed(server.URL)
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+
+	if message := describeFetchError(err, &Config{}); strings.Contains(message, "Bad Gateway") {
+		t.Fatalf("body shown without debug_feed_errors: %s", message)
+	}
+	message := describeFetchError(err, &Config{DebugFeedErrors: true})
+	if !strings.Contains(message, "502 Bad Gateway") {
+		t.Fatalf("body missing from diagnostic: %s", message)
+	}
+
+	message = describeFetchError(err, &Config{DebugFeedErrors: true, DebugFeedErrorBytes: 6})
+	if want := fmt.Sprintf("<html>... (%d more bytes)", len(htmlErrorPage)-6); !strings.Contains(message, strconv.Quote(want)) {
+		t.Fatalf("got %s, want the snippet cut to %q", message, want)
+	}
+}
+
+func TestFeedStatusShowsBodySnippet(t *testing.T) {
+	server := newFeedServer(t, htmlErrorPage)
+	feed := testFeed(server.URL)
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{feed}, DebugFeedErrors: true})
+
+	fs.runFeed(feed)
+
+	fs.statusMu.Lock()
+	lastError := fs.feedStatus(feed.Key()).LastError
+	fs.statusMu.Unlock()
+	if !strings.Contains(lastError, "502 Bad Gateway") {
+		t.Fatalf("got status error %q, want the body snippet", lastError)
+	}
+}
+
+// shortFetchBackoff makes fetch retries fast for the duration of a test
+func shortFetchBackoff(t *testing.T) {
+	backoff := feedFetchBackoff
+	t.Cleanup(func() { feedFetchBackoff = backoff })
+	feedFetchBackoff = time.Millisecond
+}
+
+// failingFeedServer answers the first failures requests with status and serves the
+// feed afterwards
+func failingFeedServer(t *testing.T, failures int32, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		fmt.Fprint(w, rssFeed(testItem{GUID: "1", Title: "First"}))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestFetchFeedWithRetryRecoversFromTransientErrors(t *testing.T) {
+	shortFetchBackoff(t)
+	server, requests := failingFeedServer(t, 2, http.StatusServiceUnavailable)
+
+	feed, err := fetchFeedWithRetry(context.Background(), server.URL, defaultParseTimeout)
+	if err != nil {
+		t.Fatalf("fetchFeedWithRetry: %v", err)
+	}
+	if len(feed.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(feed.Items))
+	}
+	if n := requests.Load(); n != 3 {
+		t.Fatalf("got %d requests, want 3", n)
+	}
+}
+
+func TestFetchFeedWithRetryGivesUp(t *testing.T) {
+	shortFetchBackoff(t)
+	server, requests := failingFeedServer(t, 10, http.StatusBadGateway)
+
+	if _, err := fetchFeedWithRetry(context.Background(), server.URL, defaultParseTimeout); err == nil {
+		t.Fatal("expected an error")
+	}
+	if n := requests.Load(); n != feedFetchAttempts {
+		t.Fatalf("got %d requests, want %d", n, feedFetchAttempts)
+	}
+}
+
+func TestFetchFeedWithRetrySkipsClientErrors(t *testing.T) {
+	shortFetchBackoff(t)
+	server, requests := failingFeedServer(t, 1, http.StatusNotFound)
+
+	if _, err := fetchFeedWithRetry(context.Background(), server.URL, defaultParseTimeout); err == nil {
+		t.Fatal("expected an error")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("got %d requests, want a 404 not to be retried", n)
+	}
+}
+
+func TestFetchFeedWithRetryStopsOnCancel(t *testing.T) {
+	server, requests := failingFeedServer(t, 10, http.StatusServiceUnavailable)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if _, err := fetchFeedWithRetry(ctx, server.URL, defaultParseTimeout); err == nil {
+		t.Fatal("expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > feedFetchBackoff {
+		t.Fatalf("returned after %v, want no wait for the backoff", elapsed)
+	}
+	if n := requests.Load(); n > 1 {
+		t.Fatalf("got %d requests after cancellation", n)
+	}
+}
+
+func TestParseFeedBodyWithoutItems(t *testing.T) {
+	for name, body := range map[string]string{
+		"rss":  `<?xml version="1.0"?><rss version="2.0"><channel><title>Empty</title></channel></rss>`,
+		"atom": `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><title>Empty</title></feed>`,
+		"json": `{"version":"https://jsonfeed.org/version/1.1","title":"Empty"}`,
+	} {
+		feed, err := parseFeedBody("https://example.com/feed", []byte(body), defaultParseTimeout)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if feed == nil || len(feed.Items) != 0 {
+			t.Errorf("%s: got %+v, want an empty feed", name, feed)
+		}
+	}
+}
+
+// enormousFeed returns an RSS document with many items, which takes a while to parse
+func enormousFeed(items int) string {
+	list := make([]testItem, items)
+	for i := range list {
+		list[i] = testItem{GUID: fmt.Sprint(i), Title: "Item", Description: strings.Repeat("Lorem ipsum dolor sit amet. ", 20)}
+	}
+	return rssFeed(list...)
+}
+
+func TestParseFeedAbortsAtDeadline(t *testing.T) {
+	body := []byte(enormousFeed(20000))
+
+	start := time.Now()
+	_, err := parseFeedBody("https://example.com/feed.xml", body, 10*time.Millisecond)
+	elapsed := time.Since(start)
+
+	if err == nil || !strings.Contains(err.Error(), "parsing the feed took longer than 10ms") {
+		t.Fatalf("got error %v, want the parse deadline", err)
+	}
+	if elapsed > time.Second {
+		t.Fatalf("parse returned after %v, want it to give up at the deadline", elapsed)
+	}
+}
+
+func TestParseTimeoutFor(t *testing.T) {
+	if got := parseTimeoutFor(Feed{}); got != defaultParseTimeout {
+		t.Fatalf("got %v, want the default %v", got, defaultParseTimeout)
+	}
+	if got := parseTimeoutFor(Feed{ParseTimeoutSeconds: 5}); got != 5*time.Second {
+		t.Fatalf("got %v, want 5s", got)
+	}
+}
@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// Formatting modes that can be used in a feed's parse_modes fallback chain
+const (
+	parseModeHTML       = "HTML"
+	parseModeMarkdownV2 = "MarkdownV2"
+	parseModePlain      = "plain"
+)
+
+// markdownV2Special lists the characters that must be escaped in MarkdownV2 text
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+// TelegramAPIError is returned when the Telegram Bot API rejects a request. Description,
+// ErrorCode and the response parameters are filled in from the response body when
+// Telegram sent one.
+type TelegramAPIError struct {
+	StatusCode      int
+	Status          string
+	Description     string
+	ErrorCode       int
+	RetryAfter      int   // seconds to wait before retrying, sent with 429 responses
+	MigrateToChatID int64 // new ID of a group that was upgraded to a supergroup
+}
+
+func (e *TelegramAPIError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("Telegram API error: %s (code: %d)", e.Description, e.ErrorCode)
+	}
+	return fmt.Sprintf("Telegram API returned error: %s", e.Status)
+}
+
+// RateLimitError is returned when Telegram rejects a request for exceeding its flood
+// limits. RetryAfter is how long Telegram asks to wait before the next request.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	err        *TelegramAPIError
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by Telegram, retry after %v: %v", e.RetryAfter, e.err)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.err
+}
+
+// ambiguousSendError is returned when a request to Telegram failed after it may already
+// have been delivered, e.g. when the response was lost to a timeout. Retrying such a
+// request could post the message twice.
+type ambiguousSendError struct {
+	err error
+}
+
+func (e *ambiguousSendError) Error() string {
+	return fmt.Sprintf("message may have been delivered: %v", e.err)
+}
+
+func (e *ambiguousSendError) Unwrap() error {
+	return e.err
+}
+
+// isAmbiguousSendError reports whether a message may have been delivered despite the error
+func isAmbiguousSendError(err error) bool {
+	var ambiguous *ambiguousSendError
+	return errors.As(err, &ambiguous)
+}
+
+// isFormattingError reports whether Telegram rejected a message as a bad request, which
+// is how it reports entities it cannot parse
+func isFormattingError(err error) bool {
+	var apiErr *TelegramAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest
+}
+
+// validateParseModes checks a feed's parse_modes fallback chain
+func validateParseModes(modes []string) error {
+	for _, mode := range modes {
+		switch mode {
+		case parseModeHTML, parseModeMarkdownV2, parseModePlain:
+		default:
+			return fmt.Errorf("unknown parse mode %q (use %s, %s or %s)", mode, parseModeHTML, parseModeMarkdownV2, parseModePlain)
+		}
+	}
+	return nil
+}
+
+// parseModeChain returns the formatting modes to try in order, defaulting to HTML only
+func parseModeChain(modes []string) []string {
+	if len(modes) == 0 {
+		return []string{parseModeHTML}
+	}
+	return modes
+}
+
+// formatMessage converts a message rendered as Telegram HTML to the given mode and
+// returns the text together with the parse_mode to send it with
+func formatMessage(htmlText string, mode string) (string, string) {
+	switch mode {
+	case parseModeMarkdownV2:
+		return htmlToMarkdownV2(htmlText), parseModeMarkdownV2
+	case parseModePlain:
+		return htmlToPlain(htmlText), ""
+	default:
+		return htmlText, parseModeHTML
+	}
+}
+
+// htmlToPlain strips all tags from a Telegram HTML message and decodes its entities
+func htmlToPlain(htmlText string) string {
+	var sb strings.Builder
+	tokenizer := xhtml.NewTokenizer(strings.NewReader(htmlText))
+	for {
+		switch tokenizer.Next() {
+		case xhtml.ErrorToken:
+			return sb.String()
+		case xhtml.TextToken:
+			sb.WriteString(html.UnescapeString(string(tokenizer.Text())))
+		}
+	}
+}
+
+// htmlToMarkdownV2 converts the tags Telegram supports in HTML mode to their MarkdownV2
+// equivalents, escaping everything else
+func htmlToMarkdownV2(htmlText string) string {
+	var sb strings.Builder
+	var links []string
+
+	tokenizer := xhtml.NewTokenizer(strings.NewReader(htmlText))
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case xhtml.ErrorToken:
+			return sb.String()
+		case xhtml.TextToken:
+			sb.WriteString(escapeMarkdownV2(html.UnescapeString(string(tokenizer.Text()))))
+		case xhtml.StartTagToken, xhtml.EndTagToken:
+			name, hasAttr := tokenizer.TagName()
+			closing := tokenType == xhtml.EndTagToken
+			switch string(name) {
+			case "b", "strong":
+				sb.WriteString("*")
+			case "i", "em":
+				sb.WriteString("_")
+			case "u", "ins":
+				sb.WriteString("__")
+			case "s", "strike", "del":
+				sb.WriteString("~")
+			case "code":
+				sb.WriteString("`")
+			case "pre":
+				sb.WriteString("```")
+			case "a":
+				if closing {
+					if len(links) > 0 {
+						href := links[len(links)-1]
+						links = links[:len(links)-1]
+						sb.WriteString("](" + strings.NewReplacer("\\", "\\\\", ")", "\\)").Replace(href) + ")")
+					}
+					continue
+				}
+				href := ""
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = tokenizer.TagAttr()
+					if string(key) == "href" {
+						href = string(val)
+					}
+				}
+				links = append(links, href)
+				sb.WriteString("[")
+			}
+		}
+	}
+}
+
+// escapeMarkdownV2 escapes the characters that have a meaning in MarkdownV2
+func escapeMarkdownV2(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(markdownV2Special, r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
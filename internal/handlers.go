@@ -1,36 +1,45 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
+	"log"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
-	"sync"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/mmcdole/gofeed"
 )
 
-// Global variables for temporary storage with thread safety
-var (
-	tempFeedItems []map[string]interface{}
-	tempFeedInfo  *gofeed.Feed
-	tempFeedMutex sync.RWMutex
-)
+// maxPreviewItems is the number of items shown when previewing a feed
+const maxPreviewItems = 5
 
 // Handlers manages all HTTP handlers
 type Handlers struct {
 	ConfigManager   *ConfigManager
 	TelegramService *TelegramService
 	Scheduler       *FeedScheduler
+	favicons        *faviconCache
 }
 
-// NewHandlers creates a new Handlers instance
+// NewHandlers creates a new Handlers instance. Messages sent from the web interface go
+// through the scheduler's Telegram service, so they share its rate limits.
 func NewHandlers(cm *ConfigManager, scheduler *FeedScheduler) *Handlers {
+	telegram := NewTelegramService(cm)
+	if scheduler != nil {
+		telegram = scheduler.telegram
+	}
+
 	return &Handlers{
 		ConfigManager:   cm,
-		TelegramService: NewTelegramService(cm),
+		TelegramService: telegram,
 		Scheduler:       scheduler,
+		favicons:        newFaviconCache(),
 	}
 }
 
@@ -38,7 +47,7 @@ func NewHandlers(cm *ConfigManager, scheduler *FeedScheduler) *Handlers {
 func (h *Handlers) IndexGetHandler(w http.ResponseWriter, r *http.Request) {
 	urlStr := r.URL.Query().Get("url")
 	if urlStr != "" {
-		h.processFeedPreview(w, urlStr)
+		h.processFeedPreview(w, r, urlStr)
 		return
 	}
 
@@ -137,145 +146,82 @@ func sanitizeFeedData(feed *gofeed.Feed) {
 	}
 }
 
+// renderIndexError shows an error on the index page, or returns it as JSON to API clients
+func renderIndexError(w http.ResponseWriter, r *http.Request, urlStr string, status int, message string) {
+	if wantsJSON(r) {
+		writeError(w, r, status, message)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Error": message,
+		"URL":   urlStr,
+	}
+	tmpl := template.Must(template.ParseFiles("templates/index.html", "templates/partials/navbar.html"))
+	tmpl.Execute(w, data)
+}
+
 // processFeedPreview handles the actual feed preview logic
-func (h *Handlers) processFeedPreview(w http.ResponseWriter, urlStr string) {
+func (h *Handlers) processFeedPreview(w http.ResponseWriter, r *http.Request, urlStr string) {
 	// Validate the URL
 	parsedURL, err := url.ParseRequestURI(urlStr)
 	if err != nil {
-		data := map[string]interface{}{
-			"Error": "Invalid URL format",
-			"URL":   urlStr,
-		}
-		tmpl := template.Must(template.ParseFiles("templates/index.html", "templates/partials/navbar.html"))
-		tmpl.Execute(w, data)
+		renderIndexError(w, r, urlStr, http.StatusBadRequest, "Invalid URL format")
 		return
 	}
 
 	// Check if it's a valid URL scheme
 	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		data := map[string]interface{}{
-			"Error": "URL must use http or https scheme",
-			"URL":   urlStr,
-		}
-		tmpl := template.Must(template.ParseFiles("templates/index.html", "templates/partials/navbar.html"))
-		tmpl.Execute(w, data)
+		renderIndexError(w, r, urlStr, http.StatusBadRequest, "URL must use http or https scheme")
+		return
+	}
+
+	// Only preview feeds from allowed hosts
+	if !isFeedHostAllowed(urlStr, h.ConfigManager.Get().AllowedFeedHosts) {
+		renderIndexError(w, r, urlStr, http.StatusForbidden, "Feeds from this host are not allowed")
 		return
 	}
 
 	// Parse the RSS feed
-	fp := gofeed.NewParser()
-	feed, err := fp.ParseURL(urlStr)
+	feed, err := fetchFeed(urlStr)
 	if err != nil {
-		data := map[string]interface{}{
-			"Error": fmt.Sprintf("Failed to parse feed: %v", err),
-			"URL":   urlStr,
-		}
-		tmpl := template.Must(template.ParseFiles("templates/index.html", "templates/partials/navbar.html"))
-		tmpl.Execute(w, data)
+		renderIndexError(w, r, urlStr, http.StatusBadGateway, fmt.Sprintf("Failed to parse feed: %v", err))
 		return
 	}
+	if feed == nil {
+		feed = &gofeed.Feed{}
+	}
 
-	// Sanitize feed data before passing to template
-	sanitizeFeedData(feed)
-
-	// Limit to first 5 items
-	if len(feed.Items) > 5 {
-		feed.Items = feed.Items[:5]
+	// Limit the number of items shown in the preview
+	if len(feed.Items) > maxPreviewItems {
+		feed.Items = feed.Items[:maxPreviewItems]
 	}
 
-	// Convert feed items to a format suitable for storage and assign indices
-	var itemsForStorage []map[string]interface{}
+	// Build the item context the same way the scheduler does, before the data is sanitized
+	// for the page, so test messages render exactly like scheduled ones
+	itemsForStorage := make([]map[string]interface{}, 0, len(feed.Items))
 	for _, item := range feed.Items {
-		itemMap := map[string]interface{}{
-			"Title":       item.Title,
-			"Description": item.Description,
-			"Content":     item.Content,
-			"Link":        item.Link,
-			"Updated":     item.Updated,
-			"Published":   item.Published,
-			"GUID":        item.GUID,
-		}
-
-		// Add author information if available
-		if item.Author != nil {
-			itemMap["Author"] = map[string]interface{}{
-				"Name":  item.Author.Name,
-				"Email": item.Author.Email,
-			}
-		} else {
-			itemMap["Author"] = nil
-		}
-
-		// Add multiple authors if available
-		if item.Authors != nil {
-			var authorsList []interface{}
-			for _, author := range item.Authors {
-				if author != nil {
-					authorsList = append(authorsList, map[string]interface{}{
-						"Name":  author.Name,
-						"Email": author.Email,
-					})
-				}
-			}
-			itemMap["Authors"] = authorsList
-		}
-
-		// Add categories if available
-		if item.Categories != nil {
-			itemMap["Categories"] = item.Categories
-		}
-
-		// Add image information if available
-		if item.Image != nil {
-			itemMap["Image"] = map[string]interface{}{
-				"URL":   item.Image.URL,
-				"Title": item.Image.Title,
-			}
-		}
-
-		// Add links if available
-		if item.Links != nil {
-			itemMap["Links"] = item.Links
-		}
-
-		// Add updated parsed if available
-		if item.UpdatedParsed != nil {
-			itemMap["UpdatedParsed"] = item.UpdatedParsed.Format("2006-01-02 15:04:05 MST")
-		}
-
-		// Add published parsed if available
-		if item.PublishedParsed != nil {
-			itemMap["PublishedParsed"] = item.PublishedParsed.Format("2006-01-02 15:04:05 MST")
-		}
+		itemsForStorage = append(itemsForStorage, buildItemMap(item, feed))
+	}
 
-		// Add enclosures if available
-		if item.Enclosures != nil {
-			var enclosuresList []interface{}
-			for _, enclosure := range item.Enclosures {
-				if enclosure != nil {
-					enclosuresList = append(enclosuresList, map[string]interface{}{
-						"URL":    enclosure.URL,
-						"Type":   enclosure.Type,
-						"Length": enclosure.Length,
-					})
-				}
+	// Render the items with the template of the configured feed when asked to
+	asScheduled := r.FormValue("as_scheduled") != ""
+	var scheduledFeed *Feed
+	var scheduledMessages []string
+	if asScheduled {
+		if configured, ok := findFeedByURL(h.ConfigManager.Get().Feeds, urlStr); ok {
+			scheduledFeed = &configured
+			for _, itemMap := range itemsForStorage {
+				scheduledMessages = append(scheduledMessages, RenderFeedItem(configured, itemMap))
 			}
-			itemMap["Enclosures"] = enclosuresList
-		}
-
-		// Add custom fields if available
-		if item.Custom != nil {
-			itemMap["Custom"] = item.Custom
 		}
-
-		itemsForStorage = append(itemsForStorage, itemMap)
 	}
 
-	// Store items and feed info in global variable with thread safety
-	tempFeedMutex.Lock()
-	tempFeedItems = itemsForStorage
-	tempFeedInfo = feed
-	tempFeedMutex.Unlock()
+	// Sanitize feed data before passing to template
+	sanitizeFeedData(feed)
+
+	// Keep the items for test sends from this page
+	previewID := previews.add(itemsForStorage, h.ConfigManager.Get().previewCacheSize())
 
 	// Prepare data for template - preserve original feed items for template compatibility
 	// Add index to each original item for the template to use
@@ -305,14 +251,27 @@ func (h *Handlers) processFeedPreview(w http.ResponseWriter, urlStr string) {
 		// Add the index for the form
 		itemWithIndex["Index"] = i
 
+		if i < len(scheduledMessages) {
+			itemWithIndex["Scheduled"] = scheduledMessages[i]
+		}
+
 		itemsWithIndices = append(itemsWithIndices, itemWithIndex)
 	}
 
 	// Prepare data for template
 	data := map[string]interface{}{
-		"Feed":  feed,
-		"Items": itemsWithIndices,
-		"URL":   urlStr,
+		"Feed":        feed,
+		"Items":       itemsWithIndices,
+		"URL":         urlStr,
+		"AsScheduled": asScheduled,
+		"PreviewID":   previewID,
+	}
+	if scheduledFeed != nil {
+		data["ScheduledFeed"] = scheduledFeed.DisplayName()
+	}
+	// Test sends of a feed that posts silently are silent unless unchecked
+	if configured, ok := findFeedByURL(h.ConfigManager.Get().Feeds, urlStr); ok {
+		data["Silent"] = configured.SilentNotifications
 	}
 
 	// Render the index page with the feed data
@@ -320,11 +279,21 @@ func (h *Handlers) processFeedPreview(w http.ResponseWriter, urlStr string) {
 	tmpl.Execute(w, data)
 }
 
+// findFeedByURL returns the configured feed with the given URL
+func findFeedByURL(feeds []Feed, feedURL string) (Feed, bool) {
+	for _, feed := range feeds {
+		if feed.FeedUrl == feedURL {
+			return feed, true
+		}
+	}
+	return Feed{}, false
+}
+
 // IndexPostHandler handles RSS feed preview and test Telegram submissions.
 func (h *Handlers) IndexPostHandler(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
-		http.Error(w, "Error parsing form data", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Error parsing form data")
 		return
 	}
 
@@ -336,35 +305,40 @@ func (h *Handlers) IndexPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	urlStr := r.FormValue("url")
 	if urlStr == "" {
-		data := map[string]interface{}{
-			"Error": "URL is required",
-			"URL":   urlStr,
-		}
-		tmpl := template.Must(template.ParseFiles("templates/index.html", "templates/partials/navbar.html"))
-		tmpl.Execute(w, data)
+		renderIndexError(w, r, urlStr, http.StatusBadRequest, "URL is required")
 		return
 	}
 
-	h.processFeedPreview(w, urlStr)
+	h.processFeedPreview(w, r, urlStr)
 }
 
 // ConfigGetHandler serves the configuration page.
 func (h *Handlers) ConfigGetHandler(w http.ResponseWriter, r *http.Request) {
 	addEmptyFeed := r.URL.Query().Get("add_feed") == "true"
 
-	feeds := h.ConfigManager.Config.Feeds
+	feeds := h.ConfigManager.Get().Feeds
 	if addEmptyFeed {
 		feeds = append(feeds, Feed{})
 	}
 
 	data := map[string]interface{}{
-		"Server":                      h.ConfigManager.Config.Server,
-		"Database":                    h.ConfigManager.Config.Database,
-		"TestTelegramApiToken":        h.ConfigManager.Config.TestTelegramApiToken,
-		"TestTelegramChatId":          h.ConfigManager.Config.TestTelegramChatId,
-		"TestTelegramMessageThreadId": h.ConfigManager.Config.TestTelegramMessageThreadId,
-		"TestTelegramTemplate":        h.ConfigManager.Config.TestTelegramTemplate,
+		"Server":                      h.ConfigManager.Get().Server,
+		"Database":                    h.ConfigManager.Get().Database,
+		"TestTelegramApiToken":        h.ConfigManager.Get().TestTelegramApiToken,
+		"TestTelegramChatId":          h.ConfigManager.Get().TestTelegramChatId,
+		"TestTelegramMessageThreadId": h.ConfigManager.Get().TestTelegramMessageThreadId,
+		"TestTelegramTemplate":        h.ConfigManager.Get().TestTelegramTemplate,
+		"AlertTelegramApiToken":       h.ConfigManager.Get().AlertTelegramApiToken,
+		"AlertTelegramChatId":         h.ConfigManager.Get().AlertTelegramChatId,
+		"AlertFailureThreshold":       h.ConfigManager.Get().AlertFailureThreshold,
+		"AlertTemplate":               h.ConfigManager.Get().AlertTemplate,
 		"Feeds":                       feeds,
+		"Tags":                        allTags(feeds),
+	}
+	if h.ConfigManager.IsRemote() {
+		data["ErrorMessage"] = "The configuration is loaded from " + h.ConfigManager.Path + " and cannot be changed here."
+	} else if h.ConfigManager.Get().FeedsDir != "" {
+		data["ErrorMessage"] = "The configuration includes the feeds in " + h.ConfigManager.Get().FeedsDir + " and cannot be changed here."
 	}
 	tmpl := template.Must(template.ParseFiles("templates/config.html", "templates/partials/navbar.html"))
 	tmpl.Execute(w, data)
@@ -374,10 +348,14 @@ func (h *Handlers) ConfigGetHandler(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) ConfigPostHandler(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
+		if wantsJSON(r) {
+			writeError(w, r, http.StatusBadRequest, "Error parsing form data: "+err.Error())
+			return
+		}
 		data := map[string]interface{}{
-			"Server":       h.ConfigManager.Config.Server,
-			"Database":     h.ConfigManager.Config.Database,
-			"Feeds":        h.ConfigManager.Config.Feeds,
+			"Server":       h.ConfigManager.Get().Server,
+			"Database":     h.ConfigManager.Get().Database,
+			"Feeds":        h.ConfigManager.Get().Feeds,
 			"ErrorMessage": "Error parsing form data: " + err.Error(),
 		}
 		tmpl := template.Must(template.ParseFiles("templates/config.html", "templates/partials/navbar.html"))
@@ -385,34 +363,23 @@ func (h *Handlers) ConfigPostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newConfig := Config{
-		Server:                      r.FormValue("server"),
-		Database:                    r.FormValue("database"),
-		TestTelegramApiToken:        r.FormValue("test_telegram_api_token"),
-		TestTelegramChatId:          0,
-		TestTelegramMessageThreadId: 0,
-		TestTelegramTemplate:        r.FormValue("test_telegram_template"),
-		Feeds:                       []Feed{},
-	}
-
-	if testChatIdStr := r.FormValue("test_telegram_chat_id"); testChatIdStr != "" {
-		if testChatId, err := strconv.ParseInt(testChatIdStr, 10, 64); err == nil {
-			newConfig.TestTelegramChatId = testChatId
-		}
-	}
-
-	if testThreadIdStr := r.FormValue("test_telegram_message_thread_id"); testThreadIdStr != "" {
-		if testThreadId, err := strconv.ParseInt(testThreadIdStr, 10, 64); err == nil {
-			newConfig.TestTelegramMessageThreadId = testThreadId
-		}
-	}
-
-	newConfig.Feeds = processFeedsFromForm(r)
-
-	h.ConfigManager.Config = &newConfig
-
-	err = h.ConfigManager.SaveConfig()
+	// Apply the form on top of the current configuration so settings that are not
+	// exposed in the form are kept. Updates are serialized by the config manager.
+	var newConfig Config
+	var movedFeeds map[string]string
+	err = h.ConfigManager.Update(func(cfg *Config) error {
+		existing := cfg.Feeds
+		applyConfigForm(r, cfg)
+		cfg.assignSharedFeedIDs(false)
+		movedFeeds = movedFeedKeys(r, existing, cfg.Feeds)
+		newConfig = *cfg
+		return nil
+	})
 	if err != nil {
+		if wantsJSON(r) {
+			writeError(w, r, http.StatusBadRequest, "Error saving config: "+err.Error())
+			return
+		}
 		data := map[string]interface{}{
 			"Server":       newConfig.Server,
 			"Database":     newConfig.Database,
@@ -424,6 +391,10 @@ func (h *Handlers) ConfigPostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.FormValue("migrate_feed_urls") != "" && h.Scheduler != nil {
+		h.migrateFeedKeys(movedFeeds, newConfig.Feeds)
+	}
+
 	// Refresh the scheduler with the new configuration
 	if h.Scheduler != nil {
 		h.Scheduler.RefreshConfiguration()
@@ -432,8 +403,109 @@ func (h *Handlers) ConfigPostHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/config", http.StatusSeeOther)
 }
 
+// movedFeedKeys maps the old key of every feed whose key is changed by the config form,
+// because its URL or id changed, to its new key. feeds are the feeds the form was applied
+// to existing with. Feeds are matched by their slot in the existing configuration, not
+// by URL.
+func movedFeedKeys(r *http.Request, existing, feeds []Feed) map[string]string {
+	feedSlots := r.Form["feed_slots"]
+	feedUrls := r.Form["feed_urls"]
+
+	moved := make(map[string]string)
+	next := 0
+	for i, feedURL := range feedUrls {
+		if feedURL == "" {
+			continue
+		}
+		// Rows without a URL are dropped, so the feeds follow the rows that have one
+		feed := next
+		next++
+		if i >= len(feedSlots) || feed >= len(feeds) {
+			continue
+		}
+		idx, err := strconv.Atoi(feedSlots[i])
+		if err != nil || idx < 0 || idx >= len(existing) {
+			continue
+		}
+		if oldKey, newKey := existing[idx].Key(), feeds[feed].Key(); oldKey != newKey {
+			moved[oldKey] = newKey
+		}
+	}
+	return moved
+}
+
+// migrateFeedKeys moves the stored items of feeds whose key changed to the new key.
+// An old key that is still in use by another feed keeps its items.
+func (h *Handlers) migrateFeedKeys(moved map[string]string, feeds []Feed) {
+	for oldKey, newKey := range moved {
+		stillUsed := false
+		for _, feed := range feeds {
+			if feed.Key() == oldKey {
+				stillUsed = true
+				break
+			}
+		}
+		if stillUsed {
+			log.Printf("Not migrating items of %s to %s, the key is still used by a feed", oldKey, newKey)
+			continue
+		}
+
+		migrated, err := h.Scheduler.dbManager.MigrateFeedURL(oldKey, newKey)
+		if err != nil {
+			log.Printf("Error migrating items of %s to %s: %v", oldKey, newKey, err)
+			continue
+		}
+		log.Printf("Migrated %d items from %s to %s", migrated, oldKey, newKey)
+	}
+}
+
+// applyConfigForm updates the configuration with the values submitted in the config form.
+func applyConfigForm(r *http.Request, cfg *Config) {
+	cfg.Server = r.FormValue("server")
+	cfg.Database = r.FormValue("database")
+	cfg.TestTelegramApiToken = r.FormValue("test_telegram_api_token")
+	cfg.TestTelegramChatId = ""
+	cfg.TestTelegramMessageThreadId = 0
+	cfg.TestTelegramTemplate = r.FormValue("test_telegram_template")
+	cfg.AlertTelegramApiToken = r.FormValue("alert_telegram_api_token")
+	cfg.AlertTelegramChatId = ""
+	cfg.AlertFailureThreshold = 0
+	cfg.AlertTemplate = r.FormValue("alert_template")
+
+	if testChatIdStr := r.FormValue("test_telegram_chat_id"); testChatIdStr != "" {
+		if testChatId, err := ParseChatID(testChatIdStr); err == nil {
+			cfg.TestTelegramChatId = testChatId
+		}
+	}
+
+	if testThreadIdStr := r.FormValue("test_telegram_message_thread_id"); testThreadIdStr != "" {
+		if testThreadId, err := strconv.ParseInt(testThreadIdStr, 10, 64); err == nil {
+			cfg.TestTelegramMessageThreadId = testThreadId
+		}
+	}
+
+	if alertChatIdStr := r.FormValue("alert_telegram_chat_id"); alertChatIdStr != "" {
+		if alertChatId, err := ParseChatID(alertChatIdStr); err == nil {
+			cfg.AlertTelegramChatId = alertChatId
+		}
+	}
+
+	if thresholdStr := r.FormValue("alert_failure_threshold"); thresholdStr != "" {
+		if threshold, err := strconv.Atoi(thresholdStr); err == nil {
+			cfg.AlertFailureThreshold = threshold
+		}
+	}
+
+	cfg.Feeds = processFeedsFromForm(r, cfg.Feeds)
+}
+
 // processFeedsFromForm processes the feed configuration from the form data.
-func processFeedsFromForm(r *http.Request) []Feed {
+// Each feed entry carries the slot it had in the existing configuration so that
+// settings which are not exposed in the form are preserved across saves.
+func processFeedsFromForm(r *http.Request, existing []Feed) []Feed {
+	feedSlots := r.Form["feed_slots"]
+	feedIDs := r.Form["feed_ids"]
+	feedNames := r.Form["feed_names"]
 	feedUrls := r.Form["feed_urls"]
 	feedIntervals := r.Form["feed_intervals"]
 	feedRetentionDays := r.Form["feed_retention_days"]
@@ -441,6 +513,12 @@ func processFeedsFromForm(r *http.Request) []Feed {
 	telegramChatIds := r.Form["telegram_chat_ids"]
 	telegramThreadIds := r.Form["telegram_thread_ids"]
 	telegramTemplates := r.Form["telegram_templates"]
+	feedTags := r.Form["feed_tags"]
+	alwaysAppendLink := formCheckboxSlots(r, "always_append_link")
+	disableWebPagePreview := formCheckboxSlots(r, "disable_web_page_preview")
+	silentNotifications := formCheckboxSlots(r, "silent_notifications")
+	feedModes := r.Form["feed_modes"]
+	feedCatchUpItems := r.Form["feed_catch_up_items"]
 
 	var feeds []Feed
 
@@ -460,9 +538,9 @@ func processFeedsFromForm(r *http.Request) []Feed {
 				}
 			}
 
-			chatId := int64(0)
+			chatId := ChatID("")
 			if i < len(telegramChatIds) && telegramChatIds[i] != "" {
-				if val, err := strconv.ParseInt(telegramChatIds[i], 10, 64); err == nil {
+				if val, err := ParseChatID(telegramChatIds[i]); err == nil {
 					chatId = val
 				}
 			}
@@ -474,22 +552,58 @@ func processFeedsFromForm(r *http.Request) []Feed {
 				}
 			}
 
-			feed := Feed{
-				FeedUrl:                  feedUrls[i],
-				FeedFetchIntervalMinutes: interval,
-				FeedRetentionDays:        retentionDays,
-				TelegramApiToken:         "",
-				TelegramChatId:           chatId,
-				TelegramMessageThreadId:  threadId,
-				TelegramTemplate:         "",
+			feed := Feed{}
+			slot := ""
+			if i < len(feedSlots) {
+				slot = feedSlots[i]
+				if idx, err := strconv.Atoi(slot); err == nil && idx >= 0 && idx < len(existing) {
+					feed = existing[idx]
+				}
 			}
 
+			// Keep auto_interval feeds without an explicit interval on the feed's own hints
+			if feed.AutoInterval && feed.FeedFetchIntervalMinutes == 0 && (i >= len(feedIntervals) || feedIntervals[i] == "" || feedIntervals[i] == "0") {
+				interval = 0
+			}
+
+			feed.FeedUrl = feedUrls[i]
+			feed.FeedFetchIntervalMinutes = interval
+			feed.FeedRetentionDays = retentionDays
+			feed.TelegramApiToken = ""
+			feed.TelegramChatId = chatId
+			feed.TelegramMessageThreadId = threadId
+			feed.TelegramTemplate = ""
+			feed.AlwaysAppendLink = alwaysAppendLink[slot]
+			feed.DisableWebPagePreview = disableWebPagePreview[slot]
+			feed.SilentNotifications = silentNotifications[slot]
+
+			if i < len(feedIDs) {
+				feed.ID = strings.TrimSpace(feedIDs[i])
+			}
+			if i < len(feedNames) {
+				feed.Name = feedNames[i]
+			}
+			if i < len(feedTags) {
+				feed.Tags = parseTags(feedTags[i])
+			}
 			if i < len(telegramTokens) {
 				feed.TelegramApiToken = telegramTokens[i]
 			}
 			if i < len(telegramTemplates) {
 				feed.TelegramTemplate = telegramTemplates[i]
 			}
+			if i < len(feedModes) {
+				feed.Mode = feedModes[i]
+				if feed.Mode == feedModeAll {
+					feed.Mode = ""
+				}
+			}
+			if i < len(feedCatchUpItems) {
+				feed.CatchUpItems = 0
+				if val, err := strconv.Atoi(feedCatchUpItems[i]); err == nil {
+					feed.CatchUpItems = val
+				}
+			}
 
 			feeds = append(feeds, feed)
 		}
@@ -497,3 +611,470 @@ func processFeedsFromForm(r *http.Request) []Feed {
 
 	return feeds
 }
+
+// parseTags splits a comma-separated tag list, dropping empty and duplicate tags
+func parseTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || (Feed{Tags: tags}).HasTag(tag) {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// allTags returns the distinct tags used by the feeds, sorted
+func allTags(feeds []Feed) []string {
+	seen := map[string]bool{}
+	var tags []string
+	for _, feed := range feeds {
+		for _, tag := range feed.Tags {
+			if !seen[strings.ToLower(tag)] {
+				seen[strings.ToLower(tag)] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// formCheckboxSlots returns the feed slots whose checkbox with the given name was checked.
+// Checkboxes are only submitted when checked, so each one carries its feed slot as value.
+func formCheckboxSlots(r *http.Request, name string) map[string]bool {
+	checked := make(map[string]bool)
+	for _, slot := range r.Form[name] {
+		checked[slot] = true
+	}
+	return checked
+}
+
+// FeedSendLatestHandler posts the most recent items of a configured feed on demand.
+func (h *Handlers) FeedSendLatestHandler(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(chi.URLParam(r, "index"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid feed index")
+		return
+	}
+
+	n := 5
+	if nStr := r.URL.Query().Get("n"); nStr != "" {
+		n, err = strconv.Atoi(nStr)
+		if err != nil || n < 1 {
+			writeError(w, r, http.StatusBadRequest, "Invalid item count")
+			return
+		}
+	}
+
+	sent, err := h.Scheduler.SendLatest(index, n)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, fmt.Sprintf("Error sending latest items (%d sent): %v", sent, err))
+		return
+	}
+
+	log.Printf("Sent %d latest items for feed %d on request", sent, index)
+	http.Redirect(w, r, "/config", http.StatusSeeOther)
+}
+
+// StatusGetHandler serves the feed status page.
+func (h *Handlers) StatusGetHandler(w http.ResponseWriter, r *http.Request) {
+	statuses := map[string]FeedStatus{}
+	if h.Scheduler != nil {
+		statuses = h.Scheduler.Status()
+	}
+
+	tag := r.URL.Query().Get("tag")
+
+	var feeds []map[string]interface{}
+	for i, feed := range h.ConfigManager.Get().Feeds {
+		if tag != "" && !feed.HasTag(tag) {
+			continue
+		}
+		status := statuses[feed.Key()]
+		row := map[string]interface{}{
+			"Index":               i,
+			"Name":                feed.DisplayName(),
+			"URL":                 feed.FeedUrl,
+			"Tags":                feed.Tags,
+			"Interval":            feed.FeedFetchIntervalMinutes,
+			"LastFetch":           "",
+			"LastError":           status.LastError,
+			"ConsecutiveFailures": status.ConsecutiveFailures,
+			"LastNewItem":         "",
+			"Stale":               status.Stale,
+			"Stuck":               status.Stuck,
+			"RunningSince":        "",
+		}
+		row["NextFetch"] = ""
+		if h.Scheduler != nil {
+			if next, ok := h.Scheduler.NextTick(feed.Key()); ok {
+				row["NextFetch"] = formatUntil(next)
+			}
+		}
+		if !status.FetchStartedAt.IsZero() {
+			row["RunningSince"] = status.FetchStartedAt.Format("2006-01-02 15:04:05 MST")
+		}
+		if !status.LastFetch.IsZero() {
+			row["LastFetch"] = status.LastFetch.Format("2006-01-02 15:04:05 MST")
+		}
+		if !status.LastNewItem.IsZero() {
+			row["LastNewItem"] = status.LastNewItem.Format("2006-01-02 15:04:05 MST")
+		}
+		feeds = append(feeds, row)
+	}
+
+	data := map[string]interface{}{
+		"Feeds":        feeds,
+		"Tags":         allTags(h.ConfigManager.Get().Feeds),
+		"Tag":          tag,
+		"Paused":       h.ConfigManager.Get().Paused,
+		"ShowFavicons": h.ConfigManager.Get().ShowFavicons,
+	}
+	tmpl := template.Must(template.ParseFiles("templates/status.html", "templates/partials/navbar.html"))
+	tmpl.Execute(w, data)
+}
+
+// FeedFaviconHandler serves the cached favicon of a feed's site, or a default icon.
+func (h *Handlers) FeedFaviconHandler(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(chi.URLParam(r, "index"))
+	if err != nil || index < 0 || index >= len(h.ConfigManager.Get().Feeds) {
+		http.NotFound(w, r)
+		return
+	}
+	feed := h.ConfigManager.Get().Feeds[index]
+
+	siteLink := ""
+	if h.Scheduler != nil {
+		siteLink = h.Scheduler.Status()[feed.Key()].SiteLink
+	}
+
+	body, contentType := h.favicons.get(r.Context(), faviconURL(siteLink, feed.FeedUrl))
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "max-age=3600")
+	w.Write(body)
+}
+
+// formatUntil describes how long until t, e.g. "in 7m"
+func formatUntil(t time.Time) string {
+	d := time.Until(t).Round(time.Minute)
+	if d < time.Minute {
+		return "in less than a minute"
+	}
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	if hours > 0 {
+		return fmt.Sprintf("in %dh %dm", hours, minutes)
+	}
+	return fmt.Sprintf("in %dm", minutes)
+}
+
+// HealthzHandler reports whether the scheduler is healthy. It returns 503 when the
+// watchdog has flagged a stuck fetch.
+func (h *Handlers) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	stuck := []string{}
+	if h.Scheduler != nil {
+		stuck = append(stuck, h.Scheduler.StuckFeeds()...)
+	}
+
+	paused := h.ConfigManager.Get().Paused
+
+	if len(stuck) > 0 {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status":      "stuck",
+			"stuck_feeds": stuck,
+			"paused":      paused,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":      "ok",
+		"stuck_feeds": stuck,
+		"paused":      paused,
+	})
+}
+
+// PauseHandler turns on the global kill switch that stops all posting.
+func (h *Handlers) PauseHandler(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, true)
+}
+
+// ResumeHandler turns off the global kill switch.
+func (h *Handlers) ResumeHandler(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, false)
+}
+
+// setPaused updates the kill switch and responds with JSON or a redirect to the status page
+func (h *Handlers) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	err := h.Scheduler.SetPaused(paused)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Error updating pause state: "+err.Error())
+		return
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"paused": paused})
+		return
+	}
+	http.Redirect(w, r, "/status", http.StatusSeeOther)
+}
+
+// APIStatusHandler returns the runtime state of every feed as JSON.
+func (h *Handlers) APIStatusHandler(w http.ResponseWriter, r *http.Request) {
+	feeds := []FeedRuntimeStatus{}
+	if h.Scheduler != nil {
+		feeds = h.Scheduler.RuntimeStatus()
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"feeds": feeds,
+	})
+}
+
+// FeedPlanHandler returns a dry-run of what the scheduler would send for a feed.
+func (h *Handlers) FeedPlanHandler(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(chi.URLParam(r, "index"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid feed index")
+		return
+	}
+
+	plan, err := h.Scheduler.Plan(index)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Error planning feed: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, plan)
+}
+
+// FeedItemHandler renders a single item of a feed, looked up by GUID, without sending it.
+func (h *Handlers) FeedItemHandler(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(chi.URLParam(r, "index"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid feed index")
+		return
+	}
+
+	guid := r.URL.Query().Get("guid")
+	if guid == "" {
+		writeError(w, r, http.StatusBadRequest, "guid is required")
+		return
+	}
+
+	item, err := h.Scheduler.RenderItem(index, guid)
+	if errors.Is(err, ErrItemNotFound) {
+		writeError(w, r, http.StatusNotFound, "No item with GUID "+guid+" in feed")
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Error rendering item: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, item)
+}
+
+// FeedSendSampleHandler renders the configured sample item with a feed's template and
+// sends it to the test chat, or to the feed's own chat with to_feed_chat=true. No feed is
+// fetched, so it works as a smoke test without network access to the feeds.
+func (h *Handlers) FeedSendSampleHandler(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(chi.URLParam(r, "index"))
+	if err != nil || index < 0 || index >= len(h.ConfigManager.Get().Feeds) {
+		writeError(w, r, http.StatusBadRequest, "Invalid feed index")
+		return
+	}
+	cfg := h.ConfigManager.Get()
+	feed := cfg.Feeds[index]
+
+	sample, err := cfg.sampleItemFor(feed)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if r.FormValue("to_feed_chat") != "true" {
+		if cfg.TestTelegramApiToken == "" || cfg.TestTelegramChatId.IsZero() {
+			writeError(w, r, http.StatusBadRequest, "Test Telegram token and chat ID are not configured")
+			return
+		}
+		feed.TelegramApiToken = cfg.TestTelegramApiToken
+		feed.TelegramChatId = cfg.TestTelegramChatId
+		feed.TelegramMessageThreadId = cfg.TestTelegramMessageThreadId
+	}
+
+	item := sample.itemMap(feed)
+	messageID, err := h.TelegramService.SendFeedItemToTelegram(feed, item)
+	if err != nil {
+		writeError(w, r, http.StatusBadGateway, "Error sending to Telegram: "+err.Error())
+		return
+	}
+
+	log.Printf("Sent sample item of feed %d to chat %s", index, feed.TelegramChatId)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message":    RenderFeedItem(feed, item),
+		"message_id": messageID,
+	})
+}
+
+// PossiblySentHandler lists the items whose delivery could not be confirmed.
+func (h *Handlers) PossiblySentHandler(w http.ResponseWriter, r *http.Request) {
+	items, err := h.Scheduler.dbManager.PossiblySentItems()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Error loading items: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+	})
+}
+
+// ConfirmPossiblySentHandler marks a possibly sent item as delivered.
+func (h *Handlers) ConfirmPossiblySentHandler(w http.ResponseWriter, r *http.Request) {
+	h.resolvePossiblySent(w, r, h.Scheduler.dbManager.ConfirmPossiblySentItem)
+}
+
+// ResendPossiblySentHandler forgets a possibly sent item so the next fetch sends it again.
+func (h *Handlers) ResendPossiblySentHandler(w http.ResponseWriter, r *http.Request) {
+	h.resolvePossiblySent(w, r, func(id int64) (bool, error) {
+		found, err := h.Scheduler.dbManager.ForgetPossiblySentItem(id)
+		if found {
+			// The feed may not change again, so its next body must not be skipped
+			h.Scheduler.bodies.reset()
+		}
+		return found, err
+	})
+}
+
+// resolvePossiblySent applies a resolution to the possibly sent item named in the URL
+func (h *Handlers) resolvePossiblySent(w http.ResponseWriter, r *http.Request, resolve func(int64) (bool, error)) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid item ID")
+		return
+	}
+
+	found, err := resolve(id)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Error resolving item: "+err.Error())
+		return
+	}
+	if !found {
+		writeError(w, r, http.StatusNotFound, "No possibly sent item with this ID")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"id": id})
+}
+
+// TokenRotateHandler replaces a Telegram bot token in every feed (and the test and alert
+// settings) that uses it, after checking that the new token works.
+func (h *Handlers) TokenRotateHandler(w http.ResponseWriter, r *http.Request) {
+	oldToken := strings.TrimSpace(r.FormValue("old_token"))
+	newToken := strings.TrimSpace(r.FormValue("new_token"))
+	if oldToken == "" || newToken == "" {
+		writeError(w, r, http.StatusBadRequest, "old_token and new_token are required")
+		return
+	}
+
+	err := h.TelegramService.Client.GetMe(newToken)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "New token was rejected by Telegram: "+err.Error())
+		return
+	}
+
+	updated := 0
+	err = h.ConfigManager.Update(func(cfg *Config) error {
+		updated = cfg.replaceToken(oldToken, newToken)
+		return nil
+	})
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Error saving config: "+err.Error())
+		return
+	}
+
+	if h.Scheduler != nil {
+		h.Scheduler.RefreshConfiguration()
+	}
+
+	log.Printf("Rotated Telegram token in %d feeds", updated)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"feeds_updated": updated,
+	})
+}
+
+// TagPauseHandler pauses every feed with the given tag.
+func (h *Handlers) TagPauseHandler(w http.ResponseWriter, r *http.Request) {
+	h.updateFeedsWithTag(w, r, "Paused feeds with tag %s", func(feed *Feed) { feed.Paused = true })
+}
+
+// TagResumeHandler resumes every feed with the given tag.
+func (h *Handlers) TagResumeHandler(w http.ResponseWriter, r *http.Request) {
+	h.updateFeedsWithTag(w, r, "Resumed feeds with tag %s", func(feed *Feed) { feed.Paused = false })
+}
+
+// TagIntervalHandler sets the fetch interval of every feed with the given tag.
+func (h *Handlers) TagIntervalHandler(w http.ResponseWriter, r *http.Request) {
+	minutes, err := strconv.Atoi(strings.TrimSpace(r.FormValue("interval_minutes")))
+	if err != nil || minutes <= 0 {
+		writeError(w, r, http.StatusBadRequest, "interval_minutes must be a positive number")
+		return
+	}
+
+	event := "Set the fetch interval of feeds with tag %s to " + strconv.Itoa(minutes) + " minutes"
+	h.updateFeedsWithTag(w, r, event, func(feed *Feed) { feed.FeedFetchIntervalMinutes = minutes })
+}
+
+// updateFeedsWithTag applies a change to every feed with the tag in the URL, saves the
+// configuration and restarts the scheduler. It responds with JSON or a redirect to the
+// status page filtered by the tag. The event, formatted with the tag, is posted to the
+// status chat.
+func (h *Handlers) updateFeedsWithTag(w http.ResponseWriter, r *http.Request, event string, fn func(feed *Feed)) {
+	tag := chi.URLParam(r, "tag")
+
+	updated := 0
+	err := h.ConfigManager.Update(func(cfg *Config) error {
+		updated = cfg.updateFeedsWithTag(tag, fn)
+		if updated == 0 {
+			return errNoFeedsWithTag
+		}
+		return nil
+	})
+	if errors.Is(err, errNoFeedsWithTag) {
+		writeError(w, r, http.StatusNotFound, "No feeds with tag "+tag)
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Error saving config: "+err.Error())
+		return
+	}
+
+	if h.Scheduler != nil {
+		h.Scheduler.RefreshConfiguration()
+		h.Scheduler.PostStatusEvent(fmt.Sprintf(event, tag))
+	}
+
+	log.Printf("Updated %d feeds with tag %s", updated, tag)
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"tag":           tag,
+			"feeds_updated": updated,
+		})
+		return
+	}
+	http.Redirect(w, r, "/status?tag="+url.QueryEscape(tag), http.StatusSeeOther)
+}
+
+// requirePprof hides the profiling endpoints unless enable_pprof is set, so that they
+// can be switched on for a diagnosis without restarting the bot
+func (h *Handlers) requirePprof(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.ConfigManager.Get().EnablePprof {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,322 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// formRequest builds a POST request with a parsed urlencoded form body
+func formRequest(t *testing.T, target string, form url.Values) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := req.ParseForm(); err != nil {
+		t.Fatalf("parsing form: %v", err)
+	}
+	return req
+}
+
+func TestParseTags(t *testing.T) {
+	got := parseTags(" news, Tech ,,news, tech, rust ")
+	if want := []string{"news", "Tech", "rust"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestFeedTagsRoundTrip(t *testing.T) {
+	cm := newTestConfigManager(t, &Config{})
+	req := formRequest(t, "/config", url.Values{
+		"feed_slots":     {"new"},
+		"feed_urls":      {"https://example.com/feed.xml"},
+		"feed_intervals": {"30"},
+		"feed_tags":      {"news, tech"},
+	})
+
+	err := cm.Update(func(cfg *Config) error {
+		cfg.Feeds = processFeedsFromForm(req, cfg.Feeds)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	loaded := NewConfigManager()
+	loaded.Path = cm.Path
+	if err := loaded.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if got := loaded.Get().Feeds[0].Tags; !reflect.DeepEqual(got, []string{"news", "tech"}) {
+		t.Fatalf("got tags %q after reloading", got)
+	}
+}
+
+func TestStatusFilterByTag(t *testing.T) {
+	// The status page is rendered from the templates directory at the repository root
+	t.Chdir("..")
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{
+		{FeedUrl: "https://news.example.com/feed.xml", Tags: []string{"News"}, FeedFetchIntervalMinutes: 30},
+		{FeedUrl: "https://blog.example.com/feed.xml", Tags: []string{"blogs"}, FeedFetchIntervalMinutes: 30},
+	}})
+	router := newTestRouter(fs)
+
+	page := serve(router, http.MethodGet, "/status?tag=news", "").Body.String()
+	if !strings.Contains(page, "news.example.com") || strings.Contains(page, "blog.example.com/feed.xml") {
+		t.Fatalf("tag filter not applied:\n%s", page)
+	}
+
+	page = serve(router, http.MethodGet, "/status", "").Body.String()
+	if !strings.Contains(page, "news.example.com") || !strings.Contains(page, "blog.example.com/feed.xml") {
+		t.Fatalf("unfiltered status is missing feeds:\n%s", page)
+	}
+}
+
+func TestPreviewAsScheduledUsesFeedTemplate(t *testing.T) {
+	// The index page is rendered from the templates directory at the repository root
+	t.Chdir("..")
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	feed.TelegramTemplate = "Scheduled: {{.Title}}"
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	router := newTestRouter(fs)
+
+	page := serve(router, http.MethodPost, "/", url.Values{"url": {server.URL}, "as_scheduled": {"1"}}.Encode()).Body.String()
+	if !strings.Contains(page, "Scheduled: First") {
+		t.Fatalf("preview does not show the feed's template:\n%s", page)
+	}
+
+	page = serve(router, http.MethodPost, "/", url.Values{"url": {server.URL}}.Encode()).Body.String()
+	if strings.Contains(page, "Scheduled: First") {
+		t.Fatal("feed template used without as_scheduled")
+	}
+}
+
+func TestPreviewTestSendMatchesScheduler(t *testing.T) {
+	// The index page is rendered from the templates directory at the repository root
+	t.Chdir("..")
+	template := "{{.Title}}|{{.Link}}|{{.Description}}|{{.Published}}|{{.PublishedParsed}}|{{.Categories}}|{{.FeedTitle}}|{{.FeedLink}}"
+	server := newFeedServer(t, rssFeed(testItem{
+		GUID:        "1",
+		Title:       "Fish & chips",
+		Link:        "https://example.com/posts/1",
+		Description: `<p>Crispy <b>and</b> <a href="https://example.com">hot</a></p>`,
+		Published:   time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		Categories:  []string{"food", "uk"},
+	}))
+	feed := testFeed(server.URL)
+	feed.TelegramTemplate = template
+	fs, recorder := newTestScheduler(t, &Config{
+		Feeds:                []Feed{feed},
+		TestTelegramApiToken: "123:test",
+		TestTelegramChatId:   "200",
+		TestTelegramTemplate: template,
+	})
+	router := newTestRouter(fs)
+
+	// Preview the feed, then send its first item as a test message
+	page := serve(router, http.MethodPost, "/", url.Values{"url": {server.URL}}.Encode()).Body.String()
+	match := regexp.MustCompile(`name="preview_id" value="([^"]+)"`).FindStringSubmatch(page)
+	if match == nil {
+		t.Fatal("preview page has no preview_id")
+	}
+	rec := serve(router, http.MethodPost, "/", url.Values{"item_index": {"0"}, "preview_id": {match[1]}, "feed_url": {server.URL}}.Encode())
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("test send failed with %d: %s", rec.Code, rec.Body.String())
+	}
+
+	fs.runFeed(feed)
+
+	texts := sentTexts(recorder)
+	if len(texts) != 2 {
+		t.Fatalf("got messages %q, want the test message and the scheduled one", texts)
+	}
+	if texts[0] != texts[1] {
+		t.Fatalf("preview and scheduler render differently:\npreview   %q\nscheduler %q", texts[0], texts[1])
+	}
+}
+
+func TestPreviewFeedWithoutItems(t *testing.T) {
+	// The index page is rendered from the templates directory at the repository root
+	t.Chdir("..")
+	server := newFeedServer(t, rssFeed())
+	fs, _ := newTestScheduler(t, &Config{})
+
+	rec := serve(newTestRouter(fs), http.MethodPost, "/", url.Values{"url": {server.URL}}.Encode())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("preview failed with %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "This feed has no items.") {
+		t.Fatalf("preview does not say the feed is empty:\n%s", rec.Body.String())
+	}
+}
+
+func TestTokenRotateUpdatesFeedsUsingOldToken(t *testing.T) {
+	first, other, second := testFeed("https://a.example.com/feed.xml"), testFeed("https://b.example.com/feed.xml"), testFeed("https://c.example.com/feed.xml")
+	first.TelegramApiToken, other.TelegramApiToken, second.TelegramApiToken = "1:old", "2:other", "1:old"
+	fs, recorder := newTestScheduler(t, &Config{
+		Feeds:                 []Feed{first, other, second},
+		TestTelegramApiToken:  "1:old",
+		AlertTelegramApiToken: "2:other",
+		SkipInitialFetch:      true, // The rotation restarts the scheduler
+	})
+
+	rec := serve(newTestRouter(fs), http.MethodPost, "/tokens/rotate", url.Values{"old_token": {"1:old"}, "new_token": {"1:new"}}.Encode())
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"feeds_updated":2`) {
+		t.Fatalf("rotate failed with %d: %s", rec.Code, rec.Body.String())
+	}
+	if calls := recorder.callsTo("getMe"); len(calls) != 1 || calls[0].Token != "1:new" {
+		t.Fatalf("new token not checked with getMe: %v", recorder.Calls())
+	}
+
+	// The rotated tokens are saved
+	loaded := NewConfigManager()
+	loaded.Path = fs.configManager.Path
+	if err := loaded.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	cfg := loaded.Get()
+	var tokens []string
+	for _, feed := range cfg.Feeds {
+		tokens = append(tokens, feed.TelegramApiToken)
+	}
+	if want := []string{"1:new", "2:other", "1:new"}; !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("got feed tokens %q, want %q", tokens, want)
+	}
+	if cfg.TestTelegramApiToken != "1:new" || cfg.AlertTelegramApiToken != "2:other" {
+		t.Fatalf("got test token %q and alert token %q", cfg.TestTelegramApiToken, cfg.AlertTelegramApiToken)
+	}
+}
+
+func TestTokenRotateRejectsInvalidToken(t *testing.T) {
+	feed := testFeed("https://a.example.com/feed.xml")
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		return http.StatusUnauthorized, telegramError(401, "Unauthorized")
+	})
+
+	rec := serve(newTestRouter(fs), http.MethodPost, "/tokens/rotate", url.Values{"old_token": {feed.TelegramApiToken}, "new_token": {"1:bad"}}.Encode())
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d, want 400: %s", rec.Code, rec.Body.String())
+	}
+	if token := fs.configManager.Get().Feeds[0].TelegramApiToken; token != feed.TelegramApiToken {
+		t.Fatalf("token changed to %q despite the rejection", token)
+	}
+
+	rec = serve(newTestRouter(fs), http.MethodPost, "/tokens/rotate", url.Values{"old_token": {feed.TelegramApiToken}}.Encode())
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d without a new token, want 400", rec.Code)
+	}
+}
+
+// runningTickers returns the keys of the feeds whose tickers are running
+func runningTickers(fs *FeedScheduler) []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	var keys []string
+	for key := range fs.tickers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestPauseAndResumeByTag(t *testing.T) {
+	var feeds []Feed
+	for i, tags := range [][]string{{"experimental"}, {"news"}, {"Experimental", "news"}, nil, {"experimental"}} {
+		feed := testFeed(fmt.Sprintf("https://feed%d.example.com/feed.xml", i))
+		feed.Tags = tags
+		feeds = append(feeds, feed)
+	}
+	fs, _ := newTestScheduler(t, &Config{Feeds: feeds, SkipInitialFetch: true})
+	fs.Start()
+	router := newTestRouter(fs)
+
+	rec := postJSON(router, "/tags/experimental/pause")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"feeds_updated":3`) {
+		t.Fatalf("pause failed with %d: %s", rec.Code, rec.Body.String())
+	}
+	want := []string{feeds[1].Key(), feeds[3].Key()}
+	sort.Strings(want)
+	if got := runningTickers(fs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got tickers %q after pausing, want %q", got, want)
+	}
+	for i, feed := range fs.configManager.Get().Feeds {
+		if paused := i == 0 || i == 2 || i == 4; feed.Paused != paused {
+			t.Errorf("feed %d paused %v, want %v", i, feed.Paused, paused)
+		}
+	}
+
+	if rec := postJSON(router, "/tags/experimental/resume"); rec.Code != http.StatusOK {
+		t.Fatalf("resume failed with %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := runningTickers(fs); len(got) != 5 {
+		t.Fatalf("got tickers %q after resuming, want all five", got)
+	}
+
+	if rec := postJSON(router, "/tags/unknown/pause"); rec.Code != http.StatusNotFound {
+		t.Fatalf("got status %d for an unknown tag, want 404", rec.Code)
+	}
+}
+
+func TestIntervalByTag(t *testing.T) {
+	tagged, other := testFeed("https://a.example.com/feed.xml"), testFeed("https://b.example.com/feed.xml")
+	tagged.Tags = []string{"slow"}
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{tagged, other}, SkipInitialFetch: true})
+	router := newTestRouter(fs)
+
+	setInterval := func(minutes string) *httptest.ResponseRecorder {
+		req := formRequest(t, "/tags/slow/interval", url.Values{"interval_minutes": {minutes}})
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := setInterval("240"); rec.Code != http.StatusOK {
+		t.Fatalf("interval change failed with %d: %s", rec.Code, rec.Body.String())
+	}
+	feeds := fs.configManager.Get().Feeds
+	if feeds[0].FeedFetchIntervalMinutes != 240 || feeds[1].FeedFetchIntervalMinutes != 60 {
+		t.Fatalf("got intervals %d and %d, want 240 and 60", feeds[0].FeedFetchIntervalMinutes, feeds[1].FeedFetchIntervalMinutes)
+	}
+
+	if rec := setInterval("0"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d for a zero interval, want 400", rec.Code)
+	}
+}
+
+func TestPprofRoutesFollowConfig(t *testing.T) {
+	fs, _ := newTestScheduler(t, &Config{})
+	router := newTestRouter(fs)
+	targets := []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/pprof/cmdline"}
+
+	for _, target := range targets {
+		if rec := serve(router, http.MethodGet, target, ""); rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: got status %d while disabled, want 404", target, rec.Code)
+		}
+	}
+
+	// Enabled without a restart
+	err := fs.configManager.Update(func(cfg *Config) error {
+		cfg.EnablePprof = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range targets {
+		if rec := serve(router, http.MethodGet, target, ""); rec.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d while enabled, want 200", target, rec.Code)
+		}
+	}
+	if rec := serve(router, http.MethodGet, "/debug/pprof/goroutine?debug=1", ""); !strings.Contains(rec.Body.String(), "goroutine profile") {
+		t.Fatalf("unexpected goroutine profile %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Ways {{.Categories}} can be rendered
+const (
+	categoryFormatPlain    = "plain"
+	categoryFormatHashtags = "hashtags"
+)
+
+// How the words of a multi-word category are joined into one hashtag
+const (
+	hashtagWordsCamel      = "camel"      // "Go Programming" becomes #GoProgramming
+	hashtagWordsUnderscore = "underscore" // "Go Programming" becomes #Go_Programming
+)
+
+// Default separators between the rendered categories
+const (
+	defaultCategoriesSeparator = ", "
+	defaultHashtagsSeparator   = " "
+)
+
+// validateCategoryFormat checks a feed's category_format and hashtag_words options
+func validateCategoryFormat(feed Feed) error {
+	switch feed.CategoryFormat {
+	case "", categoryFormatPlain, categoryFormatHashtags:
+	default:
+		return fmt.Errorf("unknown category_format %q (use %q or %q)", feed.CategoryFormat, categoryFormatPlain, categoryFormatHashtags)
+	}
+	switch feed.HashtagWords {
+	case "", hashtagWordsCamel, hashtagWordsUnderscore:
+	default:
+		return fmt.Errorf("unknown hashtag_words %q (use %q or %q)", feed.HashtagWords, hashtagWordsCamel, hashtagWordsUnderscore)
+	}
+	return nil
+}
+
+// formatCategories renders the categories of an item for {{.Categories}}, either as they
+// are or as hashtags, joined with separator
+func formatCategories(item map[string]interface{}, format, words, separator string) string {
+	if format != categoryFormatHashtags {
+		if separator == "" {
+			separator = defaultCategoriesSeparator
+		}
+		return extractStringList(item, "Categories", separator)
+	}
+	if separator == "" {
+		separator = defaultHashtagsSeparator
+	}
+
+	var hashtags []string
+	for _, category := range strings.Split(extractStringList(item, "Categories", "\n"), "\n") {
+		if hashtag := toHashtag(category, words); hashtag != "" {
+			hashtags = append(hashtags, hashtag)
+		}
+	}
+	return strings.Join(hashtags, separator)
+}
+
+// toHashtag turns a category into a hashtag Telegram makes clickable, which may only
+// contain letters, digits and underscores. "+" and "#" are spelled out so that "C++" and
+// "C#" stay distinct, other punctuation separates words. It returns "" when nothing is left.
+func toHashtag(category, words string) string {
+	category = strings.NewReplacer("+", "plus", "#", "sharp").Replace(strings.TrimSpace(category))
+
+	parts := strings.FieldsFunc(category, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	if len(parts) == 0 {
+		return ""
+	}
+
+	if words == hashtagWordsUnderscore {
+		return "#" + strings.Join(parts, "_")
+	}
+	for i := 1; i < len(parts); i++ {
+		runes := []rune(parts[i])
+		runes[0] = unicode.ToUpper(runes[0])
+		parts[i] = string(runes)
+	}
+	return "#" + strings.Join(parts, "")
+}
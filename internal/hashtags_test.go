@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestToHashtag(t *testing.T) {
+	for _, tc := range []struct {
+		category, words, want string
+	}{
+		{"Go Programming", "", "#GoProgramming"},
+		{"Go Programming", hashtagWordsCamel, "#GoProgramming"},
+		{"Go Programming", hashtagWordsUnderscore, "#Go_Programming"},
+		{"C++", "", "#Cplusplus"},
+		{"C#", "", "#Csharp"},
+		{"node.js", "", "#nodeJs"},
+		{" rss ", "", "#rss"},
+		{"Café au lait", hashtagWordsUnderscore, "#Café_au_lait"},
+		{"snake_case", "", "#snake_case"},
+		{"!?", "", ""},
+		{"", "", ""},
+	} {
+		if got := toHashtag(tc.category, tc.words); got != tc.want {
+			t.Errorf("%q (%s): got %q, want %q", tc.category, tc.words, got, tc.want)
+		}
+	}
+}
+
+func TestCategoriesTemplateVariable(t *testing.T) {
+	item := buildItemMap(&gofeed.Item{Title: "Post", Categories: []string{"Go Programming", "C++", "..."}}, &gofeed.Feed{})
+
+	for _, tc := range []struct {
+		feed Feed
+		want string
+	}{
+		{Feed{}, "Go Programming, C++, ..."},
+		{Feed{CategoriesSeparator: " / "}, "Go Programming / C++ / ..."},
+		{Feed{CategoryFormat: categoryFormatHashtags}, "#GoProgramming #Cplusplus"},
+		{Feed{CategoryFormat: categoryFormatHashtags, HashtagWords: hashtagWordsUnderscore, CategoriesSeparator: ", "}, "#Go_Programming, #Cplusplus"},
+	} {
+		tc.feed.TelegramTemplate = "{{.Categories}}"
+		if got := RenderFeedItem(tc.feed, item); got != tc.want {
+			t.Errorf("%+v: got %q, want %q", tc.feed, got, tc.want)
+		}
+	}
+}
+
+func TestValidateCategoryFormat(t *testing.T) {
+	for _, feed := range []Feed{{CategoryFormat: "tags"}, {HashtagWords: "kebab"}} {
+		if err := validateCategoryFormat(feed); err == nil {
+			t.Errorf("%+v: expected an error", feed)
+		}
+	}
+	if err := validateCategoryFormat(Feed{CategoryFormat: categoryFormatHashtags, HashtagWords: hashtagWordsCamel}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
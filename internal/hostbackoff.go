@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultHostBackoffMaxMinutes caps a backed-off fetch interval when
+// host_backoff_max_minutes is not set
+const defaultHostBackoffMaxMinutes = 6 * 60
+
+// hostBackoffMax returns the longest interval a feed is backed off to
+func (c *Config) hostBackoffMax() time.Duration {
+	minutes := c.HostBackoffMaxMinutes
+	if minutes <= 0 {
+		minutes = defaultHostBackoffMaxMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// hostBackoff tracks streaks of failed fetches per host, shared by every feed on the host
+type hostBackoff struct {
+	mu      sync.Mutex
+	streaks map[string]int
+}
+
+// newHostBackoff creates an empty host backoff tracker
+func newHostBackoff() *hostBackoff {
+	return &hostBackoff{streaks: make(map[string]int)}
+}
+
+// feedHost returns the lower-cased host of a feed URL
+func feedHost(feedURL string) string {
+	parsed, err := url.Parse(feedURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// record updates the streak of a feed's host after a fetch. Server errors and network
+// errors extend it and a successful fetch ends it; other errors, such as a 404 or an
+// unparsable feed, say nothing about an outage and leave it as it is.
+func (b *hostBackoff) record(feedURL string, fetchErr error) {
+	host := feedHost(feedURL)
+	if host == "" {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case fetchErr == nil:
+		delete(b.streaks, host)
+	case isTransientFetchError(fetchErr):
+		b.streaks[host]++
+	}
+}
+
+// interval lengthens a fetch interval while the feed's host keeps failing, doubling it
+// for every failed fetch in the streak up to maxInterval. Intervals already longer than
+// maxInterval are left alone.
+func (b *hostBackoff) interval(feedURL string, interval, maxInterval time.Duration) time.Duration {
+	b.mu.Lock()
+	streak := b.streaks[feedHost(feedURL)]
+	b.mu.Unlock()
+
+	backedOff := interval
+	for i := 0; i < streak && backedOff < maxInterval; i++ {
+		backedOff *= 2
+	}
+	if backedOff > maxInterval {
+		backedOff = maxInterval
+	}
+	if backedOff < interval {
+		return interval
+	}
+	return backedOff
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHostBackoffInterval(t *testing.T) {
+	b := newHostBackoff()
+	serverError := &feedStatusError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"}
+	const feedURL = "https://news.example.com/feed.xml"
+
+	for streak, want := range []time.Duration{10 * time.Minute, 20 * time.Minute, 40 * time.Minute, 60 * time.Minute, 60 * time.Minute} {
+		if got := b.interval(feedURL, 10*time.Minute, time.Hour); got != want {
+			t.Fatalf("after %d failures: got %v, want %v", streak, got, want)
+		}
+		b.record(feedURL, serverError)
+	}
+
+	// Feeds on the same host share the streak, other hosts are unaffected
+	if got := b.interval("https://NEWS.example.com/other.xml", 10*time.Minute, time.Hour); got != time.Hour {
+		t.Fatalf("feed on the same host got %v, want the backed-off interval", got)
+	}
+	if got := b.interval("https://blog.example.com/feed.xml", 10*time.Minute, time.Hour); got != 10*time.Minute {
+		t.Fatalf("feed on another host got %v", got)
+	}
+
+	// Errors that don't mean an outage leave the streak alone
+	b.record(feedURL, &feedStatusError{StatusCode: http.StatusNotFound, Status: "404 Not Found"})
+	b.record(feedURL, &feedParseError{})
+	if got := b.interval(feedURL, 10*time.Minute, time.Hour); got != time.Hour {
+		t.Fatalf("got %v after a client error, want the streak kept", got)
+	}
+
+	// A success ends the streak
+	b.record(feedURL, nil)
+	if got := b.interval(feedURL, 10*time.Minute, time.Hour); got != 10*time.Minute {
+		t.Fatalf("got %v after a success, want the normal interval", got)
+	}
+
+	// Intervals beyond the cap are left alone
+	b.record(feedURL, serverError)
+	if got := b.interval(feedURL, 2*time.Hour, time.Hour); got != 2*time.Hour {
+		t.Fatalf("got %v for an interval above the cap", got)
+	}
+}
+
+func TestRepeatedServerErrorsLengthenInterval(t *testing.T) {
+	shortFetchBackoff(t)
+	// Every scheduled fetch tries feedFetchAttempts times, so two fetches fail entirely
+	server, _ := failingFeedServer(t, 2*feedFetchAttempts, http.StatusServiceUnavailable)
+	feed := testFeed(server.URL)
+	neighbour := testFeed(server.URL + "/other.xml")
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed, neighbour}, HostBackoff: true, HostBackoffMaxMinutes: 180})
+
+	fs.runFeed(feed)
+	if got := fs.intervalFor(feed); got != 120*time.Minute {
+		t.Fatalf("got interval %v after one failed fetch, want 120m", got)
+	}
+	fs.runFeed(feed)
+	if got := fs.intervalFor(feed); got != 180*time.Minute {
+		t.Fatalf("got interval %v after two failed fetches, want the 180m cap", got)
+	}
+	if got := fs.intervalFor(neighbour); got != 180*time.Minute {
+		t.Fatalf("feed on the same host got interval %v, want 180m", got)
+	}
+
+	fs.runFeed(feed)
+	if texts := sentTexts(recorder); len(texts) != 1 {
+		t.Fatalf("got messages %q, want the item once the server recovered", texts)
+	}
+	if got := fs.intervalFor(feed); got != 60*time.Minute {
+		t.Fatalf("got interval %v after a success, want the configured 60m", got)
+	}
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// isFeedHostAllowed reports whether a feed URL's host matches the allow-list. Entries
+// containing wildcards are matched as globs (e.g. "*.example.com"); other entries match
+// the host itself and its subdomains. An empty allow-list allows every host.
+func isFeedHostAllowed(feedURL string, allowedHosts []string) bool {
+	if len(allowedHosts) == 0 {
+		return true
+	}
+
+	parsed, err := url.Parse(feedURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host == "" {
+		return false
+	}
+
+	for _, allowed := range allowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == "" {
+			continue
+		}
+		if strings.ContainsAny(allowed, "*?[") {
+			if matched, _ := path.Match(allowed, host); matched {
+				return true
+			}
+			continue
+		}
+		allowed = strings.TrimPrefix(allowed, ".")
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateFeedHost checks a feed URL against the allow-list
+func validateFeedHost(feedURL string, allowedHosts []string) error {
+	if !isFeedHostAllowed(feedURL, allowedHosts) {
+		return fmt.Errorf("feed host is not in allowed_feed_hosts")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package internal
+
+import "testing"
+
+func TestIsFeedHostAllowed(t *testing.T) {
+	allowed := []string{"example.com", "*.feeds.org", "News.Example.NET"}
+
+	for feedURL, want := range map[string]bool{
+		"https://example.com/feed.xml":         true,
+		"https://blog.example.com/feed.xml":    true,
+		"https://a.feeds.org/rss":              true,
+		"https://feeds.org/rss":                false,
+		"https://news.example.net/rss":         true,
+		"https://notexample.com/feed.xml":      false,
+		"https://example.com.evil.test/feed":   false,
+		"https://evil.test/feed?u=example.com": false,
+		"not a url":                            false,
+	} {
+		if got := isFeedHostAllowed(feedURL, allowed); got != want {
+			t.Errorf("%s: allowed %v, want %v", feedURL, got, want)
+		}
+	}
+
+	if !isFeedHostAllowed("https://anything.test/feed", nil) {
+		t.Error("an empty allow-list must allow every host")
+	}
+}
+
+func TestUpdateRejectsDisallowedFeedHost(t *testing.T) {
+	cm := newTestConfigManager(t, &Config{AllowedFeedHosts: []string{"example.com"}})
+
+	add := func(feedURL string) error {
+		return cm.Update(func(cfg *Config) error {
+			cfg.Feeds = append(cfg.Feeds, Feed{FeedUrl: feedURL, FeedFetchIntervalMinutes: 30})
+			return nil
+		})
+	}
+
+	if err := add("https://blog.example.com/feed.xml"); err != nil {
+		t.Fatalf("allowed host rejected: %v", err)
+	}
+	if err := add("https://other.test/feed.xml"); err == nil {
+		t.Fatal("disallowed host accepted")
+	}
+	if feeds := cm.Get().Feeds; len(feeds) != 1 {
+		t.Fatalf("got %d feeds, want only the allowed one", len(feeds))
+	}
+}
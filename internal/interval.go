@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/rss"
+)
+
+// Bounds and default for intervals derived from feed hints
+const (
+	minAutoIntervalMinutes     = 5
+	maxAutoIntervalMinutes     = 24 * 60
+	defaultAutoIntervalMinutes = 60
+)
+
+// defaultMinFetchInterval is the smallest fetch interval allowed when
+// min_fetch_interval_minutes is not set, to protect feed providers
+const defaultMinFetchInterval = 5
+
+// minFetchInterval returns the smallest fetch interval allowed for any feed
+func (c *Config) minFetchInterval() int {
+	if c.MinFetchIntervalMinutes > 0 {
+		return c.MinFetchIntervalMinutes
+	}
+	return defaultMinFetchInterval
+}
+
+// validateFetchInterval checks that a feed's fetch interval is set and not below the floor.
+// Feeds in auto_interval mode may leave it at 0.
+func validateFetchInterval(feed Feed, floor int) error {
+	minutes := feed.FeedFetchIntervalMinutes
+	if minutes == 0 && feed.AutoInterval {
+		return nil
+	}
+	if minutes <= 0 {
+		return fmt.Errorf("feed_fetch_interval_minutes must be positive (or 0 with auto_interval)")
+	}
+	if minutes < floor {
+		return fmt.Errorf("feed_fetch_interval_minutes is %d, below the minimum of %d minutes (min_fetch_interval_minutes)", minutes, floor)
+	}
+	return nil
+}
+
+// clampFetchIntervals raises fetch intervals that are unset or below the floor to the
+// floor, so that configurations written before the floor existed still load. It returns
+// a warning for every feed it changed. Saves from the UI or API reject such intervals.
+func (c *Config) clampFetchIntervals() []string {
+	floor := c.minFetchInterval()
+	var warnings []string
+	for i := range c.Feeds {
+		feed := &c.Feeds[i]
+		if err := validateFetchInterval(*feed, floor); err != nil {
+			warnings = append(warnings, fmt.Sprintf("feed %d (%s): %v; using %d minutes", i+1, feed.FeedUrl, err, floor))
+			feed.FeedFetchIntervalMinutes = floor
+		}
+	}
+	return warnings
+}
+
+// syndicationPeriodMinutes maps <sy:updatePeriod> values to their length in minutes
+var syndicationPeriodMinutes = map[string]int{
+	"hourly":  60,
+	"daily":   24 * 60,
+	"weekly":  7 * 24 * 60,
+	"monthly": 30 * 24 * 60,
+	"yearly":  365 * 24 * 60,
+}
+
+// ttlRSSTranslator is the default RSS translator, except that it keeps the channel's
+// <ttl> in the feed's custom fields, which gofeed otherwise drops
+type ttlRSSTranslator struct {
+	gofeed.DefaultRSSTranslator
+}
+
+func (t *ttlRSSTranslator) Translate(feed interface{}) (*gofeed.Feed, error) {
+	result, err := t.DefaultRSSTranslator.Translate(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	if rssFeed, ok := feed.(*rss.Feed); ok && strings.TrimSpace(rssFeed.TTL) != "" {
+		if result.Custom == nil {
+			result.Custom = map[string]string{}
+		}
+		result.Custom["ttl"] = strings.TrimSpace(rssFeed.TTL)
+	}
+
+	return result, nil
+}
+
+// suggestedIntervalMinutes derives a fetch interval from the feed's <ttl> or, failing
+// that, its syndication hints. The result is clamped to sane bounds; the boolean is
+// false when the feed gives no usable hint.
+func suggestedIntervalMinutes(feedData *gofeed.Feed) (int, bool) {
+	minutes := 0
+
+	if ttl, err := strconv.Atoi(feedData.Custom["ttl"]); err == nil && ttl > 0 {
+		minutes = ttl
+	} else if period, ok := syndicationPeriodMinutes[strings.ToLower(feedExtensionValue(feedData, "sy", "updatePeriod"))]; ok {
+		frequency, err := strconv.Atoi(feedExtensionValue(feedData, "sy", "updateFrequency"))
+		if err != nil || frequency <= 0 {
+			frequency = 1
+		}
+		minutes = period / frequency
+	}
+
+	if minutes <= 0 {
+		return 0, false
+	}
+	if minutes < minAutoIntervalMinutes {
+		minutes = minAutoIntervalMinutes
+	}
+	if minutes > maxAutoIntervalMinutes {
+		minutes = maxAutoIntervalMinutes
+	}
+	return minutes, true
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigClampsFetchIntervals(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `feeds:
+  - feed_url: https://example.com/zero.xml
+    feed_fetch_interval_minutes: 0
+  - feed_url: https://example.com/low.xml
+    feed_fetch_interval_minutes: 1
+  - feed_url: https://example.com/ok.xml
+    feed_fetch_interval_minutes: 30
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cm := NewConfigManager()
+	cm.Path = path
+	if err := cm.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	want := []int{defaultMinFetchInterval, defaultMinFetchInterval, 30}
+	for i, feed := range cm.Get().Feeds {
+		if feed.FeedFetchIntervalMinutes != want[i] {
+			t.Errorf("feed %d: interval %d, want %d", i+1, feed.FeedFetchIntervalMinutes, want[i])
+		}
+	}
+}
+
+func TestUpdateRejectsLowFetchInterval(t *testing.T) {
+	cm := newTestConfigManager(t, &Config{Feeds: []Feed{{FeedUrl: "https://example.com/a.xml", FeedFetchIntervalMinutes: 30}}})
+
+	for _, minutes := range []int{0, 1} {
+		err := cm.Update(func(cfg *Config) error {
+			cfg.Feeds[0].FeedFetchIntervalMinutes = minutes
+			return nil
+		})
+		if err == nil {
+			t.Errorf("interval %d: expected an error", minutes)
+		}
+	}
+	if got := cm.Get().Feeds[0].FeedFetchIntervalMinutes; got != 30 {
+		t.Fatalf("interval changed to %d", got)
+	}
+}
+
+func TestClampFetchIntervalsKeepsAutoInterval(t *testing.T) {
+	config := &Config{Feeds: []Feed{{FeedUrl: "https://example.com/a.xml", AutoInterval: true}}}
+	if warnings := config.clampFetchIntervals(); len(warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	if config.Feeds[0].FeedFetchIntervalMinutes != 0 {
+		t.Fatal("auto_interval feed was changed")
+	}
+}
+
+// ttlFeed is a feed document with the given channel <ttl>
+func ttlFeed(ttl string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>TTL feed</title><ttl>` + ttl + `</ttl><item><guid>1</guid><title>First</title></item></channel></rss>`
+}
+
+func TestSuggestedIntervalMinutes(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		body   string
+		want   int
+		wantOK bool
+	}{
+		{"ttl", ttlFeed("60"), 60, true},
+		{"ttl below the minimum", ttlFeed("1"), minAutoIntervalMinutes, true},
+		{"ttl above the maximum", ttlFeed("100000"), maxAutoIntervalMinutes, true},
+		{"invalid ttl", ttlFeed("soon"), 0, false},
+		{"syndication hints", syndicationFeed, 30, true},
+		{"no hints", rssFeed(testItem{GUID: "1"}), 0, false},
+	} {
+		got, ok := suggestedIntervalMinutes(parseTestFeed(t, tc.body))
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("%s: got %d %v, want %d %v", tc.name, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestAutoIntervalFollowsTTL(t *testing.T) {
+	server := newFeedServer(t, ttlFeed("60"))
+	feed := testFeed(server.URL)
+	feed.FeedFetchIntervalMinutes = 0
+	feed.AutoInterval = true
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	if got := fs.intervalFor(feed); got != defaultAutoIntervalMinutes*time.Minute {
+		t.Fatalf("got interval %v before the first fetch, want the default", got)
+	}
+
+	server.setBody(ttlFeed("120"))
+	fs.runFeed(feed)
+	if got := fs.intervalFor(feed); got != 120*time.Minute {
+		t.Fatalf("got interval %v, want the feed's ttl of 120 minutes", got)
+	}
+
+	// An explicit interval wins over the feed's hints
+	feed.FeedFetchIntervalMinutes = 45
+	if got := fs.intervalFor(feed); got != 45*time.Minute {
+		t.Fatalf("got interval %v, want the configured 45 minutes", got)
+	}
+}
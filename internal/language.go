@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Policies for items whose language isn't known when a feed has only_languages
+const (
+	unknownLanguageInclude = "include"
+	unknownLanguageExclude = "exclude"
+)
+
+// validateUnknownLanguage checks a feed's unknown_language option
+func validateUnknownLanguage(policy string) error {
+	switch policy {
+	case "", unknownLanguageInclude, unknownLanguageExclude:
+		return nil
+	}
+	return fmt.Errorf("unknown unknown_language %q (use %q or %q)", policy, unknownLanguageInclude, unknownLanguageExclude)
+}
+
+// itemLanguageField is the custom field holding the xml:lang of an item's own element
+const itemLanguageField = "xml:lang"
+
+// annotateItemLanguages keeps the xml:lang of each <item> or <entry> element in the item's
+// custom fields, since gofeed only keeps the language of the feed itself
+func annotateItemLanguages(body []byte, feedData *gofeed.Feed) {
+	if !bytes.Contains(body, []byte("xml:lang")) {
+		return
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	// The body was already converted to UTF-8, whatever its declaration says
+	decoder.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+
+	var languages []string
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || (start.Name.Local != "item" && start.Name.Local != "entry") {
+			continue
+		}
+		language := ""
+		for _, attr := range start.Attr {
+			if attr.Name.Space == "http://www.w3.org/XML/1998/namespace" && attr.Name.Local == "lang" {
+				language = strings.TrimSpace(attr.Value)
+			}
+		}
+		languages = append(languages, language)
+	}
+
+	// Only trust the elements to line up with the parsed items if there are as many
+	if len(languages) != len(feedData.Items) {
+		return
+	}
+	for i, language := range languages {
+		if language == "" {
+			continue
+		}
+		item := feedData.Items[i]
+		if item.Custom == nil {
+			item.Custom = map[string]string{}
+		}
+		item.Custom[itemLanguageField] = language
+	}
+}
+
+// itemLanguage returns the language of an item: its own <dc:language> or xml:lang, or
+// else the language the feed declares (<language>, <dc:language> or the Atom feed's xml:lang)
+func itemLanguage(item *gofeed.Item, feedData *gofeed.Feed) string {
+	if language := itemFieldValue(item, "dc:language"); language != "" {
+		return language
+	}
+	if language := strings.TrimSpace(item.Custom[itemLanguageField]); language != "" {
+		return language
+	}
+	if feedData != nil {
+		return strings.TrimSpace(feedData.Language)
+	}
+	return ""
+}
+
+// matchesLanguage reports whether a language tag is one of the wanted languages. A wanted
+// language without a region, such as "en", also matches its regional variants like "en-US".
+func matchesLanguage(language string, wanted []string) bool {
+	language = strings.ToLower(strings.ReplaceAll(language, "_", "-"))
+	for _, w := range wanted {
+		w = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(w), "_", "-"))
+		if language == w || strings.HasPrefix(language, w+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// languageAllowed reports whether an item passes the feed's only_languages filter
+func languageAllowed(feed Feed, feedData *gofeed.Feed, item *gofeed.Item) bool {
+	if len(feed.OnlyLanguages) == 0 {
+		return true
+	}
+	language := itemLanguage(item, feedData)
+	if language == "" {
+		return feed.UnknownLanguage != unknownLanguageExclude
+	}
+	return matchesLanguage(language, feed.OnlyLanguages)
+}
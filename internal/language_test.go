@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+// multilingualFeed is an Atom feed in English with entries in other languages and one
+// without a language of its own
+const multilingualFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xml:lang="en">
+  <title>News</title>
+  <entry xml:lang="fr"><id>4</id><title>Bonjour</title><updated>2024-01-04T00:00:00Z</updated></entry>
+  <entry><id>3</id><title>Inherited</title><updated>2024-01-03T00:00:00Z</updated></entry>
+  <entry xml:lang="de-AT"><id>2</id><title>Servus</title><updated>2024-01-02T00:00:00Z</updated></entry>
+  <entry xml:lang="en-US"><id>1</id><title>Hello</title><updated>2024-01-01T00:00:00Z</updated></entry>
+</feed>`
+
+func TestOnlyLanguagesWithXMLLang(t *testing.T) {
+	server := newFeedServer(t, multilingualFeed)
+	feed := testFeed(server.URL)
+	feed.OnlyLanguages = []string{"en", "de"}
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	fs.runFeed(feed)
+
+	if texts, want := sentTexts(recorder), []string{"Hello", "Servus", "Inherited"}; !reflect.DeepEqual(texts, want) {
+		t.Fatalf("got messages %q, want %q", texts, want)
+	}
+	// Skipped items are marked as seen
+	if posted, _ := fs.dbManager.IsFeedItemPosted("4", feed.Key()); !posted {
+		t.Fatal("item in another language was not recorded")
+	}
+}
+
+func TestUnknownLanguagePolicy(t *testing.T) {
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>News</title>
+  <entry><id>2</id><title>Unknown</title><updated>2024-01-02T00:00:00Z</updated></entry>
+  <entry xml:lang="en"><id>1</id><title>Hello</title><updated>2024-01-01T00:00:00Z</updated></entry>
+</feed>`
+
+	for _, tc := range []struct {
+		policy string
+		want   []string
+	}{
+		{"", []string{"Hello", "Unknown"}},
+		{unknownLanguageExclude, []string{"Hello"}},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			server := newFeedServer(t, body)
+			feed := testFeed(server.URL)
+			feed.OnlyLanguages = []string{"en"}
+			feed.UnknownLanguage = tc.policy
+			fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+			fs.runFeed(feed)
+
+			if texts := sentTexts(recorder); !reflect.DeepEqual(texts, tc.want) {
+				t.Fatalf("got messages %q, want %q", texts, tc.want)
+			}
+		})
+	}
+}
+
+func TestItemLanguageSources(t *testing.T) {
+	feedData := parseTestFeed(t, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><channel><title>News</title><language>en-GB</language>
+<item xml:lang="fr"><guid>3</guid><title>Attribute</title><dc:language>es</dc:language></item>
+<item xml:lang="fr"><guid>2</guid><title>Attribute</title></item>
+<item><guid>1</guid><title>Channel</title></item>
+</channel></rss>`)
+
+	var got []string
+	for _, item := range feedData.Items {
+		got = append(got, itemLanguage(item, feedData))
+	}
+	if want := []string{"es", "fr", "en-GB"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got languages %q, want %q", got, want)
+	}
+}
+
+func TestMatchesLanguage(t *testing.T) {
+	for _, tc := range []struct {
+		language string
+		want     bool
+	}{
+		{"en", true},
+		{"EN-us", true},
+		{"en_GB", true},
+		{"pt-BR", true},
+		{"pt-PT", false},
+		{"eng", false},
+		{"de", false},
+	} {
+		if got := matchesLanguage(tc.language, []string{"en", "pt_BR"}); got != tc.want {
+			t.Errorf("%q: got %v, want %v", tc.language, got, tc.want)
+		}
+	}
+}
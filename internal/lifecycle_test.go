@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// statusChatConfig posts status events to chat 300
+func statusChatConfig(feeds ...Feed) *Config {
+	return &Config{
+		Feeds:                  feeds,
+		StatusTelegramApiToken: "789:status",
+		StatusTelegramChatId:   "300",
+		SkipInitialFetch:       true,
+	}
+}
+
+// statusEvents returns the texts posted to the status chat
+func statusEvents(recorder *telegramRecorder) []string {
+	var events []string
+	for _, call := range recorder.callsTo("sendMessage") {
+		if call.chatID() == "300" {
+			events = append(events, call.text())
+		}
+	}
+	return events
+}
+
+func TestPostStatusEvent(t *testing.T) {
+	fs, recorder := newTestScheduler(t, statusChatConfig())
+
+	fs.PostStatusEvent("Bot started with <3> feeds")
+
+	calls := recorder.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].Token != "789:status" || calls[0].chatID() != "300" {
+		t.Fatalf("got calls %v, want one to the status chat", recorder.Calls())
+	}
+	if calls[0].text() != "ℹ️ Bot started with &lt;3&gt; feeds" || calls[0].Payload["disable_notification"] != true {
+		t.Fatalf("unexpected payload %v", calls[0].Payload)
+	}
+}
+
+func TestPostStatusEventTokenAndDisabled(t *testing.T) {
+	// The alert token is used when the status chat has none
+	fs, recorder := newTestScheduler(t, &Config{AlertTelegramApiToken: "456:alert", StatusTelegramChatId: "300"})
+	fs.PostStatusEvent("Bot shutting down")
+	if calls := recorder.callsTo("sendMessage"); len(calls) != 1 || calls[0].Token != "456:alert" {
+		t.Fatalf("got calls %v, want one with the alert token", recorder.Calls())
+	}
+
+	fs, recorder = newTestScheduler(t, &Config{AlertTelegramApiToken: "456:alert", AlertTelegramChatId: "999"})
+	fs.PostStatusEvent("Bot shutting down")
+	if calls := recorder.Calls(); len(calls) != 0 {
+		t.Fatalf("got calls %v without a status chat", calls)
+	}
+}
+
+func TestKillSwitchPostsStatusEvents(t *testing.T) {
+	fs, recorder := newTestScheduler(t, statusChatConfig())
+
+	if err := fs.SetPaused(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.SetPaused(false); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := statusEvents(recorder), []string{"ℹ️ Posting paused", "ℹ️ Posting resumed"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got events %q, want %q", got, want)
+	}
+}
+
+func TestTagPausePostsStatusEvents(t *testing.T) {
+	feed := testFeed("https://example.com/feed.xml")
+	feed.Tags = []string{"experimental"}
+	fs, recorder := newTestScheduler(t, statusChatConfig(feed))
+
+	if rec := postJSON(newTestRouter(fs), "/tags/experimental/pause"); rec.Code != http.StatusOK {
+		t.Fatalf("pause failed with %d: %s", rec.Code, rec.Body.String())
+	}
+
+	want := []string{"ℹ️ Configuration reloaded: 1 feeds", "ℹ️ Paused feeds with tag experimental"}
+	if got := statusEvents(recorder); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got events %q, want %q", got, want)
+	}
+}
+
+// settledGoroutines waits for the number of goroutines to drop to at most limit and
+// returns the number once it does, or after a second
+func settledGoroutines(limit int) int {
+	deadline := time.Now().Add(time.Second)
+	n := runtime.NumGoroutine()
+	for n > limit && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		n = runtime.NumGoroutine()
+	}
+	return n
+}
+
+func TestStartStopDoesNotLeakGoroutines(t *testing.T) {
+	config := &Config{SkipInitialFetch: true}
+	for i := 0; i < 5; i++ {
+		config.Feeds = append(config.Feeds, testFeed(fmt.Sprintf("https://example.com/%d.xml", i)))
+	}
+	cm := newTestConfigManager(t, config)
+	db := newTestDB(t)
+	baseline := runtime.NumGoroutine()
+
+	for cycle := 0; cycle < 5; cycle++ {
+		fs := NewFeedScheduler(cm, db)
+		fs.Start()
+		fs.StartCleanupRoutine()
+		fs.StartWatchdog()
+		running := runtime.NumGoroutine()
+
+		// Every reload replaces the tickers and their goroutines
+		for i := 0; i < 5; i++ {
+			fs.RefreshConfiguration()
+		}
+		if n := settledGoroutines(running); n > running {
+			t.Fatalf("cycle %d: %d goroutines after reloading, %d before", cycle, n, running)
+		}
+
+		fs.Stop()
+		if n := settledGoroutines(baseline); n > baseline {
+			t.Fatalf("cycle %d: %d goroutines after Stop, %d before Start", cycle, n, baseline)
+		}
+	}
+}
+
+// waitForRequests waits up to two seconds for a feed server to have served n requests
+func waitForRequests(server *feedServer, n int32) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for server.requests.Load() < n && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	return server.requests.Load() >= n
+}
+
+func TestFeedsKeepFetchingAfterRefresh(t *testing.T) {
+	// Restored after the scheduler is stopped, since cleanups run last in first out
+	unit := fetchIntervalUnit
+	t.Cleanup(func() { fetchIntervalUnit = unit })
+	fetchIntervalUnit = time.Millisecond
+
+	server := newFeedServer(t, rssFeed())
+	feed := testFeed(server.URL)
+	feed.FeedFetchIntervalMinutes = 20
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{feed}, SkipInitialFetch: true})
+
+	fs.Start()
+	if !waitForRequests(server, 2) {
+		t.Fatalf("feed fetched %d times after Start", server.requests.Load())
+	}
+
+	for i := 0; i < 3; i++ {
+		fs.RefreshConfiguration()
+	}
+	if n := server.requests.Load(); !waitForRequests(server, n+3) {
+		t.Fatalf("feed stopped fetching after a refresh, %d fetches", server.requests.Load())
+	}
+
+	// A feed added by a reload is scheduled too
+	added := newFeedServer(t, rssFeed())
+	err := fs.configManager.Update(func(cfg *Config) error {
+		newFeed := testFeed(added.URL)
+		newFeed.FeedFetchIntervalMinutes = 20
+		cfg.Feeds = append(cfg.Feeds, newFeed)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.RefreshConfiguration()
+	if !waitForRequests(added, 2) {
+		t.Fatalf("added feed fetched %d times after the refresh", added.requests.Load())
+	}
+	if n := server.requests.Load(); !waitForRequests(server, n+2) {
+		t.Fatal("existing feed stopped fetching after the second refresh")
+	}
+
+	// Once stopped, a refresh doesn't bring the tickers back
+	fs.Stop()
+	fs.RefreshConfiguration()
+	stopped := server.requests.Load()
+	time.Sleep(100 * time.Millisecond)
+	if n := server.requests.Load(); n != stopped {
+		t.Fatalf("feed fetched %d times after Stop", n-stopped)
+	}
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Message types a feed can post in addition to its text message
+const (
+	messageTypeText     = "text"
+	messageTypeLocation = "location"
+)
+
+// Item fields read for coordinates when the feed doesn't name its own
+const (
+	defaultPointField     = "georss:point"
+	defaultLatitudeField  = "geo:lat"
+	defaultLongitudeField = "geo:long"
+)
+
+// validateMessageType checks a feed's message_type option
+func validateMessageType(messageType string) error {
+	switch messageType {
+	case "", messageTypeText, messageTypeLocation:
+		return nil
+	}
+	return fmt.Errorf("unknown message_type %q (use %q or %q)", messageType, messageTypeText, messageTypeLocation)
+}
+
+// itemFieldValue returns a structured field of an item. Names with a namespace prefix
+// such as "geo:lat" are read from the item's extensions, other names from its custom fields.
+func itemFieldValue(item *gofeed.Item, field string) string {
+	if namespace, name, ok := strings.Cut(field, ":"); ok {
+		elements := item.Extensions[namespace][name]
+		if len(elements) == 0 {
+			return ""
+		}
+		return strings.TrimSpace(elements[0].Value)
+	}
+	return strings.TrimSpace(item.Custom[field])
+}
+
+// itemLocation returns the coordinates of an item for feeds with message_type "location".
+// The feed's latitude_field and longitude_field are used when set; otherwise a
+// <georss:point> or <geo:lat>/<geo:long> pair is looked for. The boolean is false when the
+// item has no valid coordinates.
+func itemLocation(feed Feed, item *gofeed.Item) (float64, float64, bool) {
+	var latValue, longValue string
+	if feed.LatitudeField != "" || feed.LongitudeField != "" {
+		latValue = itemFieldValue(item, feed.LatitudeField)
+		longValue = itemFieldValue(item, feed.LongitudeField)
+	} else if point := strings.Fields(itemFieldValue(item, defaultPointField)); len(point) == 2 {
+		latValue, longValue = point[0], point[1]
+	} else {
+		latValue = itemFieldValue(item, defaultLatitudeField)
+		longValue = itemFieldValue(item, defaultLongitudeField)
+	}
+
+	latitude, err := strconv.ParseFloat(latValue, 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return 0, 0, false
+	}
+	longitude, err := strconv.ParseFloat(longValue, 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return 0, 0, false
+	}
+
+	return latitude, longitude, true
+}
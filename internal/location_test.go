@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// geoFeed is an RSS feed with one item located with georss and one with W3C geo fields
+const geoFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:georss="http://www.georss.org/georss" xmlns:geo="http://www.w3.org/2003/01/geo/wgs84_pos#">
+<channel><title>Events</title><link>https://example.com/</link>
+<item><guid>2</guid><title>Concert</title><geo:lat>48.8584</geo:lat><geo:long>2.2945</geo:long></item>
+<item><guid>1</guid><title>Market</title><georss:point>52.52 13.405</georss:point></item>
+</channel></rss>`
+
+func TestItemLocation(t *testing.T) {
+	feedData := parseTestFeed(t, geoFeed)
+
+	for _, tc := range []struct {
+		item      *gofeed.Item
+		latitude  float64
+		longitude float64
+	}{
+		{feedData.Items[0], 48.8584, 2.2945},
+		{feedData.Items[1], 52.52, 13.405},
+	} {
+		latitude, longitude, ok := itemLocation(Feed{MessageType: messageTypeLocation}, tc.item)
+		if !ok || latitude != tc.latitude || longitude != tc.longitude {
+			t.Errorf("%s: got %v, %v, %v, want %v, %v", tc.item.Title, latitude, longitude, ok, tc.latitude, tc.longitude)
+		}
+	}
+}
+
+func TestItemLocationFromCustomFields(t *testing.T) {
+	feed := Feed{MessageType: messageTypeLocation, LatitudeField: "lat", LongitudeField: "lng"}
+
+	latitude, longitude, ok := itemLocation(feed, &gofeed.Item{Custom: map[string]string{"lat": " -33.8568 ", "lng": "151.2153"}})
+	if !ok || latitude != -33.8568 || longitude != 151.2153 {
+		t.Fatalf("got %v, %v, %v", latitude, longitude, ok)
+	}
+
+	for _, custom := range []map[string]string{
+		{},
+		{"lat": "north", "lng": "151.2153"},
+		{"lat": "91", "lng": "151.2153"},
+		{"lat": "-33.8568", "lng": "181"},
+	} {
+		if _, _, ok := itemLocation(feed, &gofeed.Item{Custom: custom}); ok {
+			t.Errorf("%v: expected no location", custom)
+		}
+	}
+}
+
+func TestValidateMessageType(t *testing.T) {
+	for _, messageType := range []string{"", messageTypeText, messageTypeLocation} {
+		if err := validateMessageType(messageType); err != nil {
+			t.Errorf("%q: unexpected error: %v", messageType, err)
+		}
+	}
+	if err := validateMessageType("poll"); err == nil {
+		t.Fatal("expected an error for an unknown message type")
+	}
+}
+
+func TestRunFeedSendsItemLocation(t *testing.T) {
+	server := newFeedServer(t, geoFeed)
+	feed := testFeed(server.URL)
+	feed.MessageType = messageTypeLocation
+	feed.TelegramMessageThreadId = 7
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	fs.runFeed(feed)
+
+	calls := recorder.Calls()
+	var methods []string
+	for _, call := range calls {
+		methods = append(methods, call.Method)
+	}
+	if want := "[sendMessage sendLocation sendMessage sendLocation]"; fmt.Sprint(methods) != want {
+		t.Fatalf("got calls %v, want %s", methods, want)
+	}
+
+	// Items are sent oldest first, each followed by its location
+	location := calls[1].Payload
+	if calls[1].chatID() != "100" || fmt.Sprint(location["latitude"]) != "52.52" || fmt.Sprint(location["longitude"]) != "13.405" {
+		t.Fatalf("unexpected sendLocation payload %v", location)
+	}
+	if fmt.Sprint(location["message_thread_id"]) != "7" || location["disable_notification"] != true {
+		t.Fatalf("unexpected sendLocation payload %v", location)
+	}
+	if location := calls[3].Payload; fmt.Sprint(location["latitude"]) != "48.8584" || fmt.Sprint(location["longitude"]) != "2.2945" {
+		t.Fatalf("unexpected sendLocation payload %v", location)
+	}
+}
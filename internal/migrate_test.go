@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestMigrateFeedURL(t *testing.T) {
+	db := newTestDB(t)
+	oldURL, newURL := "http://example.com/feed.xml", "https://example.com/feed.xml"
+
+	for _, guid := range []string{"1", "2"} {
+		if err := db.SaveFeedItem(FeedItem{GUID: guid, Title: "Item " + guid, FeedURL: oldURL}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Already seen under the new URL, so this row is not moved
+	if err := db.SaveFeedItem(FeedItem{GUID: "2", Title: "Item 2", FeedURL: newURL}); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := db.MigrateFeedURL(oldURL, newURL)
+	if err != nil {
+		t.Fatalf("MigrateFeedURL: %v", err)
+	}
+	if migrated != 1 {
+		t.Fatalf("migrated %d items, want 1", migrated)
+	}
+	for _, guid := range []string{"1", "2"} {
+		if posted, _ := db.IsFeedItemPosted(guid, newURL); !posted {
+			t.Errorf("item %s is not seen under the new URL", guid)
+		}
+		if posted, _ := db.IsFeedItemPosted(guid, oldURL); posted {
+			t.Errorf("item %s is still stored under the old URL", guid)
+		}
+	}
+}
+
+func TestMovedFeedKeysMatchesBySlot(t *testing.T) {
+	existing := []Feed{
+		{FeedUrl: "http://example.com/a.xml"},
+		{FeedUrl: "http://example.com/b.xml", ID: "b"},
+		{FeedUrl: "http://example.com/c.xml"},
+		{FeedUrl: "http://example.com/e.xml", ID: "e-old"},
+	}
+	// The first two feeds swap places, the third is unchanged and the fourth gets a new id
+	req := formRequest(t, "/config", url.Values{
+		"feed_slots": {"1", "0", "2", "3", "new"},
+		"feed_ids":   {"b", "", "", "e-new", ""},
+		"feed_urls":  {"https://example.com/b.xml", "https://example.com/a.xml", "http://example.com/c.xml", "http://example.com/e.xml", "https://example.com/d.xml"},
+	})
+
+	moved := movedFeedKeys(req, existing, processFeedsFromForm(req, existing))
+	want := map[string]string{"http://example.com/a.xml": "https://example.com/a.xml", "e-old": "e-new"}
+	if !reflect.DeepEqual(moved, want) {
+		t.Fatalf("got %v, want %v", moved, want)
+	}
+}
+
+func TestConfigFormMigratesSeenItems(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		migrate bool
+		want    int
+	}{
+		{"migrated", true, 0},
+		{"not migrated", false, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+			oldURL := server.URL + "/?typo"
+			fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{testFeed(oldURL)}, SkipInitialFetch: true})
+			fs.runFeed(fs.configManager.Get().Feeds[0])
+
+			form := url.Values{
+				"feed_slots":         {"0"},
+				"feed_urls":          {server.URL + "/"},
+				"feed_intervals":     {"60"},
+				"telegram_tokens":    {"123:test"},
+				"telegram_chat_ids":  {"100"},
+				"telegram_templates": {"{{.Title}}"},
+			}
+			if tc.migrate {
+				form.Set("migrate_feed_urls", "1")
+			}
+			if rec := serve(newTestRouter(fs), http.MethodPost, "/config", form.Encode()); rec.Code != http.StatusSeeOther {
+				t.Fatalf("save failed with %d: %s", rec.Code, rec.Body.String())
+			}
+
+			feed := fs.configManager.Get().Feeds[0]
+			if feed.FeedUrl != server.URL+"/" {
+				t.Fatalf("feed URL is %s after the save", feed.FeedUrl)
+			}
+			fs.runFeed(feed)
+			if texts := sentTexts(recorder); len(texts) != 1+tc.want {
+				t.Fatalf("got messages %q, want %d after the URL change", texts, tc.want)
+			}
+		})
+	}
+}
@@ -1,36 +1,274 @@
 package internal
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 // Config represents the configuration structure
 type Config struct {
-	Server                      string `yaml:"server"`
-	Database                    string `yaml:"database"`
-	TestTelegramApiToken        string `yaml:"test_telegram_api_token"`
-	TestTelegramChatId          int64  `yaml:"test_telegram_chat_id"`
-	TestTelegramMessageThreadId int64  `yaml:"test_telegram_message_thread_id"`
-	TestTelegramTemplate        string `yaml:"test_telegram_template"`
-	Feeds                       []Feed `yaml:"feeds"`
+	Server                      string            `yaml:"server"`
+	Database                    string            `yaml:"database"`
+	TestTelegramApiToken        string            `yaml:"test_telegram_api_token"`
+	TestTelegramChatId          ChatID            `yaml:"test_telegram_chat_id"`
+	TestTelegramMessageThreadId int64             `yaml:"test_telegram_message_thread_id"`
+	TestTelegramTemplate        string            `yaml:"test_telegram_template"`
+	AlertTelegramApiToken       string            `yaml:"alert_telegram_api_token,omitempty"`
+	AlertTelegramChatId         ChatID            `yaml:"alert_telegram_chat_id,omitempty"`
+	AlertFailureThreshold       int               `yaml:"alert_failure_threshold,omitempty"`
+	AlertTemplate               string            `yaml:"alert_template,omitempty"`
+	StatusTelegramApiToken      string            `yaml:"status_telegram_api_token,omitempty"`
+	StatusTelegramChatId        ChatID            `yaml:"status_telegram_chat_id,omitempty"`
+	SkipInitialFetch            bool              `yaml:"skip_initial_fetch,omitempty"`
+	Paused                      bool              `yaml:"paused,omitempty"`
+	PauseMarkSeen               bool              `yaml:"pause_mark_seen,omitempty"`
+	StuckFetchThresholdMinutes  int               `yaml:"stuck_fetch_threshold_minutes,omitempty"`
+	DebugFeedErrors             bool              `yaml:"debug_feed_errors,omitempty"`
+	DebugFeedErrorBytes         int               `yaml:"debug_feed_error_bytes,omitempty"`
+	AllowedFeedHosts            []string          `yaml:"allowed_feed_hosts,omitempty"`
+	MaxFeeds                    int               `yaml:"max_feeds,omitempty"`
+	MinFetchIntervalMinutes     int               `yaml:"min_fetch_interval_minutes,omitempty"`
+	DBRetryAttempts             int               `yaml:"db_retry_attempts,omitempty"`
+	BackfillConcurrency         int               `yaml:"backfill_concurrency,omitempty"`
+	CoalesceFetches             bool              `yaml:"coalesce_fetches,omitempty"`
+	HostBackoff                 bool              `yaml:"host_backoff,omitempty"`
+	HostBackoffMaxMinutes       int               `yaml:"host_backoff_max_minutes,omitempty"`
+	ShowFavicons                bool              `yaml:"show_favicons,omitempty"`
+	EnablePprof                 bool              `yaml:"enable_pprof,omitempty"`
+	PreviewCacheSize            int               `yaml:"preview_cache_size,omitempty"`
+	StaticMaxAgeSeconds         int               `yaml:"static_max_age_seconds,omitempty"`
+	SampleItem                  *SampleItem       `yaml:"sample_item,omitempty"`
+	Partials                    map[string]string `yaml:"partials,omitempty"`
+	TickerWarningThreshold      int               `yaml:"ticker_warning_threshold,omitempty"`
+	StoredDescription           string            `yaml:"stored_description,omitempty"`
+	StoredDescriptionLength     int               `yaml:"stored_description_length,omitempty"`
+	TelegramTimeoutSeconds      int               `yaml:"telegram_timeout_seconds,omitempty"`
+	DeliveryLog                 string            `yaml:"delivery_log,omitempty"`
+	FeedsDir                    string            `yaml:"feeds_dir,omitempty"`
+	Feeds                       []Feed            `yaml:"feeds"`
 }
 
 // Feed represents a single RSS feed configuration
 type Feed struct {
-	FeedUrl                  string `yaml:"feed_url"`
-	FeedFetchIntervalMinutes int    `yaml:"feed_fetch_interval_minutes"`
-	FeedRetentionDays        int    `yaml:"feed_retention_days"`
-	TelegramApiToken         string `yaml:"telegram_api_token"`
-	TelegramChatId           int64  `yaml:"telegram_chat_id"`
-	TelegramMessageThreadId  int64  `yaml:"telegram_message_thread_id"`
-	TelegramTemplate         string `yaml:"telegram_template"`
+	Name                     string                 `yaml:"name,omitempty"`
+	ID                       string                 `yaml:"id,omitempty"`
+	Tags                     []string               `yaml:"tags,omitempty"`
+	FeedUrl                  string                 `yaml:"feed_url"`
+	FeedFetchIntervalMinutes int                    `yaml:"feed_fetch_interval_minutes"`
+	AutoInterval             bool                   `yaml:"auto_interval,omitempty"`
+	FeedRetentionDays        int                    `yaml:"feed_retention_days"`
+	ActiveHours              string                 `yaml:"active_hours,omitempty"`
+	ActiveTimezone           string                 `yaml:"active_timezone,omitempty"`
+	RetentionKey             string                 `yaml:"retention_key,omitempty"`
+	TelegramApiToken         string                 `yaml:"telegram_api_token"`
+	TelegramChatId           ChatID                 `yaml:"telegram_chat_id"`
+	TelegramMessageThreadId  int64                  `yaml:"telegram_message_thread_id"`
+	TelegramTemplate         string                 `yaml:"telegram_template"`
+	DedupFields              []string               `yaml:"dedup_fields,omitempty"`
+	MissingIdentity          string                 `yaml:"missing_identity,omitempty"`
+	AlwaysAppendLink         bool                   `yaml:"always_append_link,omitempty"`
+	AppendSourceDomain       bool                   `yaml:"append_source_domain,omitempty"`
+	DisableWebPagePreview    bool                   `yaml:"disable_web_page_preview,omitempty"`
+	SilentNotifications      bool                   `yaml:"silent_notifications,omitempty"`
+	StaleAfterDays           int                    `yaml:"stale_after_days,omitempty"`
+	Routes                   []FeedRoute            `yaml:"routes,omitempty"`
+	RouteAlsoToDefault       bool                   `yaml:"route_also_to_default,omitempty"`
+	FallbackChatID           ChatID                 `yaml:"fallback_chat_id,omitempty"`
+	FallbackMessageThreadID  int64                  `yaml:"fallback_message_thread_id,omitempty"`
+	SendAsPhoto              bool                   `yaml:"send_as_photo,omitempty"`
+	CaptionTemplate          string                 `yaml:"caption_template,omitempty"`
+	SkipInitialFetch         bool                   `yaml:"skip_initial_fetch,omitempty"`
+	MinItemsBeforePost       int                    `yaml:"min_items_before_post,omitempty"`
+	DigestEnabled            bool                   `yaml:"digest_enabled,omitempty"`
+	DigestIntervalMinutes    int                    `yaml:"digest_interval_minutes,omitempty"`
+	DigestFlushCount         int                    `yaml:"digest_flush_count,omitempty"`
+	DigestTemplate           string                 `yaml:"digest_template,omitempty"`
+	DigestItemTemplate       string                 `yaml:"digest_item_template,omitempty"`
+	DigestOrder              string                 `yaml:"digest_order,omitempty"`
+	DigestMaxItems           int                    `yaml:"digest_max_items,omitempty"`
+	ParseModes               []string               `yaml:"parse_modes,omitempty"`
+	TrustSource              bool                   `yaml:"trust_source,omitempty"`
+	HoldFutureItems          bool                   `yaml:"hold_future_items,omitempty"`
+	MaxFutureHours           int                    `yaml:"max_future_hours,omitempty"`
+	PinStartMessage          bool                   `yaml:"pin_start_message,omitempty"`
+	ParallelFanOut           bool                   `yaml:"parallel_fan_out,omitempty"`
+	MessageType              string                 `yaml:"message_type,omitempty"`
+	LatitudeField            string                 `yaml:"latitude_field,omitempty"`
+	LongitudeField           string                 `yaml:"longitude_field,omitempty"`
+	AuthorFormat             string                 `yaml:"author_format,omitempty"`
+	AuthorsSeparator         string                 `yaml:"authors_separator,omitempty"`
+	BodyPreference           string                 `yaml:"body_preference,omitempty"`
+	StripTrackingParams      bool                   `yaml:"strip_tracking_params,omitempty"`
+	TrackingParams           []string               `yaml:"tracking_params,omitempty"`
+	ResolveLinks             bool                   `yaml:"resolve_links,omitempty"`
+	UndatedItems             string                 `yaml:"undated_items,omitempty"`
+	TemplateError            string                 `yaml:"template_error,omitempty"`
+	EmptyMessage             string                 `yaml:"empty_message,omitempty"`
+	ParseTimeoutSeconds      int                    `yaml:"parse_timeout_seconds,omitempty"`
+	Signature                string                 `yaml:"signature,omitempty"`
+	ProtectContent           bool                   `yaml:"protect_content,omitempty"`
+	Paused                   bool                   `yaml:"paused,omitempty"`
+	Mode                     string                 `yaml:"mode,omitempty"`
+	CatchUpItems             int                    `yaml:"catch_up_items,omitempty"`
+	ReplyToField             string                 `yaml:"reply_to_field,omitempty"`
+	RawEnclosureSizes        bool                   `yaml:"raw_enclosure_sizes,omitempty"`
+	ExtraTelegramParams      map[string]interface{} `yaml:"extra_telegram_params,omitempty"`
+	SendFailure              string                 `yaml:"send_failure,omitempty"`
+	OnlyLanguages            []string               `yaml:"only_languages,omitempty"`
+	UnknownLanguage          string                 `yaml:"unknown_language,omitempty"`
+	CategoryFormat           string                 `yaml:"category_format,omitempty"`
+	HashtagWords             string                 `yaml:"hashtag_words,omitempty"`
+	CategoriesSeparator      string                 `yaml:"categories_separator,omitempty"`
+	SampleItem               *SampleItem            `yaml:"sample_item,omitempty"`
+
+	partials     map[string]string // the configuration's partials, set by Config.linkPartials
+	replyTo      int64             // message to reply to, set per chat by the scheduler
+	inheritsFrom string            // key whose sent items the feed takes over, set when its id is assigned at load
+}
+
+// FeedRoute sends items matching a category or keyword to a different chat
+type FeedRoute struct {
+	Categories              []string `yaml:"categories,omitempty"`
+	Keywords                []string `yaml:"keywords,omitempty"`
+	TelegramChatId          ChatID   `yaml:"telegram_chat_id"`
+	TelegramMessageThreadId int64    `yaml:"telegram_message_thread_id,omitempty"`
+}
+
+// DisplayName returns the feed name, falling back to the feed URL
+func (f Feed) DisplayName() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return f.FeedUrl
+}
+
+// Key identifies the feed in the database and the scheduler: its id when set, so that
+// feeds sharing a URL keep separate state, and its URL otherwise
+func (f Feed) Key() string {
+	if f.ID != "" {
+		return f.ID
+	}
+	return f.FeedUrl
+}
+
+// TagList returns the feed tags as a comma-separated list
+func (f Feed) TagList() string {
+	return strings.Join(f.Tags, ", ")
+}
+
+// HasTag reports whether the feed has the given tag
+func (f Feed) HasTag(tag string) bool {
+	for _, t := range f.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
 }
 
 // TelegramMessage represents the structure for sending messages to Telegram
 type TelegramMessage struct {
-	ChatID              int64  `json:"chat_id"`
+	ChatID              ChatID `json:"chat_id"`
 	Text                string `json:"text"`
 	ParseMode           string `json:"parse_mode,omitempty"`
 	MessageThreadID     int64  `json:"message_thread_id,omitempty"`
 	DisableNotification bool   `json:"disable_notification,omitempty"`
+	ProtectContent      bool   `json:"protect_content,omitempty"`
+
+	DisableWebPagePreview bool `json:"disable_web_page_preview,omitempty"`
+
+	ReplyParameters *ReplyParameters `json:"reply_parameters,omitempty"`
+
+	// ExtraParams are further sendMessage parameters, e.g. ones added to the Bot API after
+	// this struct. The fields above take precedence over them.
+	ExtraParams map[string]interface{} `json:"-"`
+}
+
+// ReplyParameters makes a message a reply to an earlier message in the same chat
+type ReplyParameters struct {
+	MessageID                int64 `json:"message_id"`
+	AllowSendingWithoutReply bool  `json:"allow_sending_without_reply,omitempty"`
+}
+
+// MarshalJSON builds the Telegram API payload. Chat IDs are encoded as numbers or
+// @usernames and optional fields are only included when they are set.
+func (m TelegramMessage) MarshalJSON() ([]byte, error) {
+	payload := make(map[string]interface{}, len(m.ExtraParams)+2)
+	for name, value := range m.ExtraParams {
+		payload[name] = value
+	}
+	payload["chat_id"] = m.ChatID
+	payload["text"] = m.Text
+	if m.ParseMode != "" {
+		payload["parse_mode"] = m.ParseMode
+	}
+	if m.MessageThreadID > 0 {
+		payload["message_thread_id"] = m.MessageThreadID
+	}
+	if m.DisableNotification {
+		payload["disable_notification"] = true
+	}
+	if m.ProtectContent {
+		payload["protect_content"] = true
+	}
+	if m.DisableWebPagePreview {
+		payload["disable_web_page_preview"] = true
+	}
+	if m.ReplyParameters != nil {
+		payload["reply_parameters"] = m.ReplyParameters
+	}
+	return json.Marshal(payload)
+}
+
+// TelegramPhoto represents the structure for sending photos to Telegram
+type TelegramPhoto struct {
+	ChatID              ChatID `json:"chat_id"`
+	Photo               string `json:"photo"`
+	Caption             string `json:"caption,omitempty"`
+	ParseMode           string `json:"parse_mode,omitempty"`
+	MessageThreadID     int64  `json:"message_thread_id,omitempty"`
+	DisableNotification bool   `json:"disable_notification,omitempty"`
+	ProtectContent      bool   `json:"protect_content,omitempty"`
+
+	ReplyParameters *ReplyParameters `json:"reply_parameters,omitempty"`
+}
+
+// MarshalJSON builds the Telegram API payload for a photo
+func (p TelegramPhoto) MarshalJSON() ([]byte, error) {
+	payload := map[string]interface{}{
+		"chat_id": p.ChatID,
+		"photo":   p.Photo,
+	}
+	if p.Caption != "" {
+		payload["caption"] = p.Caption
+	}
+	if p.ParseMode != "" {
+		payload["parse_mode"] = p.ParseMode
+	}
+	if p.MessageThreadID > 0 {
+		payload["message_thread_id"] = p.MessageThreadID
+	}
+	if p.DisableNotification {
+		payload["disable_notification"] = true
+	}
+	if p.ProtectContent {
+		payload["protect_content"] = true
+	}
+	if p.ReplyParameters != nil {
+		payload["reply_parameters"] = p.ReplyParameters
+	}
+	return json.Marshal(payload)
+}
+
+// TelegramLocation represents the structure for sending a location to Telegram
+type TelegramLocation struct {
+	ChatID              ChatID  `json:"chat_id"`
+	Latitude            float64 `json:"latitude"`
+	Longitude           float64 `json:"longitude"`
+	MessageThreadID     int64   `json:"message_thread_id,omitempty"`
+	DisableNotification bool    `json:"disable_notification,omitempty"`
+	ProtectContent      bool    `json:"protect_content,omitempty"`
 }
 
 // FeedItem represents a feed item in the database
@@ -43,6 +281,28 @@ type FeedItem struct {
 	PublishedAt time.Time `json:"published_at"`
 	CreatedAt   time.Time `json:"created_at"`
 	FeedURL     string    `json:"feed_url"`
+
+	// DateSynthesized is set when the item had no publication date and PublishedAt was
+	// filled in according to the feed's undated_items policy
+	DateSynthesized bool `json:"date_synthesized"`
+}
+
+// DigestItem is a rendered feed item waiting to be sent in a digest
+type DigestItem struct {
+	ID        int64     `json:"id"`
+	FeedURL   string    `json:"feed_url"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PendingItem is a new feed item buffered until its feed has collected enough items to post
+type PendingItem struct {
+	ID        int64     `json:"id"`
+	FeedURL   string    `json:"feed_url"`
+	GUID      string    `json:"guid"`
+	ItemJSON  string    `json:"item_json"`
+	FeedJSON  string    `json:"feed_json"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 /*
@@ -53,15 +313,20 @@ Basic Feed Item Properties:
 - {{.Title}}           : Title of the feed item (from Item.Title)
 - {{.Description}}     : Description or summary of the feed item (from Item.Description)
 - {{.Content}}         : Full content of the feed item (from Item.Content)
+- {{.Body}}            : Description or content, whichever the feed's body_preference picks
 - {{.Link}}            : URL link to the original article (from Item.Link)
 - {{.Updated}}         : Update timestamp as string (from Item.Updated)
 - {{.Published}}       : Publication timestamp as string (from Item.Published)
 - {{.GUID}}            : Globally unique identifier for the item (from Item.GUID)
+- {{.SourceDomain}}    : Domain of the item link, or of the feed's website, e.g. "example.com"
 
 Author Information (from gofeed.Item.Author and gofeed.Item.Authors):
 - {{.Author}}          : Author name (from Item.Author.Name)
 - {{.AuthorEmail}}     : Author email address (from Item.Author.Email)
-- {{.Authors}}         : All authors with names and emails (from Item.Authors slice)
+- {{.Authors}}         : All authors with names and emails (from Item.Authors slice),
+                         formatted with the feed's author_format and authors_separator.
+                         Also a list, each with {{.Name}} and {{.Email}}, e.g.
+                         {{range .Authors}}{{.Name}} {{end}}
 
 Category Information (from gofeed.Item.Categories):
 - {{.Categories}}      : Comma-separated list of categories (from Item.Categories slice)
@@ -69,6 +334,7 @@ Category Information (from gofeed.Item.Categories):
 Media and Image Information (from gofeed.Item.Image):
 - {{.ImageURL}}        : URL of the featured image (from Item.Image.URL)
 - {{.ImageTitle}}      : Title/alt text of the featured image (from Item.Image.Title)
+- {{.ContentImage}}    : First <img> found in the content or description, resolved against the item link
 
 Links Information (from gofeed.Item.Links):
 - {{.Links}}           : Additional links associated with the item (from Item.Links slice)
@@ -76,6 +342,7 @@ Links Information (from gofeed.Item.Links):
 Enclosures Information (from gofeed.Item.Enclosures):
 - {{.Enclosures}}      : Media enclosures (audio, video, etc.) (from Item.Enclosures slice)
   Each enclosure has: URL, Length, Type (accessed as part of the combined string)
+  Lengths are shown in readable units such as "50 MB" unless raw_enclosure_sizes is set
 
 Date and Time Information (from gofeed.Item fields):
 - {{.UpdatedParsed}}   : Parsed update timestamp (from Item.UpdatedParsed)
@@ -93,13 +360,18 @@ Feed-Level Properties (from gofeed.Feed):
 - {{.FeedGenerator}}   : Generator of the feed (from Feed.Generator)
 - {{.FeedType}}        : Type of the feed (RSS, Atom, etc.) (from Feed.FeedType)
 - {{.FeedVersion}}     : Version of the feed format (from Feed.FeedVersion)
+- {{.FeedUpdatePeriod}}    : Syndication update period, e.g. hourly or daily (from <sy:updatePeriod>)
+- {{.FeedUpdateFrequency}} : Number of updates per period (from <sy:updateFrequency>)
+- {{.FeedUpdateBase}}      : Base date for the update schedule (from <sy:updateBase>)
 
 All possible template variables supported by the system:
 - {{.Title}}
 - {{.Description}}
 - {{.Content}}
+- {{.Body}}
 - {{.Link}}
 - {{.Links}}
+- {{.SourceDomain}}
 - {{.Updated}}
 - {{.UpdatedParsed}}
 - {{.Published}}
@@ -110,6 +382,7 @@ All possible template variables supported by the system:
 - {{.GUID}}
 - {{.ImageURL}}
 - {{.ImageTitle}}
+- {{.ContentImage}}
 - {{.Categories}}
 - {{.Enclosures}}
 - {{.Custom}}
@@ -121,9 +394,29 @@ All possible template variables supported by the system:
 - {{.FeedGenerator}}
 - {{.FeedType}}
 - {{.FeedVersion}}
+- {{.FeedUpdatePeriod}}
+- {{.FeedUpdateFrequency}}
+- {{.FeedUpdateBase}}
 
 Note: Both item-level and feed-level variables are supported. The system processes both individual feed items
 and feed metadata, making all these variables available for use in templates.
 
 These variables correspond to the gofeed.Item structure fields and can be used in both test templates and feed-specific templates.
+
+Templates are Go text/template templates, so actions such as {{if .Author}}by {{.Author}}{{end}} work
+as well. The default function supplies a value for empty variables: {{.Author | default "Anonymous"}}.
+{{truncate .Description 200}} and {{truncateWords .Content 40}} shorten a variable to a number of characters
+or words, between words and with an ellipsis, without breaking its HTML tags.
+
+Alert Template Variables (used by alert_template when a feed keeps failing):
+- {{.FeedName}}        : Name of the failing feed (falls back to its URL)
+- {{.FeedURL}}         : URL of the failing feed
+- {{.Error}}           : The last fetch error
+- {{.FailCount}}       : Number of consecutive failed fetches
+
+Digest Template Variables (used by digest_template when digest_enabled is set):
+- {{.FeedName}}        : Name of the feed (falls back to its URL)
+- {{.FeedURL}}         : URL of the feed
+- {{.Items}}           : The buffered items, each rendered with digest_item_template
+- {{.Count}}           : Number of items in this digest message
 */
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// marshalPayload marshals a Telegram request and decodes it back into its fields
+func marshalPayload(t *testing.T, v interface{}) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	return payload
+}
+
+func TestTelegramMessageMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  TelegramMessage
+		want map[string]interface{}
+	}{
+		{
+			name: "numeric chat, no optional fields",
+			msg:  TelegramMessage{ChatID: "-1001234", Text: "hi"},
+			want: map[string]interface{}{"chat_id": float64(-1001234), "text": "hi"},
+		},
+		{
+			name: "username chat",
+			msg:  TelegramMessage{ChatID: "@channelname", Text: "hi", ParseMode: "HTML"},
+			want: map[string]interface{}{"chat_id": "@channelname", "text": "hi", "parse_mode": "HTML"},
+		},
+		{
+			name: "zero thread ID omitted",
+			msg:  TelegramMessage{ChatID: "1", Text: "hi", MessageThreadID: 0},
+			want: map[string]interface{}{"chat_id": float64(1), "text": "hi"},
+		},
+		{
+			name: "thread ID and flags",
+			msg:  TelegramMessage{ChatID: "1", Text: "hi", MessageThreadID: 7, DisableNotification: true, DisableWebPagePreview: true},
+			want: map[string]interface{}{"chat_id": float64(1), "text": "hi", "message_thread_id": float64(7), "disable_notification": true, "disable_web_page_preview": true},
+		},
+		{
+			name: "reply",
+			msg:  TelegramMessage{ChatID: "1", Text: "hi", ReplyParameters: &ReplyParameters{MessageID: 3}},
+			want: map[string]interface{}{"chat_id": float64(1), "text": "hi", "reply_parameters": map[string]interface{}{"message_id": float64(3)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := marshalPayload(t, tt.msg)
+			if len(payload) != len(tt.want) {
+				t.Fatalf("got %v, want %v", payload, tt.want)
+			}
+			for key, want := range tt.want {
+				if got, _ := json.Marshal(payload[key]); string(got) != mustJSON(t, want) {
+					t.Errorf("%s: got %s, want %s", key, got, mustJSON(t, want))
+				}
+			}
+		})
+	}
+}
+
+func TestTelegramPhotoMarshalJSON(t *testing.T) {
+	payload := marshalPayload(t, TelegramPhoto{ChatID: "@channelname", Photo: "https://example.com/a.jpg", Caption: "A"})
+	if payload["chat_id"] != "@channelname" || payload["photo"] != "https://example.com/a.jpg" || payload["caption"] != "A" {
+		t.Fatalf("unexpected payload %v", payload)
+	}
+	if _, ok := payload["message_thread_id"]; ok {
+		t.Fatal("zero thread ID was included")
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestProtectContentOnlyWhenEnabled(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		disabled  interface{}
+		protected interface{}
+	}{
+		{"message", TelegramMessage{ChatID: "1", Text: "hi"}, TelegramMessage{ChatID: "1", Text: "hi", ProtectContent: true}},
+		{"photo", TelegramPhoto{ChatID: "1", Photo: "https://example.com/a.jpg"}, TelegramPhoto{ChatID: "1", Photo: "https://example.com/a.jpg", ProtectContent: true}},
+		{"location", TelegramLocation{ChatID: "1", Latitude: 1, Longitude: 2}, TelegramLocation{ChatID: "1", Latitude: 1, Longitude: 2, ProtectContent: true}},
+	} {
+		if _, ok := marshalPayload(t, tc.disabled)["protect_content"]; ok {
+			t.Errorf("%s: protect_content included while disabled", tc.name)
+		}
+		if got := marshalPayload(t, tc.protected)["protect_content"]; got != true {
+			t.Errorf("%s: got protect_content %v, want true", tc.name, got)
+		}
+	}
+}
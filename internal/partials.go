@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// maxPartialDepth limits how deeply partials may include other partials
+const maxPartialDepth = 5
+
+// partialPattern matches a partial reference such as {{template "footer" .}}
+var partialPattern = regexp.MustCompile(`\{\{\s*template\s+"([^"]+)"(?:\s+\.)?\s*\}\}`)
+
+// partialReferences returns the names of the partials a template references
+func partialReferences(template string) []string {
+	var names []string
+	for _, match := range partialPattern.FindAllStringSubmatch(template, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// validatePartials checks that the partials are valid templates and only reference partials that
+// exist, without cycles or deeper nesting than maxPartialDepth
+func validatePartials(partials map[string]string) error {
+	names := make([]string, 0, len(partials))
+	for name := range partials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := validateTemplate(name, partials[name]); err != nil {
+			return fmt.Errorf("partial %q: %v", name, err)
+		}
+	}
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		for _, seen := range path {
+			if seen == name {
+				return fmt.Errorf("partial %q includes itself", name)
+			}
+		}
+		if len(path) > maxPartialDepth {
+			return fmt.Errorf("partial %q is nested more than %d levels deep", name, maxPartialDepth)
+		}
+		for _, reference := range partialReferences(partials[name]) {
+			if _, ok := partials[reference]; !ok {
+				return fmt.Errorf("partial %q uses unknown partial %q", name, reference)
+			}
+			if err := visit(reference, append(path, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateFeedPartials checks that every partial referenced by a feed's templates exists
+func validateFeedPartials(feed Feed, partials map[string]string) error {
+	templates := []struct{ name, template string }{
+		{"telegram_template", feed.TelegramTemplate},
+		{"caption_template", feed.CaptionTemplate},
+		{"digest_item_template", feed.DigestItemTemplate},
+	}
+	for _, t := range templates {
+		for _, reference := range partialReferences(t.template) {
+			if _, ok := partials[reference]; !ok {
+				return fmt.Errorf("%s uses unknown partial %q", t.name, reference)
+			}
+		}
+	}
+	return nil
+}
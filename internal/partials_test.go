@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testPartials is a footer partial and a signature partial that includes it
+var testPartials = map[string]string{
+	"footer":    `<a href="{{.Link}}">Read more</a>`,
+	"signature": `{{template "footer" .}} · {{.FeedTitle}}`,
+}
+
+func TestFeedTemplateRendersSharedPartial(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First", Link: "https://example.com/1"}))
+	news, blog := testFeed(server.URL), testFeed(server.URL+"?blog")
+	news.TelegramTemplate = `<b>{{.Title}}</b>` + "\n" + `{{template "signature" .}}`
+	blog.TelegramTemplate = `{{.Title}} {{template "footer" .}}`
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{news, blog}, Partials: testPartials})
+
+	feeds := fs.configManager.Get().Feeds
+	fs.runFeed(feeds[0])
+	fs.runFeed(feeds[1])
+
+	want := []string{
+		"<b>First</b>\n" + `<a href="https://example.com/1">Read more</a> · Test feed`,
+		`First <a href="https://example.com/1">Read more</a>`,
+	}
+	if texts := sentTexts(recorder); !reflect.DeepEqual(texts, want) {
+		t.Fatalf("got messages %q, want %q", texts, want)
+	}
+}
+
+func TestPartialReferences(t *testing.T) {
+	got := partialReferences(`{{.Title}} {{template "footer" .}}{{ template "tags" }}`)
+	if want := []string{"footer", "tags"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidatePartials(t *testing.T) {
+	if err := validatePartials(testPartials); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		partials map[string]string
+		want     string
+	}{
+		{map[string]string{"footer": `{{template "missing" .}}`}, `unknown partial "missing"`},
+		{map[string]string{"a": `{{template "b" .}}`, "b": `{{template "a" .}}`}, "includes itself"},
+		{map[string]string{"footer": `{{.Title`}, `partial "footer"`},
+	} {
+		if err := validatePartials(tc.partials); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: got error %v, want one mentioning %q", tc.partials, err, tc.want)
+		}
+	}
+}
+
+func TestConfigRejectsUnknownPartial(t *testing.T) {
+	feed := testFeed("https://example.com/feed.xml")
+	feed.TelegramTemplate = `{{.Title}} {{template "missing" .}}`
+	config := &Config{Feeds: []Feed{feed}, Partials: testPartials}
+
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), `unknown partial "missing"`) {
+		t.Fatalf("got error %v, want the unknown partial", err)
+	}
+}
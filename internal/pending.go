@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// bufferItem stores a new item until the feed has collected MinItemsBeforePost of them.
+// The item is kept in the database together with the feed metadata, so the buffer
+// survives restarts and the item can still be posted after it drops out of the feed.
+func (fs *FeedScheduler) bufferItem(feed Feed, feedData *gofeed.Feed, item *gofeed.Item, key string) error {
+	itemJSON, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode pending item: %v", err)
+	}
+
+	feedMeta := *feedData
+	feedMeta.Items = nil
+	feedJSON, err := json.Marshal(feedMeta)
+	if err != nil {
+		return fmt.Errorf("failed to encode pending item feed: %v", err)
+	}
+
+	return fs.dbManager.SavePendingItem(PendingItem{
+		FeedURL:  feed.Key(),
+		GUID:     key,
+		ItemJSON: string(itemJSON),
+		FeedJSON: string(feedJSON),
+	})
+}
+
+// flushPendingItems posts the buffered items of a feed, oldest first, once there are at
+// least MinItemsBeforePost of them. Items that fail to send stay buffered.
+func (fs *FeedScheduler) flushPendingItems(feed Feed) {
+	pending, err := fs.dbManager.PendingItems(feed.Key())
+	if err != nil {
+		log.Printf("Error loading pending items for feed %s: %v", feed.FeedUrl, err)
+		return
+	}
+
+	if len(pending) < feed.MinItemsBeforePost {
+		if len(pending) > 0 {
+			log.Printf("Feed %s has %d of %d items needed before posting", feed.FeedUrl, len(pending), feed.MinItemsBeforePost)
+		}
+		return
+	}
+
+	for _, p := range pending {
+		var item gofeed.Item
+		var feedData gofeed.Feed
+		if err := json.Unmarshal([]byte(p.ItemJSON), &item); err != nil {
+			log.Printf("Dropping unreadable pending item %s: %v", p.GUID, err)
+			fs.dbManager.DeletePendingItem(p.ID)
+			continue
+		}
+		if err := json.Unmarshal([]byte(p.FeedJSON), &feedData); err != nil {
+			log.Printf("Dropping unreadable pending item %s: %v", p.GUID, err)
+			fs.dbManager.DeletePendingItem(p.ID)
+			continue
+		}
+
+		err = fs.sendAndRecordItem(feed, &feedData, &item, p.GUID)
+		if err != nil {
+			log.Printf("Error delivering pending feed item: %v", err)
+			return
+		}
+
+		err = fs.dbManager.DeletePendingItem(p.ID)
+		if err != nil {
+			log.Printf("Error removing pending item %s: %v", p.GUID, err)
+		}
+	}
+}
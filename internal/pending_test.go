@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMinItemsBeforePostBuffersUntilThreshold(t *testing.T) {
+	server := newFeedServer(t, rssFeed(
+		testItem{GUID: "2", Title: "Second"},
+		testItem{GUID: "1", Title: "First"},
+	))
+	feed := testFeed(server.URL)
+	feed.MinItemsBeforePost = 3
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	fs.runFeed(feed)
+	fs.runFeed(feed)
+	if texts := sentTexts(recorder); len(texts) != 0 {
+		t.Fatalf("got messages %q below the threshold", texts)
+	}
+	pending, err := fs.dbManager.PendingItems(feed.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("got %d buffered items, want 2", len(pending))
+	}
+
+	// The buffer is kept in the database, so a restarted scheduler picks it up
+	restarted := NewFeedScheduler(fs.configManager, fs.dbManager)
+	restarted.telegram.Client = recorder.client()
+	t.Cleanup(restarted.Stop)
+
+	server.setBody(rssFeed(
+		testItem{GUID: "3", Title: "Third"},
+		testItem{GUID: "2", Title: "Second"},
+		testItem{GUID: "1", Title: "First"},
+	))
+	restarted.runFeed(feed)
+
+	if texts, want := sentTexts(recorder), []string{"First", "Second", "Third"}; !reflect.DeepEqual(texts, want) {
+		t.Fatalf("got messages %q, want %q", texts, want)
+	}
+	if pending, _ := fs.dbManager.PendingItems(feed.Key()); len(pending) != 0 {
+		t.Fatalf("%d items left in the buffer", len(pending))
+	}
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+)
+
+// defaultPreviewCacheSize is how many previews are kept for test sends when
+// preview_cache_size is not set
+const defaultPreviewCacheSize = 50
+
+// previewCacheSize returns how many previews are kept for test sends
+func (c *Config) previewCacheSize() int {
+	if c.PreviewCacheSize > 0 {
+		return c.PreviewCacheSize
+	}
+	return defaultPreviewCacheSize
+}
+
+// previewSet holds the items of one feed preview
+type previewSet struct {
+	id    string
+	items []map[string]interface{}
+}
+
+// previewStore keeps the items of recent feed previews so that they can be sent as test
+// messages. Every preview gets its own ID, so concurrent previews don't replace each
+// other's items, and only the most recently used previews are kept.
+type previewStore struct {
+	mu     sync.Mutex
+	nextID uint64
+	order  *list.List // most recently used first
+	sets   map[string]*list.Element
+}
+
+// newPreviewStore creates an empty preview store
+func newPreviewStore() *previewStore {
+	return &previewStore{
+		order: list.New(),
+		sets:  make(map[string]*list.Element),
+	}
+}
+
+// previews holds the items of the feeds previewed on the index page
+var previews = newPreviewStore()
+
+// add stores the items of a preview, at most maxPreviewItems of them, and returns its ID.
+// The least recently used previews are dropped to keep at most capacity of them.
+func (ps *previewStore) add(items []map[string]interface{}, capacity int) string {
+	if len(items) > maxPreviewItems {
+		items = items[:maxPreviewItems]
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.nextID++
+	id := strconv.FormatUint(ps.nextID, 10)
+	ps.sets[id] = ps.order.PushFront(&previewSet{id: id, items: items})
+
+	for ps.order.Len() > capacity {
+		oldest := ps.order.Back()
+		ps.order.Remove(oldest)
+		delete(ps.sets, oldest.Value.(*previewSet).id)
+	}
+
+	return id
+}
+
+// item returns an item of a preview and marks the preview as recently used. An empty ID
+// stands for the latest preview. The boolean is false when the preview was dropped or
+// has no item at the index.
+func (ps *previewStore) item(id string, index int) (map[string]interface{}, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	element := ps.order.Front()
+	if id != "" {
+		element = ps.sets[id]
+	}
+	if element == nil {
+		return nil, false
+	}
+	ps.order.MoveToFront(element)
+
+	set := element.Value.(*previewSet)
+	if index < 0 || index >= len(set.items) {
+		return nil, false
+	}
+	return set.items[index], true
+}
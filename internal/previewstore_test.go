@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"sync"
+	"testing"
+)
+
+// previewItems returns preview items with the given titles
+func previewItems(titles ...string) []map[string]interface{} {
+	items := make([]map[string]interface{}, len(titles))
+	for i, title := range titles {
+		items[i] = map[string]interface{}{"Title": title}
+	}
+	return items
+}
+
+func TestPreviewStoreEvictsOldest(t *testing.T) {
+	ps := newPreviewStore()
+	first := ps.add(previewItems("a"), 2)
+	second := ps.add(previewItems("b"), 2)
+	third := ps.add(previewItems("c"), 2)
+
+	if _, ok := ps.item(first, 0); ok {
+		t.Fatal("oldest preview was kept past the cap")
+	}
+	for id, want := range map[string]string{second: "b", third: "c"} {
+		if item, ok := ps.item(id, 0); !ok || item["Title"] != want {
+			t.Fatalf("preview %s: got %v, want %q", id, item, want)
+		}
+	}
+}
+
+func TestPreviewStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	ps := newPreviewStore()
+	first := ps.add(previewItems("a"), 2)
+	second := ps.add(previewItems("b"), 2)
+
+	// Sending from the first preview makes the second the least recently used
+	if _, ok := ps.item(first, 0); !ok {
+		t.Fatal("first preview missing")
+	}
+	ps.add(previewItems("c"), 2)
+
+	if _, ok := ps.item(second, 0); ok {
+		t.Fatal("least recently used preview was kept")
+	}
+	if _, ok := ps.item(first, 0); !ok {
+		t.Fatal("recently used preview was evicted")
+	}
+	// An empty ID is the most recently used preview
+	if item, ok := ps.item("", 0); !ok || item["Title"] != "a" {
+		t.Fatalf("got %v for the latest preview", item)
+	}
+}
+
+func TestPreviewStoreLimitsItemsPerSet(t *testing.T) {
+	ps := newPreviewStore()
+	id := ps.add(previewItems("1", "2", "3", "4", "5", "6", "7", "8"), defaultPreviewCacheSize)
+
+	if _, ok := ps.item(id, maxPreviewItems-1); !ok {
+		t.Fatal("last kept item missing")
+	}
+	if _, ok := ps.item(id, maxPreviewItems); ok {
+		t.Fatalf("item past the limit of %d was kept", maxPreviewItems)
+	}
+	if _, ok := ps.item(id, -1); ok {
+		t.Fatal("negative index returned an item")
+	}
+}
+
+func TestPreviewStoreConcurrentAdds(t *testing.T) {
+	ps := newPreviewStore()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := ps.add(previewItems("x"), 10)
+			ps.item(id, 0)
+		}()
+	}
+	wg.Wait()
+
+	if n := ps.order.Len(); n != 10 || len(ps.sets) != 10 {
+		t.Fatalf("got %d previews and %d IDs, want 10", n, len(ps.sets))
+	}
+}
+
+func TestPreviewCacheSize(t *testing.T) {
+	if n := (&Config{}).previewCacheSize(); n != defaultPreviewCacheSize {
+		t.Fatalf("got default %d, want %d", n, defaultPreviewCacheSize)
+	}
+	if n := (&Config{PreviewCacheSize: 3}).previewCacheSize(); n != 3 {
+		t.Fatalf("got %d, want 3", n)
+	}
+}
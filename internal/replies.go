@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"log"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// replyTo returns the ID of the message an item should reply to in a chat: the message
+// the item named by the feed's reply_to_field was sent as. It is 0 when the feed doesn't
+// reply or the parent item isn't known, in which case the item is posted on its own.
+func (fs *FeedScheduler) replyTo(feed Feed, item *gofeed.Item, chatID ChatID) int64 {
+	if feed.ReplyToField == "" {
+		return 0
+	}
+	parent := replyReference(item, feed.ReplyToField)
+	if parent == "" {
+		return 0
+	}
+
+	messageID, found, err := fs.dbManager.SentMessage(chatID, parent)
+	if err != nil {
+		log.Printf("Error looking up parent message of feed item: %v", err)
+		return 0
+	}
+	if !found {
+		log.Printf("Parent %s of feed item not found in chat %s, sending it on its own: %s", parent, chatID, item.Title)
+		return 0
+	}
+	return messageID
+}
+
+// replyReference returns the parent reference an item carries in a field. Elements such
+// as Atom's <thr:in-reply-to ref="..." href="..."/> keep it in attributes instead of text.
+func replyReference(item *gofeed.Item, field string) string {
+	if value := itemFieldValue(item, field); value != "" {
+		return value
+	}
+	namespace, name, ok := strings.Cut(field, ":")
+	if !ok || len(item.Extensions[namespace][name]) == 0 {
+		return ""
+	}
+	attrs := item.Extensions[namespace][name][0].Attrs
+	if ref := strings.TrimSpace(attrs["ref"]); ref != "" {
+		return ref
+	}
+	return strings.TrimSpace(attrs["href"])
+}
+
+// replyParameters returns the reply parameters for a message replying to messageID, or
+// nil when it isn't a reply. The message is still sent if the parent was deleted.
+func replyParameters(messageID int64) *ReplyParameters {
+	if messageID == 0 {
+		return nil
+	}
+	return &ReplyParameters{MessageID: messageID, AllowSendingWithoutReply: true}
+}
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+)
+
+// threadFeed is an Atom feed with a post, a reply to it and a reply to an unknown post
+const threadFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:thr="http://purl.org/syndication/thread/1.0">
+  <title>Thread</title>
+  <entry><id>urn:3</id><title>Orphan</title><thr:in-reply-to ref="urn:missing"/><updated>2024-01-03T00:00:00Z</updated></entry>
+  <entry><id>urn:2</id><title>Reply</title><thr:in-reply-to ref="urn:1" href="https://example.com/1"/><updated>2024-01-02T00:00:00Z</updated></entry>
+  <entry><id>urn:1</id><title>Post</title><link href="https://example.com/1"/><updated>2024-01-01T00:00:00Z</updated></entry>
+</feed>`
+
+func TestRepliesToStoredMessage(t *testing.T) {
+	server := newFeedServer(t, threadFeed)
+	feed := testFeed(server.URL)
+	feed.ReplyToField = "thr:in-reply-to"
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	fs.runFeed(feed)
+
+	calls := recorder.callsTo("sendMessage")
+	if len(calls) != 3 {
+		t.Fatalf("got %d messages, want 3", len(calls))
+	}
+	for i, want := range []string{"Post", "Reply", "Orphan"} {
+		if calls[i].text() != want {
+			t.Fatalf("message %d is %q, want %q", i, calls[i].text(), want)
+		}
+	}
+
+	// The post went out as message 1, the reply refers to it
+	if _, ok := calls[0].Payload["reply_parameters"]; ok {
+		t.Fatalf("post sent as a reply: %v", calls[0].Payload)
+	}
+	reply, ok := calls[1].Payload["reply_parameters"].(map[string]interface{})
+	if !ok || fmt.Sprint(reply["message_id"]) != "1" || reply["allow_sending_without_reply"] != true {
+		t.Fatalf("unexpected reply parameters %v", calls[1].Payload["reply_parameters"])
+	}
+	// Without a known parent the item is posted on its own
+	if _, ok := calls[2].Payload["reply_parameters"]; ok {
+		t.Fatalf("orphan sent as a reply: %v", calls[2].Payload)
+	}
+}
+
+func TestReplyParametersPayload(t *testing.T) {
+	if params := replyParameters(0); params != nil {
+		t.Fatalf("got %+v for no parent, want nil", params)
+	}
+
+	payload := marshalPayload(t, TelegramMessage{ChatID: "100", Text: "Reply", ReplyParameters: replyParameters(42)})
+	if got, want := mustJSON(t, payload["reply_parameters"]), `{"allow_sending_without_reply":true,"message_id":42}`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	payload = marshalPayload(t, TelegramMessage{ChatID: "100", Text: "Post"})
+	if _, ok := payload["reply_parameters"]; ok {
+		t.Fatalf("got reply_parameters in %v", payload)
+	}
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
+
+// errorResponse is the JSON body of an error response
+type errorResponse struct {
+	Error string `json:"error"`
+	Code  int    `json:"code"`
+}
+
+// wantsJSON reports whether the client expects JSON, either by asking for it in the
+// Accept header or by calling an /api route
+func wantsJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// writeError responds with a JSON error to API clients and an HTML error page otherwise
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if wantsJSON(r) {
+		writeJSON(w, status, errorResponse{Error: message, Code: status})
+		return
+	}
+	writeErrorPage(w, status, message)
+}
+
+// writeErrorPage renders the error page, or a bare HTML page with the escaped message
+// when the templates can't be loaded
+func writeErrorPage(w http.ResponseWriter, status int, message string) {
+	data := map[string]interface{}{
+		"Code":   status,
+		"Status": http.StatusText(status),
+		"Error":  message,
+	}
+	var page bytes.Buffer
+	tmpl, err := template.ParseFiles("templates/error.html", "templates/partials/navbar.html")
+	if err == nil {
+		err = tmpl.Execute(&page, data)
+	}
+	if err != nil {
+		log.Printf("Error rendering error page: %v", err)
+		page.Reset()
+		fmt.Fprintf(&page, "<!DOCTYPE html>\n<html><body><h1>%d %s</h1><p>%s</p></body></html>\n",
+			status, http.StatusText(status), template.HTMLEscapeString(message))
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(page.Bytes())
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// decodeErrorResponse decodes a JSON error body, failing the test if it isn't one
+func decodeErrorResponse(t *testing.T, rec *httptest.ResponseRecorder) errorResponse {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("got Content-Type %q, want JSON", ct)
+	}
+	var body errorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding error body %q: %v", rec.Body.String(), err)
+	}
+	return body
+}
+
+func TestWriteErrorNegotiatesFormat(t *testing.T) {
+	// The error page is rendered from the templates directory at the repository root
+	t.Chdir("..")
+	fs, _ := newTestScheduler(t, &Config{})
+	router := newTestRouter(fs)
+
+	req := httptest.NewRequest(http.MethodGet, "/feeds/abc/plan", nil)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	body := decodeErrorResponse(t, rec)
+	if rec.Code != http.StatusBadRequest || body.Code != http.StatusBadRequest || body.Error != "Invalid feed index" {
+		t.Fatalf("got %d %+v", rec.Code, body)
+	}
+
+	rec = serve(router, http.MethodGet, "/feeds/abc/plan", "")
+	if rec.Code != http.StatusBadRequest || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("got %d with Content-Type %q, want an HTML error", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	page := rec.Body.String()
+	if !strings.Contains(page, "<html") || !strings.Contains(page, "navbar") || !strings.Contains(page, "Invalid feed index") {
+		t.Fatalf("got body %q, want the error page", page)
+	}
+}
+
+func TestWriteErrorEscapesHTML(t *testing.T) {
+	for _, dir := range []string{"..", "."} {
+		// Outside the repository root the templates are missing and a bare page is written
+		t.Run(dir, func(t *testing.T) {
+			t.Chdir(dir)
+			rec := httptest.NewRecorder()
+			writeError(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusNotFound, `<script>alert("x")</script>`)
+
+			if rec.Code != http.StatusNotFound || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+				t.Fatalf("got %d with Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+			}
+			page := rec.Body.String()
+			if strings.Contains(page, "<script>") || !strings.Contains(page, "&lt;script&gt;") || !strings.Contains(page, "404 Not Found") {
+				t.Fatalf("got body %q, want the escaped message", page)
+			}
+		})
+	}
+}
+
+func TestAPIRoutesAlwaysReturnJSONErrors(t *testing.T) {
+	fs, _ := newTestScheduler(t, &Config{})
+
+	rec := serve(newTestRouter(fs), http.MethodPost, "/api/possibly-sent/abc/confirm", "")
+	if body := decodeErrorResponse(t, rec); body.Code != rec.Code || rec.Code < 400 {
+		t.Fatalf("got %d %+v", rec.Code, body)
+	}
+}
+
+func TestIndexErrorNegotiatesFormat(t *testing.T) {
+	// The index page is rendered from the templates directory at the repository root
+	t.Chdir("..")
+	fs, _ := newTestScheduler(t, &Config{})
+	router := newTestRouter(fs)
+	form := url.Values{"url": {"ftp://example.com/feed.xml"}}.Encode()
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	body := decodeErrorResponse(t, rec)
+	if rec.Code != http.StatusBadRequest || body.Error != "URL must use http or https scheme" {
+		t.Fatalf("got %d %+v", rec.Code, body)
+	}
+
+	rec = serve(router, http.MethodPost, "/", form)
+	if strings.Contains(rec.Header().Get("Content-Type"), "json") {
+		t.Fatal("got a JSON error without asking for JSON")
+	}
+	if page := rec.Body.String(); !strings.Contains(page, "<html") || !strings.Contains(page, "URL must use http or https scheme") {
+		t.Fatalf("got body %q, want the index page with the error", page)
+	}
+}
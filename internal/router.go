@@ -1,8 +1,6 @@
 package internal
 
 import (
-	"net/http"
-
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -14,15 +12,33 @@ func Router(h *Handlers) *chi.Mux {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
-	// Static files
-	r.Get("/static/*", func(w http.ResponseWriter, r *http.Request) {
-		http.StripPrefix("/static/", http.FileServer(http.Dir("static/"))).ServeHTTP(w, r)
-	})
+	// Static files, with caching headers
+	r.Get("/static/*", h.serveStatic("static/"))
 
 	r.Get("/", h.IndexGetHandler)
 	r.Post("/", h.IndexPostHandler)
 	r.Get("/config", h.ConfigGetHandler)
 	r.Post("/config", h.ConfigPostHandler)
+	r.Get("/status", h.StatusGetHandler)
+	r.Get("/healthz", h.HealthzHandler)
+	r.Get("/api/status", h.APIStatusHandler)
+	r.Post("/pause", h.PauseHandler)
+	r.Post("/resume", h.ResumeHandler)
+	r.Post("/tokens/rotate", h.TokenRotateHandler)
+	r.Post("/tags/{tag}/pause", h.TagPauseHandler)
+	r.Post("/tags/{tag}/resume", h.TagResumeHandler)
+	r.Post("/tags/{tag}/interval", h.TagIntervalHandler)
+	r.Get("/feeds/{index}/plan", h.FeedPlanHandler)
+	r.Get("/feeds/{index}/item", h.FeedItemHandler)
+	r.Get("/feeds/{index}/favicon", h.FeedFaviconHandler)
+	r.Post("/feeds/{index}/send-latest", h.FeedSendLatestHandler)
+	r.Post("/feeds/{index}/send-sample", h.FeedSendSampleHandler)
+	r.Get("/api/possibly-sent", h.PossiblySentHandler)
+	r.Post("/api/possibly-sent/{id}/confirm", h.ConfirmPossiblySentHandler)
+	r.Post("/api/possibly-sent/{id}/resend", h.ResendPossiblySentHandler)
+
+	// Profiling, only served while enable_pprof is set
+	r.With(h.requirePprof).Mount("/debug", middleware.Profiler())
 
 	return r
 }
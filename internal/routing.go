@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// telegramTarget is a chat (and optional thread) an item is delivered to
+type telegramTarget struct {
+	ChatID   ChatID
+	ThreadID int64
+}
+
+// validateRoutes checks that every routing rule has a chat and something to match on
+func validateRoutes(routes []FeedRoute) error {
+	for i, route := range routes {
+		if route.TelegramChatId.IsZero() {
+			return fmt.Errorf("route %d has no telegram_chat_id", i+1)
+		}
+		if len(route.Categories) == 0 && len(route.Keywords) == 0 {
+			return fmt.Errorf("route %d needs at least one category or keyword", i+1)
+		}
+	}
+	return nil
+}
+
+// routeMatches reports whether an item matches one of the route's categories or keywords
+func routeMatches(route FeedRoute, item *gofeed.Item) bool {
+	for _, category := range route.Categories {
+		for _, itemCategory := range item.Categories {
+			if strings.EqualFold(strings.TrimSpace(category), strings.TrimSpace(itemCategory)) {
+				return true
+			}
+		}
+	}
+
+	text := strings.ToLower(item.Title + "\n" + item.Description)
+	for _, keyword := range route.Keywords {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword != "" && strings.Contains(text, keyword) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveTargets returns the chats an item should be delivered to. Items matching a
+// routing rule go to the rule's chat instead of the feed's default chat, unless the
+// feed also routes to the default.
+func resolveTargets(feed Feed, item *gofeed.Item) []telegramTarget {
+	defaultTarget := telegramTarget{ChatID: feed.TelegramChatId, ThreadID: feed.TelegramMessageThreadId}
+
+	var targets []telegramTarget
+	for _, route := range feed.Routes {
+		if routeMatches(route, item) {
+			targets = appendTarget(targets, telegramTarget{ChatID: route.TelegramChatId, ThreadID: route.TelegramMessageThreadId})
+		}
+	}
+
+	if len(targets) == 0 || feed.RouteAlsoToDefault {
+		targets = appendTarget(targets, defaultTarget)
+	}
+
+	return targets
+}
+
+// appendTarget adds a target unless it is already in the list
+func appendTarget(targets []telegramTarget, target telegramTarget) []telegramTarget {
+	for _, existing := range targets {
+		if existing == target {
+			return targets
+		}
+	}
+	return append(targets, target)
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func routedFeed() Feed {
+	feed := testFeed("https://example.com/feed.xml")
+	feed.TelegramChatId = "100"
+	feed.Routes = []FeedRoute{
+		{Categories: []string{"Sports"}, TelegramChatId: "200"},
+		{Keywords: []string{"golang"}, TelegramChatId: "300", TelegramMessageThreadId: 5},
+	}
+	return feed
+}
+
+func TestResolveTargets(t *testing.T) {
+	feed := routedFeed()
+
+	tests := []struct {
+		name string
+		item *gofeed.Item
+		want []telegramTarget
+	}{
+		{"matching category", &gofeed.Item{Title: "Match report", Categories: []string{" sports "}}, []telegramTarget{{ChatID: "200"}}},
+		{"matching keyword", &gofeed.Item{Title: "New GoLang release"}, []telegramTarget{{ChatID: "300", ThreadID: 5}}},
+		{"both rules", &gofeed.Item{Title: "golang for sports", Categories: []string{"Sports"}}, []telegramTarget{{ChatID: "200"}, {ChatID: "300", ThreadID: 5}}},
+		{"unmatched", &gofeed.Item{Title: "Weather", Categories: []string{"News"}}, []telegramTarget{{ChatID: "100"}}},
+	}
+	for _, tt := range tests {
+		if got := resolveTargets(feed, tt.item); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	feed.RouteAlsoToDefault = true
+	want := []telegramTarget{{ChatID: "200"}, {ChatID: "100"}}
+	if got := resolveTargets(feed, &gofeed.Item{Categories: []string{"Sports"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("route_also_to_default: got %v, want %v", got, want)
+	}
+}
+
+func TestRoutedItemsAreSentToTheirChats(t *testing.T) {
+	server := newFeedServer(t, rssFeed(
+		testItem{GUID: "2", Title: "Weather"},
+		testItem{GUID: "1", Title: "Match report", Categories: []string{"Sports"}},
+	))
+	feed := routedFeed()
+	feed.FeedUrl = server.URL
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	if err := fs.fetchAndProcessFeed(fs.configManager.Get().Feeds[0]); err != nil {
+		t.Fatalf("fetchAndProcessFeed: %v", err)
+	}
+
+	calls := recorder.callsTo("sendMessage")
+	if len(calls) != 2 {
+		t.Fatalf("got %d sends, want 2", len(calls))
+	}
+	if calls[0].text() != "Match report" || calls[0].chatID() != "200" {
+		t.Errorf("matched item went to %s: %q", calls[0].chatID(), calls[0].text())
+	}
+	if calls[1].text() != "Weather" || calls[1].chatID() != "100" {
+		t.Errorf("unmatched item went to %s: %q", calls[1].chatID(), calls[1].text())
+	}
+}
+
+func TestValidateRoutes(t *testing.T) {
+	if err := validateRoutes([]FeedRoute{{Categories: []string{"a"}}}); err == nil {
+		t.Error("expected an error for a route without a chat")
+	}
+	if err := validateRoutes([]FeedRoute{{TelegramChatId: "1"}}); err == nil {
+		t.Error("expected an error for a route without categories or keywords")
+	}
+	if err := validateRoutes(routedFeed().Routes); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParallelFanOutKeepsPerChatOrder(t *testing.T) {
+	server := newFeedServer(t, rssFeed(
+		testItem{GUID: "2", Title: "Second", Categories: []string{"news"}},
+		testItem{GUID: "1", Title: "First", Categories: []string{"news"}},
+	))
+	feed := testFeed(server.URL)
+	feed.ParallelFanOut = true
+	for chat := 1; chat <= 10; chat++ {
+		feed.Routes = append(feed.Routes, FeedRoute{Categories: []string{"news"}, TelegramChatId: ChatID(fmt.Sprint(chat))})
+	}
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	// Chat 5 is rate limited once; it is retried without holding up the others
+	var limited atomic.Bool
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		if call.chatID() == "5" && limited.CompareAndSwap(false, true) {
+			return http.StatusTooManyRequests, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`
+		}
+		return 0, ""
+	})
+
+	start := time.Now()
+	fs.runFeed(feed)
+	elapsed := time.Since(start)
+
+	// Sent one by one, 20 messages would take at least 20 seconds
+	if elapsed > 6*time.Second {
+		t.Fatalf("fan-out to 10 chats took %v", elapsed)
+	}
+
+	received := map[string][]string{}
+	for _, call := range recorder.callsTo("sendMessage") {
+		received[call.chatID()] = append(received[call.chatID()], call.text())
+	}
+	for chat := 1; chat <= 10; chat++ {
+		want := []string{"First", "Second"}
+		if chat == 5 {
+			want = []string{"First", "First", "Second"} // The rate limited attempt is recorded too
+		}
+		if got := received[fmt.Sprint(chat)]; !reflect.DeepEqual(got, want) {
+			t.Errorf("chat %d got %q, want %q", chat, got, want)
+		}
+	}
+}
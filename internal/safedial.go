@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// isPublicIP reports whether an address is reachable on the public internet, as opposed
+// to loopback, private, link-local and similar internal ranges
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// safeDialer refuses connections to internal addresses. The check runs on the resolved
+// address right before connecting, so DNS tricks can't bypass it.
+var safeDialer = &net.Dialer{
+	Timeout: 10 * time.Second,
+	Control: func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || !isPublicIP(ip) {
+			return fmt.Errorf("connection to internal address %s is not allowed", host)
+		}
+		return nil
+	},
+}
+
+// newSafeHTTPClient returns an HTTP client that only connects to public addresses
+func newSafeHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         safeDialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// SampleItem is a static item configured for test sends, so that a feed's template and
+// chat can be checked without fetching the feed
+type SampleItem struct {
+	Title       string   `yaml:"title"`
+	Description string   `yaml:"description,omitempty"`
+	Content     string   `yaml:"content,omitempty"`
+	Link        string   `yaml:"link,omitempty"`
+	GUID        string   `yaml:"guid,omitempty"`
+	Author      string   `yaml:"author,omitempty"`
+	Published   string   `yaml:"published,omitempty"`
+	Categories  []string `yaml:"categories,omitempty"`
+	ImageURL    string   `yaml:"image_url,omitempty"`
+}
+
+// defaultSampleItem is sent by SendTestMessage when no sample_item is configured
+var defaultSampleItem = SampleItem{
+	Title:       "Test message from go-telegram-notifications-bot",
+	Description: "This is a sample item sent to check the bot's Telegram settings.",
+	Link:        "https://example.com/",
+	GUID:        "sample-item",
+}
+
+// sampleItemFor returns the sample item of a feed, or the global one when the feed has none
+func (c *Config) sampleItemFor(feed Feed) (*SampleItem, error) {
+	if feed.SampleItem != nil {
+		return feed.SampleItem, nil
+	}
+	if c.SampleItem != nil {
+		return c.SampleItem, nil
+	}
+	return nil, fmt.Errorf("no sample_item configured for the feed or globally")
+}
+
+// itemMap builds the template data of the sample item the same way as for fetched items
+func (s SampleItem) itemMap(feed Feed) map[string]interface{} {
+	item := &gofeed.Item{
+		Title:       s.Title,
+		Description: s.Description,
+		Content:     s.Content,
+		Link:        s.Link,
+		GUID:        s.GUID,
+		Published:   s.Published,
+		Categories:  s.Categories,
+	}
+	if s.Author != "" {
+		item.Author = &gofeed.Person{Name: s.Author}
+	}
+	if s.ImageURL != "" {
+		item.Image = &gofeed.Image{URL: s.ImageURL}
+	}
+
+	return buildItemMap(item, &gofeed.Feed{Link: feed.FeedUrl})
+}
+
+// SendTestMessage renders the global sample item, or a built-in one, with the test
+// template and sends it to the test chat, without fetching any feed
+func (ts *TelegramService) SendTestMessage() error {
+	sample := defaultSampleItem
+	if ts.ConfigManager.Get().SampleItem != nil {
+		sample = *ts.ConfigManager.Get().SampleItem
+	}
+
+	return ts.SendTestTelegram(sample.itemMap(Feed{}), map[string]interface{}{}, Feed{})
+}
@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSendSampleItemWithoutFetching(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "Fetched"}))
+	feed := testFeed(server.URL)
+	feed.TelegramTemplate = "<b>{{.Title}}</b> by {{.Author}}: {{.Link}}"
+	fs, recorder := newTestScheduler(t, &Config{
+		Feeds:                []Feed{feed},
+		TestTelegramApiToken: "123:test",
+		TestTelegramChatId:   "200",
+		SampleItem:           &SampleItem{Title: "Sample & co", Author: "Ada", Link: "https://example.com/sample"},
+	})
+
+	rec := postJSON(newTestRouter(fs), "/feeds/0/send-sample")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("send failed with %d: %s", rec.Code, rec.Body.String())
+	}
+
+	want := "<b>Sample &amp; co</b> by Ada: https://example.com/sample"
+	calls := recorder.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].chatID() != "200" || calls[0].text() != want {
+		t.Fatalf("got calls %v, want %q to the test chat", calls, want)
+	}
+	if !strings.Contains(rec.Body.String(), `"message_id":1`) {
+		t.Fatalf("unexpected response %s", rec.Body.String())
+	}
+	if n := server.requests.Load(); n != 0 {
+		t.Fatalf("feed fetched %d times for a sample send", n)
+	}
+}
+
+func TestSendSampleItemPrefersFeedSample(t *testing.T) {
+	feed := testFeed("https://unreachable.invalid/feed.xml")
+	feed.SampleItem = &SampleItem{Title: "Feed sample"}
+	fs, recorder := newTestScheduler(t, &Config{
+		Feeds:      []Feed{feed},
+		SampleItem: &SampleItem{Title: "Global sample"},
+	})
+
+	// Without a test chat the sample can still go to the feed's own chat
+	router := newTestRouter(fs)
+	if rec := postJSON(router, "/feeds/0/send-sample"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d without a test chat, want 400", rec.Code)
+	}
+	rec := postJSON(router, "/feeds/0/send-sample?to_feed_chat=true")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("send failed with %d: %s", rec.Code, rec.Body.String())
+	}
+
+	calls := recorder.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].chatID() != "100" || calls[0].text() != "Feed sample" {
+		t.Fatalf("got calls %v, want the feed's sample in its chat", calls)
+	}
+}
+
+func TestSendSampleItemNotConfigured(t *testing.T) {
+	fs, recorder := newTestScheduler(t, &Config{
+		Feeds:                []Feed{testFeed("https://unreachable.invalid/feed.xml")},
+		TestTelegramApiToken: "123:test",
+		TestTelegramChatId:   "200",
+	})
+
+	router := newTestRouter(fs)
+	if rec := postJSON(router, "/feeds/0/send-sample"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d without a sample item, want 400", rec.Code)
+	}
+	if rec := postJSON(router, "/feeds/3/send-sample"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d for an unknown feed, want 400", rec.Code)
+	}
+	if calls := recorder.Calls(); len(calls) != 0 {
+		t.Fatalf("got Telegram calls %v", calls)
+	}
+}
+
+func TestSendTestMessageSendsOnce(t *testing.T) {
+	ts, recorder := newTestTelegramService(t, &Config{
+		TestTelegramApiToken: "123:test",
+		TestTelegramChatId:   "200",
+		TestTelegramTemplate: "{{.Title}} | {{.Link}}",
+	})
+
+	if err := ts.SendTestMessage(); err != nil {
+		t.Fatalf("SendTestMessage: %v", err)
+	}
+
+	calls := recorder.Calls()
+	if len(calls) != 1 || calls[0].Method != "sendMessage" || calls[0].chatID() != "200" {
+		t.Fatalf("got calls %v, want exactly one message to the test chat", calls)
+	}
+	if want := defaultSampleItem.Title + " | " + defaultSampleItem.Link; calls[0].text() != want {
+		t.Fatalf("got %q, want %q", calls[0].text(), want)
+	}
+}
+
+func TestSendTestMessageUsesConfiguredSample(t *testing.T) {
+	ts, recorder := newTestTelegramService(t, &Config{
+		TestTelegramApiToken: "123:test",
+		TestTelegramChatId:   "200",
+		SampleItem:           &SampleItem{Title: "Configured sample"},
+	})
+
+	if err := ts.SendTestMessage(); err != nil {
+		t.Fatalf("SendTestMessage: %v", err)
+	}
+	if texts := sentTexts(recorder); len(texts) != 1 || texts[0] != "Configured sample" {
+		t.Fatalf("got messages %q, want the configured sample", texts)
+	}
+}
+
+func TestSendTestMessageReportsFailure(t *testing.T) {
+	ts, recorder := newTestTelegramService(t, &Config{TestTelegramApiToken: "123:test", TestTelegramChatId: "200"})
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		return http.StatusBadRequest, telegramError(400, "Bad Request: chat not found")
+	})
+
+	err := ts.SendTestMessage()
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Fatalf("got error %v, want Telegram's", err)
+	}
+	if calls := recorder.Calls(); len(calls) != 1 {
+		t.Fatalf("got %d calls, want exactly one send", len(calls))
+	}
+
+	// Without a test chat nothing is sent
+	ts, recorder = newTestTelegramService(t, &Config{TestTelegramApiToken: "123:test"})
+	if err := ts.SendTestMessage(); err == nil {
+		t.Fatal("expected an error without a test chat")
+	}
+	if calls := recorder.Calls(); len(calls) != 0 {
+		t.Fatalf("got calls %v without a test chat", calls)
+	}
+}
@@ -2,14 +2,34 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"runtime/debug"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/mmcdole/gofeed"
 )
 
+// defaultAlertFailureThreshold is the number of consecutive failed fetches before an alert is sent
+const defaultAlertFailureThreshold = 3
+
+// defaultStuckFetchThreshold is how long a fetch may run before the watchdog flags the feed as stuck
+const defaultStuckFetchThreshold = 15 * time.Minute
+
+// defaultTickerWarningThreshold is the number of running tickers above which a warning is logged
+const defaultTickerWarningThreshold = 500
+
+// watchdogInterval is how often the watchdog checks for stuck fetches
+const watchdogInterval = time.Minute
+
+// fetchIntervalUnit is the unit of feed_fetch_interval_minutes
+var fetchIntervalUnit = time.Minute
+
 // FeedScheduler manages scheduling and fetching of feeds
 type FeedScheduler struct {
 	configManager *ConfigManager
@@ -20,18 +40,78 @@ type FeedScheduler struct {
 	wg            sync.WaitGroup
 	mu            sync.Mutex
 	tickers       map[string]*time.Ticker
+	tickerCtx     context.Context    // cancelled when the tickers are replaced or stopped
+	stopTickers   context.CancelFunc // cancels tickerCtx
+	tickerWG      sync.WaitGroup     // goroutines of the current tickers
+	statusMu      sync.Mutex
+	status        map[string]*FeedStatus
+	startedAt     time.Time
+	digestMu      sync.Mutex
+	links         *linkResolver
+	fetches       *fetchCoalescer
+	backoff       *hostBackoff
+	backfill      chan struct{} // limits how many feeds record their backlog at once
+	bodies        *bodyHashes
+	deliveries    *deliveryLog
+
+	// OnItemSent is called after an item has been sent and saved. It runs in its own
+	// goroutine so slow callbacks don't hold up the scheduler.
+	OnItemSent func(feed Feed, item FeedItem, messageID int64)
+}
+
+// FeedStatus holds runtime information about a scheduled feed
+type FeedStatus struct {
+	LastTick            time.Time // when the latest fetch started
+	LastFetch           time.Time // when the latest fetch completed
+	FetchStartedAt      time.Time // start of the fetch in progress, zero when idle
+	NextTick            time.Time // when the ticker is expected to fire next
+	AutoIntervalMinutes int       // interval suggested by the feed's ttl or syndication hints
+	SiteLink            string    // link to the feed's website, from the latest fetch
+	LastError           string
+	ConsecutiveFailures int
+	LastNewItem         time.Time
+	Stale               bool
+	Stuck               bool
+	SentToday           int
+	sentDay             string
+	TemplateError       string // why the feed's template last failed to render, empty when it renders
+}
+
+// FeedRuntimeStatus is the JSON representation of a feed's runtime state
+type FeedRuntimeStatus struct {
+	Name                string     `json:"name"`
+	FeedURL             string     `json:"feed_url"`
+	IntervalMinutes     int        `json:"interval_minutes"`
+	NextTick            *time.Time `json:"next_tick"`
+	LastFetch           *time.Time `json:"last_fetch"`
+	LastResult          string     `json:"last_result"`
+	LastError           string     `json:"last_error,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	ItemsSentToday      int        `json:"items_sent_today"`
+	Stale               bool       `json:"stale"`
+	Stuck               bool       `json:"stuck"`
+	TemplateError       string     `json:"template_error,omitempty"`
+	Paused              bool       `json:"paused,omitempty"`
 }
 
 // NewFeedScheduler creates a new feed scheduler
 func NewFeedScheduler(cm *ConfigManager, dbm *DBManager) *FeedScheduler {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &FeedScheduler{
+		startedAt:     time.Now(),
 		configManager: cm,
 		dbManager:     dbm,
 		telegram:      NewTelegramService(cm),
 		ctx:           ctx,
 		cancel:        cancel,
 		tickers:       make(map[string]*time.Ticker),
+		status:        make(map[string]*FeedStatus),
+		links:         newLinkResolver(),
+		fetches:       newFetchCoalescer(fetchFeedWithRetry),
+		backoff:       newHostBackoff(),
+		backfill:      make(chan struct{}, cm.Get().backfillConcurrency()),
+		bodies:        newBodyHashes(),
+		deliveries:    newDeliveryLog(),
 	}
 }
 
@@ -40,217 +120,1189 @@ func (fs *FeedScheduler) Start() {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	// Stop any existing tickers
-	for url, ticker := range fs.tickers {
-		ticker.Stop()
-		delete(fs.tickers, url)
+	// After Stop every goroutine started here would exit right away
+	if fs.ctx.Err() != nil {
+		log.Println("Feed scheduler is stopped, not starting it")
+		return
+	}
+
+	// Stop the tickers of the previous configuration, so that their goroutines don't keep
+	// fetching alongside the new ones
+	fs.stopTickerGoroutines()
+	fs.tickerCtx, fs.stopTickers = context.WithCancel(fs.ctx)
+
+	// The new configuration may handle the items of unchanged feeds differently
+	fs.bodies.reset()
+
+	// Make sure the database is reachable before the initial fetch records items
+	err := fs.withDBRetry("database check at startup", fs.dbManager.Ping)
+	if err != nil {
+		log.Printf("Database is not available: %v", err)
 	}
+	fs.inheritSharedFeedItems()
 
-	// Perform initial fetch for each feed
-	for _, feed := range fs.configManager.Config.Feeds {
+	// Perform initial fetch for each feed, unless configured to wait for the first tick
+	for _, feed := range fs.configManager.Get().Feeds {
+		if feed.Paused {
+			log.Printf("Feed is paused, not scheduling it: %s", feed.FeedUrl)
+			continue
+		}
+		if fs.configManager.Get().SkipInitialFetch || feed.SkipInitialFetch {
+			log.Printf("Skipping initial fetch for feed: %s", feed.FeedUrl)
+			continue
+		}
 		log.Printf("Performing initial fetch for feed: %s", feed.FeedUrl)
-		err := fs.fetchAndProcessFeed(feed)
-		if err != nil {
-			log.Printf("Error during initial fetch for feed %s: %v", feed.FeedUrl, err)
+		fs.runFeed(feed)
+	}
+
+	// Start new tickers for each feed that isn't paused
+	for _, feed := range fs.configManager.Get().Feeds {
+		if !feed.Paused {
+			fs.startTickerForFeed(feed)
 		}
 	}
 
-	// Start new tickers for each feed
-	for _, feed := range fs.configManager.Config.Feeds {
-		fs.startTickerForFeed(feed)
+	threshold := fs.configManager.Get().TickerWarningThreshold
+	if threshold <= 0 {
+		threshold = defaultTickerWarningThreshold
+	}
+	if len(fs.tickers) > threshold {
+		log.Printf("Warning: %d feed tickers are running, more than the warning threshold of %d", len(fs.tickers), threshold)
 	}
 
 	log.Println("Feed scheduler started")
 }
 
+// PinStartMessages sends and pins a "started" message for every feed with
+// pin_start_message enabled, replacing the one pinned at the previous start
+func (fs *FeedScheduler) PinStartMessages() {
+	if fs.configManager.Get().Paused {
+		return
+	}
+
+	for _, feed := range fs.configManager.Get().Feeds {
+		if !feed.PinStartMessage {
+			continue
+		}
+
+		previousID, _, err := fs.dbManager.PinnedMessage(feed.Key(), feed.TelegramChatId)
+		if err != nil {
+			log.Printf("Error loading pinned message of feed %s: %v", feed.FeedUrl, err)
+			continue
+		}
+
+		messageID, err := fs.telegram.SendStartMessage(feed, previousID)
+		if err != nil {
+			log.Printf("Error pinning start message of feed %s: %v", feed.FeedUrl, err)
+			continue
+		}
+
+		err = fs.dbManager.SavePinnedMessage(feed.Key(), feed.TelegramChatId, messageID)
+		if err != nil {
+			log.Printf("Error saving pinned message of feed %s: %v", feed.FeedUrl, err)
+		}
+	}
+}
+
 // startTickerForFeed starts a ticker for a specific feed
 func (fs *FeedScheduler) startTickerForFeed(feed Feed) {
 	// Stop existing ticker if present
-	if existingTicker, exists := fs.tickers[feed.FeedUrl]; exists {
+	if existingTicker, exists := fs.tickers[feed.Key()]; exists {
 		existingTicker.Stop()
 	}
 
-	interval := time.Duration(feed.FeedFetchIntervalMinutes) * time.Minute
+	interval := fs.intervalFor(feed)
 	ticker := time.NewTicker(interval)
 
-	fs.tickers[feed.FeedUrl] = ticker
+	fs.tickers[feed.Key()] = ticker
+	fs.setNextTick(feed.Key(), time.Now().Add(interval))
 
 	// Start goroutine to handle ticker ticks
-	fs.wg.Add(1)
+	ctx := fs.tickerCtx
+	fs.tickerWG.Add(1)
 	go func(f Feed) {
-		defer fs.wg.Done()
+		defer fs.tickerWG.Done()
 		for {
 			select {
-			case <-ticker.C:
-				err := fs.fetchAndProcessFeed(f)
-				if err != nil {
-					log.Printf("Error processing feed %s: %v", f.FeedUrl, err)
+			case tick := <-ticker.C:
+				if ctx.Err() != nil {
+					return // Replaced by a new configuration while the tick was pending
 				}
-			case <-fs.ctx.Done():
+				fs.setNextTick(f.Key(), tick.Add(interval))
+				fs.runFeed(f)
+
+				// Follow the interval suggested by the feed in auto_interval mode, or backed
+				// off while its host is failing
+				if next := fs.intervalFor(f); next != interval {
+					log.Printf("Changing interval of feed %s to %d minutes", f.FeedUrl, int(next.Minutes()))
+					interval = next
+					ticker.Reset(interval)
+					fs.setNextTick(f.Key(), time.Now().Add(interval))
+				}
+			case <-ctx.Done():
 				ticker.Stop()
 				return
 			}
 		}
 	}(feed)
 
-	log.Printf("Started scheduler for feed: %s (interval: %d minutes)", feed.FeedUrl, feed.FeedFetchIntervalMinutes)
+	log.Printf("Started scheduler for feed: %s (interval: %d minutes)", feed.FeedUrl, int(interval.Minutes()))
+
+	if feed.DigestEnabled {
+		fs.startDigestTicker(feed)
+	}
+}
+
+// stopTickerGoroutines stops every feed and digest ticker and waits for their goroutines
+// to return. A fetch in progress is finished first. The caller must hold fs.mu.
+func (fs *FeedScheduler) stopTickerGoroutines() {
+	if fs.stopTickers != nil {
+		fs.stopTickers()
+	}
+	for key, ticker := range fs.tickers {
+		ticker.Stop()
+		delete(fs.tickers, key)
+	}
+	fs.tickerWG.Wait()
+}
+
+// intervalFor returns the fetch interval of a feed. Feeds in auto_interval mode without an
+// explicit interval use the interval suggested by the feed once it has been fetched.
+// The result is never below the configured minimum, which also keeps a zero interval
+// from reaching time.NewTicker.
+func (fs *FeedScheduler) intervalFor(feed Feed) time.Duration {
+	minutes := feed.FeedFetchIntervalMinutes
+	if minutes <= 0 && feed.AutoInterval {
+		fs.statusMu.Lock()
+		minutes = fs.feedStatus(feed.Key()).AutoIntervalMinutes
+		fs.statusMu.Unlock()
+
+		if minutes <= 0 {
+			minutes = defaultAutoIntervalMinutes
+		}
+	}
+
+	if floor := fs.configManager.Get().minFetchInterval(); minutes < floor {
+		minutes = floor
+	}
+	interval := time.Duration(minutes) * fetchIntervalUnit
+
+	if fs.configManager.Get().HostBackoff {
+		interval = fs.backoff.interval(feed.FeedUrl, interval, fs.configManager.Get().hostBackoffMax())
+	}
+	return interval
+}
+
+// fetchScheduled fetches a feed for processing. With coalesce_fetches enabled, feeds
+// that point at the same URL share one fetch per cycle. Otherwise errFeedUnchanged is
+// returned when the body is the same as the last fully processed one, and the hash of
+// the body is returned to be recorded once its items are handled.
+func (fs *FeedScheduler) fetchScheduled(feed Feed) (*gofeed.Feed, string, error) {
+	if fs.configManager.Get().CoalesceFetches {
+		feedData, err := fs.fetches.Fetch(fs.ctx, feed.FeedUrl, parseTimeoutFor(feed))
+		return feedData, "", err
+	}
+
+	body, err := fetchFeedBodyWithRetry(fs.ctx, feed.FeedUrl)
+	if err != nil {
+		return nil, "", err
+	}
+	hash := hashBody(body)
+	if fs.bodies.unchanged(feed.Key(), hash) {
+		return nil, hash, errFeedUnchanged
+	}
+
+	feedData, err := parseFeedBody(feed.FeedUrl, body, parseTimeoutFor(feed))
+	return feedData, hash, err
+}
+
+// runFeed fetches and processes a feed, recording the outcome in the feed status.
+// Feeds outside their active hours are skipped.
+func (fs *FeedScheduler) runFeed(feed Feed) {
+	if !isActive(feed, time.Now()) {
+		log.Printf("Skipping fetch of feed %s outside its active hours %s", feed.FeedUrl, feed.ActiveHours)
+		return
+	}
+
+	fs.statusMu.Lock()
+	status := fs.feedStatus(feed.Key())
+	status.LastTick = time.Now()
+	status.FetchStartedAt = status.LastTick
+	fs.statusMu.Unlock()
+
+	err := fs.fetchAndProcessFeedSafely(feed)
+	if err != nil {
+		log.Printf("Error processing feed %s: %v", feed.FeedUrl, err)
+	}
+	fs.recordFetchResult(feed, err)
+	if err == nil {
+		fs.checkStale(feed)
+	}
+}
+
+// fetchAndProcessFeedSafely runs fetchAndProcessFeed, turning a panic into an error so
+// that the feed is marked as failing and its ticker goroutine keeps running
+func (fs *FeedScheduler) fetchAndProcessFeedSafely(feed Feed) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic while processing feed %s: %v\n%s", feed.FeedUrl, r, debug.Stack())
+			err = fmt.Errorf("panic while processing feed: %v", r)
+		}
+	}()
+
+	return fs.fetchAndProcessFeed(feed)
+}
+
+// feedStatus returns the status entry of a feed, creating it if needed. statusMu must be held.
+func (fs *FeedScheduler) feedStatus(feedKey string) *FeedStatus {
+	status, exists := fs.status[feedKey]
+	if !exists {
+		status = &FeedStatus{}
+		fs.status[feedKey] = status
+	}
+	return status
+}
+
+// setNextTick records when the ticker of a feed is expected to fire next
+func (fs *FeedScheduler) setNextTick(feedKey string, next time.Time) {
+	fs.statusMu.Lock()
+	fs.feedStatus(feedKey).NextTick = next
+	fs.statusMu.Unlock()
+}
+
+// SetPaused turns the global kill switch on or off and saves it in the configuration
+func (fs *FeedScheduler) SetPaused(paused bool) error {
+	err := fs.configManager.Update(func(cfg *Config) error {
+		cfg.Paused = paused
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if paused {
+		log.Println("Posting paused")
+		fs.PostStatusEvent("Posting paused")
+	} else {
+		log.Println("Posting resumed")
+		fs.PostStatusEvent("Posting resumed")
+	}
+	return nil
+}
+
+// NextTick returns when the feed is expected to be fetched next. The boolean is false
+// when the feed has no running ticker.
+func (fs *FeedScheduler) NextTick(feedKey string) (time.Time, bool) {
+	fs.statusMu.Lock()
+	defer fs.statusMu.Unlock()
+
+	status, exists := fs.status[feedKey]
+	if !exists || status.NextTick.IsZero() {
+		return time.Time{}, false
+	}
+	return status.NextTick, true
+}
+
+// recordItemSent counts an item sent for a feed, starting over every day
+func (fs *FeedScheduler) recordItemSent(feedKey string) {
+	today := time.Now().Format("2006-01-02")
+
+	fs.statusMu.Lock()
+	status := fs.feedStatus(feedKey)
+	if status.sentDay != today {
+		status.sentDay = today
+		status.SentToday = 0
+	}
+	status.SentToday++
+	fs.statusMu.Unlock()
+}
+
+// recordTemplateError records whether the feed's template rendered an item. With the skip
+// policy, the alert chat is told when the template starts failing.
+func (fs *FeedScheduler) recordTemplateError(feed Feed, renderErr error) {
+	message := ""
+	if renderErr != nil {
+		message = renderErr.Error()
+	}
+
+	fs.statusMu.Lock()
+	status := fs.feedStatus(feed.Key())
+	previous := status.TemplateError
+	status.TemplateError = message
+	fs.statusMu.Unlock()
+
+	if renderErr != nil && previous == "" && feed.TemplateError == templateErrorSkip {
+		err := fs.telegram.SendOperationAlert(fmt.Sprintf("Rendering feed %s", feed.DisplayName()), renderErr)
+		if err != nil {
+			log.Printf("Error sending template error alert for feed %s: %v", feed.FeedUrl, err)
+		}
+	}
+}
+
+// RuntimeStatus returns the runtime state of every configured feed, in config order
+func (fs *FeedScheduler) RuntimeStatus() []FeedRuntimeStatus {
+	statuses := fs.Status()
+	today := time.Now().Format("2006-01-02")
+
+	result := []FeedRuntimeStatus{}
+	for _, feed := range fs.configManager.Get().Feeds {
+		status := statuses[feed.Key()]
+
+		runtime := FeedRuntimeStatus{
+			Name:                feed.DisplayName(),
+			FeedURL:             feed.FeedUrl,
+			IntervalMinutes:     int(fs.intervalFor(feed).Minutes()),
+			LastError:           status.LastError,
+			ConsecutiveFailures: status.ConsecutiveFailures,
+			Stale:               status.Stale,
+			Stuck:               status.Stuck,
+			TemplateError:       status.TemplateError,
+			Paused:              feed.Paused,
+		}
+		if status.sentDay == today {
+			runtime.ItemsSentToday = status.SentToday
+		}
+		if !status.NextTick.IsZero() {
+			next := status.NextTick
+			runtime.NextTick = &next
+		}
+		switch {
+		case status.LastFetch.IsZero():
+			runtime.LastResult = "pending"
+		case status.LastError != "":
+			runtime.LastResult = "error"
+		default:
+			runtime.LastResult = "ok"
+		}
+		if !status.LastFetch.IsZero() {
+			lastFetch := status.LastFetch
+			runtime.LastFetch = &lastFetch
+		}
+
+		result = append(result, runtime)
+	}
+
+	return result
+}
+
+// Status returns a snapshot of the runtime status of every feed, keyed by Feed.Key
+func (fs *FeedScheduler) Status() map[string]FeedStatus {
+	fs.statusMu.Lock()
+	defer fs.statusMu.Unlock()
+
+	snapshot := make(map[string]FeedStatus, len(fs.status))
+	for url, status := range fs.status {
+		snapshot[url] = *status
+	}
+	return snapshot
+}
+
+// StartWatchdog starts a routine that flags feeds whose fetch has been running for
+// longer than the stuck fetch threshold
+func (fs *FeedScheduler) StartWatchdog() {
+	fs.wg.Add(1)
+	go func() {
+		defer fs.wg.Done()
+
+		ticker := time.NewTicker(watchdogInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				fs.checkStuck(time.Now())
+			case <-fs.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	log.Println("Watchdog started")
+}
+
+// checkStuck flags feeds whose current fetch started more than the threshold before now
+func (fs *FeedScheduler) checkStuck(now time.Time) {
+	threshold := time.Duration(fs.configManager.Get().StuckFetchThresholdMinutes) * time.Minute
+	if threshold <= 0 {
+		threshold = defaultStuckFetchThreshold
+	}
+
+	fs.statusMu.Lock()
+	defer fs.statusMu.Unlock()
+
+	for url, status := range fs.status {
+		stuck := !status.FetchStartedAt.IsZero() && now.Sub(status.FetchStartedAt) > threshold
+		if stuck && !status.Stuck {
+			log.Printf("Fetch of feed %s has been running since %s and looks stuck", url, status.FetchStartedAt.Format(time.RFC3339))
+		}
+		status.Stuck = stuck
+	}
+}
+
+// StuckFeeds returns the URLs of feeds the watchdog has flagged as stuck
+func (fs *FeedScheduler) StuckFeeds() []string {
+	fs.statusMu.Lock()
+	defer fs.statusMu.Unlock()
+
+	var stuck []string
+	for url, status := range fs.status {
+		if status.Stuck {
+			stuck = append(stuck, url)
+		}
+	}
+	sort.Strings(stuck)
+	return stuck
+}
+
+// checkStale flags a feed as stale when it has not produced a new item within StaleAfterDays
+func (fs *FeedScheduler) checkStale(feed Feed) {
+	if feed.StaleAfterDays <= 0 {
+		return
+	}
+
+	lastNewItem, found, err := fs.dbManager.LastItemTime(feed.Key())
+	if err != nil {
+		log.Printf("Error checking staleness of feed %s: %v", feed.FeedUrl, err)
+		return
+	}
+	if !found {
+		// Nothing stored yet, count from when the scheduler started
+		lastNewItem = fs.startedAt
+	}
+
+	stale := time.Since(lastNewItem) > time.Duration(feed.StaleAfterDays)*24*time.Hour
+
+	fs.statusMu.Lock()
+	status := fs.feedStatus(feed.Key())
+	becameStale := stale && !status.Stale
+	status.LastNewItem = lastNewItem
+	status.Stale = stale
+	fs.statusMu.Unlock()
+
+	if becameStale {
+		log.Printf("Feed %s has not published new items since %s", feed.FeedUrl, lastNewItem.Format(time.RFC3339))
+		err = fs.telegram.SendStaleAlert(feed, lastNewItem)
+		if err != nil {
+			log.Printf("Error sending stale alert for feed %s: %v", feed.FeedUrl, err)
+		}
+	}
+}
+
+// recordFetchResult updates the feed status and alerts once consecutive failures reach the threshold
+func (fs *FeedScheduler) recordFetchResult(feed Feed, fetchErr error) {
+	fs.statusMu.Lock()
+	status := fs.feedStatus(feed.Key())
+	status.LastFetch = time.Now()
+	status.FetchStartedAt = time.Time{}
+	status.Stuck = false
+	if fetchErr == nil {
+		status.LastError = ""
+		status.ConsecutiveFailures = 0
+	} else {
+		status.LastError = fetchErr.Error()
+		status.ConsecutiveFailures++
+	}
+	failCount := status.ConsecutiveFailures
+	fs.statusMu.Unlock()
+
+	threshold := fs.configManager.Get().AlertFailureThreshold
+	if threshold <= 0 {
+		threshold = defaultAlertFailureThreshold
+	}
+
+	// Alert once per failure streak
+	if fetchErr != nil && failCount == threshold {
+		err := fs.telegram.SendAlert(feed, failCount, fetchErr)
+		if err != nil {
+			log.Printf("Error sending alert for feed %s: %v", feed.FeedUrl, err)
+		}
+	}
 }
 
 // fetchAndProcessFeed fetches a feed and processes its items
 func (fs *FeedScheduler) fetchAndProcessFeed(feed Feed) error {
-	log.Printf("Fetching feed: %s", feed.FeedUrl)
+	if len(feed.Tags) > 0 {
+		log.Printf("Fetching feed: %s [%s]", feed.FeedUrl, feed.TagList())
+	} else {
+		log.Printf("Fetching feed: %s", feed.FeedUrl)
+	}
 
-	fp := gofeed.NewParser()
-	feedData, err := fp.ParseURL(feed.FeedUrl)
+	feedData, hash, err := fs.fetchScheduled(feed)
+	if err == errFeedUnchanged {
+		fs.backoff.record(feed.FeedUrl, nil)
+		log.Printf("Feed %s is unchanged since the last fetch", feed.FeedUrl)
+		fs.flushPendingIfBuffered(feed)
+		return nil
+	}
+	fs.backoff.record(feed.FeedUrl, err)
 	if err != nil {
-		return fmt.Errorf("failed to parse feed %s: %v", feed.FeedUrl, err)
+		return fmt.Errorf("failed to parse feed %s: %s", feed.FeedUrl, describeFetchError(err, fs.configManager.Get()))
 	}
+	if feedData == nil {
+		log.Printf("Feed %s returned no data", feed.FeedUrl)
+		return nil
+	}
+
+	fs.statusMu.Lock()
+	fs.feedStatus(feed.Key()).SiteLink = feedData.Link
+	fs.statusMu.Unlock()
 
-	// Process items in reverse order (oldest first) to maintain chronological order
+	if feed.AutoInterval && feed.FeedFetchIntervalMinutes <= 0 {
+		if minutes, ok := suggestedIntervalMinutes(feedData); ok {
+			fs.statusMu.Lock()
+			fs.feedStatus(feed.Key()).AutoIntervalMinutes = minutes
+			fs.statusMu.Unlock()
+		}
+	}
+
+	if len(feedData.Items) == 0 {
+		log.Printf("Feed %s has no items", feed.FeedUrl)
+		return nil
+	}
+
+	// On the first fetch, realtime and catch_up feeds only post part of the backlog
+	backlog := -1
+	if limit := backlogLimit(feed); limit >= 0 {
+		first, err := fs.isFirstFetch(feed)
+		if err != nil {
+			log.Printf("Error checking for a first fetch of feed %s: %v", feed.FeedUrl, err)
+		} else if first {
+			backlog = limit
+			log.Printf("First fetch of feed %s in %s mode, posting up to %d of %d items", feed.FeedUrl, feed.Mode, limit, len(feedData.Items))
+		}
+	}
+
+	// Items recorded before dedup_fields changed are recognized by their previous key
+	previousDedup, err := fs.previousDedupFeed(feed)
+	if err != nil {
+		return fmt.Errorf("failed to check dedup scheme of feed %s: %v", feed.FeedUrl, err)
+	}
+
+	// Process items in reverse order (oldest first) to maintain chronological order.
+	// retryLater is set when an item is left for the next fetch.
+	var seen []FeedItem
+	retryLater := false
 	for i := len(feedData.Items) - 1; i >= 0; i-- {
 		item := feedData.Items[i]
 
+		key := dedupKey(feed, item)
+		if key == "" {
+			log.Printf("Skipping item without GUID or link in feed %s: %s", feed.FeedUrl, item.Title)
+			continue
+		}
+		if skipUndated(feed, item) {
+			log.Printf("Skipping item without publication date in feed %s: %s", feed.FeedUrl, item.Title)
+			continue
+		}
+
 		// Check if this item has already been posted
-		isPosted, err := fs.dbManager.IsFeedItemPosted(item.GUID, feed.FeedUrl)
+		isPosted, err := fs.dbManager.IsFeedItemPosted(key, feed.Key())
 		if err != nil {
 			log.Printf("Error checking if item is posted: %v", err)
+			retryLater = true
 			continue
 		}
 
+		// Recorded under the new key as well, so that changing dedup_fields doesn't post
+		// the whole feed again
+		if !isPosted && previousDedup != nil {
+			isPosted, err = fs.postedUnderPreviousKey(feed, previousDedup, item, key)
+			if err == nil && isPosted {
+				err = fs.dbManager.SaveFeedItem(trimForStorage(newFeedItem(feed, item, key), fs.configManager.Get()))
+			}
+			if err != nil {
+				log.Printf("Error checking item under its previous dedup key: %v", err)
+				retryLater = true
+				continue
+			}
+		}
+
 		if isPosted {
 			continue // Skip already posted items
 		}
 
-		// Convert gofeed.Item to our FeedItem struct
-		feedItem := FeedItem{
-			GUID:        item.GUID,
-			Title:       item.Title,
-			Description: item.Description,
-			Link:        item.Link,
-			FeedURL:     feed.FeedUrl,
+		if !languageAllowed(feed, feedData, item) {
+			log.Printf("Skipping item in language %q in feed %s: %s", itemLanguage(item, feedData), feed.FeedUrl, item.Title)
+			err = fs.dbManager.SaveFeedItem(trimForStorage(newFeedItem(feed, item, key), fs.configManager.Get()))
+			if err != nil {
+				log.Printf("Error recording skipped item: %v", err)
+				retryLater = true
+			}
+			continue
 		}
 
-		if item.PublishedParsed != nil {
-			feedItem.PublishedAt = *item.PublishedParsed
-		} else {
-			feedItem.PublishedAt = time.Now()
-		}
-
-		// Create itemMap for Telegram
-		itemMap := map[string]interface{}{
-			"Title":       item.Title,
-			"Description": item.Description,
-			"Content":     item.Content,
-			"Link":        item.Link,
-			"Updated":     item.Updated,
-			"Published":   item.Published,
-			"GUID":        item.GUID,
-
-			"Author": func() interface{} {
-				if item.Author != nil {
-					return map[string]interface{}{
-						"Name":  item.Author.Name,
-						"Email": item.Author.Email,
-					}
-				}
-				return nil
-			}(),
-
-			"Authors": func() []interface{} {
-				var authorsList []interface{}
-				for _, author := range item.Authors {
-					if author != nil {
-						authorsList = append(authorsList, map[string]interface{}{
-							"Name":  author.Name,
-							"Email": author.Email,
-						})
-					}
-				}
-				return authorsList
-			}(),
-
-			// Categories
-			"Categories": item.Categories,
-
-			// Image information
-			"Image": func() interface{} {
-				if item.Image != nil {
-					return map[string]interface{}{
-						"URL":   item.Image.URL,
-						"Title": item.Image.Title,
-					}
+		// Feeds list the newest items first, so older backlog items are only marked as seen
+		if backlog >= 0 && i >= backlog {
+			seen = append(seen, trimForStorage(newFeedItem(feed, item, key), fs.configManager.Get()))
+			continue
+		}
+
+		if fs.configManager.Get().Paused {
+			retryLater = true
+			if fs.configManager.Get().PauseMarkSeen {
+				err = fs.dbManager.SaveFeedItem(trimForStorage(newFeedItem(feed, item, key), fs.configManager.Get()))
+				if err != nil {
+					log.Printf("Error recording item while paused: %v", err)
+				} else {
+					retryLater = false
 				}
-				return nil
-			}(),
+			}
+			continue // Posting is paused
+		}
 
-			// Links
-			"Links": item.Links,
+		if holdUntilPublished(feed, item, time.Now()) {
+			log.Printf("Holding item until its publication time %s: %s", item.PublishedParsed.Format(time.RFC3339), item.Title)
+			retryLater = true
+			continue // Not saved, so it is checked again on the next tick
+		}
 
-			// Date/time information
-			"UpdatedParsed": func() string {
-				if item.UpdatedParsed != nil {
-					return item.UpdatedParsed.Format("2006-01-02 15:04:05 MST")
-				}
-				return ""
-			}(),
-			"PublishedParsed": func() string {
-				if item.PublishedParsed != nil {
-					return item.PublishedParsed.Format("2006-01-02 15:04:05 MST")
-				}
-				return ""
-			}(),
-
-			// Enclosures
-			"Enclosures": func() []interface{} {
-				var enclosuresList []interface{}
-				for _, enclosure := range item.Enclosures {
-					if enclosure != nil {
-						enclosuresList = append(enclosuresList, map[string]interface{}{
-							"URL":    enclosure.URL,
-							"Type":   enclosure.Type,
-							"Length": enclosure.Length,
-						})
-					}
-				}
-				return enclosuresList
-			}(),
+		switch {
+		case feed.DigestEnabled:
+			err = fs.addToDigest(feed, feedData, item, key)
+		case feed.MinItemsBeforePost > 1:
+			err = fs.bufferItem(feed, feedData, item, key)
+		default:
+			err = fs.sendAndRecordItem(feed, feedData, item, key)
+		}
+		if err != nil {
+			log.Printf("Error delivering feed item: %v", err)
+			retryLater = true
+			continue
+		}
+	}
 
-			// Custom fields
-			"Custom": item.Custom,
+	if len(seen) > 0 {
+		if err := fs.markBacklogSeen(seen); err != nil {
+			log.Printf("Error recording backlog of feed %s: %v", feed.FeedUrl, err)
+			retryLater = true
+		}
+	}
+
+	if hash != "" && !retryLater {
+		fs.bodies.record(feed.Key(), hash)
+	}
 
-			// Feed-level properties
-			"FeedTitle":       feedData.Title,
-			"FeedDescription": feedData.Description,
-			"FeedLink":        feedData.Link,
-			"FeedLanguage":    feedData.Language,
-			"FeedCopyright":   feedData.Copyright,
-			"FeedGenerator":   feedData.Generator,
-			"FeedType":        feedData.FeedType,
-			"FeedVersion":     feedData.FeedVersion,
+	// Once every item is recorded under its new key the previous keys aren't needed
+	if previousDedup != nil && !retryLater {
+		if err := fs.dbManager.SaveDedupScheme(feed.Key(), dedupScheme(feed)); err != nil {
+			log.Printf("Error recording dedup scheme of feed %s: %v", feed.FeedUrl, err)
 		}
+	}
 
-		// Send the item to Telegram first
-		err = fs.telegram.SendFeedItemToTelegram(feed, itemMap)
-		if err != nil {
-			log.Printf("Error sending feed item to Telegram: %v", err)
-			// Don't save to database if sending to Telegram failed
+	fs.flushPendingIfBuffered(feed)
+
+	return nil
+}
+
+// flushPendingIfBuffered sends the buffered items of a feed with min_items_before_post
+// once there are enough of them
+func (fs *FeedScheduler) flushPendingIfBuffered(feed Feed) {
+	if feed.MinItemsBeforePost > 1 && !feed.DigestEnabled && !fs.configManager.Get().Paused {
+		fs.flushPendingItems(feed)
+	}
+}
+
+// defaultMaxFutureHours is how far in the future an item may be dated and still be held
+const defaultMaxFutureHours = 24 * 7
+
+// holdUntilPublished reports whether an item is dated in the future and should wait until
+// its publication time. Items dated further ahead than the feed's cap are assumed to have
+// a bogus date and are not held.
+func holdUntilPublished(feed Feed, item *gofeed.Item, now time.Time) bool {
+	if !feed.HoldFutureItems || item.PublishedParsed == nil || !item.PublishedParsed.After(now) {
+		return false
+	}
+
+	maxFutureHours := feed.MaxFutureHours
+	if maxFutureHours <= 0 {
+		maxFutureHours = defaultMaxFutureHours
+	}
+	return item.PublishedParsed.Sub(now) <= time.Duration(maxFutureHours)*time.Hour
+}
+
+// SendLatest fetches the feed at the given index and posts its n most recent items,
+// even if they were posted before. It returns the number of items sent.
+func (fs *FeedScheduler) SendLatest(index int, n int) (int, error) {
+	feeds := fs.configManager.Get().Feeds
+	if index < 0 || index >= len(feeds) {
+		return 0, fmt.Errorf("feed index %d out of range", index)
+	}
+	feed := feeds[index]
+
+	if fs.configManager.Get().Paused {
+		return 0, fmt.Errorf("posting is paused")
+	}
+
+	feedData, err := fetchFeedContext(fs.ctx, feed.FeedUrl, parseTimeoutFor(feed))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse feed %s: %s", feed.FeedUrl, describeFetchError(err, fs.configManager.Get()))
+	}
+
+	if n > len(feedData.Items) {
+		n = len(feedData.Items)
+	}
+
+	// Feeds list the newest items first; send the selected ones oldest first
+	sent := 0
+	for i := n - 1; i >= 0; i-- {
+		item := feedData.Items[i]
+		key := dedupKey(feed, item)
+		if key == "" {
+			log.Printf("Skipping item without GUID or link in feed %s: %s", feed.FeedUrl, item.Title)
 			continue
 		}
+		err = fs.sendAndRecordItem(feed, feedData, item, key)
+		if err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// PlannedItem describes an item in a dry-run plan
+type PlannedItem struct {
+	GUID    string   `json:"guid"`
+	Title   string   `json:"title"`
+	Link    string   `json:"link"`
+	Message string   `json:"message,omitempty"`
+	Chats   []ChatID `json:"chats,omitempty"`
+}
+
+// FeedPlan lists which items of a feed would be sent and which would be skipped as already
+// seen or held until their publication time
+type FeedPlan struct {
+	FeedURL string        `json:"feed_url"`
+	Send    []PlannedItem `json:"send"`
+	Skip    []PlannedItem `json:"skip"`
+}
+
+// Plan fetches the feed at the given index and reports what the scheduler would do
+// with its items, without sending or saving anything
+func (fs *FeedScheduler) Plan(index int) (*FeedPlan, error) {
+	feeds := fs.configManager.Get().Feeds
+	if index < 0 || index >= len(feeds) {
+		return nil, fmt.Errorf("feed index %d out of range", index)
+	}
+	feed := feeds[index]
+
+	feedData, err := fetchFeedContext(fs.ctx, feed.FeedUrl, parseTimeoutFor(feed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse feed %s: %s", feed.FeedUrl, describeFetchError(err, fs.configManager.Get()))
+	}
+
+	previousDedup, err := fs.previousDedupFeed(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &FeedPlan{FeedURL: feed.FeedUrl, Send: []PlannedItem{}, Skip: []PlannedItem{}}
+
+	// Same order as fetchAndProcessFeed: oldest first
+	for i := len(feedData.Items) - 1; i >= 0; i-- {
+		item := feedData.Items[i]
+		key := dedupKey(feed, item)
+		if key == "" || skipUndated(feed, item) {
+			continue // Items without identity, or undated ones the feed skips, are never sent
+		}
 
-		// Save the item to the database after successful send
-		err = fs.dbManager.SaveFeedItem(feedItem)
+		planned := PlannedItem{GUID: key, Title: item.Title, Link: item.Link}
+
+		isPosted, err := fs.dbManager.IsFeedItemPosted(key, feed.Key())
+		if err == nil && !isPosted && previousDedup != nil {
+			isPosted, err = fs.postedUnderPreviousKey(feed, previousDedup, item, key)
+		}
 		if err != nil {
-			log.Printf("Error saving feed item: %v", err)
+			return nil, err
+		}
+		if isPosted || !languageAllowed(feed, feedData, item) || holdUntilPublished(feed, item, time.Now()) {
+			plan.Skip = append(plan.Skip, planned)
 			continue
+		}
+
+		planned.Message = RenderFeedItem(feed, buildItemMap(item, feedData))
+		for _, target := range resolveTargets(feed, item) {
+			planned.Chats = append(planned.Chats, target.ChatID)
+		}
+		plan.Send = append(plan.Send, planned)
+	}
+
+	return plan, nil
+}
+
+// ErrItemNotFound is returned when a feed has no item with the requested GUID
+var ErrItemNotFound = errors.New("item not found")
+
+// RenderItem fetches the feed at the given index and renders the item with the given GUID
+// (or dedup key) the way it would be sent, without sending it
+func (fs *FeedScheduler) RenderItem(index int, guid string) (*PlannedItem, error) {
+	feeds := fs.configManager.Get().Feeds
+	if index < 0 || index >= len(feeds) {
+		return nil, fmt.Errorf("feed index %d out of range", index)
+	}
+	feed := feeds[index]
+
+	feedData, err := fetchFeedContext(fs.ctx, feed.FeedUrl, parseTimeoutFor(feed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse feed %s: %s", feed.FeedUrl, describeFetchError(err, fs.configManager.Get()))
+	}
+
+	for _, item := range feedData.Items {
+		key := dedupKey(feed, item)
+		if item.GUID != guid && key != guid {
+			continue
+		}
+
+		rendered := &PlannedItem{
+			GUID:    key,
+			Title:   item.Title,
+			Link:    item.Link,
+			Message: RenderFeedItem(feed, buildItemMap(item, feedData)),
+		}
+		for _, target := range resolveTargets(feed, item) {
+			rendered.Chats = append(rendered.Chats, target.ChatID)
+		}
+		return rendered, nil
+	}
+
+	return nil, ErrItemNotFound
+}
+
+// sendAndRecordItem sends a single feed item to Telegram and records it in the database
+func (fs *FeedScheduler) sendAndRecordItem(feed Feed, feedData *gofeed.Feed, item *gofeed.Item, key string) error {
+	feedItem := newFeedItem(feed, item, key)
+
+	itemMap := buildItemMap(item, feedData)
+	fs.resolveItemLink(feed, itemMap)
+
+	// Apply the feed's policy when its template doesn't render the item
+	renderErr := templateError(feed)
+	fs.recordTemplateError(feed, renderErr)
+	if renderErr != nil {
+		switch feed.TemplateError {
+		case templateErrorSkip:
+			// The item isn't recorded, so it is sent once the template is fixed
+			return fmt.Errorf("skipped feed item %s: %v", item.Title, renderErr)
+		case templateErrorRaw:
+			log.Printf("Sending raw template of feed %s: %v", feed.FeedUrl, renderErr)
+		default:
+			log.Printf("Sending feed item with the fallback template, feed %s: %v", feed.FeedUrl, renderErr)
+			feed = fallbackFeed(feed)
+		}
+	}
+
+	// Apply the feed's policy when the item leaves the message empty, which Telegram rejects
+	if renderErr == nil && renderedEmpty(feed, itemMap) {
+		if feed.EmptyMessage != emptyMessageSkip {
+			log.Printf("Feed item renders an empty message, sending it with the fallback template: %s", item.Title)
+			feed = fallbackFeed(feed)
+		}
+
+		// Without a title and link the fallback is empty as well
+		if renderedEmpty(feed, itemMap) {
+			// Recorded as seen, since the item renders the same way on every fetch
+			log.Printf("Skipping feed item that renders an empty message in feed %s: %s", feed.FeedUrl, item.Title)
+			return fs.dbManager.SaveFeedItem(trimForStorage(feedItem, fs.configManager.Get()))
+		}
+	}
+
+	// renderedMessage is the text the item is sent as, when it isn't sent as a photo
+	renderedMessage := func() string {
+		if renderErr != nil && feed.TemplateError == templateErrorRaw {
+			return rawTemplateMessage(feed, renderErr)
+		}
+		return RenderFeedItem(feed, itemMap)
+	}
+
+	// Send the item to every target chat first
+	targets := resolveTargets(feed, item)
+	ids := make([]int64, len(targets))
+	errs := make([]error, len(targets))
+	fellBack := make([]bool, len(targets))
+	send := func(i int) {
+		routedFeed := feed
+		routedFeed.TelegramChatId = targets[i].ChatID
+		routedFeed.TelegramMessageThreadId = targets[i].ThreadID
+		routedFeed.replyTo = fs.replyTo(feed, item, targets[i].ChatID)
+
+		if renderErr != nil && feed.TemplateError == templateErrorRaw {
+			ids[i], errs[i] = fs.telegram.SendRenderedMessage(routedFeed, rawTemplateMessage(feed, renderErr))
 		} else {
-			log.Printf("Sent feed item to Telegram and saved to database: %s", item.Title)
+			ids[i], errs[i] = fs.telegram.SendFeedItemToTelegram(routedFeed, itemMap)
+		}
+
+		// Deliver what the chat can't take to the fallback chat, so the item is still seen
+		if errs[i] != nil && !feed.FallbackChatID.IsZero() && isPermanentSendError(errs[i]) {
+			fallbackID, err := fs.telegram.SendToFallbackChat(routedFeed, renderedMessage(), errs[i])
+			if err != nil {
+				log.Printf("Error sending feed item to fallback chat %s: %v", feed.FallbackChatID, err)
+			} else {
+				log.Printf("Chat %s rejected feed item, sent it to fallback chat %s: %v", targets[i].ChatID, feed.FallbackChatID, errs[i])
+				ids[i], errs[i], fellBack[i] = fallbackID, nil, true
+			}
+		}
+
+		// Follow the text with the item's location; a failure here doesn't undo the item
+		if errs[i] == nil && !fellBack[i] && feed.MessageType == messageTypeLocation {
+			if latitude, longitude, ok := itemLocation(feed, item); ok {
+				_, err := fs.telegram.SendLocation(routedFeed, latitude, longitude)
+				if err != nil {
+					log.Printf("Error sending location of feed item to chat %s: %v", targets[i].ChatID, err)
+				}
+			}
+		}
+	}
+
+	if feed.ParallelFanOut && len(targets) > 1 {
+		// Each chat is sent to (and retried) on its own; waiting for all of them before the
+		// next item keeps the items in order within every chat
+		var wg sync.WaitGroup
+		for i := range targets {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				send(i)
+			}(i)
 		}
+		wg.Wait()
+	} else {
+		for i := range targets {
+			send(i)
+		}
+	}
+
+	var messageID int64
+	var sendErr error
+	delivered := 0
+	ambiguous := false
+	for i, target := range targets {
+		if errs[i] != nil {
+			log.Printf("Error sending feed item to chat %s: %v", target.ChatID, errs[i])
+			sendErr = errs[i]
+			ambiguous = ambiguous || isAmbiguousSendError(errs[i])
+			continue
+		}
+		if fellBack[i] {
+			// Recorded as delivered, so the fallback chat doesn't get the item on every fetch
+			delivered++
+			continue
+		}
+		if messageID == 0 {
+			messageID = ids[i]
+		}
+		delivered++
+
+		if ids[i] != 0 {
+			err := fs.dbManager.SaveSentMessage(feed.Key(), target.ChatID, key, item.Link, ids[i])
+			if err != nil {
+				log.Printf("Error recording message ID of feed item: %v", err)
+			}
+		}
+	}
+
+	if delivered == 0 && ambiguous {
+		// Record the item so it isn't sent again, and leave it to an operator to confirm
+		// it or have it resent
+		err := fs.dbManager.SavePossiblySentItem(trimForStorage(feedItem, fs.configManager.Get()))
+		if err != nil {
+			return err
+		}
+		log.Printf("Feed item may have been sent, marked as possibly sent: %s", item.Title)
+		return nil
+	}
+
+	if delivered == 0 && feed.SendFailure == sendFailureMarkSeen && isPermanentSendError(sendErr) {
+		// Retrying the item on every fetch wouldn't get it through
+		err := fs.dbManager.SaveFeedItem(trimForStorage(feedItem, fs.configManager.Get()))
+		if err != nil {
+			return err
+		}
+		log.Printf("Feed item was rejected by Telegram, marked as seen without sending: %s: %v", item.Title, sendErr)
+		return nil
+	}
+
+	if delivered == 0 {
+		// Don't save to database if sending to Telegram failed
+		return fmt.Errorf("failed to send feed item to Telegram: %v", sendErr)
+	}
+
+	// Save the item to the database after successful send
+	err := fs.dbManager.SaveFeedItem(trimForStorage(feedItem, fs.configManager.Get()))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Sent feed item to Telegram and saved to database: %s", item.Title)
+	fs.recordItemSent(feed.Key())
+
+	if path := fs.configManager.Get().DeliveryLog; path != "" {
+		messageLength := utf8.RuneCountInString(renderedMessage())
+		now := time.Now()
+		for i, target := range targets {
+			if errs[i] != nil {
+				continue
+			}
+			event := deliveryEvent{
+				Time:          now,
+				Feed:          feed.FeedUrl,
+				FeedName:      feed.Name,
+				GUID:          key,
+				Chat:          target.ChatID,
+				MessageID:     ids[i],
+				MessageLength: messageLength,
+			}
+			if fellBack[i] {
+				event.Chat, event.Fallback = feed.FallbackChatID, true
+			}
+			fs.deliveries.write(path, event)
+		}
+	}
+
+	if fs.OnItemSent != nil {
+		go fs.OnItemSent(feed, feedItem, messageID)
 	}
 
 	return nil
 }
 
-// Stop stops the feed scheduler
+// newFeedItem converts a gofeed.Item to the FeedItem stored in the database
+func newFeedItem(feed Feed, item *gofeed.Item, key string) FeedItem {
+	feedItem := FeedItem{
+		GUID:        key,
+		Title:       item.Title,
+		Description: item.Description,
+		Link:        item.Link,
+		FeedURL:     feed.Key(),
+	}
+
+	feedItem.PublishedAt, feedItem.DateSynthesized = publicationDate(feed, item, time.Now())
+
+	return feedItem
+}
+
+// buildItemMap creates the template data map for a feed item. It is the only place the
+// item context is built, for scheduled posts and previews alike, and it copies slices and
+// maps so later changes to the item don't leak into the context.
+func buildItemMap(item *gofeed.Item, feedData *gofeed.Feed) map[string]interface{} {
+	return map[string]interface{}{
+		"Title":       item.Title,
+		"Description": item.Description,
+		"Content":     item.Content,
+		"Link":        item.Link,
+		"Updated":     item.Updated,
+		"Published":   item.Published,
+		"GUID":        item.GUID,
+
+		"Author": func() interface{} {
+			if item.Author != nil {
+				return map[string]interface{}{
+					"Name":  item.Author.Name,
+					"Email": item.Author.Email,
+				}
+			}
+			return nil
+		}(),
+
+		"Authors": func() []interface{} {
+			var authorsList []interface{}
+			for _, author := range item.Authors {
+				if author != nil {
+					authorsList = append(authorsList, map[string]interface{}{
+						"Name":  author.Name,
+						"Email": author.Email,
+					})
+				}
+			}
+			return authorsList
+		}(),
+
+		// Categories
+		"Categories": append([]string(nil), item.Categories...),
+
+		// Image information
+		"Image": func() interface{} {
+			if item.Image != nil {
+				return map[string]interface{}{
+					"URL":   item.Image.URL,
+					"Title": item.Image.Title,
+				}
+			}
+			return nil
+		}(),
+
+		// Links
+		"Links": append([]string(nil), item.Links...),
+
+		// Date/time information
+		"UpdatedParsed": func() string {
+			if item.UpdatedParsed != nil {
+				return item.UpdatedParsed.Format("2006-01-02 15:04:05 MST")
+			}
+			return ""
+		}(),
+		"PublishedParsed": func() string {
+			if item.PublishedParsed != nil {
+				return item.PublishedParsed.Format("2006-01-02 15:04:05 MST")
+			}
+			return ""
+		}(),
+
+		// Enclosures
+		"Enclosures": func() []interface{} {
+			var enclosuresList []interface{}
+			for _, enclosure := range item.Enclosures {
+				if enclosure != nil {
+					enclosuresList = append(enclosuresList, map[string]interface{}{
+						"URL":    enclosure.URL,
+						"Type":   enclosure.Type,
+						"Length": enclosure.Length,
+					})
+				}
+			}
+			return enclosuresList
+		}(),
+
+		// Custom fields
+		"Custom": func() map[string]string {
+			if item.Custom == nil {
+				return nil
+			}
+			custom := make(map[string]string, len(item.Custom))
+			for key, value := range item.Custom {
+				custom[key] = value
+			}
+			return custom
+		}(),
+
+		// Feed-level properties
+		"FeedTitle":       feedData.Title,
+		"FeedDescription": feedData.Description,
+		"FeedLink":        feedData.Link,
+		"FeedLanguage":    feedData.Language,
+		"FeedCopyright":   feedData.Copyright,
+		"FeedGenerator":   feedData.Generator,
+		"FeedType":        feedData.FeedType,
+		"FeedVersion":     feedData.FeedVersion,
+
+		// Syndication hints (sy: namespace)
+		"FeedUpdatePeriod":    feedExtensionValue(feedData, "sy", "updatePeriod"),
+		"FeedUpdateFrequency": feedExtensionValue(feedData, "sy", "updateFrequency"),
+		"FeedUpdateBase":      feedExtensionValue(feedData, "sy", "updateBase"),
+	}
+}
+
+// feedExtensionValue returns the first value of a feed-level extension element, such as
+// <sy:updatePeriod>, or an empty string when the feed doesn't declare it
+func feedExtensionValue(feedData *gofeed.Feed, namespace, name string) string {
+	elements := feedData.Extensions[namespace][name]
+	if len(elements) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(elements[0].Value)
+}
+
+// Stop stops the feed scheduler. A stopped scheduler can't be started again.
 func (fs *FeedScheduler) Stop() {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
@@ -258,20 +1310,33 @@ func (fs *FeedScheduler) Stop() {
 	fs.cancel()
 
 	// Stop all tickers
-	for url, ticker := range fs.tickers {
-		ticker.Stop()
-		delete(fs.tickers, url)
-	}
+	fs.stopTickerGoroutines()
 
 	// Wait for all goroutines to finish
 	fs.wg.Wait()
+	fs.deliveries.close()
 
 	log.Println("Feed scheduler stopped")
 }
 
-// RefreshConfiguration updates the scheduler with new configuration
+// RefreshConfiguration updates the scheduler with new configuration. The tickers of the
+// old configuration are stopped, and their goroutines have returned, before the new
+// configuration is scheduled.
 func (fs *FeedScheduler) RefreshConfiguration() {
+	if fs.ctx.Err() != nil {
+		log.Println("Feed scheduler is stopped, not reloading the configuration")
+		return
+	}
 	fs.Start() // Restart with new configuration
+	fs.PostStatusEvent(fmt.Sprintf("Configuration reloaded: %d feeds", len(fs.configManager.Get().Feeds)))
+}
+
+// PostStatusEvent posts a lifecycle event to the status chat, logging failures
+func (fs *FeedScheduler) PostStatusEvent(event string) {
+	err := fs.telegram.SendStatusEvent(event)
+	if err != nil {
+		log.Printf("Error posting status event %q: %v", event, err)
+	}
 }
 
 // StartCleanupRoutine starts a periodic cleanup routine
@@ -304,9 +1369,11 @@ func (fs *FeedScheduler) StartCleanupRoutine() {
 func (fs *FeedScheduler) runCleanup() {
 	log.Println("Starting cleanup of old feed items...")
 
-	for _, feed := range fs.configManager.Config.Feeds {
+	for _, feed := range fs.configManager.Get().Feeds {
 		if feed.FeedRetentionDays > 0 {
-			err := fs.dbManager.CleanupOldItems(feed.FeedRetentionDays)
+			err := fs.withDBRetry("cleanup of "+feed.FeedUrl, func() error {
+				return fs.dbManager.CleanupOldItems(feed.Key(), feed.FeedRetentionDays, feed.RetentionKey)
+			})
 			if err != nil {
 				log.Printf("Error cleaning up old items for feed %s: %v", feed.FeedUrl, err)
 			}
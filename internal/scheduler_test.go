@@ -0,0 +1,894 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// testItem is an item of a feed served by feedServer
+type testItem struct {
+	GUID        string
+	Title       string
+	Link        string
+	Description string
+	Published   time.Time
+	Categories  []string
+}
+
+// rssFeed builds an RSS document listing the items in the given order, newest first
+func rssFeed(items ...testItem) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>Test feed</title><link>https://example.com/</link>`)
+	for _, item := range items {
+		sb.WriteString("<item>")
+		if item.GUID != "" {
+			fmt.Fprintf(&sb, "<guid>%s</guid>", html.EscapeString(item.GUID))
+		}
+		fmt.Fprintf(&sb, "<title>%s</title>", html.EscapeString(item.Title))
+		if item.Link != "" {
+			fmt.Fprintf(&sb, "<link>%s</link>", html.EscapeString(item.Link))
+		}
+		if item.Description != "" {
+			fmt.Fprintf(&sb, "<description>%s</description>", html.EscapeString(item.Description))
+		}
+		for _, category := range item.Categories {
+			fmt.Fprintf(&sb, "<category>%s</category>", html.EscapeString(category))
+		}
+		if !item.Published.IsZero() {
+			fmt.Fprintf(&sb, "<pubDate>%s</pubDate>", item.Published.Format(time.RFC1123Z))
+		}
+		sb.WriteString("</item>")
+	}
+	sb.WriteString("</channel></rss>")
+	return sb.String()
+}
+
+// feedServer serves a feed document and counts the requests for it
+type feedServer struct {
+	*httptest.Server
+	requests atomic.Int32
+	mu       sync.Mutex
+	body     string
+}
+
+// newFeedServer serves body until it is replaced with setBody
+func newFeedServer(t *testing.T, body string) *feedServer {
+	t.Helper()
+	s := &feedServer{body: body}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		s.mu.Lock()
+		body := s.body
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *feedServer) setBody(body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.body = body
+}
+
+// testFeed returns a feed of the given URL that posts to chat 100 with a plain template
+func testFeed(feedURL string) Feed {
+	return Feed{
+		FeedUrl:                  feedURL,
+		TelegramApiToken:         "123:test",
+		TelegramChatId:           "100",
+		TelegramTemplate:         "{{.Title}}",
+		FeedFetchIntervalMinutes: 60,
+	}
+}
+
+// newTestScheduler creates a scheduler with a temporary database whose Telegram calls
+// go to a recorder. The scheduler is stopped when the test ends.
+func newTestScheduler(t *testing.T, config *Config) (*FeedScheduler, *telegramRecorder) {
+	t.Helper()
+	cm := newTestConfigManager(t, config)
+	fs := NewFeedScheduler(cm, newTestDB(t))
+	recorder := newTelegramRecorder(t)
+	fs.telegram.Client = recorder.client()
+	t.Cleanup(fs.Stop)
+	return fs, recorder
+}
+
+// sentTexts returns the texts of the messages sent to Telegram, in order
+func sentTexts(recorder *telegramRecorder) []string {
+	var texts []string
+	for _, call := range recorder.callsTo("sendMessage") {
+		texts = append(texts, call.text())
+	}
+	return texts
+}
+
+// newTestRouter serves the API of a test scheduler
+func newTestRouter(fs *FeedScheduler) http.Handler {
+	return Router(NewHandlers(fs.configManager, fs))
+}
+
+// serve sends a request to a handler and returns the recorded response
+func serve(handler http.Handler, method, target string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSendLatestSendsAndRecordsNItems(t *testing.T) {
+	server := newFeedServer(t, rssFeed(
+		testItem{GUID: "4", Title: "Fourth"},
+		testItem{GUID: "3", Title: "Third"},
+		testItem{GUID: "2", Title: "Second"},
+		testItem{GUID: "1", Title: "First"},
+	))
+	feed := testFeed(server.URL)
+	feed.Mode = feedModeRealtime
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	rec := serve(newTestRouter(fs), http.MethodPost, "/feeds/0/send-latest?n=2", "")
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// The newest two, oldest first, even though the feed is in realtime mode
+	texts := sentTexts(recorder)
+	if len(texts) != 2 || texts[0] != "Third" || texts[1] != "Fourth" {
+		t.Fatalf("got sends %q, want Third and Fourth", texts)
+	}
+	for guid, want := range map[string]bool{"4": true, "3": true, "2": false, "1": false} {
+		posted, err := fs.dbManager.IsFeedItemPosted(guid, feed.Key())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if posted != want {
+			t.Errorf("item %s recorded: %v, want %v", guid, posted, want)
+		}
+	}
+}
+
+func TestSendLatestRejectsInvalidCount(t *testing.T) {
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{testFeed("https://example.com/feed.xml")}})
+
+	for _, target := range []string{"/feeds/0/send-latest?n=0", "/feeds/0/send-latest?n=x", "/feeds/x/send-latest"} {
+		if rec := serve(newTestRouter(fs), http.MethodPost, target, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want 400", target, rec.Code)
+		}
+	}
+	if len(recorder.Calls()) != 0 {
+		t.Fatal("Telegram was called")
+	}
+}
+
+func TestOnItemSentCallback(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "item-1", Title: "First", Link: "https://example.com/1"}))
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{testFeed(server.URL)}})
+
+	type sentEvent struct {
+		feed      Feed
+		item      FeedItem
+		messageID int64
+	}
+	events := make(chan sentEvent, 1)
+	fs.OnItemSent = func(feed Feed, item FeedItem, messageID int64) {
+		events <- sentEvent{feed, item, messageID}
+	}
+
+	if err := fs.fetchAndProcessFeed(fs.configManager.Get().Feeds[0]); err != nil {
+		t.Fatalf("fetchAndProcessFeed: %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event.feed.FeedUrl != server.URL || event.item.GUID != "item-1" || event.item.Link != "https://example.com/1" || event.messageID != 1 {
+			t.Fatalf("unexpected callback arguments %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback was not called")
+	}
+}
+
+func TestOnItemSentDoesNotBlock(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "item-1", Title: "First"}))
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{testFeed(server.URL)}})
+
+	release := make(chan struct{})
+	defer close(release)
+	fs.OnItemSent = func(Feed, FeedItem, int64) { <-release }
+
+	done := make(chan error, 1)
+	go func() { done <- fs.fetchAndProcessFeed(fs.configManager.Get().Feeds[0]) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("fetchAndProcessFeed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow callback held up the scheduler")
+	}
+}
+
+func TestCheckStaleFlagsSilentFeed(t *testing.T) {
+	feed := testFeed("https://example.com/feed.xml")
+	feed.StaleAfterDays = 2
+	fs, recorder := newTestScheduler(t, &Config{
+		AlertTelegramApiToken: "456:alert",
+		AlertTelegramChatId:   "999",
+		Feeds:                 []Feed{feed},
+	})
+
+	// The last new item arrived three days ago
+	if err := fs.dbManager.SaveFeedItem(FeedItem{GUID: "old", Title: "Old", FeedURL: feed.Key()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.dbManager.db.Exec(`UPDATE feed_items SET created_at = datetime('now', '-3 days')`); err != nil {
+		t.Fatal(err)
+	}
+
+	fs.checkStale(feed)
+	if !fs.Status()[feed.Key()].Stale {
+		t.Fatal("feed not flagged as stale")
+	}
+	if calls := recorder.callsTo("sendMessage"); len(calls) != 1 || calls[0].chatID() != "999" {
+		t.Fatalf("got calls %v, want one alert", recorder.Calls())
+	}
+
+	// Still stale: no second alert
+	fs.checkStale(feed)
+	if got := len(recorder.callsTo("sendMessage")); got != 1 {
+		t.Fatalf("got %d alerts, want 1", got)
+	}
+
+	// A new item clears the flag
+	if err := fs.dbManager.SaveFeedItem(FeedItem{GUID: "new", Title: "New", FeedURL: feed.Key()}); err != nil {
+		t.Fatal(err)
+	}
+	fs.checkStale(feed)
+	if fs.Status()[feed.Key()].Stale {
+		t.Fatal("feed still stale after a new item")
+	}
+}
+
+func TestCheckStaleWithinThreshold(t *testing.T) {
+	feed := testFeed("https://example.com/feed.xml")
+	feed.StaleAfterDays = 2
+	fs, recorder := newTestScheduler(t, &Config{AlertTelegramApiToken: "456:alert", AlertTelegramChatId: "999", Feeds: []Feed{feed}})
+
+	// Nothing stored yet and the scheduler just started
+	fs.checkStale(feed)
+	if fs.Status()[feed.Key()].Stale || len(recorder.Calls()) != 0 {
+		t.Fatal("new feed flagged as stale")
+	}
+
+	// Nothing stored since a start three days ago
+	fs.startedAt = time.Now().Add(-72 * time.Hour)
+	fs.checkStale(feed)
+	if !fs.Status()[feed.Key()].Stale {
+		t.Fatal("feed without items since the start not flagged as stale")
+	}
+}
+
+func TestPlanSkipsItemsAlreadyInDB(t *testing.T) {
+	server := newFeedServer(t, rssFeed(
+		testItem{GUID: "3", Title: "Third"},
+		testItem{GUID: "2", Title: "Second"},
+		testItem{GUID: "1", Title: "First"},
+	))
+	feed := testFeed(server.URL)
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	if err := fs.dbManager.SaveFeedItem(FeedItem{GUID: "2", Title: "Second", FeedURL: feed.Key()}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(newTestRouter(fs), http.MethodGet, "/feeds/0/plan", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+	// Chat IDs are marshalled as numbers, so decode them generically
+	var plan struct {
+		Send []struct {
+			GUID    string        `json:"guid"`
+			Message string        `json:"message"`
+			Chats   []json.Number `json:"chats"`
+		} `json:"send"`
+		Skip []struct {
+			GUID string `json:"guid"`
+		} `json:"skip"`
+	}
+	decoder := json.NewDecoder(rec.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&plan); err != nil {
+		t.Fatalf("decoding plan: %v", err)
+	}
+
+	if len(plan.Send) != 2 || plan.Send[0].GUID != "1" || plan.Send[1].GUID != "3" {
+		t.Fatalf("got send list %+v, want items 1 and 3", plan.Send)
+	}
+	if plan.Send[0].Message != "First" || len(plan.Send[0].Chats) != 1 || plan.Send[0].Chats[0] != "100" {
+		t.Errorf("unexpected planned item %+v", plan.Send[0])
+	}
+	if len(plan.Skip) != 1 || plan.Skip[0].GUID != "2" {
+		t.Fatalf("got skip list %+v, want item 2", plan.Skip)
+	}
+
+	// Nothing was sent or saved
+	if len(recorder.Calls()) != 0 {
+		t.Fatal("plan sent messages")
+	}
+	if posted, _ := fs.dbManager.IsFeedItemPosted("1", feed.Key()); posted {
+		t.Fatal("plan recorded an item")
+	}
+}
+
+func TestStartSkipsInitialFetch(t *testing.T) {
+	// Restored after the scheduler is stopped, since cleanups run last in first out
+	unit := fetchIntervalUnit
+	t.Cleanup(func() { fetchIntervalUnit = unit })
+	fetchIntervalUnit = 10 * time.Millisecond
+
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}, SkipInitialFetch: true})
+
+	fs.Start()
+	if n := server.requests.Load(); n != 0 {
+		t.Fatalf("feed fetched %d times during Start", n)
+	}
+
+	// The interval is 60 units, so the first tick comes after 600ms
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sentTexts(recorder)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if texts := sentTexts(recorder); len(texts) != 1 || texts[0] != "First" {
+		t.Fatalf("got messages %q after the first tick, want the item", texts)
+	}
+}
+
+func TestStartFetchesByDefault(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{testFeed(server.URL)}})
+
+	fs.Start()
+	if n := server.requests.Load(); n != 1 {
+		t.Fatalf("feed fetched %d times during Start, want 1", n)
+	}
+	if texts := sentTexts(recorder); len(texts) != 1 {
+		t.Fatalf("got messages %q, want the item", texts)
+	}
+}
+
+func TestWatchdogFlagsStuckFetch(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		fmt.Fprint(w, rssFeed())
+	}))
+	defer server.Close()
+	feed := testFeed(server.URL)
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{feed}, StuckFetchThresholdMinutes: 5})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fs.runFeed(feed)
+	}()
+	defer func() {
+		close(release)
+		<-done
+	}()
+
+	// Wait for the fetch to start
+	for {
+		fs.statusMu.Lock()
+		started := fs.feedStatus(feed.Key()).FetchStartedAt
+		fs.statusMu.Unlock()
+		if !started.IsZero() {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	fs.checkStuck(time.Now())
+	if stuck := fs.StuckFeeds(); len(stuck) != 0 {
+		t.Fatalf("flagged %v before the threshold", stuck)
+	}
+
+	fs.checkStuck(time.Now().Add(6 * time.Minute))
+	if stuck := fs.StuckFeeds(); len(stuck) != 1 || stuck[0] != feed.Key() {
+		t.Fatalf("got stuck feeds %v, want %s", stuck, feed.Key())
+	}
+	rec := serve(newTestRouter(fs), http.MethodGet, "/healthz", "")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"stuck"`) {
+		t.Fatalf("got /healthz %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestWatchdogClearsFinishedFetch(t *testing.T) {
+	server := newFeedServer(t, rssFeed())
+	feed := testFeed(server.URL)
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	fs.runFeed(feed)
+	fs.checkStuck(time.Now().Add(time.Hour))
+	if stuck := fs.StuckFeeds(); len(stuck) != 0 {
+		t.Fatalf("finished fetch flagged as stuck: %v", stuck)
+	}
+	if rec := serve(newTestRouter(fs), http.MethodGet, "/healthz", ""); rec.Code != http.StatusOK {
+		t.Fatalf("got /healthz %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestFeedItemEndpoint(t *testing.T) {
+	server := newFeedServer(t, rssFeed(
+		testItem{GUID: "2", Title: "Second"},
+		testItem{GUID: "1", Title: "First"},
+	))
+	feed := testFeed(server.URL)
+	feed.TelegramTemplate = "<b>{{.Title}}</b>"
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	router := newTestRouter(fs)
+
+	rec := serve(router, http.MethodGet, "/feeds/0/item?guid=1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+	var item struct {
+		GUID    string `json:"guid"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &item); err != nil {
+		t.Fatalf("decoding item: %v", err)
+	}
+	if item.GUID != "1" || item.Message != "<b>First</b>" {
+		t.Fatalf("got item %+v", item)
+	}
+
+	rec = serve(router, http.MethodGet, "/feeds/0/item?guid=missing", "")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("got status %d for an unknown GUID: %s", rec.Code, rec.Body.String())
+	}
+
+	if len(recorder.Calls()) != 0 {
+		t.Fatal("rendering an item sent a message")
+	}
+}
+
+// apiStatus returns the feeds reported by GET /api/status
+func apiStatus(t *testing.T, fs *FeedScheduler) []FeedRuntimeStatus {
+	t.Helper()
+	rec := serve(newTestRouter(fs), http.MethodGet, "/api/status", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Feeds []FeedRuntimeStatus `json:"feeds"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding status: %v", err)
+	}
+	return body.Feeds
+}
+
+func TestAPIStatusReflectsFetchResults(t *testing.T) {
+	okServer := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	failingServer, _ := failingFeedServer(t, 100, http.StatusNotFound)
+	okFeed, failingFeed := testFeed(okServer.URL), testFeed(failingServer.URL)
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{okFeed, failingFeed}})
+
+	feeds := apiStatus(t, fs)
+	if len(feeds) != 2 || feeds[0].LastResult != "pending" || feeds[0].LastFetch != nil {
+		t.Fatalf("unexpected status before fetching: %+v", feeds)
+	}
+
+	fs.runFeed(okFeed)
+	fs.runFeed(failingFeed)
+	fs.runFeed(failingFeed)
+
+	feeds = apiStatus(t, fs)
+	ok, failing := feeds[0], feeds[1]
+	if ok.LastResult != "ok" || ok.LastFetch == nil || ok.ConsecutiveFailures != 0 || ok.ItemsSentToday != 1 {
+		t.Errorf("unexpected status of the working feed: %+v", ok)
+	}
+	if ok.IntervalMinutes != 60 || ok.FeedURL != okServer.URL {
+		t.Errorf("unexpected feed details: %+v", ok)
+	}
+	if failing.LastResult != "error" || failing.LastError == "" || failing.ConsecutiveFailures != 2 || failing.ItemsSentToday != 0 {
+		t.Errorf("unexpected status of the failing feed: %+v", failing)
+	}
+}
+
+func TestNextTickAfterStart(t *testing.T) {
+	server := newFeedServer(t, rssFeed())
+	feed := testFeed(server.URL)
+	paused := testFeed(server.URL + "/paused")
+	paused.Paused = true
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{feed, paused}, SkipInitialFetch: true})
+
+	before := time.Now()
+	fs.Start()
+	after := time.Now()
+
+	next, ok := fs.NextTick(feed.Key())
+	if !ok {
+		t.Fatal("no next tick for a running feed")
+	}
+	interval := 60 * time.Minute
+	if next.Before(before.Add(interval)) || next.After(after.Add(interval)) {
+		t.Fatalf("next tick %v is not %v after Start", next, interval)
+	}
+
+	if _, ok := fs.NextTick(paused.Key()); ok {
+		t.Error("paused feed has a next tick")
+	}
+	if _, ok := fs.NextTick("https://unknown.example.com/feed.xml"); ok {
+		t.Error("unknown feed has a next tick")
+	}
+}
+
+func TestNextTickAdvancesOnTick(t *testing.T) {
+	unit := fetchIntervalUnit
+	t.Cleanup(func() { fetchIntervalUnit = unit })
+	fetchIntervalUnit = time.Millisecond
+
+	server := newFeedServer(t, rssFeed())
+	feed := testFeed(server.URL)
+	fs, _ := newTestScheduler(t, &Config{Feeds: []Feed{feed}, SkipInitialFetch: true})
+
+	fs.Start()
+	first, _ := fs.NextTick(feed.Key())
+
+	deadline := time.Now().Add(5 * time.Second)
+	for server.requests.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if next, _ := fs.NextTick(feed.Key()); !next.After(first) {
+		t.Fatalf("next tick %v did not advance past %v", next, first)
+	}
+}
+
+func TestHoldUntilPublished(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *gofeed.Item {
+		published := now.Add(d)
+		return &gofeed.Item{PublishedParsed: &published}
+	}
+	feed := Feed{HoldFutureItems: true, MaxFutureHours: 48}
+
+	for _, tc := range []struct {
+		name string
+		feed Feed
+		item *gofeed.Item
+		want bool
+	}{
+		{"future item", feed, at(time.Hour), true},
+		{"past item", feed, at(-time.Hour), false},
+		{"undated item", feed, &gofeed.Item{}, false},
+		{"beyond the cap", feed, at(49 * time.Hour), false},
+		{"default cap", Feed{HoldFutureItems: true}, at(6 * 24 * time.Hour), true},
+		{"holding disabled", Feed{}, at(time.Hour), false},
+	} {
+		if got := holdUntilPublished(tc.feed, tc.item, now); got != tc.want {
+			t.Errorf("%s: held %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFutureItemHeldThenReleased(t *testing.T) {
+	// pubDate has whole seconds, so this is between one and two seconds ahead
+	published := time.Now().Truncate(time.Second).Add(2 * time.Second)
+	server := newFeedServer(t, rssFeed(
+		testItem{GUID: "future", Title: "Scheduled", Published: published},
+		testItem{GUID: "now", Title: "Current", Published: time.Now().Add(-time.Hour)},
+	))
+	feed := testFeed(server.URL)
+	feed.HoldFutureItems = true
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	fs.runFeed(feed)
+	if texts := sentTexts(recorder); len(texts) != 1 || texts[0] != "Current" {
+		t.Fatalf("got messages %q, want the future item held", texts)
+	}
+
+	// The same body is served again once the publication time has passed
+	time.Sleep(time.Until(published) + 100*time.Millisecond)
+	fs.runFeed(feed)
+	if texts := sentTexts(recorder); len(texts) != 2 || texts[1] != "Scheduled" {
+		t.Fatalf("got messages %q, want the held item released", texts)
+	}
+}
+
+// postJSON sends a POST request that asks for a JSON response
+func postJSON(handler http.Handler, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, nil)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestKillSwitchStopsPosting(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	router := newTestRouter(fs)
+
+	if rec := postJSON(router, "/pause"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"paused":true`) {
+		t.Fatalf("got /pause %d: %s", rec.Code, rec.Body.String())
+	}
+	if body := serve(router, http.MethodGet, "/healthz", "").Body.String(); !strings.Contains(body, `"paused":true`) {
+		t.Fatalf("/healthz does not report the kill switch: %s", body)
+	}
+
+	fs.runFeed(feed)
+	if _, err := fs.SendLatest(0, 1); err == nil {
+		t.Error("SendLatest succeeded while paused")
+	}
+	if calls := recorder.Calls(); len(calls) != 0 {
+		t.Fatalf("got %d Telegram calls while paused", len(calls))
+	}
+
+	if rec := postJSON(router, "/resume"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"paused":false`) {
+		t.Fatalf("got /resume %d: %s", rec.Code, rec.Body.String())
+	}
+	fs.runFeed(feed)
+	if texts := sentTexts(recorder); len(texts) != 1 || texts[0] != "First" {
+		t.Fatalf("got messages %q, want the item held back while paused", texts)
+	}
+}
+
+func TestKillSwitchMarkSeen(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}, Paused: true, PauseMarkSeen: true})
+
+	fs.runFeed(feed)
+	if err := fs.SetPaused(false); err != nil {
+		t.Fatal(err)
+	}
+	fs.runFeed(feed)
+
+	if calls := recorder.Calls(); len(calls) != 0 {
+		t.Fatalf("got %d Telegram calls, want items seen while paused to be skipped", len(calls))
+	}
+}
+
+// syndicationFeed declares syndication hints with the sy namespace
+const syndicationFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:sy="http://purl.org/rss/1.0/modules/syndication/">
+<channel>
+<title>Hinted feed</title>
+<link>https://example.com/</link>
+<sy:updatePeriod>hourly</sy:updatePeriod>
+<sy:updateFrequency>2</sy:updateFrequency>
+<sy:updateBase>2000-01-01T12:00+00:00</sy:updateBase>
+<item><guid>1</guid><title>First</title></item>
+</channel>
+</rss>`
+
+// parseTestFeed parses a feed document the way fetched feeds are parsed
+func parseTestFeed(t *testing.T, body string) *gofeed.Feed {
+	t.Helper()
+	feedData, err := parseFeedBody("https://example.com/feed.xml", []byte(body), defaultParseTimeout)
+	if err != nil {
+		t.Fatalf("parsing feed: %v", err)
+	}
+	return feedData
+}
+
+func TestSyndicationHintsInTemplates(t *testing.T) {
+	feedData := parseTestFeed(t, syndicationFeed)
+	item := buildItemMap(feedData.Items[0], feedData)
+
+	message := RenderFeedItem(Feed{TelegramTemplate: "{{.FeedUpdatePeriod}} {{.FeedUpdateFrequency}} {{.FeedUpdateBase}}"}, item)
+	if want := "hourly 2 2000-01-01T12:00+00:00"; message != want {
+		t.Fatalf("got %q, want %q", message, want)
+	}
+
+	plain := parseTestFeed(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	message = RenderFeedItem(Feed{TelegramTemplate: "[{{.FeedUpdatePeriod}}]"}, buildItemMap(plain.Items[0], plain))
+	if message != "[]" {
+		t.Fatalf("got %q for a feed without hints", message)
+	}
+}
+
+func TestPinStartMessagesReplacesPreviousPin(t *testing.T) {
+	pinned := testFeed("https://example.com/pinned.xml")
+	pinned.PinStartMessage = true
+	plain := testFeed("https://example.com/plain.xml")
+	plain.TelegramChatId = "300"
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{pinned, plain}})
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		if call.Method == "pinChatMessage" || call.Method == "unpinChatMessage" {
+			return http.StatusOK, `{"ok":true,"result":true}`
+		}
+		return 0, ""
+	})
+
+	// messageIDs returns the message_id of each call to a method
+	messageIDs := func(method string) []string {
+		var ids []string
+		for _, call := range recorder.callsTo(method) {
+			if call.chatID() != "100" {
+				t.Fatalf("%s sent to chat %s", method, call.chatID())
+			}
+			ids = append(ids, fmt.Sprint(call.Payload["message_id"]))
+		}
+		return ids
+	}
+
+	fs.PinStartMessages()
+	sent := recorder.callsTo("sendMessage")
+	if len(sent) != 1 || sent[0].Payload["disable_notification"] != true {
+		t.Fatalf("unexpected start messages %v", recorder.Calls())
+	}
+	if pins := messageIDs("pinChatMessage"); len(pins) != 1 || pins[0] != "1" {
+		t.Fatalf("got pins %v, want message 1", pins)
+	}
+	if unpins := messageIDs("unpinChatMessage"); len(unpins) != 0 {
+		t.Fatalf("unpinned %v on the first start", unpins)
+	}
+
+	// The next start pins a new message and unpins the old one
+	fs.PinStartMessages()
+	if pins := messageIDs("pinChatMessage"); len(pins) != 2 || pins[1] != "3" {
+		t.Fatalf("got pins %v, want message 3 pinned", pins)
+	}
+	if unpins := messageIDs("unpinChatMessage"); len(unpins) != 1 || unpins[0] != "1" {
+		t.Fatalf("got unpins %v, want message 1 unpinned", unpins)
+	}
+	if id, found, err := fs.dbManager.PinnedMessage(pinned.Key(), pinned.TelegramChatId); err != nil || !found || id != 3 {
+		t.Fatalf("got stored pin %d %v %v, want 3", id, found, err)
+	}
+}
+
+func TestAmbiguousSendMarksItemPossiblySent(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	router := newTestRouter(fs)
+
+	// Telegram answers, but the response is cut off, so the message may have been posted
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		return http.StatusOK, `{"ok":true,"result":{"mess`
+	})
+	fs.runFeed(feed)
+	fs.runFeed(feed)
+
+	if texts := sentTexts(recorder); len(texts) != 1 {
+		t.Fatalf("got messages %q, want a single attempt without retries", texts)
+	}
+	items, err := fs.dbManager.PossiblySentItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].GUID != "1" {
+		t.Fatalf("got possibly sent items %+v, want the item", items)
+	}
+
+	rec := serve(router, http.MethodGet, "/api/possibly-sent", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"First"`) {
+		t.Fatalf("listing failed with %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Resending forgets the item, so the next fetch posts it again
+	recorder.setRespond(nil)
+	if rec := postJSON(router, fmt.Sprintf("/api/possibly-sent/%d/resend", items[0].ID)); rec.Code != http.StatusOK {
+		t.Fatalf("resend failed with %d: %s", rec.Code, rec.Body.String())
+	}
+	fs.runFeed(feed)
+	if texts := sentTexts(recorder); len(texts) != 2 {
+		t.Fatalf("got messages %q, want the item resent", texts)
+	}
+	if items, _ := fs.dbManager.PossiblySentItems(); len(items) != 0 {
+		t.Fatalf("got possibly sent items %+v after resending", items)
+	}
+}
+
+func TestConfirmPossiblySentItem(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	router := newTestRouter(fs)
+
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		return http.StatusOK, `not json`
+	})
+	fs.runFeed(feed)
+	items, err := fs.dbManager.PossiblySentItems()
+	if err != nil || len(items) != 1 {
+		t.Fatalf("got possibly sent items %+v, %v", items, err)
+	}
+
+	if rec := postJSON(router, fmt.Sprintf("/api/possibly-sent/%d/confirm", items[0].ID)); rec.Code != http.StatusOK {
+		t.Fatalf("confirm failed with %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := postJSON(router, fmt.Sprintf("/api/possibly-sent/%d/confirm", items[0].ID)); rec.Code != http.StatusNotFound {
+		t.Fatalf("confirming twice returned %d, want 404", rec.Code)
+	}
+
+	// A confirmed item counts as delivered
+	recorder.setRespond(nil)
+	fs.runFeed(feed)
+	if texts := sentTexts(recorder); len(texts) != 1 {
+		t.Fatalf("got messages %q, confirmed item sent again", texts)
+	}
+	if items, _ := fs.dbManager.PossiblySentItems(); len(items) != 0 {
+		t.Fatalf("got possibly sent items %+v after confirming", items)
+	}
+}
+
+// panicOnceTransport panics on its first request and passes the rest to the wrapped transport
+type panicOnceTransport struct {
+	next     http.RoundTripper
+	panicked atomic.Bool
+}
+
+func (pt *panicOnceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if pt.panicked.CompareAndSwap(false, true) {
+		panic("transport exploded")
+	}
+	return pt.next.RoundTrip(req)
+}
+
+func TestPanicInFeedKeepsSchedulerRunning(t *testing.T) {
+	// Restored after the scheduler is stopped, since cleanups run last in first out
+	unit := fetchIntervalUnit
+	t.Cleanup(func() { fetchIntervalUnit = unit })
+	fetchIntervalUnit = 10 * time.Millisecond
+
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	client := recorder.client()
+	client.HTTPClient = &http.Client{Transport: &panicOnceTransport{next: client.HTTPClient.Transport}}
+	fs.telegram.Client = client
+
+	// The initial fetch panics while sending the item
+	fs.Start()
+	status := apiStatus(t, fs)
+	if len(status) != 1 || status[0].LastResult != "error" || !strings.Contains(status[0].LastError, "transport exploded") {
+		t.Fatalf("panicking fetch not marked as failed: %+v", status)
+	}
+
+	// The ticker survives and sends the item on the next tick
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sentTexts(recorder)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if texts := sentTexts(recorder); len(texts) != 1 || texts[0] != "First" {
+		t.Fatalf("got messages %q after the panic, want the item", texts)
+	}
+}
+
+func TestRunFeedWithoutItems(t *testing.T) {
+	server := newFeedServer(t, rssFeed())
+	feed := testFeed(server.URL)
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	fs.runFeed(feed)
+
+	if calls := recorder.Calls(); len(calls) != 0 {
+		t.Fatalf("got Telegram calls %v for an empty feed", calls)
+	}
+	status := apiStatus(t, fs)
+	if len(status) != 1 || status[0].LastResult != "ok" || status[0].ConsecutiveFailures != 0 {
+		t.Fatalf("empty feed not treated as a successful fetch: %+v", status)
+	}
+}
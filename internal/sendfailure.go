@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Policies for items Telegram keeps rejecting
+const (
+	sendFailureRetry    = "retry"     // leave the item unrecorded, so every fetch tries it again
+	sendFailureMarkSeen = "mark_seen" // record the item as seen and give up on it
+)
+
+// validateSendFailure checks a feed's send_failure option
+func validateSendFailure(policy string) error {
+	switch policy {
+	case "", sendFailureRetry, sendFailureMarkSeen:
+		return nil
+	}
+	return fmt.Errorf("unknown send_failure %q (use %q or %q)", policy, sendFailureRetry, sendFailureMarkSeen)
+}
+
+// isPermanentSendError reports whether Telegram rejected a message in a way that sending
+// it again won't fix, such as an unknown chat or a message it can't parse. Rate limiting,
+// server errors and network failures are temporary.
+func isPermanentSendError(err error) bool {
+	var apiErr *TelegramAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 && apiErr.StatusCode != http.StatusTooManyRequests
+}
+
+// retriesExhaustedError is returned when a message still failed after every retry
+type retriesExhaustedError struct {
+	attempts int
+	err      error
+}
+
+func (e *retriesExhaustedError) Error() string {
+	return fmt.Sprintf("failed to send message to Telegram after %d attempts: %v", e.attempts, e.err)
+}
+
+func (e *retriesExhaustedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// rejectAll makes the fake Telegram API reject every message with a 400
+func rejectAll(recorder *telegramRecorder) {
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		return http.StatusBadRequest, telegramError(400, "Bad Request: chat not found")
+	})
+}
+
+func TestMarkSeenOnPermanentFailure(t *testing.T) {
+	shortTelegramRetryDelay(t)
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	feed.SendFailure = sendFailureMarkSeen
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	rejectAll(recorder)
+
+	fs.runFeed(feed)
+	attempts := len(recorder.Calls())
+	if attempts == 0 {
+		t.Fatal("item was not sent")
+	}
+	if posted, _ := fs.dbManager.IsFeedItemPosted("1", feed.Key()); !posted {
+		t.Fatal("rejected item was not marked as seen")
+	}
+
+	fs.runFeed(feed)
+	if n := len(recorder.Calls()); n != attempts {
+		t.Fatalf("got %d more calls on the next fetch, want the item left alone", n-attempts)
+	}
+}
+
+func TestRetryPolicyLeavesFailedItem(t *testing.T) {
+	shortTelegramRetryDelay(t)
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	rejectAll(recorder)
+
+	fs.runFeed(feed)
+
+	// Unrecorded, so the next fetch tries it again
+	if len(recorder.Calls()) == 0 {
+		t.Fatal("item was not sent")
+	}
+	if posted, _ := fs.dbManager.IsFeedItemPosted("1", feed.Key()); posted {
+		t.Fatal("failed item was recorded under the retry policy")
+	}
+}
+
+func TestIsPermanentSendError(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{&TelegramAPIError{StatusCode: http.StatusBadRequest}, true},
+		{&TelegramAPIError{StatusCode: http.StatusForbidden}, true},
+		{&retriesExhaustedError{attempts: 5, err: &TelegramAPIError{StatusCode: http.StatusBadRequest}}, true},
+		{&RateLimitError{err: &TelegramAPIError{StatusCode: http.StatusTooManyRequests}}, false},
+		{&TelegramAPIError{StatusCode: http.StatusBadGateway}, false},
+		{fmt.Errorf("error sending to Telegram: connection refused"), false},
+	} {
+		if got := isPermanentSendError(tc.err); got != tc.want {
+			t.Errorf("%v: got %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestValidateSendFailure(t *testing.T) {
+	for _, policy := range []string{"", sendFailureRetry, sendFailureMarkSeen} {
+		if err := validateSendFailure(policy); err != nil {
+			t.Errorf("%q: unexpected error: %v", policy, err)
+		}
+	}
+	if err := validateSendFailure("drop"); err == nil {
+		t.Fatal("expected an error for an unknown policy")
+	}
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failFirstSend makes the fake Telegram API fail the first call with a temporary error
+func failFirstSend(recorder *telegramRecorder) {
+	var failed atomic.Bool
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		if failed.CompareAndSwap(false, true) {
+			return http.StatusBadGateway, telegramError(502, "Bad Gateway")
+		}
+		return 0, ""
+	})
+}
+
+func TestHandlersHaveNoSendPathOfTheirOwn(t *testing.T) {
+	if _, ok := reflect.TypeOf(&Handlers{}).MethodByName("SendFeedItemToTelegram"); ok {
+		t.Fatal("Handlers sends feed items without the Telegram service")
+	}
+}
+
+func TestScheduledAndWebSendsRetry(t *testing.T) {
+	shortTelegramRetryDelay(t)
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	fs, recorder := newTestScheduler(t, &Config{
+		Feeds:                []Feed{feed},
+		TestTelegramApiToken: "123:test",
+		TestTelegramChatId:   "200",
+		SampleItem:           &SampleItem{Title: "Sample"},
+	})
+
+	failFirstSend(recorder)
+	fs.runFeed(feed)
+	if texts := sentTexts(recorder); !reflect.DeepEqual(texts, []string{"First", "First"}) {
+		t.Fatalf("got scheduled sends %q, want a retry", texts)
+	}
+	if posted, _ := fs.dbManager.IsFeedItemPosted("1", feed.Key()); !posted {
+		t.Fatal("retried item was not recorded")
+	}
+
+	failFirstSend(recorder)
+	if rec := postJSON(newTestRouter(fs), "/feeds/0/send-sample"); rec.Code != http.StatusOK {
+		t.Fatalf("web send failed with %d: %s", rec.Code, rec.Body.String())
+	}
+	if texts := sentTexts(recorder)[2:]; !reflect.DeepEqual(texts, []string{"Sample", "Sample"}) {
+		t.Fatalf("got web sends %q, want a retry", texts)
+	}
+}
+
+func TestSendLatestIsRateLimited(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "2", Title: "Second"}, testItem{GUID: "1", Title: "First"}))
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{testFeed(server.URL)}})
+
+	start := time.Now()
+	rec := serve(newTestRouter(fs), http.MethodPost, "/feeds/0/send-latest?n=2", "")
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("send failed with %d: %s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("two sends took %v, want them rate limited", elapsed)
+	}
+	if n := len(recorder.callsTo("sendMessage")); n != 2 {
+		t.Fatalf("got %d messages, want 2", n)
+	}
+}
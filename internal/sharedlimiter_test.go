@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHandlersShareSchedulerTelegramService(t *testing.T) {
+	fs, _ := newTestScheduler(t, &Config{})
+	if h := NewHandlers(fs.configManager, fs); h.TelegramService != fs.telegram {
+		t.Fatal("handlers got their own Telegram service")
+	}
+	// Without a scheduler the handlers still get a rate limited service
+	if h := NewHandlers(fs.configManager, nil); h.TelegramService == nil {
+		t.Fatal("handlers without a scheduler have no Telegram service")
+	}
+}
+
+func TestSendsFromDifferentComponentsShareLimiter(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "Scheduled"}))
+	feed := testFeed(server.URL)
+	fs, recorder := newTestScheduler(t, &Config{
+		Feeds:                  []Feed{feed},
+		TestTelegramApiToken:   "123:test",
+		TestTelegramChatId:     "200",
+		SampleItem:             &SampleItem{Title: "Sample"},
+		StatusTelegramApiToken: "789:status",
+		StatusTelegramChatId:   "300",
+	})
+	router := newTestRouter(fs)
+
+	// The scheduler's send is the first one, so it isn't delayed
+	fs.runFeed(feed)
+
+	// A send from the web interface right after it waits for the shared limiter
+	start := time.Now()
+	if rec := postJSON(router, "/feeds/0/send-sample"); rec.Code != http.StatusOK {
+		t.Fatalf("send failed with %d: %s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("web send took %v right after a scheduled send, want it rate limited", elapsed)
+	}
+
+	// And so does a status event
+	start = time.Now()
+	fs.PostStatusEvent("Posting paused")
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("status event took %v right after a web send, want it rate limited", elapsed)
+	}
+
+	if n := len(recorder.callsTo("sendMessage")); n != 3 {
+		t.Fatalf("got %d messages, want 3", n)
+	}
+}
@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultStaticMaxAge is how long browsers may cache static assets when
+// static_max_age_seconds is not set
+const defaultStaticMaxAge = time.Hour
+
+// staticMaxAge returns how long browsers may cache static assets before revalidating them
+func (c *Config) staticMaxAge() time.Duration {
+	if c.StaticMaxAgeSeconds > 0 {
+		return time.Duration(c.StaticMaxAgeSeconds) * time.Second
+	}
+	return defaultStaticMaxAge
+}
+
+// serveStatic serves the files in dir under /static/ with a Cache-Control max-age and an
+// ETag made of the file's modification time and size, so browsers reuse assets and
+// revalidate them cheaply once the max-age has passed
+func (h *Handlers) serveStatic(dir string) http.HandlerFunc {
+	files := http.StripPrefix("/static/", http.FileServer(http.Dir(dir)))
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/static/"))
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if err == nil && !info.IsDir() {
+			maxAge := h.ConfigManager.Get().staticMaxAge()
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+			w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+		}
+		files.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaticAssetCacheHeaders(t *testing.T) {
+	t.Chdir("..")
+	fs, _ := newTestScheduler(t, &Config{})
+	router := newTestRouter(fs)
+
+	rec := serve(router, http.MethodGet, "/static/tabler.min.css", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d for a static asset", rec.Code)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+		t.Fatalf("got Cache-Control %q, want the default max-age", got)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("static asset served without an ETag")
+	}
+
+	// A browser revalidating with the ETag gets the asset back unchanged
+	req := httptest.NewRequest(http.MethodGet, "/static/tabler.min.css", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("got status %d for a matching ETag, want 304", rec.Code)
+	}
+
+	// Missing files aren't given cache headers
+	rec = serve(router, http.MethodGet, "/static/missing.css", "")
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Cache-Control") != "" {
+		t.Fatalf("got status %d and Cache-Control %q for a missing asset", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+}
+
+func TestStaticAssetMaxAgeFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log(1)"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fs, _ := newTestScheduler(t, &Config{StaticMaxAgeSeconds: 86400})
+	handler := NewHandlers(fs.configManager, fs).serveStatic(dir)
+
+	rec := serve(handler, http.MethodGet, "/static/app.js", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "console.log(1)" {
+		t.Fatalf("got status %d and body %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=86400" {
+		t.Fatalf("got Cache-Control %q, want the configured max-age", got)
+	}
+}
@@ -1,7 +1,9 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
+	"html"
 	"log"
 	"net/http"
 	"strconv"
@@ -12,30 +14,86 @@ import (
 // TelegramService handles all Telegram-related functionality
 type TelegramService struct {
 	ConfigManager   *ConfigManager
+	Client          *TelegramClient
 	lastMessageTime time.Time
+	chatSlots       map[ChatID]time.Time
 	mutex           sync.RWMutex
 }
 
-// NewTelegramService creates a new Telegram service
+// NewTelegramService creates a new Telegram service. Its requests follow the configured
+// telegram_timeout_seconds, including changes made after it is created.
 func NewTelegramService(cm *ConfigManager) *TelegramService {
+	client := NewTelegramClient(defaultTelegramTimeout)
+	client.Timeout = func() time.Duration { return cm.Get().telegramTimeout() }
 	return &TelegramService{
 		ConfigManager:   cm,
+		Client:          client,
 		lastMessageTime: time.Time{},
+		chatSlots:       make(map[ChatID]time.Time),
 	}
 }
 
-// SendTestTelegram sends a test message to Telegram
-func (ts *TelegramService) SendTestTelegram(item map[string]interface{}, feed map[string]interface{}) error {
-	token := ts.ConfigManager.Config.TestTelegramApiToken
-	chatID := ts.ConfigManager.Config.TestTelegramChatId
-	threadID := ts.ConfigManager.Config.TestTelegramMessageThreadId
-	template := ts.ConfigManager.Config.TestTelegramTemplate
+// waitForRateLimit blocks until at least 1 second has passed since the last message
+func (ts *TelegramService) waitForRateLimit() {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	timeSinceLastMessage := time.Since(ts.lastMessageTime)
+	if timeSinceLastMessage < time.Second {
+		time.Sleep(time.Second - timeSinceLastMessage)
+	}
+	ts.lastMessageTime = time.Now()
+}
+
+// Spacing used for feeds that fan out to their chats in parallel
+const (
+	perChatMessageInterval = time.Second
+	globalMessageInterval  = time.Second / 30
+)
+
+// waitForChatRateLimit blocks until at least 1 second has passed since the last message
+// to the same chat, while keeping all messages under Telegram's overall limit of 30 per
+// second. Unlike waitForRateLimit it doesn't hold the lock while waiting, so different
+// chats can be sent to concurrently.
+func (ts *TelegramService) waitForChatRateLimit(chatID ChatID) {
+	ts.mutex.Lock()
+	slot := time.Now()
+	if next := ts.chatSlots[chatID].Add(perChatMessageInterval); next.After(slot) {
+		slot = next
+	}
+	if next := ts.lastMessageTime.Add(globalMessageInterval); next.After(slot) {
+		slot = next
+	}
+	ts.chatSlots[chatID] = slot
+	ts.lastMessageTime = slot
+	ts.mutex.Unlock()
+
+	time.Sleep(time.Until(slot))
+}
+
+// rateLimiter returns the rate limiting to apply to messages of a feed: per chat for
+// feeds with parallel_fan_out, and the global one-message-per-second limit otherwise
+func (ts *TelegramService) rateLimiter(feed Feed) func() {
+	if feed.ParallelFanOut {
+		chatID := feed.TelegramChatId
+		return func() { ts.waitForChatRateLimit(chatID) }
+	}
+	return ts.waitForRateLimit
+}
+
+// SendTestTelegram sends a test message to Telegram, using the sending settings of
+// options, such as silent_notifications
+func (ts *TelegramService) SendTestTelegram(item map[string]interface{}, feed map[string]interface{}, options Feed) error {
+	token := ts.ConfigManager.Get().TestTelegramApiToken
+	chatID := ts.ConfigManager.Get().TestTelegramChatId
+	threadID := ts.ConfigManager.Get().TestTelegramMessageThreadId
+	template := ts.ConfigManager.Get().TestTelegramTemplate
 
 	if token == "" {
 		return fmt.Errorf("test Telegram API token not configured")
 	}
 
-	if chatID == 0 {
+	if chatID.IsZero() {
 		return fmt.Errorf("test Telegram chat ID not configured")
 	}
 
@@ -43,38 +101,53 @@ func (ts *TelegramService) SendTestTelegram(item map[string]interface{}, feed ma
 		template = "{{.Title}}"
 	}
 
-	message := ProcessFeedItemForTelegram(item, feed, template)
+	message, err := ProcessFeedItemForTelegram(item, feed, template)
+	if err != nil {
+		return fmt.Errorf("test_telegram_template: %v", err)
+	}
 
 	telegramMsg := TelegramMessage{
-		ChatID:          chatID,
-		Text:            message,
-		ParseMode:       "HTML",
-		MessageThreadID: threadID,
+		ChatID:                chatID,
+		Text:                  message,
+		ParseMode:             "HTML",
+		MessageThreadID:       threadID,
+		DisableNotification:   options.SilentNotifications,
+		DisableWebPagePreview: options.DisableWebPagePreview,
 	}
 
 	// Apply rate limiting - wait at least 1 second between all messages
-	ts.mutex.Lock()
-	timeSinceLastMessage := time.Since(ts.lastMessageTime)
-	if timeSinceLastMessage < time.Second {
-		time.Sleep(time.Second - timeSinceLastMessage)
-	}
-	ts.lastMessageTime = time.Now()
-	ts.mutex.Unlock()
+	ts.waitForRateLimit()
 
-	return SendTelegramMessage(token, telegramMsg)
+	_, err = ts.Client.SendMessage(token, telegramMsg)
+	return err
 }
 
-// SendFeedItemToTelegram sends a feed item to Telegram based on the feed configuration
-func (ts *TelegramService) SendFeedItemToTelegram(feed Feed, item map[string]interface{}) error {
-	token := feed.TelegramApiToken
-	chatID := feed.TelegramChatId
-	threadID := feed.TelegramMessageThreadId
-	template := feed.TelegramTemplate
+// RenderFeedItem renders the message that would be sent to Telegram for a feed item
+func RenderFeedItem(feed Feed, item map[string]interface{}) string {
+	return withSignature(feed, renderFeedItemTemplate(feed, item, feed.TelegramTemplate))
+}
+
+// RenderFeedItemCaption renders the photo caption for a feed item, using the caption
+// template when one is configured and the message template otherwise
+func RenderFeedItemCaption(feed Feed, item map[string]interface{}) string {
+	template := feed.CaptionTemplate
+	if template == "" {
+		template = feed.TelegramTemplate
+	}
+	return withSignature(feed, renderFeedItemTemplate(feed, item, template))
+}
 
-	if token == "" || chatID == 0 {
-		return fmt.Errorf("Telegram configuration is incomplete for feed: %s", feed.FeedUrl)
+// withSignature appends the feed's signature footer to a rendered message
+func withSignature(feed Feed, message string) string {
+	if feed.Signature == "" {
+		return message
 	}
+	return message + "\n\n" + feed.Signature
+}
 
+// renderFeedItemTemplate renders a feed item with the given template. A template that
+// fails to render is replaced by the title and link fallback.
+func renderFeedItemTemplate(feed Feed, item map[string]interface{}, template string) string {
 	if template == "" {
 		template = "{{.Title}}"
 	}
@@ -88,18 +161,90 @@ func (ts *TelegramService) SendFeedItemToTelegram(feed Feed, item map[string]int
 		"Generator":   "",
 		"FeedType":    "",
 		"FeedVersion": "",
+
+		"AuthorFormat":     feed.AuthorFormat,
+		"AuthorsSeparator": feed.AuthorsSeparator,
+		"BodyPreference":   feed.BodyPreference,
+
+		"CategoryFormat":      feed.CategoryFormat,
+		"HashtagWords":        feed.HashtagWords,
+		"CategoriesSeparator": feed.CategoriesSeparator,
+		"RawEnclosureSizes":   feed.RawEnclosureSizes,
 	}
 
-	message := ProcessFeedItemForTelegram(item, feedMap, template)
+	if feed.StripTrackingParams {
+		cleaned := make(map[string]interface{}, len(item))
+		for key, value := range item {
+			cleaned[key] = value
+		}
+		cleaned["Link"] = stripTrackingParams(getStringValue(item, "Link"), feed.TrackingParams)
+		item = cleaned
+	}
 
-	// Apply rate limiting - wait at least 1 second between all messages
-	ts.mutex.Lock()
-	timeSinceLastMessage := time.Since(ts.lastMessageTime)
-	if timeSinceLastMessage < time.Second {
-		time.Sleep(time.Second - timeSinceLastMessage)
+	sanitize := SanitizeText
+	if feed.TrustSource {
+		sanitize = NormalizeTelegramHTML
 	}
-	ts.lastMessageTime = time.Now()
-	ts.mutex.Unlock()
+
+	message, err := processFeedItem(item, feedMap, template, feed.partials, sanitize)
+	if err != nil {
+		log.Printf("Error rendering template of feed %s, using the fallback template: %v", feed.FeedUrl, err)
+		message, _ = processFeedItem(item, feedMap, fallbackTemplate, nil, sanitize)
+	}
+	if feed.AlwaysAppendLink {
+		message = appendLinkIfMissing(message, getStringValue(item, "Link"))
+	}
+	if feed.AppendSourceDomain {
+		if domain := sourceDomain(getStringValue(item, "Link"), feedValue(item, feedMap, "FeedLink", "Link")); domain != "" {
+			message += "\n" + SanitizeText(domain)
+		}
+	}
+
+	return message
+}
+
+// SendFeedItemToTelegram sends a feed item to Telegram based on the feed configuration
+// and returns the ID of the sent message
+func (ts *TelegramService) SendFeedItemToTelegram(feed Feed, item map[string]interface{}) (int64, error) {
+	token := feed.TelegramApiToken
+	chatID := feed.TelegramChatId
+	threadID := feed.TelegramMessageThreadId
+	wait := ts.rateLimiter(feed)
+
+	if token == "" || chatID.IsZero() {
+		return 0, fmt.Errorf("Telegram configuration is incomplete for feed: %s", feed.FeedUrl)
+	}
+
+	// Send as a photo when enabled and the item has an image, falling back to a text message
+	if feed.SendAsPhoto {
+		photoURL, _ := extractImageInfo(item)
+		if photoURL == "" {
+			photoURL = extractContentImage(item)
+		}
+		if photoURL != "" {
+			wait()
+			messageID, err := ts.Client.SendPhoto(token, TelegramPhoto{
+				ChatID:          chatID,
+				Photo:           photoURL,
+				Caption:         RenderFeedItemCaption(feed, item),
+				ParseMode:       "HTML",
+				MessageThreadID: threadID,
+				ProtectContent:  feed.ProtectContent,
+				ReplyParameters: replyParameters(feed.replyTo),
+
+				DisableNotification: feed.SilentNotifications,
+			})
+			if err == nil {
+				return messageID, nil
+			}
+			if isAmbiguousSendError(err) {
+				return 0, err // A text message could duplicate the photo
+			}
+			log.Printf("Failed to send photo to Telegram, falling back to a text message: %v", err)
+		}
+	}
+
+	message := RenderFeedItem(feed, item)
 
 	// Send the message with simple retry logic
 	telegramMsg := TelegramMessage{
@@ -107,30 +252,243 @@ func (ts *TelegramService) SendFeedItemToTelegram(feed Feed, item map[string]int
 		Text:            message,
 		ParseMode:       "HTML",
 		MessageThreadID: threadID,
+		ProtectContent:  feed.ProtectContent,
+		ReplyParameters: replyParameters(feed.replyTo),
+		ExtraParams:     feed.ExtraTelegramParams,
+
+		DisableNotification:   feed.SilentNotifications,
+		DisableWebPagePreview: feed.DisableWebPagePreview,
+	}
+
+	return ts.sendMessageWithRetry(token, telegramMsg, feed.ParseModes, wait)
+}
+
+// SendLocation sends a location to the feed's chat, e.g. to follow an item's text message
+func (ts *TelegramService) SendLocation(feed Feed, latitude, longitude float64) (int64, error) {
+	if feed.TelegramApiToken == "" || feed.TelegramChatId.IsZero() {
+		return 0, fmt.Errorf("Telegram configuration is incomplete for feed: %s", feed.FeedUrl)
 	}
 
-	// Simple retry: try up to 5 times with 30 second delays
+	ts.rateLimiter(feed)()
+
+	return ts.Client.SendLocation(feed.TelegramApiToken, TelegramLocation{
+		ChatID:              feed.TelegramChatId,
+		Latitude:            latitude,
+		Longitude:           longitude,
+		MessageThreadID:     feed.TelegramMessageThreadId,
+		DisableNotification: true,
+		ProtectContent:      feed.ProtectContent,
+	})
+}
+
+// SendDigest sends an already rendered digest message to the feed's chat
+func (ts *TelegramService) SendDigest(feed Feed, message string) (int64, error) {
+	return ts.SendRenderedMessage(feed, message)
+}
+
+// SendRenderedMessage sends an already rendered HTML message to the feed's chat
+func (ts *TelegramService) SendRenderedMessage(feed Feed, message string) (int64, error) {
+	if feed.TelegramApiToken == "" || feed.TelegramChatId.IsZero() {
+		return 0, fmt.Errorf("Telegram configuration is incomplete for feed: %s", feed.FeedUrl)
+	}
+
+	return ts.sendMessageWithRetry(feed.TelegramApiToken, TelegramMessage{
+		ChatID:          feed.TelegramChatId,
+		Text:            message,
+		ParseMode:       "HTML",
+		MessageThreadID: feed.TelegramMessageThreadId,
+		ProtectContent:  feed.ProtectContent,
+		ExtraParams:     feed.ExtraTelegramParams,
+
+		DisableNotification:   feed.SilentNotifications,
+		DisableWebPagePreview: feed.DisableWebPagePreview,
+	}, feed.ParseModes, ts.rateLimiter(feed))
+}
+
+// telegramRetryDelay is how long a failed send waits before it is tried again, unless
+// Telegram says how long to wait
+var telegramRetryDelay = 30 * time.Second
+
+// sendMessageWithRetry sends an HTML message through the formatting fallback chain,
+// retrying failed attempts. wait applies the rate limiting before each attempt.
+func (ts *TelegramService) sendMessageWithRetry(token string, telegramMsg TelegramMessage, modes []string, wait func()) (int64, error) {
+	// Apply rate limiting
+	wait()
+
+	// Simple retry: try up to 5 times telegramRetryDelay apart, or as long as Telegram asks
+	// to wait when it rate limits the bot
+	var err error
 	for attempt := 0; attempt < 5; attempt++ {
-		err := SendTelegramMessage(token, telegramMsg)
+		var messageID int64
+		messageID, err = ts.sendWithFallback(token, telegramMsg, modes, wait)
 		if err == nil {
-			return nil
+			return messageID, nil
+		}
+		if isAmbiguousSendError(err) {
+			return 0, err // Retrying could post the message twice
+		}
+
+		delay := telegramRetryDelay
+		var rateErr *RateLimitError
+		if errors.As(err, &rateErr) {
+			delay = rateErr.RetryAfter
 		}
 
-		log.Printf("Failed to send message to Telegram (attempt %d/5): %v. Retrying in 30 seconds...",
-			attempt+1, err)
-		time.Sleep(30 * time.Second)
+		log.Printf("Failed to send message to Telegram (attempt %d/5): %v. Retrying in %v...",
+			attempt+1, err, delay)
+		time.Sleep(delay)
 
 		// Apply rate limiting again after each retry
-		ts.mutex.Lock()
-		timeSinceLastMessage := time.Since(ts.lastMessageTime)
-		if timeSinceLastMessage < time.Second {
-			time.Sleep(time.Second - timeSinceLastMessage)
+		wait()
+	}
+
+	return 0, &retriesExhaustedError{attempts: 5, err: err}
+}
+
+// sendWithFallback tries each formatting mode in order until Telegram accepts the
+// message. Only formatting errors move on to the next mode; other errors are returned
+// right away so the caller can retry.
+func (ts *TelegramService) sendWithFallback(token string, telegramMsg TelegramMessage, modes []string, wait func()) (int64, error) {
+	// Shortened before it is converted, so every mode sends the same text
+	htmlText := truncateText(telegramMsg.Text, parseModeHTML, maxMessageLength)
+	chain := parseModeChain(modes)
+
+	var err error
+	for i, mode := range chain {
+		if i > 0 {
+			wait()
+		}
+
+		msg := telegramMsg
+		msg.Text, msg.ParseMode = formatMessage(htmlText, mode)
+
+		var messageID int64
+		messageID, err = ts.Client.SendMessage(token, msg)
+		if err == nil {
+			if i > 0 {
+				log.Printf("Message delivered using %s formatting", mode)
+			}
+			return messageID, nil
+		}
+		if !isFormattingError(err) {
+			return 0, err
+		}
+		if i < len(chain)-1 {
+			log.Printf("Telegram rejected %s formatting: %v. Trying %s", mode, err, chain[i+1])
 		}
-		ts.lastMessageTime = time.Now()
-		ts.mutex.Unlock()
 	}
 
-	return fmt.Errorf("failed to send feed item to Telegram after 5 attempts")
+	return 0, err
+}
+
+// SendStartMessage sends the "started" message of a feed, pins it and unpins the message
+// pinned at the previous start, if any. It returns the ID of the new pinned message.
+func (ts *TelegramService) SendStartMessage(feed Feed, previousID int64) (int64, error) {
+	if feed.TelegramApiToken == "" || feed.TelegramChatId.IsZero() {
+		return 0, fmt.Errorf("Telegram configuration is incomplete for feed: %s", feed.FeedUrl)
+	}
+
+	message := fmt.Sprintf("📌 Notifications for <b>%s</b> started on %s",
+		html.EscapeString(feed.DisplayName()), time.Now().Format("2006-01-02 15:04 MST"))
+
+	ts.waitForRateLimit()
+	messageID, err := ts.Client.SendMessage(feed.TelegramApiToken, TelegramMessage{
+		ChatID:              feed.TelegramChatId,
+		Text:                message,
+		ParseMode:           "HTML",
+		MessageThreadID:     feed.TelegramMessageThreadId,
+		DisableNotification: true,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	ts.waitForRateLimit()
+	err = ts.Client.PinChatMessage(feed.TelegramApiToken, feed.TelegramChatId, messageID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to pin message: %v", err)
+	}
+
+	if previousID != 0 && previousID != messageID {
+		ts.waitForRateLimit()
+		err = ts.Client.UnpinChatMessage(feed.TelegramApiToken, feed.TelegramChatId, previousID)
+		if err != nil {
+			// The old message may have been deleted or unpinned by hand
+			log.Printf("Failed to unpin previous start message of feed %s: %v", feed.FeedUrl, err)
+		}
+	}
+
+	return messageID, nil
+}
+
+// defaultAlertTemplate is used when no alert template is configured
+const defaultAlertTemplate = "⚠️ {{.FeedName}} failed {{.FailCount}} times in a row: {{.Error}}"
+
+// SendAlert notifies the configured alert chat that a feed keeps failing
+func (ts *TelegramService) SendAlert(feed Feed, failCount int, fetchErr error) error {
+	message := RenderAlertMessage(ts.ConfigManager.Get().AlertTemplate, feed, failCount, fetchErr)
+	return ts.sendAdminMessage(message)
+}
+
+// SendStaleAlert notifies the configured alert chat that a feed stopped publishing new items
+func (ts *TelegramService) SendStaleAlert(feed Feed, since time.Time) error {
+	message := fmt.Sprintf("💤 %s has not published new items since %s",
+		html.EscapeString(feed.DisplayName()), since.Format("2006-01-02 15:04 MST"))
+	return ts.sendAdminMessage(message)
+}
+
+// SendOperationAlert notifies the configured alert chat that a background operation,
+// such as the cleanup, keeps failing
+func (ts *TelegramService) SendOperationAlert(operation string, opErr error) error {
+	message := fmt.Sprintf("⚠️ %s failed: %s", html.EscapeString(operation), html.EscapeString(opErr.Error()))
+	return ts.sendAdminMessage(message)
+}
+
+// SendStatusEvent posts a lifecycle event of the bot, such as a start or shutdown, to the
+// status chat. It does nothing when no status chat is configured. The alert token is
+// used when the status chat has no token of its own.
+func (ts *TelegramService) SendStatusEvent(event string) error {
+	token := ts.ConfigManager.Get().StatusTelegramApiToken
+	chatID := ts.ConfigManager.Get().StatusTelegramChatId
+	if token == "" {
+		token = ts.ConfigManager.Get().AlertTelegramApiToken
+	}
+
+	if token == "" || chatID.IsZero() {
+		return nil // Status updates are disabled
+	}
+
+	ts.waitForRateLimit()
+
+	_, err := ts.Client.SendMessage(token, TelegramMessage{
+		ChatID:              chatID,
+		Text:                "ℹ️ " + html.EscapeString(event),
+		ParseMode:           "HTML",
+		DisableNotification: true,
+	})
+	return err
+}
+
+// sendAdminMessage sends a message to the alert chat, doing nothing when alerts are not configured
+func (ts *TelegramService) sendAdminMessage(message string) error {
+	token := ts.ConfigManager.Get().AlertTelegramApiToken
+	chatID := ts.ConfigManager.Get().AlertTelegramChatId
+
+	if token == "" || chatID.IsZero() {
+		return nil // Alerts are disabled
+	}
+	if ts.ConfigManager.Get().Paused {
+		return nil // All posting is paused
+	}
+
+	ts.waitForRateLimit()
+
+	_, err := ts.Client.SendMessage(token, TelegramMessage{
+		ChatID:    chatID,
+		Text:      message,
+		ParseMode: "HTML",
+	})
+	return err
 }
 
 // HandleTestTelegramByIndex handles testing Telegram notifications by retrieving the item from global storage using its index
@@ -139,32 +497,29 @@ func (ts *TelegramService) HandleTestTelegramByIndex(w http.ResponseWriter, r *h
 	feedUrl := r.FormValue("feed_url")
 
 	if itemIndexStr == "" {
-		http.Error(w, "Item index is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Item index is required")
 		return
 	}
 
 	index, err := strconv.Atoi(itemIndexStr)
 	if err != nil {
-		http.Error(w, "Invalid item index", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid item index")
 		return
 	}
 
-	// Retrieve the item from global storage with thread safety
-	tempFeedMutex.RLock()
-	if index < 0 || index >= len(tempFeedItems) {
-		tempFeedMutex.RUnlock()
-		http.Error(w, "Item not found at the given index", http.StatusBadRequest)
+	// Retrieve the item from the preview it was shown in
+	item, ok := previews.item(r.FormValue("preview_id"), index)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "Item not found at the given index; preview the feed again")
 		return
 	}
 
-	item := tempFeedItems[index]
-	tempFeedMutex.RUnlock()
-
-	// Create feed map with actual feed information from stored feed
+	// The item carries the feed's metadata; the feed map only supplies the same
+	// fallbacks the scheduler uses
 	feedMap := map[string]interface{}{
 		"Title":       "",
 		"Description": "",
-		"Link":        "",
+		"Link":        feedUrl,
 		"Language":    "",
 		"Copyright":   "",
 		"Generator":   "",
@@ -172,23 +527,14 @@ func (ts *TelegramService) HandleTestTelegramByIndex(w http.ResponseWriter, r *h
 		"FeedVersion": "",
 	}
 
-	// Use the stored feed info if available
-	tempFeedMutex.RLock()
-	if tempFeedInfo != nil {
-		feedMap["Title"] = tempFeedInfo.Title
-		feedMap["Description"] = tempFeedInfo.Description
-		feedMap["Link"] = tempFeedInfo.Link
-		feedMap["Language"] = tempFeedInfo.Language
-		feedMap["Copyright"] = tempFeedInfo.Copyright
-		feedMap["Generator"] = tempFeedInfo.Generator
-		feedMap["FeedType"] = tempFeedInfo.FeedType
-		feedMap["FeedVersion"] = tempFeedInfo.FeedVersion
-	}
-	tempFeedMutex.RUnlock()
+	// Show the item the way the configured feed would, if there is one; the form decides
+	// whether it is sent silently
+	options, _ := findFeedByURL(ts.ConfigManager.Get().Feeds, feedUrl)
+	options.SilentNotifications = r.FormValue("silent") != ""
 
-	err = ts.SendTestTelegram(item, feedMap)
+	err = ts.SendTestTelegram(item, feedMap, options)
 	if err != nil {
-		http.Error(w, "Error sending to Telegram: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Error sending to Telegram: "+err.Error())
 		return
 	}
 
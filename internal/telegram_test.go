@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// newTestTelegramService creates a Telegram service whose calls go to a recorder
+func newTestTelegramService(t *testing.T, config *Config) (*TelegramService, *telegramRecorder) {
+	t.Helper()
+	ts := NewTelegramService(newTestConfigManager(t, config))
+	recorder := newTelegramRecorder(t)
+	ts.Client = recorder.client()
+	return ts, recorder
+}
+
+func photoItem() map[string]interface{} {
+	return buildItemMap(&gofeed.Item{
+		Title: "Sunset",
+		Link:  "https://example.com/sunset",
+		Image: &gofeed.Image{URL: "https://example.com/sunset.jpg"},
+	}, &gofeed.Feed{})
+}
+
+func TestSendFeedItemUsesCaptionTemplateForPhotos(t *testing.T) {
+	ts, recorder := newTestTelegramService(t, &Config{})
+	feed := testFeed("https://example.com/feed.xml")
+	feed.SendAsPhoto = true
+	feed.CaptionTemplate = "Photo: {{.Title}}"
+	feed.TelegramTemplate = "Text: {{.Title}}"
+
+	if _, err := ts.SendFeedItemToTelegram(feed, photoItem()); err != nil {
+		t.Fatalf("SendFeedItemToTelegram: %v", err)
+	}
+
+	calls := recorder.callsTo("sendPhoto")
+	if len(calls) != 1 {
+		t.Fatalf("got calls %v, want one sendPhoto", recorder.Calls())
+	}
+	if calls[0].text() != "Photo: Sunset" || calls[0].Payload["photo"] != "https://example.com/sunset.jpg" {
+		t.Fatalf("unexpected payload %v", calls[0].Payload)
+	}
+	if len(recorder.callsTo("sendMessage")) != 0 {
+		t.Fatal("a text message was sent as well")
+	}
+}
+
+func TestSendFeedItemFallsBackToTextTemplate(t *testing.T) {
+	ts, recorder := newTestTelegramService(t, &Config{})
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		if call.Method == "sendPhoto" {
+			return http.StatusBadRequest, telegramError(400, "Bad Request: wrong file identifier/HTTP URL specified")
+		}
+		return 0, ""
+	})
+	feed := testFeed("https://example.com/feed.xml")
+	feed.SendAsPhoto = true
+	feed.CaptionTemplate = "Photo: {{.Title}}"
+	feed.TelegramTemplate = "Text: {{.Title}}"
+
+	if _, err := ts.SendFeedItemToTelegram(feed, photoItem()); err != nil {
+		t.Fatalf("SendFeedItemToTelegram: %v", err)
+	}
+
+	if texts := sentTexts(recorder); len(texts) != 1 || texts[0] != "Text: Sunset" {
+		t.Fatalf("got text messages %q, want the main template", texts)
+	}
+}
+
+func TestSendAlertDeliversToAlertChat(t *testing.T) {
+	ts, recorder := newTestTelegramService(t, &Config{
+		AlertTelegramApiToken: "456:alert",
+		AlertTelegramChatId:   "999",
+		AlertTemplate:         "{{.FeedName}}: {{.Error}} ({{.FailCount}})",
+	})
+
+	if err := ts.SendAlert(Feed{FeedUrl: "https://example.com/feed.xml"}, 3, fmt.Errorf("timeout")); err != nil {
+		t.Fatalf("SendAlert: %v", err)
+	}
+	calls := recorder.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].Token != "456:alert" || calls[0].chatID() != "999" {
+		t.Fatalf("unexpected calls %v", recorder.Calls())
+	}
+	if want := "https://example.com/feed.xml: timeout (3)"; calls[0].text() != want {
+		t.Fatalf("got %q, want %q", calls[0].text(), want)
+	}
+}
+
+func TestAlwaysAppendLink(t *testing.T) {
+	item := map[string]interface{}{"Title": "Post", "Link": "https://example.com/post?a=1&b=2"}
+	feed := Feed{FeedUrl: "https://example.com/feed.xml", AlwaysAppendLink: true}
+
+	feed.TelegramTemplate = "{{.Title}}"
+	if got, want := RenderFeedItem(feed, item), "Post\nhttps://example.com/post?a=1&amp;b=2"; got != want {
+		t.Errorf("without the link: got %q, want %q", got, want)
+	}
+
+	feed.TelegramTemplate = "<a href=\"{{.Link}}\">{{.Title}}</a>"
+	if got, want := RenderFeedItem(feed, item), "<a href=\"https://example.com/post?a=1&amp;b=2\">Post</a>"; got != want {
+		t.Errorf("with the link: got %q, want %q", got, want)
+	}
+
+	feed.AlwaysAppendLink = false
+	feed.TelegramTemplate = "{{.Title}}"
+	if got := RenderFeedItem(feed, item); got != "Post" {
+		t.Errorf("option disabled: got %q", got)
+	}
+}
+
+func TestSendWithFallbackWalksChain(t *testing.T) {
+	ts, recorder := newTestTelegramService(t, &Config{})
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		if call.Payload["parse_mode"] != nil {
+			return http.StatusBadRequest, telegramError(400, "Bad Request: can't parse entities")
+		}
+		return 0, ""
+	})
+
+	modes := []string{parseModeHTML, parseModeMarkdownV2, parseModePlain}
+	_, err := ts.sendWithFallback("token", TelegramMessage{ChatID: "1", Text: "<b>Fish &amp; chips</b>"}, modes, func() {})
+	if err != nil {
+		t.Fatalf("sendWithFallback: %v", err)
+	}
+
+	calls := recorder.Calls()
+	if len(calls) != 3 {
+		t.Fatalf("got %d calls, want one per mode", len(calls))
+	}
+	if calls[0].Payload["parse_mode"] != parseModeHTML || calls[0].text() != "<b>Fish &amp; chips</b>" {
+		t.Errorf("unexpected HTML attempt %v", calls[0].Payload)
+	}
+	if calls[1].Payload["parse_mode"] != parseModeMarkdownV2 || calls[1].text() != "*Fish & chips*" {
+		t.Errorf("unexpected MarkdownV2 attempt %v", calls[1].Payload)
+	}
+	if _, set := calls[2].Payload["parse_mode"]; set || calls[2].text() != "Fish & chips" {
+		t.Errorf("unexpected plain attempt %v", calls[2].Payload)
+	}
+}
+
+func TestSendWithFallbackStopsOnOtherErrors(t *testing.T) {
+	ts, recorder := newTestTelegramService(t, &Config{})
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		return http.StatusForbidden, telegramError(403, "Forbidden: bot was blocked by the user")
+	})
+
+	_, err := ts.sendWithFallback("token", TelegramMessage{ChatID: "1", Text: "x"}, []string{parseModeHTML, parseModePlain}, func() {})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls := recorder.Calls(); len(calls) != 1 {
+		t.Fatalf("got %d calls, want the chain to stop after the first", len(calls))
+	}
+}
+
+func TestSendAsPhotoUsesContentImage(t *testing.T) {
+	ts, recorder := newTestTelegramService(t, &Config{})
+	feed := Feed{TelegramApiToken: "token", TelegramChatId: "1", SendAsPhoto: true, TelegramTemplate: "{{.Title}}"}
+	item := buildItemMap(&gofeed.Item{
+		Title:   "Post",
+		Link:    "https://example.com/posts/1",
+		Content: `<p>Intro</p><img src="/img/cover.jpg">`,
+	}, &gofeed.Feed{})
+
+	if _, err := ts.SendFeedItemToTelegram(feed, item); err != nil {
+		t.Fatalf("SendFeedItemToTelegram: %v", err)
+	}
+	calls := recorder.callsTo("sendPhoto")
+	if len(calls) != 1 || calls[0].Payload["photo"] != "https://example.com/img/cover.jpg" {
+		t.Fatalf("unexpected calls %v", recorder.Calls())
+	}
+}
+
+func TestSendFeedItemProtectContentAndSignature(t *testing.T) {
+	ts, recorder := newTestTelegramService(t, &Config{})
+	feed := testFeed("https://example.com/feed.xml")
+	feed.ProtectContent = true
+	feed.Signature = "— Example News"
+	item := map[string]interface{}{"Title": "Post"}
+
+	if _, err := ts.SendFeedItemToTelegram(feed, item); err != nil {
+		t.Fatalf("SendFeedItemToTelegram: %v", err)
+	}
+	feed.ProtectContent = false
+	feed.Signature = ""
+	if _, err := ts.SendFeedItemToTelegram(feed, item); err != nil {
+		t.Fatalf("SendFeedItemToTelegram: %v", err)
+	}
+
+	calls := recorder.callsTo("sendMessage")
+	if len(calls) != 2 {
+		t.Fatalf("got calls %v, want two messages", recorder.Calls())
+	}
+	if calls[0].Payload["protect_content"] != true || calls[0].text() != "Post\n\n— Example News" {
+		t.Fatalf("unexpected payload %v with protect_content and a signature", calls[0].Payload)
+	}
+	if _, ok := calls[1].Payload["protect_content"]; ok || calls[1].text() != "Post" {
+		t.Fatalf("unexpected payload %v without the options", calls[1].Payload)
+	}
+}
+
+func TestSendAsPhotoProtectContent(t *testing.T) {
+	ts, recorder := newTestTelegramService(t, &Config{})
+	feed := testFeed("https://example.com/feed.xml")
+	feed.SendAsPhoto = true
+	feed.ProtectContent = true
+
+	if _, err := ts.SendFeedItemToTelegram(feed, photoItem()); err != nil {
+		t.Fatalf("SendFeedItemToTelegram: %v", err)
+	}
+	calls := recorder.callsTo("sendPhoto")
+	if len(calls) != 1 || calls[0].Payload["protect_content"] != true {
+		t.Fatalf("got calls %v, want a protected photo", recorder.Calls())
+	}
+}
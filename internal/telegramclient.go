@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultTelegramAPIURL is the address of the official Telegram Bot API
+const defaultTelegramAPIURL = "https://api.telegram.org"
+
+// maxTelegramResponseSize limits how much of a Bot API response is read
+const maxTelegramResponseSize = 1 << 20
+
+// defaultTelegramTimeout bounds a Bot API request when telegram_timeout_seconds is not set
+const defaultTelegramTimeout = 30 * time.Second
+
+// telegramTimeout returns how long a Bot API request may take before it is abandoned
+func (c *Config) telegramTimeout() time.Duration {
+	if c.TelegramTimeoutSeconds > 0 {
+		return time.Duration(c.TelegramTimeoutSeconds) * time.Second
+	}
+	return defaultTelegramTimeout
+}
+
+// TelegramClient calls the Telegram Bot API. HTTPClient and BaseURL can be replaced,
+// e.g. to use a self-hosted Bot API server or to record requests in tests.
+type TelegramClient struct {
+	HTTPClient *http.Client
+	BaseURL    string
+	// Timeout returns how long a request may take, and is asked for every request so
+	// it can follow the configuration. Requests are not limited when it is nil.
+	Timeout func() time.Duration
+}
+
+// NewTelegramClient creates a client for the official Telegram Bot API whose requests
+// are abandoned after timeout, so a hanging API doesn't block the sender
+func NewTelegramClient(timeout time.Duration) *TelegramClient {
+	return &TelegramClient{
+		HTTPClient: &http.Client{},
+		BaseURL:    defaultTelegramAPIURL,
+		Timeout:    func() time.Duration { return timeout },
+	}
+}
+
+// SendMessage sends a message to Telegram and returns the ID of the sent message.
+func (tc *TelegramClient) SendMessage(token string, msg TelegramMessage) (int64, error) {
+	msg.Text = truncateText(msg.Text, msg.ParseMode, maxMessageLength)
+	return tc.call(token, "sendMessage", msg)
+}
+
+// SendPhoto sends a photo with a caption to Telegram and returns the ID of the sent message.
+func (tc *TelegramClient) SendPhoto(token string, photo TelegramPhoto) (int64, error) {
+	photo.Caption = truncateText(photo.Caption, photo.ParseMode, maxCaptionLength)
+	return tc.call(token, "sendPhoto", photo)
+}
+
+// SendLocation sends a location to Telegram and returns the ID of the sent message.
+func (tc *TelegramClient) SendLocation(token string, location TelegramLocation) (int64, error) {
+	return tc.call(token, "sendLocation", location)
+}
+
+// PinChatMessage pins a message in a chat without notifying its members.
+func (tc *TelegramClient) PinChatMessage(token string, chatID ChatID, messageID int64) error {
+	_, err := tc.call(token, "pinChatMessage", map[string]interface{}{
+		"chat_id":              chatID,
+		"message_id":           messageID,
+		"disable_notification": true,
+	})
+	return err
+}
+
+// UnpinChatMessage unpins a message in a chat.
+func (tc *TelegramClient) UnpinChatMessage(token string, chatID ChatID, messageID int64) error {
+	_, err := tc.call(token, "unpinChatMessage", map[string]interface{}{
+		"chat_id":    chatID,
+		"message_id": messageID,
+	})
+	return err
+}
+
+// GetMe checks that a bot token is valid.
+func (tc *TelegramClient) GetMe(token string) error {
+	_, err := tc.call(token, "getMe", map[string]interface{}{})
+	return err
+}
+
+// call posts a JSON payload to a Telegram Bot API method and returns the ID of the resulting message.
+func (tc *TelegramClient) call(token, method string, payload interface{}) (int64, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("error marshaling JSON: %v", err)
+	}
+
+	ctx := context.Background()
+	if tc.Timeout != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, tc.Timeout())
+		defer cancel()
+	}
+
+	telegramURL := fmt.Sprintf("%s/bot%s/%s", strings.TrimSuffix(tc.BaseURL, "/"), token, method)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, telegramURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, fmt.Errorf("error creating Telegram request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := tc.HTTPClient.Do(request)
+	if err != nil {
+		// Only a failure to connect means Telegram never saw the request
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return 0, fmt.Errorf("error sending to Telegram: %v", err)
+		}
+		return 0, &ambiguousSendError{err: fmt.Errorf("error sending to Telegram: %v", err)}
+	}
+	defer response.Body.Close()
+
+	var apiResponse struct {
+		Ok          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		Description string          `json:"description"`
+		ErrorCode   int             `json:"error_code"`
+		Parameters  struct {
+			RetryAfter      int   `json:"retry_after"`
+			MigrateToChatID int64 `json:"migrate_to_chat_id"`
+		} `json:"parameters"`
+	}
+
+	// Error responses carry Telegram's description in the body as well
+	decodeErr := json.NewDecoder(io.LimitReader(response.Body, maxTelegramResponseSize)).Decode(&apiResponse)
+
+	if response.StatusCode != http.StatusOK || (decodeErr == nil && !apiResponse.Ok) {
+		apiErr := &TelegramAPIError{StatusCode: response.StatusCode, Status: response.Status}
+		if decodeErr == nil {
+			apiErr.Description = apiResponse.Description
+			apiErr.ErrorCode = apiResponse.ErrorCode
+			apiErr.RetryAfter = apiResponse.Parameters.RetryAfter
+			apiErr.MigrateToChatID = apiResponse.Parameters.MigrateToChatID
+		}
+		if apiErr.RetryAfter > 0 {
+			return 0, &RateLimitError{RetryAfter: time.Duration(apiErr.RetryAfter) * time.Second, err: apiErr}
+		}
+		return 0, apiErr
+	}
+
+	if decodeErr != nil {
+		return 0, &ambiguousSendError{err: fmt.Errorf("error decoding Telegram API response: %v", decodeErr)}
+	}
+
+	// Methods such as pinChatMessage return true instead of a message
+	var message struct {
+		MessageID int64 `json:"message_id"`
+	}
+	json.Unmarshal(apiResponse.Result, &message)
+
+	return message.MessageID, nil
+}
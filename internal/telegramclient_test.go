@@ -0,0 +1,305 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// telegramCall is a Bot API request recorded by telegramRecorder
+type telegramCall struct {
+	Token   string
+	Method  string
+	Payload map[string]interface{}
+}
+
+// chatID returns the chat_id of the call as sent, e.g. "-1001234" or "@channel"
+func (c telegramCall) chatID() string {
+	return fmt.Sprint(c.Payload["chat_id"])
+}
+
+// text returns the text of a sendMessage call or the caption of a sendPhoto call
+func (c telegramCall) text() string {
+	if text, ok := c.Payload["text"].(string); ok {
+		return text
+	}
+	caption, _ := c.Payload["caption"].(string)
+	return caption
+}
+
+// telegramRecorder is a fake Telegram Bot API that records every call. Calls succeed
+// with increasing message IDs unless respond returns a status other than 0.
+type telegramRecorder struct {
+	server  *httptest.Server
+	mu      sync.Mutex
+	calls   []telegramCall
+	nextID  int64
+	respond func(call telegramCall) (status int, body string)
+}
+
+// newTelegramRecorder starts a fake Bot API that is shut down when the test ends
+func newTelegramRecorder(t *testing.T) *telegramRecorder {
+	t.Helper()
+	r := &telegramRecorder{}
+	r.server = httptest.NewServer(http.HandlerFunc(r.handle))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *telegramRecorder) handle(w http.ResponseWriter, req *http.Request) {
+	// Paths look like /bot<token>/<method>
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+	call := telegramCall{Token: strings.TrimPrefix(parts[0], "bot")}
+	if len(parts) == 2 {
+		call.Method = parts[1]
+	}
+	decoder := json.NewDecoder(req.Body)
+	decoder.UseNumber()
+	decoder.Decode(&call.Payload)
+
+	r.mu.Lock()
+	r.calls = append(r.calls, call)
+	r.nextID++
+	id := r.nextID
+	respond := r.respond
+	r.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if respond != nil {
+		if status, body := respond(call); status != 0 {
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+			return
+		}
+	}
+	fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d}}`, id)
+}
+
+// setRespond replaces the responses of the fake API
+func (r *telegramRecorder) setRespond(respond func(call telegramCall) (int, string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.respond = respond
+}
+
+// client returns a TelegramClient that talks to the fake API
+func (r *telegramRecorder) client() *TelegramClient {
+	return &TelegramClient{HTTPClient: r.server.Client(), BaseURL: r.server.URL}
+}
+
+// Calls returns the recorded calls in the order they were made
+func (r *telegramRecorder) Calls() []telegramCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]telegramCall(nil), r.calls...)
+}
+
+// callsTo returns the recorded calls of one method
+func (r *telegramRecorder) callsTo(method string) []telegramCall {
+	var calls []telegramCall
+	for _, call := range r.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// telegramError is the body of a failed Bot API call
+func telegramError(code int, description string) string {
+	return fmt.Sprintf(`{"ok":false,"error_code":%d,"description":%q}`, code, description)
+}
+
+func TestTelegramClientSendMessage(t *testing.T) {
+	recorder := newTelegramRecorder(t)
+	client := recorder.client()
+
+	id, err := client.SendMessage("123:abc", TelegramMessage{ChatID: "-1001234", Text: "<b>Hello</b>", ParseMode: "HTML"})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if id != 1 {
+		t.Fatalf("got message ID %d, want 1", id)
+	}
+
+	calls := recorder.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("got %d calls, want 1", len(calls))
+	}
+	call := calls[0]
+	if call.Token != "123:abc" || call.Method != "sendMessage" {
+		t.Fatalf("got token %q method %q", call.Token, call.Method)
+	}
+	if call.chatID() != "-1001234" || call.text() != "<b>Hello</b>" || call.Payload["parse_mode"] != "HTML" {
+		t.Fatalf("unexpected payload %v", call.Payload)
+	}
+}
+
+func TestTelegramClientAPIError(t *testing.T) {
+	recorder := newTelegramRecorder(t)
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		return http.StatusBadRequest, telegramError(400, "Bad Request: chat not found")
+	})
+
+	_, err := recorder.client().SendMessage("token", TelegramMessage{ChatID: "1", Text: "x"})
+	var apiErr *TelegramAPIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got error %v, want a TelegramAPIError", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Description != "Bad Request: chat not found" {
+		t.Fatalf("unexpected error %+v", apiErr)
+	}
+}
+
+func TestTelegramClientRateLimit(t *testing.T) {
+	recorder := newTelegramRecorder(t)
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		return http.StatusTooManyRequests, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 7","parameters":{"retry_after":7}}`
+	})
+
+	_, err := recorder.client().SendMessage("token", TelegramMessage{ChatID: "1", Text: "x"})
+	var rateErr *RateLimitError
+	if !errors.As(err, &rateErr) {
+		t.Fatalf("got error %v, want a RateLimitError", err)
+	}
+	if rateErr.RetryAfter != 7*time.Second {
+		t.Fatalf("got RetryAfter %v, want 7s", rateErr.RetryAfter)
+	}
+}
+
+func TestTelegramClientPinChatMessage(t *testing.T) {
+	recorder := newTelegramRecorder(t)
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		return http.StatusOK, `{"ok":true,"result":true}`
+	})
+
+	if err := recorder.client().PinChatMessage("token", "@channel", 42); err != nil {
+		t.Fatalf("PinChatMessage: %v", err)
+	}
+	calls := recorder.callsTo("pinChatMessage")
+	if len(calls) != 1 || calls[0].chatID() != "@channel" || fmt.Sprint(calls[0].Payload["message_id"]) != "42" {
+		t.Fatalf("unexpected calls %v", recorder.Calls())
+	}
+}
+
+// shortTelegramRetryDelay makes failed sends retry right away for the rest of the test
+func shortTelegramRetryDelay(t *testing.T) {
+	delay := telegramRetryDelay
+	t.Cleanup(func() { telegramRetryDelay = delay })
+	telegramRetryDelay = time.Millisecond
+}
+
+func TestTelegramClientErrorDescription(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"bad request", http.StatusBadRequest, telegramError(400, "Bad Request: message text is empty"), "Telegram API error: Bad Request: message text is empty (code: 400)"},
+		{"forbidden", http.StatusForbidden, telegramError(403, "Forbidden: bot was blocked by the user"), "Forbidden: bot was blocked by the user"},
+		{"not ok", http.StatusOK, telegramError(400, "Bad Request: chat not found"), "Bad Request: chat not found"},
+		{"not JSON", http.StatusBadGateway, "<html>Bad Gateway</html>", "Telegram API returned error: 502 Bad Gateway"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := newTelegramRecorder(t)
+			recorder.setRespond(func(call telegramCall) (int, string) {
+				return tc.status, tc.body
+			})
+
+			_, err := recorder.client().SendMessage("token", TelegramMessage{ChatID: "1", Text: "x"})
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("got error %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestSendFeedItemErrorIncludesDescription(t *testing.T) {
+	shortTelegramRetryDelay(t)
+	ts, recorder := newTestTelegramService(t, &Config{})
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		return http.StatusBadRequest, telegramError(400, "Bad Request: chat not found")
+	})
+
+	_, err := ts.SendFeedItemToTelegram(testFeed("https://example.com/feed.xml"), map[string]interface{}{"Title": "Hello"})
+	if err == nil || !strings.Contains(err.Error(), "Bad Request: chat not found") {
+		t.Fatalf("got error %v, want Telegram's description", err)
+	}
+	var apiErr *TelegramAPIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode != 400 {
+		t.Fatalf("got error %v, want the TelegramAPIError", err)
+	}
+}
+
+func TestTelegramClientTimesOutOnSlowServer(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	client := NewTelegramClient(100 * time.Millisecond)
+	client.BaseURL = server.URL
+
+	start := time.Now()
+	_, err := client.SendMessage("token", TelegramMessage{ChatID: "1", Text: "x"})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("send took %v with a 100ms timeout", elapsed)
+	}
+	if err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	// Telegram may have received the message, so it must not be retried blindly
+	if !isAmbiguousSendError(err) {
+		t.Fatalf("got error %v, want an ambiguous send", err)
+	}
+}
+
+func TestTelegramTimeoutFromConfig(t *testing.T) {
+	cm := newTestConfigManager(t, &Config{})
+	ts := NewTelegramService(cm)
+	if got := ts.Client.Timeout(); got != defaultTelegramTimeout {
+		t.Fatalf("got timeout %v, want %v", got, defaultTelegramTimeout)
+	}
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	ts.Client.BaseURL = server.URL
+
+	// A changed timeout applies to the next request, without creating a new service
+	err := cm.Update(func(cfg *Config) error {
+		cfg.TelegramTimeoutSeconds = 1
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ts.Client.Timeout(); got != time.Second {
+		t.Fatalf("got timeout %v after the update, want 1s", got)
+	}
+	start := time.Now()
+	if _, err := ts.Client.SendMessage("token", TelegramMessage{ChatID: "1", Text: "x"}); !isAmbiguousSendError(err) {
+		t.Fatalf("got error %v, want an ambiguous send", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("send took %v with a 1s timeout", elapsed)
+	}
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Policies for items whose template fails to render
+const (
+	templateErrorFallback = "fallback"
+	templateErrorSkip     = "skip"
+	templateErrorRaw      = "raw"
+)
+
+// fallbackTemplate is sent instead of a feed template that fails to render
+const fallbackTemplate = "<b>{{.Title}}</b>\n{{.Link}}"
+
+// validateTemplateErrorPolicy checks a feed's template_error policy
+func validateTemplateErrorPolicy(policy string) error {
+	switch policy {
+	case "", templateErrorFallback, templateErrorSkip, templateErrorRaw:
+		return nil
+	}
+	return fmt.Errorf("unknown template_error %q (use %q, %q or %q)", policy, templateErrorFallback, templateErrorSkip, templateErrorRaw)
+}
+
+// itemTemplates returns the templates used to render an item of the feed
+func itemTemplates(feed Feed) []string {
+	templates := []string{feed.TelegramTemplate}
+	if feed.SendAsPhoto && feed.CaptionTemplate != "" {
+		templates = append(templates, feed.CaptionTemplate)
+	}
+	return templates
+}
+
+// templateError checks that the feed's templates render, e.g. that they only use known
+// variables and partials, by rendering them without item data
+func templateError(feed Feed) error {
+	for _, template := range itemTemplates(feed) {
+		_, err := processFeedItem(map[string]interface{}{}, map[string]interface{}{}, template, feed.partials, SanitizeText)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fallbackFeed returns the feed with its templates replaced by the title and link fallback
+func fallbackFeed(feed Feed) Feed {
+	feed.TelegramTemplate = fallbackTemplate
+	feed.CaptionTemplate = ""
+	return feed
+}
+
+// rawTemplateMessage shows a feed's unrendered template and why it failed to render
+func rawTemplateMessage(feed Feed, renderErr error) string {
+	var message strings.Builder
+	message.WriteString(fmt.Sprintf("⚠️ Template error: %s\n", html.EscapeString(renderErr.Error())))
+	for _, template := range itemTemplates(feed) {
+		message.WriteString("<pre>" + html.EscapeString(template) + "</pre>\n")
+	}
+	return strings.TrimSuffix(message.String(), "\n")
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+// brokenTemplate uses a variable that doesn't exist
+const brokenTemplate = "{{.Title}} {{.Nonexistent}}"
+
+func TestTemplateErrorPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy string
+		check  func(t *testing.T, texts []string)
+	}{
+		{"", func(t *testing.T, texts []string) {
+			if len(texts) != 1 || texts[0] != "<b>First</b>\nhttps://example.com/1" {
+				t.Fatalf("got messages %q, want the title and link fallback", texts)
+			}
+		}},
+		{templateErrorFallback, func(t *testing.T, texts []string) {
+			if len(texts) != 1 || texts[0] != "<b>First</b>\nhttps://example.com/1" {
+				t.Fatalf("got messages %q, want the title and link fallback", texts)
+			}
+		}},
+		{templateErrorRaw, func(t *testing.T, texts []string) {
+			if len(texts) != 1 || !strings.HasPrefix(texts[0], "⚠️ Template error: ") || !strings.Contains(texts[0], "<pre>{{.Title}} {{.Nonexistent}}</pre>") {
+				t.Fatalf("got messages %q, want the raw template", texts)
+			}
+		}},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First", Link: "https://example.com/1"}))
+			feed := testFeed(server.URL)
+			feed.TelegramTemplate = brokenTemplate
+			feed.TemplateError = tc.policy
+			fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+			fs.runFeed(feed)
+
+			tc.check(t, sentTexts(recorder))
+			if posted, _ := fs.dbManager.IsFeedItemPosted("1", feed.Key()); !posted {
+				t.Fatal("item sent despite the broken template was not recorded")
+			}
+			if status := apiStatus(t, fs); status[0].TemplateError == "" {
+				t.Fatal("template error not recorded in the status")
+			}
+		})
+	}
+}
+
+func TestTemplateErrorSkipAlertsAndRetries(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	feed.TelegramTemplate = brokenTemplate
+	feed.TemplateError = templateErrorSkip
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}, AlertTelegramApiToken: "456:alert", AlertTelegramChatId: "999"})
+
+	fs.runFeed(feed)
+	fs.runFeed(feed)
+
+	calls := recorder.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].chatID() != "999" || !strings.Contains(calls[0].text(), "Nonexistent") {
+		t.Fatalf("got calls %v, want a single alert", calls)
+	}
+	if posted, _ := fs.dbManager.IsFeedItemPosted("1", feed.Key()); posted {
+		t.Fatal("skipped item was recorded")
+	}
+	if status := apiStatus(t, fs); status[0].TemplateError == "" {
+		t.Fatal("template error not recorded in the status")
+	}
+
+	// Once the template is fixed, the skipped item is sent and the error cleared
+	feed.TelegramTemplate = "{{.Title}}"
+	fs.runFeed(feed)
+	calls = recorder.callsTo("sendMessage")
+	if len(calls) != 2 || calls[1].chatID() != "100" || calls[1].text() != "First" {
+		t.Fatalf("got calls %v, want the item after the fix", calls)
+	}
+	if status := apiStatus(t, fs); status[0].TemplateError != "" {
+		t.Fatalf("template error %q kept after the fix", status[0].TemplateError)
+	}
+}
+
+func TestValidateTemplateErrorPolicy(t *testing.T) {
+	for _, policy := range []string{"", templateErrorFallback, templateErrorSkip, templateErrorRaw} {
+		if err := validateTemplateErrorPolicy(policy); err != nil {
+			t.Errorf("%q: unexpected error: %v", policy, err)
+		}
+	}
+	if err := validateTemplateErrorPolicy("ignore"); err == nil {
+		t.Fatal("expected an error for an unknown policy")
+	}
+}
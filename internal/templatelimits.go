@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Limits that keep templates from producing or costing more than a message is worth
+const (
+	maxTemplateLength       = 16 << 10
+	maxTemplatePlaceholders = 200
+	maxTemplateNodes        = 2000
+	maxTemplateRangeCount   = 1000
+	maxTemplateRangeDepth   = 3
+	maxRenderedLength       = 64 << 10
+)
+
+// validateTemplate rejects templates that don't parse or exceed the length, placeholder
+// or parse-node budget
+func validateTemplate(name, template string) error {
+	if len(template) > maxTemplateLength {
+		return fmt.Errorf("%s is %d bytes long, the limit is %d", name, len(template), maxTemplateLength)
+	}
+	if placeholders := strings.Count(template, "{{"); placeholders > maxTemplatePlaceholders {
+		return fmt.Errorf("%s uses %d placeholders, the limit is %d", name, placeholders, maxTemplatePlaceholders)
+	}
+	tmpl, err := parseTemplate(name, template, nil)
+	if err != nil {
+		return err
+	}
+	return checkTemplateTree(name, tmpl)
+}
+
+// checkTemplateTree walks the parse tree of a template and rejects it when it has more
+// than maxTemplateNodes nodes, nests more than maxTemplateRangeDepth ranges, or ranges
+// over integer literals that multiply to more than maxTemplateRangeCount iterations, such
+// as {{range 2000000000}} or {{range 1000}}{{range 1000}}, which would keep rendering long
+// after it is useful. Templates included inside a range count towards its iterations, and
+// a template that includes itself from inside a range is rejected.
+func checkTemplateTree(name string, tmpl *template.Template) error {
+	nodes := 0
+	var walk func(node parse.Node, iterations int64, depth int, including []string) error
+	walk = func(node parse.Node, iterations int64, depth int, including []string) error {
+		if node == nil || reflect.ValueOf(node).IsNil() {
+			return nil
+		}
+		nodes++
+		if nodes > maxTemplateNodes {
+			return fmt.Errorf("%s has more than %d template nodes", name, maxTemplateNodes)
+		}
+
+		var children []parse.Node
+		switch n := node.(type) {
+		case *parse.ListNode:
+			children = n.Nodes
+		case *parse.ActionNode:
+			children = []parse.Node{n.Pipe}
+		case *parse.IfNode:
+			children = []parse.Node{n.Pipe, n.List, n.ElseList}
+		case *parse.WithNode:
+			children = []parse.Node{n.Pipe, n.List, n.ElseList}
+		case *parse.RangeNode:
+			if err := walk(n.Pipe, iterations, depth, including); err != nil {
+				return err
+			}
+			if err := walk(n.ElseList, iterations, depth, including); err != nil {
+				return err
+			}
+			if depth++; depth > maxTemplateRangeDepth {
+				return fmt.Errorf("%s nests more than %d ranges", name, maxTemplateRangeDepth)
+			}
+			if count, ok := rangeLiteral(n.Pipe); ok && count > 1 {
+				if count > maxTemplateRangeCount || iterations*count > maxTemplateRangeCount {
+					return fmt.Errorf("%s ranges over %d iterations, the limit is %d", name, iterations*count, maxTemplateRangeCount)
+				}
+				iterations *= count
+			}
+			return walk(n.List, iterations, depth, including)
+		case *parse.TemplateNode:
+			children = []parse.Node{n.Pipe}
+			// Outside a range the included template is checked on its own
+			if included := tmpl.Lookup(n.Name); depth > 0 && included != nil && included.Tree != nil {
+				for _, outer := range including {
+					if outer == n.Name {
+						return fmt.Errorf("%s includes %q from within itself", name, n.Name)
+					}
+				}
+				if err := walk(included.Tree.Root, iterations, depth, append(including[:len(including):len(including)], n.Name)); err != nil {
+					return err
+				}
+			}
+		case *parse.PipeNode:
+			for _, decl := range n.Decl {
+				children = append(children, decl)
+			}
+			for _, cmd := range n.Cmds {
+				children = append(children, cmd)
+			}
+		case *parse.CommandNode:
+			children = n.Args
+		case *parse.ChainNode:
+			children = []parse.Node{n.Node}
+		}
+		for _, child := range children {
+			if err := walk(child, iterations, depth, including); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		if err := walk(t.Tree.Root, 1, 0, []string{t.Name()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rangeLiteral returns the integer a range pipeline iterates over when it is a plain
+// integer literal, as in {{range 10}} or {{range $i := 10}}
+func rangeLiteral(pipe *parse.PipeNode) (int64, bool) {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return 0, false
+	}
+	number, ok := pipe.Cmds[0].Args[0].(*parse.NumberNode)
+	if !ok || !number.IsInt {
+		return 0, false
+	}
+	return number.Int64, true
+}
+
+// validateFeedTemplates checks every template configured on a feed
+func validateFeedTemplates(feed Feed) error {
+	templates := map[string]string{
+		"telegram_template":    feed.TelegramTemplate,
+		"caption_template":     feed.CaptionTemplate,
+		"digest_template":      feed.DigestTemplate,
+		"digest_item_template": feed.DigestItemTemplate,
+		"author_format":        feed.AuthorFormat,
+	}
+	for name, template := range templates {
+		if err := validateTemplate(name, template); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// capRenderedOutput cuts a rendered template down to maxRenderedLength bytes without
+// splitting a UTF-8 character
+func capRenderedOutput(message string) string {
+	if len(message) <= maxRenderedLength {
+		return message
+	}
+	return strings.ToValidUTF8(message[:maxRenderedLength], "")
+}
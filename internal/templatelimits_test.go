@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteTemplateOutputSizeExceeded(t *testing.T) {
+	message, err := executeTemplate("message", "{{range .}}"+strings.Repeat("é", 1000)+"{{end}}", nil, make([]int, 100))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(message) > maxRenderedLength {
+		t.Fatalf("got %d bytes, the limit is %d", len(message), maxRenderedLength)
+	}
+	if !strings.HasSuffix(message, "é") {
+		t.Fatal("output was cut inside a character")
+	}
+}
+
+func TestExecuteTemplateRefusesRunawayRender(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	for _, template := range []string{
+		"{{range 100000000}}x{{end}}",
+		// Writes nothing, so capping the output would never stop it
+		"{{range 1000}}{{range 1000}}{{range 1000}}{{end}}{{end}}{{end}}x",
+	} {
+		start := time.Now()
+		if _, err := executeTemplate("message", template, nil, nil); err == nil || err == errRenderTimeout {
+			t.Errorf("%q: got error %v, want it refused", template, err)
+		}
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("%q: refused after %v", template, elapsed)
+		}
+	}
+	if n := settledGoroutines(baseline); n > baseline {
+		t.Fatalf("%d goroutines after refusing the templates, %d before", n, baseline)
+	}
+}
+
+func TestValidateTemplateRejectsNestedRanges(t *testing.T) {
+	for _, template := range []string{
+		"{{range 1000}}{{range 1000}}{{range 1000}}{{end}}{{end}}{{end}}x",
+		"{{range 100}}{{if .}}{{range 100}}{{end}}{{end}}{{end}}",
+		"{{range $i := 50}}{{range $j := 50}}{{$i}}{{$j}}{{end}}{{end}}",
+		"{{range .Categories}}{{range .}}{{range .}}{{range .}}{{end}}{{end}}{{end}}{{end}}",
+		// Ranges in an included template multiply with the one including it
+		`{{define "row"}}{{range 100}}x{{end}}{{end}}{{range 100}}{{template "row"}}{{end}}`,
+		`{{define "loop"}}{{range 2}}{{template "loop"}}{{end}}{{end}}{{template "loop"}}`,
+	} {
+		if err := validateTemplate("telegram_template", template); err == nil {
+			t.Errorf("%q: expected an error", template)
+		}
+	}
+
+	for _, template := range []string{
+		"{{range 10}}{{range 100}}x{{end}}{{end}}",
+		"{{range .Categories}}{{range 1000}}x{{end}}{{end}}",
+		`{{define "row"}}{{range 10}}x{{end}}{{end}}{{range 100}}{{template "row"}}{{end}}`,
+	} {
+		if err := validateTemplate("telegram_template", template); err != nil {
+			t.Errorf("%q: unexpected error: %v", template, err)
+		}
+	}
+}
+
+func TestExecuteTemplateChecksPartials(t *testing.T) {
+	partials := map[string]string{"row": "{{range 1000}}x{{end}}"}
+	_, err := executeTemplate("message", `{{range 1000}}{{template "row"}}{{end}}`, partials, nil)
+	if err == nil || !strings.Contains(err.Error(), "iterations") {
+		t.Fatalf("got error %v, want the range limit", err)
+	}
+}
+
+func TestValidateTemplateRejectsHugeRange(t *testing.T) {
+	for _, template := range []string{
+		"{{range 2000000000}}{{end}}",
+		"{{range $i := 2000000000}}{{$i}}{{end}}",
+		"{{if .Title}}{{range 5000}}x{{end}}{{end}}",
+	} {
+		if err := validateTemplate("telegram_template", template); err == nil {
+			t.Errorf("%q: expected an error", template)
+		}
+	}
+	if err := validateTemplate("telegram_template", "{{range 3}}x{{end}}"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateTemplateNodeBudget(t *testing.T) {
+	// Each placeholder stays within the placeholder limit but adds many nodes
+	template := strings.Repeat("{{print"+strings.Repeat(" 1", 20)+"}}", maxTemplatePlaceholders)
+	if err := validateTemplate("telegram_template", template); err == nil || !strings.Contains(err.Error(), "nodes") {
+		t.Fatalf("got error %v, want a node budget error", err)
+	}
+}
+
+func TestValidatePartialsChecksBudget(t *testing.T) {
+	if err := validatePartials(map[string]string{"footer": "{{range 2000000000}}{{end}}"}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
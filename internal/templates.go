@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// itemTemplateData holds the variables of item templates, such as {{.Title}}. Every value
+// is already sanitized for Telegram's HTML.
+type itemTemplateData struct {
+	Title           string
+	Description     string
+	Content         string
+	Body            string
+	Link            string
+	Links           string
+	Updated         string
+	UpdatedParsed   string
+	Published       string
+	PublishedParsed string
+	Author          string
+	AuthorEmail     string
+	Authors         templateAuthors
+	GUID            string
+	ImageURL        string
+	ImageTitle      string
+	ContentImage    string
+	Categories      string
+	Enclosures      string
+	Custom          string
+	SourceDomain    string
+
+	FeedTitle           string
+	FeedDescription     string
+	FeedLink            string
+	FeedLanguage        string
+	FeedCopyright       string
+	FeedGenerator       string
+	FeedType            string
+	FeedVersion         string
+	FeedUpdatePeriod    string
+	FeedUpdateFrequency string
+	FeedUpdateBase      string
+}
+
+// templateAuthor is an author of an item, as used by author_format and {{range .Authors}}
+type templateAuthor struct {
+	Name  string
+	Email string
+
+	formatted string // the author as written by author_format
+	separator string // written between this author and the next one
+}
+
+// String returns the author as written by author_format, so {{.}} shows it in a range
+func (a templateAuthor) String() string {
+	return a.formatted
+}
+
+// templateAuthors are the authors of an item. {{range .Authors}} visits each of them,
+// while {{.Authors}} shows them all, joined with authors_separator.
+type templateAuthors []templateAuthor
+
+func (a templateAuthors) String() string {
+	var sb strings.Builder
+	for i, author := range a {
+		if i > 0 {
+			sb.WriteString(a[i-1].separator)
+		}
+		sb.WriteString(author.formatted)
+	}
+	return sb.String()
+}
+
+// digestTemplateData holds the variables of digest templates
+type digestTemplateData struct {
+	FeedName string
+	FeedURL  string
+	Count    int
+	Items    string
+}
+
+// alertTemplateData holds the variables of the alert template
+type alertTemplateData struct {
+	FeedName  string
+	FeedURL   string
+	Error     string
+	FailCount int
+}
+
+// templateFuncs are available in every template, next to the text/template builtins
+var templateFuncs = template.FuncMap{
+	"default":       defaultValue,
+	"truncate":      truncateHTML,
+	"truncateWords": truncateHTMLWords,
+}
+
+// defaultValue returns value, or fallback when value is empty, as in
+// {{.Author | default "Anonymous"}}
+func defaultValue(fallback string, value interface{}) string {
+	if value == nil {
+		return fallback
+	}
+	if text := fmt.Sprint(value); text != "" {
+		return text
+	}
+	return fallback
+}
+
+// parseTemplate parses a template. Partials are added as named templates, which the
+// template can include with {{template "name" .}}.
+func parseTemplate(name, text string, partials map[string]string) (*template.Template, error) {
+	tmpl := template.New(name).Funcs(templateFuncs)
+	for partialName, partial := range partials {
+		if _, err := tmpl.New(partialName).Parse(partial); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl.Parse(text)
+}
+
+// templateRenderTimeout bounds how long a single template may take to render
+const templateRenderTimeout = 2 * time.Second
+
+// errRenderTimeout is returned when a template takes longer than templateRenderTimeout
+var errRenderTimeout = fmt.Errorf("template took longer than %v to render", templateRenderTimeout)
+
+// executeTemplate parses a template and renders it with data. Templates over the limits
+// of checkTemplateTree are refused rather than rendered, so every render finishes on its
+// own. Output beyond maxRenderedLength is dropped. Rendering runs in its own goroutine
+// and the caller stops waiting after templateRenderTimeout, so slow data can't block it.
+func executeTemplate(name, text string, partials map[string]string, data interface{}) (string, error) {
+	tmpl, err := parseTemplate(name, text, partials)
+	if err != nil {
+		return "", err
+	}
+	if err := checkTemplateTree(name, tmpl); err != nil {
+		return "", err
+	}
+
+	out := &cappedBuffer{limit: maxRenderedLength}
+	done := make(chan error, 1)
+	go func() {
+		done <- tmpl.Execute(out, data)
+	}()
+
+	timer := time.NewTimer(templateRenderTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			return "", err
+		}
+		return capRenderedOutput(string(out.data)), nil
+	case <-timer.C:
+		// The next write fails and ends the abandoned render early
+		out.stopped.Store(true)
+		return "", errRenderTimeout
+	}
+}
+
+// cappedBuffer collects output up to a limit and silently drops the rest, so a runaway
+// template can't grow a message without bounds. Writes fail once it is stopped.
+type cappedBuffer struct {
+	data    []byte
+	limit   int
+	stopped atomic.Bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.stopped.Load() {
+		return 0, errRenderTimeout
+	}
+	if room := b.limit + 1 - len(b.data); room > 0 {
+		if len(p) > room {
+			b.data = append(b.data, p[:room]...)
+		} else {
+			b.data = append(b.data, p...)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestProcessFeedItemForTelegramConditionals(t *testing.T) {
+	item := map[string]interface{}{"Title": "Release", "Author": map[string]interface{}{"Name": "Ana"}}
+	feed := map[string]interface{}{}
+
+	message, err := ProcessFeedItemForTelegram(item, feed, "{{.Title}}{{if .Author}} by {{.Author}}{{end}} {{.Link | default \"no link\"}}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "Release by Ana no link"; message != want {
+		t.Fatalf("got %q, want %q", message, want)
+	}
+}
+
+func TestProcessFeedItemForTelegramParseError(t *testing.T) {
+	_, err := ProcessFeedItemForTelegram(map[string]interface{}{}, map[string]interface{}{}, "{{if .Title}}unclosed")
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
+
+func TestValidateTemplateRejectsParseErrors(t *testing.T) {
+	if err := validateTemplate("telegram_template", "{{.Title"); err == nil {
+		t.Fatal("expected an error for an unterminated action")
+	}
+	if err := validateTemplate("telegram_template", "{{.Title}}"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestExecuteTemplateTimeout(t *testing.T) {
+	// Ranging over a channel that is never written blocks until the channel is closed
+	block := make(chan int)
+	baseline := runtime.NumGoroutine()
+
+	start := time.Now()
+	_, err := executeTemplate("message", "{{range .}}{{.}}{{end}}", nil, block)
+	if err != errRenderTimeout {
+		t.Fatalf("got error %v, want %v", err, errRenderTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > templateRenderTimeout+time.Second {
+		t.Fatalf("executeTemplate returned after %v", elapsed)
+	}
+
+	// The abandoned render ends once its data does
+	close(block)
+	if n := settledGoroutines(baseline); n > baseline {
+		t.Fatalf("%d goroutines after the render ended, %d before", n, baseline)
+	}
+}
+
+func TestExecuteTemplateStopsAbandonedRender(t *testing.T) {
+	out := &cappedBuffer{limit: 10}
+	out.stopped.Store(true)
+	if _, err := out.Write([]byte("x")); err != errRenderTimeout {
+		t.Fatalf("got error %v, want %v", err, errRenderTimeout)
+	}
+	if len(out.data) != 0 {
+		t.Fatalf("stopped buffer kept %q", out.data)
+	}
+}
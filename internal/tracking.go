@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"net/url"
+	"strings"
+)
+
+// defaultTrackingParams are removed from item links when a feed strips tracking
+// parameters without listing its own. A trailing * matches any suffix.
+var defaultTrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid", "yclid",
+	"mc_cid", "mc_eid", "igshid", "_hsenc", "_hsmi", "mkt_tok",
+}
+
+// isTrackingParam reports whether a query parameter name matches one of the patterns
+func isTrackingParam(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// stripTrackingParams removes tracking query parameters from a link, keeping the other
+// parameters in their original order as well as the fragment. Links that can't be parsed
+// are returned unchanged.
+func stripTrackingParams(link string, patterns []string) string {
+	if len(patterns) == 0 {
+		patterns = defaultTrackingParams
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || u.RawQuery == "" {
+		return link
+	}
+
+	var kept []string
+	for _, param := range strings.Split(u.RawQuery, "&") {
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if param == "" || isTrackingParam(name, patterns) {
+			continue
+		}
+		kept = append(kept, param)
+	}
+
+	u.RawQuery = strings.Join(kept, "&")
+	u.ForceQuery = false
+	return u.String()
+}
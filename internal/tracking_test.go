@@ -0,0 +1,42 @@
+package internal
+
+import "testing"
+
+func TestStripTrackingParams(t *testing.T) {
+	for _, tc := range []struct {
+		link string
+		want string
+	}{
+		{"https://example.com/post?utm_source=rss&id=42&fbclid=abc", "https://example.com/post?id=42"},
+		{"https://example.com/post?page=2&UTM_Medium=feed&sort=new#comments", "https://example.com/post?page=2&sort=new#comments"},
+		{"https://example.com/post?utm_source=rss&utm_campaign=spring", "https://example.com/post"},
+		{"https://example.com/post?q=a%26b&gclid=x", "https://example.com/post?q=a%26b"},
+		{"https://example.com/post", "https://example.com/post"},
+		{"https://example.com/post?id=1#utm_source", "https://example.com/post?id=1#utm_source"},
+	} {
+		if got := stripTrackingParams(tc.link, nil); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.link, got, tc.want)
+		}
+	}
+}
+
+func TestStripTrackingParamsCustomList(t *testing.T) {
+	got := stripTrackingParams("https://example.com/post?ref=home&utm_source=rss&src_*=1&src_id=2", []string{"ref", "src_*"})
+	if want := "https://example.com/post?utm_source=rss"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestTemplateLinkWithoutTrackingParams(t *testing.T) {
+	item := map[string]interface{}{"Title": "Post", "Link": "https://example.com/post?id=42&utm_source=rss&fbclid=abc#top"}
+
+	feed := Feed{StripTrackingParams: true}
+	if got := renderFeedItemTemplate(feed, item, "{{.Link}}"); got != "https://example.com/post?id=42#top" {
+		t.Fatalf("got %q", got)
+	}
+
+	feed.StripTrackingParams = false
+	if got := renderFeedItemTemplate(feed, item, "{{.Link}}"); got != "https://example.com/post?id=42&amp;utm_source=rss&amp;fbclid=abc#top" {
+		t.Fatalf("got %q, want the link untouched", got)
+	}
+}
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// ellipsis marks text shortened by the truncate template functions
+const ellipsis = "…"
+
+// truncateHTML shortens Telegram HTML to at most limit characters of visible text, cutting
+// at the last word boundary before the limit, or inside a word longer than the limit, and
+// appends an ellipsis. Tags are never cut and the ones still open are closed. Text within
+// the limit is returned as it is. Used as {{truncate .Description 200}}.
+func truncateHTML(text string, limit int) string {
+	plain := []rune(htmlToPlain(text))
+	if len(plain) <= limit {
+		return text
+	}
+	if limit <= 0 {
+		return ""
+	}
+
+	cut := limit
+	if !unicode.IsSpace(plain[cut]) {
+		for i := cut - 1; i > 0; i-- {
+			if unicode.IsSpace(plain[i]) {
+				cut = i
+				break
+			}
+		}
+	}
+	for cut > 0 && unicode.IsSpace(plain[cut-1]) {
+		cut--
+	}
+	if cut == 0 {
+		cut = limit
+	}
+
+	return cutHTML(text, cut)
+}
+
+// truncateHTMLWords shortens Telegram HTML to its first words words of visible text and
+// appends an ellipsis, like truncateHTML. Used as {{truncateWords .Content 40}}.
+func truncateHTMLWords(text string, words int) string {
+	plain := []rune(htmlToPlain(text))
+
+	count := 0
+	end := 0
+	for i, r := range plain {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		if i == 0 || unicode.IsSpace(plain[i-1]) {
+			count++
+			if count > words {
+				if words <= 0 {
+					return ""
+				}
+				return cutHTML(text, end)
+			}
+		}
+		end = i + 1
+	}
+	return text
+}
+
+// cutHTML keeps the first cut characters of the visible text of Telegram HTML, appends
+// an ellipsis and closes the tags that are still open
+func cutHTML(text string, cut int) string {
+	var sb strings.Builder
+	var open []string
+	remaining := cut
+
+	tokenizer := xhtml.NewTokenizer(strings.NewReader(text))
+tokens:
+	for remaining > 0 {
+		tokenType := tokenizer.Next()
+		raw := string(tokenizer.Raw())
+		switch tokenType {
+		case xhtml.ErrorToken:
+			break tokens
+		case xhtml.TextToken:
+			runes := []rune(html.UnescapeString(raw))
+			if len(runes) > remaining {
+				sb.WriteString(html.EscapeString(string(runes[:remaining])))
+				break tokens
+			}
+			sb.WriteString(raw)
+			remaining -= len(runes)
+		case xhtml.StartTagToken:
+			sb.WriteString(raw)
+			name, _ := tokenizer.TagName()
+			if string(name) != "br" {
+				open = append(open, string(name))
+			}
+		case xhtml.EndTagToken:
+			sb.WriteString(raw)
+			name, _ := tokenizer.TagName()
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == string(name) {
+					open = open[:i]
+					break
+				}
+			}
+		default:
+			sb.WriteString(raw)
+		}
+	}
+
+	sb.WriteString(ellipsis)
+	for i := len(open) - 1; i >= 0; i-- {
+		sb.WriteString("</" + open[i] + ">")
+	}
+	return sb.String()
+}
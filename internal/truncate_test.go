@@ -0,0 +1,63 @@
+package internal
+
+import "testing"
+
+func TestTruncateHTML(t *testing.T) {
+	for _, tc := range []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{"Short text", 200, "Short text"},
+		{"Exactly ten", 11, "Exactly ten"},
+		{"The quick brown fox jumps", 12, "The quick" + ellipsis},
+		{"The quick brown fox", 9, "The quick" + ellipsis},
+		{"Supercalifragilistic", 5, "Super" + ellipsis},
+		{"Grüße aus München und Köln", 17, "Grüße aus München" + ellipsis},
+		{"日本語のテキストです", 4, "日本語の" + ellipsis},
+		{"Emoji 🎉🎉 party time", 9, "Emoji 🎉🎉" + ellipsis},
+		{"<b>Bold words here</b> and more", 10, "<b>Bold words" + ellipsis + "</b>"},
+		{`<a href="https://example.com/a">A long link text</a>`, 6, `<a href="https://example.com/a">A long` + ellipsis + "</a>"},
+		{"<b>Tom &amp; Jerry show</b>", 11, "<b>Tom &amp; Jerry" + ellipsis + "</b>"},
+		{"Anything", 0, ""},
+	} {
+		if got := truncateHTML(tc.text, tc.limit); got != tc.want {
+			t.Errorf("truncate %q %d: got %q, want %q", tc.text, tc.limit, got, tc.want)
+		}
+	}
+}
+
+func TestTruncateHTMLWords(t *testing.T) {
+	for _, tc := range []struct {
+		text  string
+		words int
+		want  string
+	}{
+		{"Three short words", 40, "Three short words"},
+		{"Three short words", 3, "Three short words"},
+		{"One two three four", 2, "One two" + ellipsis},
+		{"  Leading   spaces and more ", 2, "  Leading   spaces" + ellipsis},
+		{"Grüße aus München und Köln", 3, "Grüße aus München" + ellipsis},
+		{"<i>Über alles</i> sonst nichts", 1, "<i>Über" + ellipsis + "</i>"},
+		{"Anything at all", 0, ""},
+	} {
+		if got := truncateHTMLWords(tc.text, tc.words); got != tc.want {
+			t.Errorf("truncateWords %q %d: got %q, want %q", tc.text, tc.words, got, tc.want)
+		}
+	}
+}
+
+func TestTruncateTemplateFunctions(t *testing.T) {
+	item := map[string]interface{}{
+		"Description": "<p>Große Neuigkeiten über den Release</p>",
+		"Content":     "Short",
+	}
+
+	message, err := ProcessFeedItemForTelegram(item, map[string]interface{}{}, "{{truncate .Description 15}}|{{truncateWords .Content 40}}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "Große" + ellipsis + "|Short"; message != want {
+		t.Fatalf("got %q, want %q", message, want)
+	}
+}
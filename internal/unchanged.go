@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+)
+
+// errFeedUnchanged is returned for a scheduled fetch whose body is identical to the last
+// one that was fully processed, so parsing it and checking its items can be skipped
+var errFeedUnchanged = errors.New("feed unchanged since the last fetch")
+
+// bodyHashes remembers a hash of the last fully processed body of every feed. It lets
+// unchanged feeds be skipped when their server doesn't support conditional requests.
+type bodyHashes struct {
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+// newBodyHashes creates an empty set of body hashes
+func newBodyHashes() *bodyHashes {
+	return &bodyHashes{hashes: make(map[string]string)}
+}
+
+// hashBody returns the hash of a feed body
+func hashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// unchanged reports whether a feed's body has the hash of its last fully processed body
+func (bh *bodyHashes) unchanged(feedKey, hash string) bool {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+	return bh.hashes[feedKey] == hash
+}
+
+// record stores the hash of a feed body whose items have all been handled. Bodies with
+// items left for a later fetch, e.g. ones that failed to send, must not be recorded.
+func (bh *bodyHashes) record(feedKey, hash string) {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+	bh.hashes[feedKey] = hash
+}
+
+// reset forgets every hash, e.g. after the configuration changed how items are handled
+func (bh *bodyHashes) reset() {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+	bh.hashes = make(map[string]string)
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// forgetItems deletes everything recorded for a feed, so that any item the scheduler
+// still looks up in the database is sent again
+func forgetItems(t *testing.T, fs *FeedScheduler, feedKey string) {
+	t.Helper()
+	if _, err := fs.dbManager.db.Exec(`DELETE FROM feed_items WHERE feed_url = ?`, feedKey); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnchangedBodySkipsItemChecks(t *testing.T) {
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+	fs.runFeed(feed)
+	forgetItems(t, fs, feed.Key())
+
+	// The same body isn't parsed, so the forgotten item isn't looked up or sent again
+	fs.runFeed(feed)
+	if texts := sentTexts(recorder); !reflect.DeepEqual(texts, []string{"First"}) {
+		t.Fatalf("got messages %q, want the item sent once", texts)
+	}
+	if n := server.requests.Load(); n != 2 {
+		t.Fatalf("feed fetched %d times, want 2", n)
+	}
+
+	// A changed body is checked item by item again
+	server.setBody(rssFeed(testItem{GUID: "2", Title: "Second"}, testItem{GUID: "1", Title: "First"}))
+	fs.runFeed(feed)
+	if texts, want := sentTexts(recorder), []string{"First", "First", "Second"}; !reflect.DeepEqual(texts, want) {
+		t.Fatalf("got messages %q, want %q", texts, want)
+	}
+}
+
+func TestFailedSendDoesNotRecordBody(t *testing.T) {
+	shortTelegramRetryDelay(t)
+	server := newFeedServer(t, rssFeed(testItem{GUID: "1", Title: "First"}))
+	feed := testFeed(server.URL)
+	fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+	recorder.setRespond(func(call telegramCall) (int, string) {
+		return http.StatusBadGateway, telegramError(502, "Bad Gateway")
+	})
+
+	fs.runFeed(feed)
+	recorder.setRespond(nil)
+
+	// The item left for later is tried again although the body is the same
+	fs.runFeed(feed)
+	if posted, _ := fs.dbManager.IsFeedItemPosted("1", feed.Key()); !posted {
+		t.Fatal("item was not retried with an unchanged body")
+	}
+}
+
+func TestBodyHashes(t *testing.T) {
+	bh := newBodyHashes()
+	hash := hashBody([]byte("<rss/>"))
+
+	if bh.unchanged("feed", hash) {
+		t.Fatal("unknown feed reported unchanged")
+	}
+	bh.record("feed", hash)
+	if !bh.unchanged("feed", hash) {
+		t.Fatal("recorded body reported changed")
+	}
+	if bh.unchanged("feed", hashBody([]byte("<rss></rss>"))) || bh.unchanged("other", hash) {
+		t.Fatal("different body or feed reported unchanged")
+	}
+	bh.reset()
+	if bh.unchanged("feed", hash) {
+		t.Fatal("body reported unchanged after a reset")
+	}
+}
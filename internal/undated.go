@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Policies for items without a publication date
+const (
+	undatedFetchTime = "fetch_time"
+	undatedEpoch     = "epoch"
+	undatedSkip      = "skip"
+)
+
+// validateUndatedItems checks a feed's undated_items policy
+func validateUndatedItems(policy string) error {
+	switch policy {
+	case "", undatedFetchTime, undatedEpoch, undatedSkip:
+		return nil
+	}
+	return fmt.Errorf("unknown undated_items %q (use %q, %q or %q)", policy, undatedFetchTime, undatedEpoch, undatedSkip)
+}
+
+// skipUndated reports whether an item is skipped because it has no publication date
+func skipUndated(feed Feed, item *gofeed.Item) bool {
+	return feed.UndatedItems == undatedSkip && item.PublishedParsed == nil
+}
+
+// publicationDate returns the date stored for an item and whether it was made up because
+// the item has none: the fetch time by default, or the Unix epoch with the "epoch" policy
+func publicationDate(feed Feed, item *gofeed.Item, now time.Time) (time.Time, bool) {
+	if item.PublishedParsed != nil {
+		return *item.PublishedParsed, false
+	}
+	if feed.UndatedItems == undatedEpoch {
+		return time.Unix(0, 0).UTC(), true
+	}
+	return now, true
+}
@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestPublicationDate(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	published := time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC)
+
+	for _, policy := range []string{"", undatedFetchTime, undatedEpoch, undatedSkip} {
+		feed := Feed{UndatedItems: policy}
+		date, synthesized := publicationDate(feed, &gofeed.Item{PublishedParsed: &published}, now)
+		if !date.Equal(published) || synthesized {
+			t.Errorf("%q: dated item got %v, %v", policy, date, synthesized)
+		}
+	}
+
+	for _, tc := range []struct {
+		policy string
+		want   time.Time
+	}{
+		{"", now},
+		{undatedFetchTime, now},
+		{undatedEpoch, time.Unix(0, 0)},
+	} {
+		date, synthesized := publicationDate(Feed{UndatedItems: tc.policy}, &gofeed.Item{}, now)
+		if !date.Equal(tc.want) || !synthesized {
+			t.Errorf("%q: undated item got %v, %v, want %v, true", tc.policy, date, synthesized, tc.want)
+		}
+	}
+}
+
+func TestValidateUndatedItems(t *testing.T) {
+	for _, policy := range []string{"", undatedFetchTime, undatedEpoch, undatedSkip} {
+		if err := validateUndatedItems(policy); err != nil {
+			t.Errorf("%q: unexpected error: %v", policy, err)
+		}
+	}
+	if err := validateUndatedItems("ignore"); err == nil {
+		t.Fatal("expected an error for an unknown policy")
+	}
+}
+
+// storedDate returns the publication date stored for an item and whether it was synthesized
+func storedDate(t *testing.T, db *DBManager, guid string) (time.Time, bool) {
+	t.Helper()
+	var published time.Time
+	var synthesized bool
+	err := db.db.QueryRow(`SELECT published_at, date_synthesized FROM feed_items WHERE guid = ?`, guid).Scan(&published, &synthesized)
+	if err != nil {
+		t.Fatalf("reading item %s: %v", guid, err)
+	}
+	return published, synthesized
+}
+
+func TestRunFeedUndatedItemPolicies(t *testing.T) {
+	published := time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC)
+	body := rssFeed(
+		testItem{GUID: "undated", Title: "Undated"},
+		testItem{GUID: "dated", Title: "Dated", Published: published},
+	)
+
+	for _, tc := range []struct {
+		policy string
+		sent   []string
+	}{
+		{"", []string{"Dated", "Undated"}},
+		{undatedFetchTime, []string{"Dated", "Undated"}},
+		{undatedEpoch, []string{"Dated", "Undated"}},
+		{undatedSkip, []string{"Dated"}},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			server := newFeedServer(t, body)
+			feed := testFeed(server.URL)
+			feed.UndatedItems = tc.policy
+			fs, recorder := newTestScheduler(t, &Config{Feeds: []Feed{feed}})
+
+			before := time.Now()
+			fs.runFeed(feed)
+
+			if texts := sentTexts(recorder); !reflect.DeepEqual(texts, tc.sent) {
+				t.Fatalf("got messages %q, want %q", texts, tc.sent)
+			}
+			if date, synthesized := storedDate(t, fs.dbManager, "dated"); !date.Equal(published) || synthesized {
+				t.Fatalf("dated item stored with %v, %v", date, synthesized)
+			}
+
+			if tc.policy == undatedSkip {
+				if posted, _ := fs.dbManager.IsFeedItemPosted("undated", feed.Key()); posted {
+					t.Fatal("skipped item was stored")
+				}
+				return
+			}
+			date, synthesized := storedDate(t, fs.dbManager, "undated")
+			if !synthesized {
+				t.Fatal("undated item not flagged as synthesized")
+			}
+			if tc.policy == undatedEpoch {
+				if !date.Equal(time.Unix(0, 0)) {
+					t.Fatalf("undated item stored with %v, want the epoch", date)
+				}
+			} else if date.Before(before.Add(-time.Second)) {
+				t.Fatalf("undated item stored with %v, want the fetch time", date)
+			}
+		})
+	}
+}
@@ -1,60 +1,72 @@
 package internal
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"net/http"
+	"html"
+	"log"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/microcosm-cc/bluemonday"
+	xhtml "golang.org/x/net/html"
 )
 
-// SendTelegramMessage sends a message to Telegram using the official API.
-func SendTelegramMessage(token string, msg TelegramMessage) error {
-	const maxMessageLength = 4096
-	if len(msg.Text) > maxMessageLength {
-		truncated := msg.Text[:maxMessageLength]
-		lastSentence := strings.LastIndex(truncated, ". ")
-		if lastSentence > maxMessageLength/2 {
-			msg.Text = truncated[:lastSentence+1] + "..."
-		} else {
-			msg.Text = truncated + "..."
-		}
-	}
+// Telegram limits for message text and media captions
+const (
+	maxMessageLength = 4096
+	maxCaptionLength = 1024
+)
 
-	jsonData, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("error marshaling JSON: %v", err)
+// truncateText shortens a message to at most limit characters as Telegram counts them,
+// in UTF-16 code units of the visible text, preferring to cut at the end of a sentence.
+// HTML is only cut between tags, and the tags still open are closed. MarkdownV2 is left
+// as it is, since it is converted from HTML that has already been shortened.
+func truncateText(text, parseMode string, limit int) string {
+	if parseMode == parseModeMarkdownV2 {
+		return text
 	}
 
-	telegramURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", token)
-	response, err := http.Post(telegramURL, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("error sending to Telegram: %v", err)
+	plain := text
+	if parseMode == parseModeHTML {
+		plain = htmlToPlain(text)
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("Telegram API returned error: %s", response.Status)
+	runes := []rune(plain)
+	if utf16Length(runes) <= limit {
+		return text
 	}
 
-	var apiResponse struct {
-		Ok          bool        `json:"ok"`
-		Result      interface{} `json:"result"`
-		Description string      `json:"description"`
-		ErrorCode   int         `json:"error_code"`
+	// Keep the characters that fit next to the ellipsis
+	room := limit - utf16Length([]rune(ellipsis))
+	cut, length := 0, 0
+	for cut < len(runes) {
+		length += utf16Length(runes[cut : cut+1])
+		if length > room {
+			break
+		}
+		cut++
 	}
 
-	if err := json.NewDecoder(response.Body).Decode(&apiResponse); err != nil {
-		return fmt.Errorf("error decoding Telegram API response: %v", err)
+	kept := string(runes[:cut])
+	if lastSentence := strings.LastIndex(kept, ". "); lastSentence >= 0 {
+		if sentenceEnd := utf8.RuneCountInString(kept[:lastSentence]) + 1; sentenceEnd > cut/2 {
+			cut = sentenceEnd
+		}
 	}
 
-	if !apiResponse.Ok {
-		return fmt.Errorf("Telegram API error: %s (code: %d)", apiResponse.Description, apiResponse.ErrorCode)
+	if parseMode == parseModeHTML {
+		return cutHTML(text, cut)
 	}
+	return string(runes[:cut]) + ellipsis
+}
 
-	return nil
+// utf16Length returns the length of text in UTF-16 code units, as Telegram counts it
+func utf16Length(runes []rune) int {
+	return len(utf16.Encode(runes))
 }
 
 // SanitizeText sanitizes input text to allow only a safe subset of HTML tags.
@@ -62,89 +74,172 @@ func SanitizeText(text string) string {
 	policy := bluemonday.StrictPolicy()
 	policy.AllowElements("b", "strong", "i", "em", "u", "ins",
 		"s", "strike", "del", "code", "pre", "blockquote")
-	policy.AllowAttrs("href").OnElements("a")
+	allowLinks(policy)
 	sanitized := policy.Sanitize(text)
 	return sanitized
 }
 
+// allowLinks lets links through with the URL schemes Telegram accepts, so a
+// javascript: or data: link is dropped rather than rejected by Telegram
+func allowLinks(policy *bluemonday.Policy) {
+	policy.RequireParseableURLs(true)
+	policy.AllowURLSchemes("http", "https", "tg", "mailto")
+	policy.AllowAttrs("href").OnElements("a")
+}
+
+// Class attributes Telegram understands on spans and code blocks
+var (
+	spoilerClassPattern = regexp.MustCompile(`^tg-spoiler$`)
+	codeLanguagePattern = regexp.MustCompile(`^language-[A-Za-z0-9_+-]+$`)
+)
+
+// NormalizeTelegramHTML keeps everything Telegram's HTML parse mode supports, including
+// attributes such as spoiler classes and code languages, and drops all other markup.
+// It is used for trusted feeds instead of the stricter SanitizeText.
+func NormalizeTelegramHTML(text string) string {
+	policy := bluemonday.NewPolicy()
+	policy.AllowElements("b", "strong", "i", "em", "u", "ins",
+		"s", "strike", "del", "code", "pre", "tg-spoiler")
+	allowLinks(policy)
+	policy.AllowAttrs("class").Matching(spoilerClassPattern).OnElements("span")
+	policy.AllowAttrs("class").Matching(codeLanguagePattern).OnElements("code")
+	policy.AllowAttrs("expandable").OnElements("blockquote")
+	policy.AllowElements("blockquote")
+	policy.AllowAttrs("emoji-id").OnElements("tg-emoji")
+	return unwrapBareSpans(policy.Sanitize(text))
+}
+
+// unwrapBareSpans removes the tags of spans left without a class by the sanitizer, which
+// Telegram rejects, keeping their text
+func unwrapBareSpans(htmlText string) string {
+	if !strings.Contains(htmlText, "<span") {
+		return htmlText
+	}
+
+	var sb strings.Builder
+	var kept []bool // whether each open span was written
+	tokenizer := xhtml.NewTokenizer(strings.NewReader(htmlText))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == xhtml.ErrorToken {
+			return sb.String()
+		}
+		raw := tokenizer.Raw()
+
+		if tokenType == xhtml.StartTagToken || tokenType == xhtml.EndTagToken {
+			name, hasAttr := tokenizer.TagName()
+			if string(name) == "span" {
+				if tokenType == xhtml.StartTagToken {
+					kept = append(kept, hasAttr)
+					if !hasAttr {
+						continue
+					}
+				} else if len(kept) > 0 {
+					keep := kept[len(kept)-1]
+					kept = kept[:len(kept)-1]
+					if !keep {
+						continue
+					}
+				}
+			}
+		}
+		sb.Write(raw)
+	}
+}
+
 // ProcessFeedItemForTelegram processes a feed item and feed metadata and prepares it for Telegram messaging.
-func ProcessFeedItemForTelegram(item map[string]interface{}, feed map[string]interface{}, template string) string {
-	titleStr := getStringValue(item, "Title")
-	descriptionStr := getStringValue(item, "Description")
-	contentStr := getStringValue(item, "Content")
-	linkStr := getStringValue(item, "Link")
-	updatedStr := getStringValue(item, "Updated")
-	publishedStr := getStringValue(item, "Published")
-	guidStr := getStringValue(item, "GUID")
-
-	feedTitle := getStringValue(feed, "Title")
-	feedDescription := getStringValue(feed, "Description")
-	feedLink := getStringValue(feed, "Link")
-	feedLanguage := getStringValue(feed, "Language")
-	feedCopyright := getStringValue(feed, "Copyright")
-	feedGenerator := getStringValue(feed, "Generator")
-	feedType := getStringValue(feed, "FeedType")
-	feedVersion := getStringValue(feed, "FeedVersion")
-
-	authorNameStr, authorEmailStr := extractAuthorInfo(item)
-	allAuthorsStr := extractStringList(item, "Authors", "; ")
-	categoriesStr := extractStringList(item, "Categories", ", ")
-	linksStr := extractStringList(item, "Links", ", ")
-	enclosuresStr := extractEnclosures(item)
-	imageURLStr, imageTitleStr := extractImageInfo(item)
-	customStr := extractCustomFields(item)
-	updatedParsedStr := getStringValue(item, "UpdatedParsed")
-	publishedParsedStr := getStringValue(item, "PublishedParsed")
-
-	titleStr = SanitizeText(titleStr)
-	descriptionStr = SanitizeText(descriptionStr)
-	contentStr = SanitizeText(contentStr)
-	linkStr = SanitizeText(linkStr)
-	linksStr = SanitizeText(linksStr)
-	updatedStr = SanitizeText(updatedStr)
-	updatedParsedStr = SanitizeText(updatedParsedStr)
-	publishedStr = SanitizeText(publishedStr)
-	publishedParsedStr = SanitizeText(publishedParsedStr)
-	authorNameStr = SanitizeText(authorNameStr)
-	authorEmailStr = SanitizeText(authorEmailStr)
-	allAuthorsStr = SanitizeText(allAuthorsStr)
-	guidStr = SanitizeText(guidStr)
-	imageURLStr = SanitizeText(imageURLStr)
-	imageTitleStr = SanitizeText(imageTitleStr)
-	categoriesStr = SanitizeText(categoriesStr)
-	enclosuresStr = SanitizeText(enclosuresStr)
-	customStr = SanitizeText(customStr)
-
-	message := ReplaceTemplateVars(template, map[string]string{
-		".Title":           titleStr,
-		".Description":     descriptionStr,
-		".Content":         contentStr,
-		".Link":            linkStr,
-		".Links":           linksStr,
-		".Updated":         updatedStr,
-		".UpdatedParsed":   updatedParsedStr,
-		".Published":       publishedStr,
-		".PublishedParsed": publishedParsedStr,
-		".Author":          authorNameStr,
-		".AuthorEmail":     authorEmailStr,
-		".Authors":         allAuthorsStr,
-		".GUID":            guidStr,
-		".ImageURL":        imageURLStr,
-		".ImageTitle":      imageTitleStr,
-		".Categories":      categoriesStr,
-		".Enclosures":      enclosuresStr,
-		".Custom":          customStr,
-		".FeedTitle":       feedTitle,
-		".FeedDescription": feedDescription,
-		".FeedLink":        feedLink,
-		".FeedLanguage":    feedLanguage,
-		".FeedCopyright":   feedCopyright,
-		".FeedGenerator":   feedGenerator,
-		".FeedType":        feedType,
-		".FeedVersion":     feedVersion,
-	})
+func ProcessFeedItemForTelegram(item map[string]interface{}, feed map[string]interface{}, template string) (string, error) {
+	return processFeedItem(item, feed, template, nil, SanitizeText)
+}
 
-	return message
+// processFeedItem renders the template with the item's fields, cleaning each one with
+// sanitize. The template can include the given partials.
+func processFeedItem(item map[string]interface{}, feed map[string]interface{}, template string, partials map[string]string, sanitize func(string) string) (string, error) {
+	feedLink := feedValue(item, feed, "FeedLink", "Link")
+	authorName, authorEmail := extractAuthorInfo(item)
+	imageURL, imageTitle := extractImageInfo(item)
+
+	data := itemTemplateData{
+		Title:           sanitize(getStringValue(item, "Title")),
+		Description:     sanitize(getStringValue(item, "Description")),
+		Content:         sanitize(getStringValue(item, "Content")),
+		Body:            sanitize(selectBody(getStringValue(item, "Description"), getStringValue(item, "Content"), getStringValue(feed, "BodyPreference"))),
+		Link:            sanitize(getStringValue(item, "Link")),
+		Links:           sanitize(extractStringList(item, "Links", ", ")),
+		Updated:         sanitize(getStringValue(item, "Updated")),
+		UpdatedParsed:   sanitize(getStringValue(item, "UpdatedParsed")),
+		Published:       sanitize(getStringValue(item, "Published")),
+		PublishedParsed: sanitize(getStringValue(item, "PublishedParsed")),
+		Author:          sanitize(authorName),
+		AuthorEmail:     sanitize(authorEmail),
+		Authors:         formatAuthors(item, getStringValue(feed, "AuthorFormat"), getStringValue(feed, "AuthorsSeparator"), sanitize),
+		GUID:            sanitize(getStringValue(item, "GUID")),
+		ImageURL:        sanitize(imageURL),
+		ImageTitle:      sanitize(imageTitle),
+		ContentImage:    sanitize(extractContentImage(item)),
+		Categories:      sanitize(formatCategories(item, getStringValue(feed, "CategoryFormat"), getStringValue(feed, "HashtagWords"), getStringValue(feed, "CategoriesSeparator"))),
+		Enclosures:      sanitize(extractEnclosures(item, feed["RawEnclosureSizes"] == true)),
+		Custom:          sanitize(extractCustomFields(item)),
+		SourceDomain:    sanitize(sourceDomain(getStringValue(item, "Link"), feedLink)),
+
+		FeedTitle:           sanitize(feedValue(item, feed, "FeedTitle", "Title")),
+		FeedDescription:     sanitize(feedValue(item, feed, "FeedDescription", "Description")),
+		FeedLink:            sanitize(feedLink),
+		FeedLanguage:        sanitize(feedValue(item, feed, "FeedLanguage", "Language")),
+		FeedCopyright:       sanitize(feedValue(item, feed, "FeedCopyright", "Copyright")),
+		FeedGenerator:       sanitize(feedValue(item, feed, "FeedGenerator", "Generator")),
+		FeedType:            sanitize(feedValue(item, feed, "FeedType", "FeedType")),
+		FeedVersion:         sanitize(feedValue(item, feed, "FeedVersion", "FeedVersion")),
+		FeedUpdatePeriod:    sanitize(getStringValue(item, "FeedUpdatePeriod")),
+		FeedUpdateFrequency: sanitize(getStringValue(item, "FeedUpdateFrequency")),
+		FeedUpdateBase:      sanitize(getStringValue(item, "FeedUpdateBase")),
+	}
+
+	return executeTemplate("message", template, partials, data)
+}
+
+// feedValue returns a feed-level value, preferring the one buildItemMap stored in the item
+// so that every caller renders the same item identically
+func feedValue(item map[string]interface{}, feed map[string]interface{}, itemKey string, feedKey string) string {
+	if value := getStringValue(item, itemKey); value != "" {
+		return value
+	}
+	return getStringValue(feed, feedKey)
+}
+
+// appendLinkIfMissing appends the item link on its own line unless the message already contains it.
+func appendLinkIfMissing(message, link string) string {
+	if link == "" {
+		return message
+	}
+
+	sanitizedLink := SanitizeText(link)
+	if strings.Contains(message, link) || strings.Contains(message, sanitizedLink) {
+		return message
+	}
+
+	return message + "\n" + sanitizedLink
+}
+
+// sourceDomain returns the host of the first link that has one, without its port and a
+// leading "www.", e.g. "blog.example.com" for "https://www.blog.example.com:8443/post".
+// Links without a scheme such as "example.com/post" are read as http links.
+func sourceDomain(links ...string) string {
+	for _, link := range links {
+		link = strings.TrimSpace(link)
+		if link == "" {
+			continue
+		}
+		parsed, err := url.Parse(link)
+		if err == nil && parsed.Host == "" && parsed.Scheme == "" && !strings.HasPrefix(link, "/") {
+			parsed, err = url.Parse("http://" + link)
+		}
+		if err != nil || parsed.Hostname() == "" {
+			continue
+		}
+		return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	}
+	return ""
 }
 
 // getStringValue safely extracts a string value from a map.
@@ -227,8 +322,79 @@ func extractStringList(item map[string]interface{}, key, separator string) strin
 	}
 }
 
-// extractEnclosures extracts enclosure information from the item.
-func extractEnclosures(item map[string]interface{}) string {
+// Default formatting of the {{.Authors}} variable
+const (
+	defaultAuthorsSeparator = "; "
+	defaultAuthorFormat     = "{{.Name}} &lt;{{.Email}}&gt;"
+)
+
+// formatAuthors formats every author of an item with format, a template with {{.Name}}
+// and {{.Email}}, to be joined with separator. With the default format, authors without
+// an email are shown by name only. Every value is cleaned with sanitize.
+func formatAuthors(item map[string]interface{}, format, separator string, sanitize func(string) string) templateAuthors {
+	if separator == "" {
+		separator = defaultAuthorsSeparator
+	}
+	separator = sanitize(separator)
+
+	if _, ok := item["Authors"].([]interface{}); !ok {
+		// Authors given as text are shown as they are
+		text := sanitize(extractStringList(item, "Authors", separator))
+		if text == "" {
+			return nil
+		}
+		return templateAuthors{{Name: text, formatted: text, separator: separator}}
+	}
+
+	var authors templateAuthors
+	for _, author := range itemAuthors(item) {
+		// The format is HTML, so the name and email are escaped before they are put in it
+		formatted := html.EscapeString(author.Name)
+		authorFormat := format
+		if authorFormat == "" && author.Email != "" {
+			authorFormat = defaultAuthorFormat
+		}
+		if authorFormat != "" {
+			escaped := templateAuthor{Name: html.EscapeString(author.Name), Email: html.EscapeString(author.Email)}
+			if text, err := executeTemplate("author_format", authorFormat, nil, escaped); err == nil {
+				formatted = text
+			}
+		}
+
+		authors = append(authors, templateAuthor{
+			Name:      sanitize(author.Name),
+			Email:     sanitize(author.Email),
+			formatted: sanitize(formatted),
+			separator: separator,
+		})
+	}
+	return authors
+}
+
+// itemAuthors returns the name and email of every author of an item
+func itemAuthors(item map[string]interface{}) []templateAuthor {
+	authorsSlice, ok := item["Authors"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var authors []templateAuthor
+	for _, author := range authorsSlice {
+		switch v := author.(type) {
+		case map[string]interface{}:
+			authors = append(authors, templateAuthor{Name: getStringValue(v, "Name"), Email: getStringValue(v, "Email")})
+		case string:
+			authors = append(authors, templateAuthor{Name: v})
+		default:
+			authors = append(authors, templateAuthor{Name: fmt.Sprintf("%v", v)})
+		}
+	}
+	return authors
+}
+
+// extractEnclosures extracts enclosure information from the item. Sizes are shown in
+// readable units unless raw is set, and are left out when the feed doesn't give one.
+func extractEnclosures(item map[string]interface{}, raw bool) string {
 	enclosuresInterface := item["Enclosures"]
 	if enclosuresInterface == nil {
 		return ""
@@ -249,10 +415,8 @@ func extractEnclosures(item map[string]interface{}) string {
 			if typ, ok := enclosureMap["Type"].(string); ok {
 				enclosureParts = append(enclosureParts, typ)
 			}
-			if length, ok := enclosureMap["Length"].(string); ok {
+			if length := enclosureLength(enclosureMap["Length"], raw); length != "" {
 				enclosureParts = append(enclosureParts, length)
-			} else if length, ok := enclosureMap["Length"].(float64); ok {
-				enclosureParts = append(enclosureParts, fmt.Sprintf("%.0f", length))
 			}
 			if len(enclosureParts) > 0 {
 				enclosures = append(enclosures, strings.Join(enclosureParts, " | "))
@@ -262,6 +426,55 @@ func extractEnclosures(item map[string]interface{}) string {
 	return strings.Join(enclosures, "; ")
 }
 
+// enclosureLength formats an enclosure size given in bytes, either as a string from the
+// feed or as a number once the item went through JSON. Sizes that aren't numbers are kept
+// as they are; missing and zero sizes, which feeds use for unknown, return "".
+func enclosureLength(value interface{}, raw bool) string {
+	var bytes float64
+	switch length := value.(type) {
+	case string:
+		length = strings.TrimSpace(length)
+		parsed, err := strconv.ParseFloat(length, 64)
+		if err != nil {
+			return length
+		}
+		bytes = parsed
+	case float64:
+		bytes = length
+	case int64:
+		bytes = float64(length)
+	case int:
+		bytes = float64(length)
+	default:
+		return ""
+	}
+
+	if bytes <= 0 {
+		return ""
+	}
+	if raw {
+		return strconv.FormatFloat(math.Round(bytes), 'f', 0, 64)
+	}
+	return formatByteSize(bytes)
+}
+
+// formatByteSize formats a size in bytes with binary units, e.g. "50 MB" or "1.5 KB".
+// Sizes below 10 units keep one decimal.
+func formatByteSize(bytes float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+
+	if unit == 0 || bytes >= 10 {
+		return fmt.Sprintf("%.0f %s", math.Round(bytes), units[unit])
+	}
+	formatted := strconv.FormatFloat(math.Round(bytes*10)/10, 'f', -1, 64)
+	return formatted + " " + units[unit]
+}
+
 // extractImageInfo extracts image information from the item.
 func extractImageInfo(item map[string]interface{}) (url, title string) {
 	imageInterface := item["Image"]
@@ -320,11 +533,75 @@ func extractCustomFields(item map[string]interface{}) string {
 	return strings.Join(customs, "; ")
 }
 
-// ReplaceTemplateVars replaces template variables with actual values.
-func ReplaceTemplateVars(template string, vars map[string]string) string {
-	result := template
-	for key, value := range vars {
-		result = strings.ReplaceAll(result, "{{"+key+"}}", value)
+// RenderAlertMessage renders the alert template for a failing feed. An alert template
+// that fails to render is replaced by the default one.
+func RenderAlertMessage(template string, feed Feed, failCount int, fetchErr error) string {
+	if template == "" {
+		template = defaultAlertTemplate
+	}
+
+	data := alertTemplateData{
+		FeedName:  html.EscapeString(feed.DisplayName()),
+		FeedURL:   html.EscapeString(feed.FeedUrl),
+		FailCount: failCount,
+	}
+	if fetchErr != nil {
+		data.Error = html.EscapeString(fetchErr.Error())
+	}
+
+	message, err := executeTemplate("alert_template", template, nil, data)
+	if err != nil {
+		log.Printf("Error rendering alert template, using the default one: %v", err)
+		message, _ = executeTemplate("alert_template", defaultAlertTemplate, nil, data)
+	}
+	return message
+}
+
+// extractContentImage returns the source of the first <img> in the item's content, or its
+// description when the content has none. Relative sources are resolved against the item link.
+func extractContentImage(item map[string]interface{}) string {
+	for _, key := range []string{"Content", "Description"} {
+		src := firstImageSource(getStringValue(item, key))
+		if src == "" {
+			continue
+		}
+
+		base, err := url.Parse(getStringValue(item, "Link"))
+		if err != nil {
+			return src
+		}
+		ref, err := url.Parse(src)
+		if err != nil {
+			return src
+		}
+		return base.ResolveReference(ref).String()
+	}
+	return ""
+}
+
+// firstImageSource returns the src attribute of the first <img> tag in an HTML fragment.
+func firstImageSource(htmlText string) string {
+	if !strings.Contains(htmlText, "<img") {
+		return ""
+	}
+
+	tokenizer := xhtml.NewTokenizer(strings.NewReader(htmlText))
+	for {
+		switch tokenizer.Next() {
+		case xhtml.ErrorToken:
+			return ""
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			if string(name) != "img" {
+				continue
+			}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = tokenizer.TagAttr()
+				if string(key) == "src" && strings.TrimSpace(string(val)) != "" {
+					return strings.TrimSpace(string(val))
+				}
+			}
+		}
 	}
-	return result
 }
@@ -0,0 +1,309 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestTruncateTextCountsCharacters(t *testing.T) {
+	// 4096 Cyrillic letters are 8192 bytes but within the message limit
+	text := strings.Repeat("ж", maxMessageLength)
+	if got := truncateText(text, parseModeHTML, maxMessageLength); got != text {
+		t.Fatal("text within the character limit was truncated")
+	}
+
+	got := truncateText(text+"ж", parseModeHTML, maxMessageLength)
+	if !utf8.ValidString(got) {
+		t.Fatal("truncation split a character")
+	}
+	if n := utf8.RuneCountInString(got); n != maxMessageLength {
+		t.Fatalf("got %d characters, want %d", n, maxMessageLength)
+	}
+	if !strings.HasSuffix(got, ellipsis) {
+		t.Fatal("missing ellipsis")
+	}
+}
+
+func TestTruncateTextCountsUTF16Units(t *testing.T) {
+	// Emoji outside the Basic Multilingual Plane count twice
+	text := strings.Repeat("😀", 10)
+	got := truncateText(text, "", 10)
+	if n := utf16Length([]rune(got)); n > 10 {
+		t.Fatalf("got %d UTF-16 units, limit is 10", n)
+	}
+	if got != strings.Repeat("😀", 4)+ellipsis {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestTruncateTextKeepsHTMLValid(t *testing.T) {
+	text := "<b>Überschrift</b> " + strings.Repeat("<i>grüße</i> ", 300)
+	got := truncateText(text, parseModeHTML, maxCaptionLength)
+
+	if n := utf16Length([]rune(htmlToPlain(got))); n > maxCaptionLength {
+		t.Fatalf("visible text is %d characters, limit is %d", n, maxCaptionLength)
+	}
+	if strings.Count(got, "<i>") != strings.Count(got, "</i>") {
+		t.Fatalf("unbalanced tags in %q", got[len(got)-40:])
+	}
+	if strings.HasSuffix(strings.TrimSuffix(got, "</i>"), "<") {
+		t.Fatal("a tag was cut")
+	}
+	// Markup doesn't count towards the limit
+	if len([]rune(got)) <= maxCaptionLength {
+		t.Fatal("tags were counted as visible text")
+	}
+}
+
+func TestTruncateTextPrefersSentenceEnd(t *testing.T) {
+	text := strings.Repeat("a", 60) + ". " + strings.Repeat("b", 60)
+	if got := truncateText(text, "", 100); got != strings.Repeat("a", 60)+"."+ellipsis {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestTruncateTextLeavesMarkdownV2(t *testing.T) {
+	text := strings.Repeat("\\.", maxMessageLength)
+	if got := truncateText(text, parseModeMarkdownV2, maxMessageLength); got != text {
+		t.Fatal("MarkdownV2 text was truncated")
+	}
+}
+
+func authorsItem() map[string]interface{} {
+	return map[string]interface{}{
+		"Title": "Post",
+		"Authors": []interface{}{
+			map[string]interface{}{"Name": "Ana", "Email": "ana@example.com"},
+			map[string]interface{}{"Name": "Bo"},
+		},
+	}
+}
+
+func TestAuthorsDefaultFormatting(t *testing.T) {
+	message, err := ProcessFeedItemForTelegram(authorsItem(), map[string]interface{}{}, "{{.Authors}}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "Ana &lt;ana@example.com&gt;; Bo"; message != want {
+		t.Fatalf("got %q, want %q", message, want)
+	}
+}
+
+func TestAuthorsCustomFormatAndSeparator(t *testing.T) {
+	feed := map[string]interface{}{"AuthorFormat": "{{.Name}}", "AuthorsSeparator": ", "}
+	message, err := ProcessFeedItemForTelegram(authorsItem(), feed, "by {{.Authors}}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "by Ana, Bo"; message != want {
+		t.Fatalf("got %q, want %q", message, want)
+	}
+}
+
+func TestAuthorsRange(t *testing.T) {
+	message, err := ProcessFeedItemForTelegram(authorsItem(), map[string]interface{}{}, "{{range .Authors}}[{{.Name}}|{{.Email}}]{{end}}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "[Ana|ana@example.com][Bo|]"; message != want {
+		t.Fatalf("got %q, want %q", message, want)
+	}
+}
+
+func TestAuthorsGivenAsText(t *testing.T) {
+	item := map[string]interface{}{"Authors": "Ana and Bo"}
+	message, err := ProcessFeedItemForTelegram(item, map[string]interface{}{}, "{{.Authors}}|{{.Authors | default \"none\"}}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "Ana and Bo|Ana and Bo"; message != want {
+		t.Fatalf("got %q, want %q", message, want)
+	}
+
+	message, _ = ProcessFeedItemForTelegram(map[string]interface{}{}, map[string]interface{}{}, "{{.Authors | default \"none\"}}")
+	if message != "none" {
+		t.Fatalf("got %q for an item without authors", message)
+	}
+}
+
+func TestRenderAlertMessage(t *testing.T) {
+	feed := Feed{Name: "Tech <News>", FeedUrl: "https://example.com/feed.xml"}
+	fetchErr := fmt.Errorf("unexpected HTTP status 503 Service Unavailable")
+
+	message := RenderAlertMessage("{{.FeedName}} ({{.FeedURL}}) failed {{.FailCount}}×: {{.Error}}", feed, 3, fetchErr)
+	want := "Tech &lt;News&gt; (https://example.com/feed.xml) failed 3×: unexpected HTTP status 503 Service Unavailable"
+	if message != want {
+		t.Fatalf("got %q, want %q", message, want)
+	}
+
+	if message := RenderAlertMessage("", feed, 5, fetchErr); message != "⚠️ Tech &lt;News&gt; failed 5 times in a row: unexpected HTTP status 503 Service Unavailable" {
+		t.Fatalf("default template rendered %q", message)
+	}
+	if message := RenderAlertMessage("{{.Missing}}", feed, 5, fetchErr); !strings.HasPrefix(message, "⚠️ Tech") {
+		t.Fatalf("broken template rendered %q, want the default", message)
+	}
+}
+
+func TestTrustSourceKeepsTelegramHTML(t *testing.T) {
+	item := buildItemMap(&gofeed.Item{
+		Title:       "Post",
+		Description: `Read <a href="https://example.com/post">the post</a> with <b><i>nested</i></b> <span class="tg-spoiler">spoilers</span>, <span class="x">plain <span>spans</span></span> and <code class="language-go">code</code><script>alert(1)</script>`,
+	}, &gofeed.Feed{})
+
+	trusted := RenderFeedItem(Feed{TelegramTemplate: "{{.Description}}", TrustSource: true}, item)
+	want := `Read <a href="https://example.com/post">the post</a> with <b><i>nested</i></b> <span class="tg-spoiler">spoilers</span>, plain spans and <code class="language-go">code</code>`
+	if trusted != want {
+		t.Fatalf("trusted feed:\ngot  %q\nwant %q", trusted, want)
+	}
+
+	// Untrusted feeds keep the baseline sanitizer: plain links and basic formatting, nested
+	// or not, still pass, while Telegram-specific markup and attributes are stripped
+	untrusted := RenderFeedItem(Feed{TelegramTemplate: "{{.Description}}"}, item)
+	want = `Read <a href="https://example.com/post">the post</a> with <b><i>nested</i></b> spoilers, plain spans and <code>code</code>`
+	if untrusted != want {
+		t.Fatalf("untrusted feed:\ngot  %q\nwant %q", untrusted, want)
+	}
+
+	// Neither lets a script link through
+	item = buildItemMap(&gofeed.Item{Title: "Post", Description: `<a href="javascript:alert(1)">click</a>`}, &gofeed.Feed{})
+	for _, trust := range []bool{false, true} {
+		if message := RenderFeedItem(Feed{TelegramTemplate: "{{.Description}}", TrustSource: trust}, item); strings.Contains(message, "javascript") {
+			t.Errorf("trust_source %v kept the script link: %q", trust, message)
+		}
+	}
+}
+
+func TestExtractContentImage(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		content     string
+		description string
+		want        string
+	}{
+		{"one image", `<p><img src="https://cdn.example.com/a.jpg"></p>`, "", "https://cdn.example.com/a.jpg"},
+		{"relative source", `<img src="/images/a.jpg" alt="">`, "", "https://example.com/images/a.jpg"},
+		{"first of several", `<img src="first.png"><p>text</p><img src="second.png">`, "", "https://example.com/posts/first.png"},
+		{"image without source skipped", `<img alt="x"><img src="b.png"/>`, "", "https://example.com/posts/b.png"},
+		{"description when content has none", "<p>no images</p>", `<img src="d.gif">`, "https://example.com/posts/d.gif"},
+		{"no images", "<p>text</p>", "", ""},
+	} {
+		item := map[string]interface{}{
+			"Link":        "https://example.com/posts/1",
+			"Content":     tc.content,
+			"Description": tc.description,
+		}
+		if got := extractContentImage(item); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestContentImageTemplateVariable(t *testing.T) {
+	item := buildItemMap(&gofeed.Item{
+		Title:   "Post",
+		Link:    "https://example.com/posts/1",
+		Content: `<img src="a.jpg"><img src="b.jpg">`,
+	}, &gofeed.Feed{})
+
+	message := RenderFeedItem(Feed{TelegramTemplate: "{{.ContentImage}}"}, item)
+	if message != "https://example.com/posts/a.jpg" {
+		t.Fatalf("got %q", message)
+	}
+}
+
+func TestEnclosureLength(t *testing.T) {
+	for _, tc := range []struct {
+		value interface{}
+		want  string
+	}{
+		{"52428800", "50 MB"},
+		{" 1536 ", "1.5 KB"},
+		{float64(512), "512 B"},
+		{float64(1024), "1 KB"},
+		{int64(3 << 30), "3 GB"},
+		{12345678, "12 MB"},
+		{"unknown", "unknown"},
+		{"0", ""},
+		{nil, ""},
+	} {
+		if got := enclosureLength(tc.value, false); got != tc.want {
+			t.Errorf("%#v: got %q, want %q", tc.value, got, tc.want)
+		}
+	}
+
+	if got := enclosureLength(float64(52428800), true); got != "52428800" {
+		t.Errorf("raw size: got %q", got)
+	}
+	if got := enclosureLength(nil, true); got != "" {
+		t.Errorf("raw missing size: got %q", got)
+	}
+}
+
+func TestEnclosuresTemplateVariable(t *testing.T) {
+	item := buildItemMap(&gofeed.Item{
+		Title: "Episode",
+		Enclosures: []*gofeed.Enclosure{
+			{URL: "https://example.com/1.mp3", Type: "audio/mpeg", Length: "52428800"},
+			{URL: "https://example.com/1.jpg", Type: "image/jpeg"},
+		},
+	}, &gofeed.Feed{})
+
+	message := RenderFeedItem(Feed{TelegramTemplate: "{{.Enclosures}}"}, item)
+	if want := "https://example.com/1.mp3 | audio/mpeg | 50 MB; https://example.com/1.jpg | image/jpeg"; message != want {
+		t.Fatalf("got %q, want %q", message, want)
+	}
+	message = RenderFeedItem(Feed{TelegramTemplate: "{{.Enclosures}}", RawEnclosureSizes: true}, item)
+	if want := "https://example.com/1.mp3 | audio/mpeg | 52428800; https://example.com/1.jpg | image/jpeg"; message != want {
+		t.Fatalf("got %q, want %q", message, want)
+	}
+}
+
+func TestSourceDomain(t *testing.T) {
+	for _, tc := range []struct {
+		links []string
+		want  string
+	}{
+		{[]string{"https://example.com/post"}, "example.com"},
+		{[]string{"https://www.example.com/post"}, "example.com"},
+		{[]string{"https://blog.news.example.co.uk/2024/01/post"}, "blog.news.example.co.uk"},
+		{[]string{"http://Example.COM:8080/post?id=1"}, "example.com"},
+		{[]string{"https://www.blog.example.com:8443/post"}, "blog.example.com"},
+		{[]string{"http://[2001:db8::1]:8080/post"}, "2001:db8::1"},
+		{[]string{"example.com/post"}, "example.com"},
+		{[]string{"/relative/post", "https://feed.example.org/"}, "feed.example.org"},
+		{[]string{"mailto:editor@example.com", "https://example.org"}, "example.org"},
+		{[]string{"", "  "}, ""},
+		{[]string{"/relative/post"}, ""},
+		{nil, ""},
+	} {
+		if got := sourceDomain(tc.links...); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.links, got, tc.want)
+		}
+	}
+}
+
+func TestSourceDomainInMessages(t *testing.T) {
+	feedData := &gofeed.Feed{Title: "News", Link: "https://news.example.org/"}
+	withLink := buildItemMap(&gofeed.Item{Title: "Post", Link: "https://www.blog.example.com:8443/post"}, feedData)
+	withoutLink := buildItemMap(&gofeed.Item{Title: "Post"}, feedData)
+
+	feed := Feed{TelegramTemplate: "{{.Title}} ({{.SourceDomain}})"}
+	if got := RenderFeedItem(feed, withLink); got != "Post (blog.example.com)" {
+		t.Fatalf("got %q", got)
+	}
+	if got := RenderFeedItem(feed, withoutLink); got != "Post (news.example.org)" {
+		t.Fatalf("got %q for an item without a link", got)
+	}
+
+	feed = Feed{TelegramTemplate: "{{.Title}}", AppendSourceDomain: true}
+	if got := RenderFeedItem(feed, withLink); got != "Post\nblog.example.com" {
+		t.Fatalf("got %q with append_source_domain", got)
+	}
+	if got := RenderFeedItem(feed, buildItemMap(&gofeed.Item{Title: "Post"}, &gofeed.Feed{})); got != "Post" {
+		t.Fatalf("got %q without any link", got)
+	}
+}
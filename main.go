@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,8 +13,26 @@ import (
 )
 
 func main() {
+	configPath := flag.String("config", "config.yaml", "Path or http(s) URL of the configuration file")
+	check := flag.Bool("check", false, "Check the configuration file and exit, with a non-zero status if it has problems")
+	sendTest := flag.Bool("send-test", false, "Send a test message to the test chat and exit, with a non-zero status if it fails")
+	flag.Parse()
+
+	if *check {
+		problems := internal.CheckConfig(*configPath)
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+		fmt.Printf("Configuration %s is valid\n", *configPath)
+		return
+	}
+
 	// Initialize config manager
 	configManager := internal.NewConfigManager()
+	configManager.Path = *configPath
 
 	// Load configuration
 	err := configManager.LoadConfig()
@@ -21,8 +40,18 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	if *sendTest {
+		err = internal.NewTelegramService(configManager).SendTestMessage()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to send test message: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Test message sent")
+		return
+	}
+
 	// Initialize database
-	dbManager, err := internal.NewDBManager(configManager.Config.Database)
+	dbManager, err := internal.NewDBManager(configManager.Get().Database)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
@@ -34,9 +63,17 @@ func main() {
 	// Start the scheduler
 	scheduler.Start()
 
+	// Pin a "started" message in the chats of feeds that ask for one
+	scheduler.PinStartMessages()
+
 	// Start the cleanup routine
 	scheduler.StartCleanupRoutine()
 
+	// Start the watchdog for stuck fetches
+	scheduler.StartWatchdog()
+
+	scheduler.PostStatusEvent(fmt.Sprintf("Bot started with %d feeds", len(configManager.Get().Feeds)))
+
 	// Initialize handlers
 	handlers := internal.NewHandlers(configManager, scheduler)
 
@@ -45,8 +82,8 @@ func main() {
 
 	// Extract port from server config (format: ":8080")
 	port := ":8080" // default port
-	if configManager.Config.Server != "" {
-		port = configManager.Config.Server
+	if configManager.Get().Server != "" {
+		port = configManager.Get().Server
 		if port[0] != ':' {
 			port = ":" + port
 		}
@@ -66,6 +103,7 @@ func main() {
 	// Wait for interrupt signal
 	<-stop
 	log.Println("Shutting down gracefully...")
+	scheduler.PostStatusEvent("Bot shutting down")
 
 	// Stop the scheduler
 	scheduler.Stop()